@@ -2,17 +2,56 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"github.com/IBM/sarama"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	accessAPI "github.com/nogavadu/auth-service/internal/api/grpc/access"
 	authAPI "github.com/nogavadu/auth-service/internal/api/grpc/auth"
+	extAuthzAPI "github.com/nogavadu/auth-service/internal/api/grpc/extauthz"
+	serviceAccountAPI "github.com/nogavadu/auth-service/internal/api/grpc/serviceaccount"
 	userAPI "github.com/nogavadu/auth-service/internal/api/grpc/user"
+	avatarAPI "github.com/nogavadu/auth-service/internal/api/http/avatar"
+	connectAPI "github.com/nogavadu/auth-service/internal/api/http/connectrpc"
+	forwardAuthAPI "github.com/nogavadu/auth-service/internal/api/http/forwardauth"
+	gatewayAPI "github.com/nogavadu/auth-service/internal/api/http/gateway"
+	jwksAPI "github.com/nogavadu/auth-service/internal/api/http/jwks"
+	oidcAPI "github.com/nogavadu/auth-service/internal/api/http/oidc"
+	openAPI "github.com/nogavadu/auth-service/internal/api/http/openapi"
+	samlAPI "github.com/nogavadu/auth-service/internal/api/http/saml"
+	scimAPI "github.com/nogavadu/auth-service/internal/api/http/scim"
+	"github.com/nogavadu/auth-service/internal/config"
 	envConfig "github.com/nogavadu/auth-service/internal/config/env"
+	apiKeyRepo "github.com/nogavadu/auth-service/internal/repository/apikey"
+	authCodeRepo "github.com/nogavadu/auth-service/internal/repository/authcode"
+	clientRepo "github.com/nogavadu/auth-service/internal/repository/client"
+	deviceCodeRepo "github.com/nogavadu/auth-service/internal/repository/devicecode"
+	emailChangeRepo "github.com/nogavadu/auth-service/internal/repository/emailchange"
+	identityRepo "github.com/nogavadu/auth-service/internal/repository/identity"
+	loginStateRepo "github.com/nogavadu/auth-service/internal/repository/loginstate"
+	refreshTokenRepo "github.com/nogavadu/auth-service/internal/repository/refreshtoken"
 	roleRepo "github.com/nogavadu/auth-service/internal/repository/role"
+	serviceAccountRepo "github.com/nogavadu/auth-service/internal/repository/serviceaccount"
+	suspensionRepo "github.com/nogavadu/auth-service/internal/repository/suspension"
 	userRepo "github.com/nogavadu/auth-service/internal/repository/user"
+	"github.com/nogavadu/auth-service/internal/service"
 	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	apiKeyService "github.com/nogavadu/auth-service/internal/service/apikey"
 	authService "github.com/nogavadu/auth-service/internal/service/auth"
+	ldapAuthenticator "github.com/nogavadu/auth-service/internal/service/authenticator/ldap"
+	passwordAuthenticator "github.com/nogavadu/auth-service/internal/service/authenticator/password"
+	federationService "github.com/nogavadu/auth-service/internal/service/federation"
+	identityService "github.com/nogavadu/auth-service/internal/service/identity"
+	oidcService "github.com/nogavadu/auth-service/internal/service/oidc"
+	samlService "github.com/nogavadu/auth-service/internal/service/saml"
+	scimService "github.com/nogavadu/auth-service/internal/service/scim"
+	serviceAccountService "github.com/nogavadu/auth-service/internal/service/serviceaccount"
 	"github.com/nogavadu/auth-service/internal/service/user"
+	"github.com/nogavadu/auth-service/internal/storage"
+	localStorage "github.com/nogavadu/auth-service/internal/storage/local"
+	s3Storage "github.com/nogavadu/auth-service/internal/storage/s3"
 	descAccess "github.com/nogavadu/auth-service/pkg/access_v1"
 	descAuth "github.com/nogavadu/auth-service/pkg/auth_v1"
+	descServiceAccount "github.com/nogavadu/auth-service/pkg/service_account_v1"
 	descUser "github.com/nogavadu/auth-service/pkg/user_v1"
 	"github.com/nogavadu/platform_common/pkg/db/pg"
 	"github.com/nogavadu/platform_common/pkg/db/transaction"
@@ -20,8 +59,10 @@ import (
 	"google.golang.org/grpc/reflection"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 )
 
 func main() {
@@ -45,6 +86,148 @@ func main() {
 		os.Exit(1)
 	}
 
+	httpServerConfig, err := envConfig.NewHTTPServerConfig()
+	if err != nil {
+		log.Error("failed to load HTTP server config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	corsConfig, err := envConfig.NewCORSConfig()
+	if err != nil {
+		log.Error("failed to load CORS config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	federationConfig, err := envConfig.NewFederationConfig()
+	if err != nil {
+		log.Error("failed to load federation config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	federationProviders, err := federationService.LoadProviders(federationConfig.ProvidersFile())
+	if err != nil {
+		log.Error("failed to load identity providers", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	samlConfig, err := envConfig.NewSAMLConfig()
+	if err != nil {
+		log.Error("failed to load SAML config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	samlProviders, err := samlService.LoadProviders(samlConfig.ProvidersFile())
+	if err != nil {
+		log.Error("failed to load SAML providers", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	scimConfig, err := envConfig.NewSCIMConfig()
+	if err != nil {
+		log.Error("failed to load SCIM config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	scimClients, err := scimService.LoadClients(scimConfig.ClientsFile())
+	if err != nil {
+		log.Error("failed to load SCIM clients", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	oidcConfig, err := envConfig.NewOIDCConfig()
+	if err != nil {
+		log.Error("failed to load OIDC config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	authenticatorConfig, err := envConfig.NewAuthenticatorConfig()
+	if err != nil {
+		log.Error("failed to load authenticator config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	userLifecycleConfig, err := envConfig.NewUserLifecycleConfig()
+	if err != nil {
+		log.Error("failed to load user lifecycle config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	userAttributesConfig, err := envConfig.NewUserAttributesConfig()
+	if err != nil {
+		log.Error("failed to load user attributes config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	attributeSchema, err := user.LoadAttributeSchema(userAttributesConfig.SchemaFile())
+	if err != nil {
+		log.Error("failed to load user attribute schema", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	kafkaConfig, err := envConfig.NewKafkaConfig()
+	if err != nil {
+		log.Error("failed to load kafka config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	emailChangeConfig, err := envConfig.NewEmailChangeConfig()
+	if err != nil {
+		log.Error("failed to load email change config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	avatarConfig, err := envConfig.NewAvatarConfig()
+	if err != nil {
+		log.Error("failed to load avatar config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	var avatarStorage storage.BlobStorage
+	switch avatarConfig.Storage() {
+	case config.AvatarStorageS3:
+		s3Config, err := envConfig.NewS3Config()
+		if err != nil {
+			log.Error("failed to load S3 config", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+
+		avatarStorage, err = s3Storage.New(
+			s3Config.Endpoint(),
+			s3Config.Region(),
+			s3Config.Bucket(),
+			s3Config.AccessKeyId(),
+			s3Config.SecretAccessKey(),
+			avatarConfig.PublicURL(),
+		)
+		if err != nil {
+			log.Error("failed to create S3 avatar storage", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	default:
+		publicURL := avatarConfig.PublicURL()
+		if publicURL == "" {
+			publicURL = strings.TrimSuffix(avatarAPI.Path, "/")
+		}
+
+		avatarStorage, err = localStorage.New(avatarConfig.LocalDir(), publicURL)
+		if err != nil {
+			log.Error("failed to create local avatar storage", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	extAuthzConfig, err := envConfig.NewExtAuthzConfig()
+	if err != nil {
+		log.Error("failed to load ext_authz config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	extAuthzRoutes, err := extAuthzAPI.LoadRoutes(extAuthzConfig.RoutesFile())
+	if err != nil {
+		log.Error("failed to load ext_authz routes", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
 	ctx := context.Background()
 
 	dbc, err := pg.New(ctx, pgConfig.DSN())
@@ -61,6 +244,13 @@ func main() {
 
 	txManager := transaction.NewTransactionManager(dbc.DB())
 
+	kafkaProducer, err := sarama.NewSyncProducer(kafkaConfig.Brokers(), nil)
+	if err != nil {
+		log.Error("failed to create kafka producer", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer kafkaProducer.Close()
+
 	lis, err := net.Listen("tcp", grpcServerConfig.Address())
 	if err != nil {
 		os.Exit(1)
@@ -69,43 +259,204 @@ func main() {
 	s := grpc.NewServer()
 	reflection.Register(s)
 
-	descAuth.RegisterAuthV1Server(
-		s, authAPI.New(
-			authService.New(
+	authenticators := make([]service.Authenticator, 0, len(authenticatorConfig.Order()))
+	for _, name := range authenticatorConfig.Order() {
+		switch name {
+		case passwordAuthenticator.Name:
+			authenticators = append(authenticators, passwordAuthenticator.New(userRepo.New(dbc)))
+		case ldapAuthenticator.Name:
+			ldapConfig, err := envConfig.NewLDAPConfig()
+			if err != nil {
+				log.Error("failed to load LDAP config", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+
+			authenticators = append(authenticators, ldapAuthenticator.New(
 				log,
-				jwtConfig.RefreshTokenSecret(),
-				jwtConfig.RefreshTokenExp(),
-				jwtConfig.AccessTokenSecret(),
-				jwtConfig.AccessTokenExp(),
+				ldapConfig,
 				userRepo.New(dbc),
 				roleRepo.New(dbc),
+				identityRepo.New(dbc),
 				txManager,
-			),
-		),
+			))
+		default:
+			log.Error("unknown authenticator", slog.String("name", name))
+			os.Exit(1)
+		}
+	}
+
+	authServ := authService.New(
+		log,
+		jwtConfig.RefreshTokenSecret(),
+		jwtConfig.RefreshTokenExp(),
+		jwtConfig.AccessTokenSecret(),
+		jwtConfig.AccessTokenExp(),
+		jwtConfig.AccessTokenKey(),
+		attributeSchema.ClaimKeys(),
+		authenticators,
+		userRepo.New(dbc),
+		roleRepo.New(dbc),
+		suspensionRepo.New(dbc),
+		txManager,
+		kafkaProducer,
 	)
-	descAccess.RegisterAccessV1Server(
-		s, accessAPI.New(
-			accessService.New(
-				log,
-				jwtConfig.RefreshTokenSecret(),
-				jwtConfig.RefreshTokenExp(),
-				jwtConfig.AccessTokenSecret(),
-				jwtConfig.AccessTokenExp(),
-				userRepo.New(dbc),
-				roleRepo.New(dbc),
-			),
+	serviceAccountServ := serviceAccountService.New(
+		log,
+		jwtConfig.AccessTokenSecret(),
+		jwtConfig.AccessTokenExp(),
+		jwtConfig.AccessTokenKey(),
+		serviceAccountRepo.New(dbc),
+		roleRepo.New(dbc),
+		txManager,
+	)
+	accessServ := accessService.New(
+		log,
+		jwtConfig.RefreshTokenSecret(),
+		jwtConfig.RefreshTokenExp(),
+		jwtConfig.AccessTokenSecret(),
+		jwtConfig.AccessTokenExp(),
+		jwtConfig.AccessTokenKey(),
+		userRepo.New(dbc),
+		roleRepo.New(dbc),
+		serviceAccountRepo.New(dbc),
+		apiKeyRepo.New(dbc),
+		suspensionRepo.New(dbc),
+	)
+	federationServ := federationService.New(
+		log,
+		federationProviders,
+		authServ,
+		userRepo.New(dbc),
+		identityRepo.New(dbc),
+		loginStateRepo.New(dbc),
+		txManager,
+	)
+	samlServ := samlService.New(
+		log,
+		samlProviders,
+		samlConfig.Certificate(),
+		samlConfig.Key(),
+		authServ,
+		userRepo.New(dbc),
+		roleRepo.New(dbc),
+		identityRepo.New(dbc),
+		loginStateRepo.New(dbc),
+		txManager,
+	)
+	accessImpl := accessAPI.New(accessServ)
+	authImpl := authAPI.New(
+		authServ,
+		serviceAccountServ,
+		apiKeyService.New(log, apiKeyRepo.New(dbc), roleRepo.New(dbc)),
+		federationServ,
+		samlServ,
+		identityService.New(
+			log,
+			federationServ,
+			userRepo.New(dbc),
+			identityRepo.New(dbc),
+			txManager,
 		),
+		accessServ,
 	)
-	descUser.RegisterUserV1Server(
-		s, userAPI.New(
-			user.New(
+	userServ := user.New(
+		log,
+		userLifecycleConfig.PurgeGracePeriod(),
+		attributeSchema,
+		emailChangeConfig.Topic(),
+		emailChangeConfig.TokenTTL(),
+		avatarConfig.MaxSize(),
+		userRepo.New(dbc),
+		roleRepo.New(dbc),
+		identityRepo.New(dbc),
+		suspensionRepo.New(dbc),
+		emailChangeRepo.New(dbc),
+		txManager,
+		kafkaProducer,
+		avatarStorage,
+	)
+	userImpl := userAPI.New(userServ, accessServ)
+	serviceAccountImpl := serviceAccountAPI.New(serviceAccountServ, accessServ)
+
+	descAuth.RegisterAuthV1Server(s, authImpl)
+	descAccess.RegisterAccessV1Server(s, accessImpl)
+	descUser.RegisterUserV1Server(s, userImpl)
+	descServiceAccount.RegisterServiceAccountV1Server(s, serviceAccountImpl)
+	authv3.RegisterAuthorizationServer(s, extAuthzAPI.New(accessServ, extAuthzRoutes))
+
+	var publicKeys []*rsa.PublicKey
+	if key := jwtConfig.AccessTokenKey(); key != nil {
+		publicKeys = append(publicKeys, &key.PublicKey)
+	}
+
+	gateway, err := gatewayAPI.New(ctx, grpcServerConfig.Address())
+	if err != nil {
+		log.Error("failed to create HTTP gateway", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", gateway)
+	mux.Handle(jwksAPI.Path, jwksAPI.New(publicKeys...))
+	mux.Handle(forwardAuthAPI.Path, forwardAuthAPI.New(accessServ))
+	mux.Handle(openAPI.Path, openAPI.New())
+	mux.Handle(samlAPI.Path, samlAPI.New(samlServ))
+	if avatarConfig.Storage() == config.AvatarStorageLocal {
+		mux.Handle(avatarAPI.Path, avatarAPI.New(avatarConfig.LocalDir()))
+	}
+	connectAPI.Register(mux, authImpl, accessImpl, userImpl, serviceAccountImpl)
+	if len(scimClients) > 0 {
+		scimAPI.New(
+			scimService.New(
 				log,
+				scimClients,
 				userRepo.New(dbc),
 				roleRepo.New(dbc),
 				txManager,
 			),
-		),
-	)
+		).Register(mux)
+	}
+
+	// The provider's tokens are RS256-signed, so it needs the access token key.
+	if oidcConfig.Issuer() != "" && jwtConfig.AccessTokenKey() != nil {
+		oidcServ := oidcService.New(
+			log,
+			oidcConfig.Issuer(),
+			jwtConfig.AccessTokenKey(),
+			jwtConfig.AccessTokenExp(),
+			jwtConfig.RefreshTokenExp(),
+			authServ,
+			serviceAccountServ,
+			userRepo.New(dbc),
+			clientRepo.New(dbc),
+			authCodeRepo.New(dbc),
+			deviceCodeRepo.New(dbc),
+			refreshTokenRepo.New(dbc),
+		)
+		oidcAPI.New(oidcConfig.Issuer(), oidcServ).Register(mux)
+
+		go oidcService.RunDeviceCodePurger(ctx, log, oidcServ)
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	httpServer := &http.Server{
+		Addr:      httpServerConfig.Address(),
+		Handler:   connectAPI.CORS(mux, corsConfig.AllowedOrigins()),
+		Protocols: protocols,
+	}
+
+	go user.RunPurger(ctx, log, userServ, userLifecycleConfig.PurgeInterval())
+
+	go func() {
+		log.Info("Starting HTTP Server", slog.String("port", strconv.Itoa(httpServerConfig.Port())))
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("failed to serve HTTP", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}()
 
 	log.Info("Starting gRPC Server", slog.String("port", strconv.Itoa(grpcServerConfig.Port())))
 	if err = s.Serve(lis); err != nil {
@@ -2,18 +2,28 @@ package access
 
 import (
 	"context"
+	"errors"
 	"github.com/go-playground/validator/v10"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
+	"github.com/nogavadu/auth-service/internal/domain/model"
 	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
 	accessDesc "github.com/nogavadu/auth-service/pkg/access_v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"strings"
+	"time"
 )
 
 const authPrefix = "Bearer "
 
+// levelRule keeps required levels within the INT column role levels are
+// stored in.
+const levelRule = "lte=2147483647"
+
 type Implementation struct {
 	accessDesc.UnimplementedAccessV1Server
 	serv service.AccessService
@@ -26,30 +36,149 @@ func New(accessService service.AccessService) *Implementation {
 }
 
 func (i *Implementation) Check(ctx context.Context, req *accessDesc.CheckRequest) (*emptypb.Empty, error) {
+	accessToken, err := accessTokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	requiredLvl := req.GetRequiredLvl()
+	if err = validator.New().Var(requiredLvl, "required,"+levelRule); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, err = i.serv.Check(ctx, accessToken, int(requiredLvl)); err != nil {
+		return nil, checkError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (i *Implementation) CheckV2(ctx context.Context, req *accessDesc.CheckRequest) (*accessDesc.CheckResponse, error) {
+	accessToken, err := accessTokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	requiredLvl := req.GetRequiredLvl()
+	if err = validator.New().Var(requiredLvl, levelRule); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	principal, err := i.serv.CheckV2(ctx, accessToken, int(requiredLvl))
+	if err != nil {
+		return nil, checkError(err)
+	}
+
+	permissions := make([]*accessDesc.Permission, 0, len(principal.Permissions))
+	for _, p := range principal.Permissions {
+		permissions = append(permissions, &accessDesc.Permission{
+			Permission: p.Permission,
+			Resource:   p.Resource,
+		})
+	}
+
+	return &accessDesc.CheckResponse{
+		Principal: &accessDesc.Principal{
+			UserId:      int64(principal.UserId),
+			Email:       principal.Email,
+			Roles:       []string{principal.Role},
+			RoleLevel:   uint32(principal.RoleLevel),
+			Permissions: permissions,
+			SessionId:   principal.SessionId,
+			ClientId:    principal.ClientId,
+			Scopes:      principal.Scopes,
+			ApiKeyId:    int64(principal.APIKeyId),
+			ExpiresAt:   timestampOrNil(principal.ExpiresAt),
+			AuthTime:    timestampOrNil(principal.AuthTime),
+		},
+	}, nil
+}
+
+func (i *Implementation) BatchCheck(ctx context.Context, req *accessDesc.BatchCheckRequest) (*accessDesc.BatchCheckResponse, error) {
+	accessToken, err := accessTokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*model.AccessItem, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		accessItem := &model.AccessItem{
+			Resource: item.GetResource(),
+		}
+
+		switch requirement := item.GetRequirement().(type) {
+		case *accessDesc.AccessItem_RequiredLvl:
+			lvl := int(requirement.RequiredLvl)
+			accessItem.RequiredLvl = &lvl
+		case *accessDesc.AccessItem_Permission:
+			accessItem.Permission = &requirement.Permission
+		}
+
+		items = append(items, accessItem)
+	}
+
+	decisions, err := i.serv.BatchCheck(ctx, accessToken, items)
+	if err != nil {
+		if errors.Is(err, accessService.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if errors.Is(err, accessService.ErrUserSuspended) {
+			return nil, authz.SuspendedError(err)
+		}
+
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &accessDesc.BatchCheckResponse{
+		Decisions: make([]*accessDesc.AccessDecision, 0, len(decisions)),
+	}
+	for _, decision := range decisions {
+		resp.Decisions = append(resp.Decisions, &accessDesc.AccessDecision{
+			Allowed: decision.Allowed,
+			Reason:  decision.Reason,
+		})
+	}
+
+	return resp, nil
+}
+
+func accessTokenFromContext(ctx context.Context) (string, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Error(codes.Unauthenticated, "metadata is not provided")
+		return "", status.Error(codes.Unauthenticated, "metadata is not provided")
 	}
 
 	authHeader, ok := md["authorization"]
 	if !ok || len(authHeader) == 0 {
-		return nil, status.Error(codes.Unauthenticated, "authorization header is not provided")
+		return "", status.Error(codes.Unauthenticated, "authorization header is not provided")
 	}
 
 	if !strings.HasPrefix(authHeader[0], authPrefix) {
-		return nil, status.Error(codes.Unauthenticated, "invalid authorization header format")
+		return "", status.Error(codes.Unauthenticated, "invalid authorization header format")
 	}
 
-	accessToken := strings.TrimPrefix(authHeader[0], authPrefix)
+	return strings.TrimPrefix(authHeader[0], authPrefix), nil
+}
 
-	requiredLvl := req.GetRequiredLvl()
-	if err := validator.New().Var(requiredLvl, "required"); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+// timestampOrNil leaves unknown times out of the response.
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
 	}
 
-	if err := i.serv.Check(ctx, accessToken, int(requiredLvl)); err != nil {
-		return nil, status.Error(codes.PermissionDenied, err.Error())
+	return timestamppb.New(t)
+}
+
+func checkError(err error) error {
+	if errors.Is(err, accessService.ErrInvalidToken) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if errors.Is(err, accessService.ErrInternal) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if errors.Is(err, accessService.ErrUserSuspended) {
+		return authz.SuspendedError(err)
 	}
 
-	return &emptypb.Empty{}, nil
+	return status.Error(codes.PermissionDenied, err.Error())
 }
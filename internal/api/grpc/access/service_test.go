@@ -0,0 +1,139 @@
+package access
+
+import (
+	"context"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	accessDesc "github.com/nogavadu/auth-service/pkg/access_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// accessServ knows a plain user's token, which carries no times, an API key
+// of theirs, and an admin's token.
+type accessServ struct {
+	service.AccessService
+}
+
+var (
+	expiresAt = time.Unix(1_900_000_000, 0)
+	authTime  = time.Unix(1_800_000_000, 0)
+)
+
+var principals = map[string]*model.Principal{
+	"user-token": {
+		UserId: 7,
+		Role:   "user",
+	},
+	"api-key": {
+		UserId:   7,
+		Role:     "user",
+		APIKeyId: 3,
+		Scopes:   []string{"users:read"},
+	},
+	"admin-token": {
+		UserId:      1,
+		Role:        "admin",
+		RoleLevel:   25,
+		Permissions: []*model.Permission{{Permission: "*", Resource: "*"}},
+		SessionId:   "session",
+		ExpiresAt:   expiresAt,
+		AuthTime:    authTime,
+	},
+}
+
+func (s *accessServ) Check(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	return s.CheckV2(ctx, accessToken, requiredLvl)
+}
+
+func (s *accessServ) CheckV2(_ context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	principal, ok := principals[accessToken]
+	if !ok {
+		return nil, accessService.ErrInvalidToken
+	}
+	if principal.RoleLevel < requiredLvl {
+		return nil, accessService.ErrPermissionDenied
+	}
+
+	return principal, nil
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestCheckV2(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		requiredLvl  uint32
+		wantCode     codes.Code
+		wantAPIKeyId int64
+		wantTimes    bool
+	}{
+		{name: "user without a level", token: "user-token"},
+		{name: "user below the level", token: "user-token", requiredLvl: 10, wantCode: codes.PermissionDenied},
+		{name: "api key", token: "api-key", wantAPIKeyId: 3},
+		{name: "admin", token: "admin-token", requiredLvl: 25, wantTimes: true},
+		{name: "level out of range", token: "admin-token", requiredLvl: 1 << 31, wantCode: codes.InvalidArgument},
+		{name: "unknown token", token: "bogus", wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl := New(&accessServ{})
+
+			resp, err := impl.CheckV2(withToken(tt.token), &accessDesc.CheckRequest{RequiredLvl: tt.requiredLvl})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %v, want %v", code, tt.wantCode)
+			}
+			if tt.wantCode != codes.OK {
+				return
+			}
+
+			principal := resp.GetPrincipal()
+			if principal.GetApiKeyId() != tt.wantAPIKeyId {
+				t.Errorf("got api key id %d, want %d", principal.GetApiKeyId(), tt.wantAPIKeyId)
+			}
+			if !tt.wantTimes {
+				if principal.GetExpiresAt() != nil || principal.GetAuthTime() != nil {
+					t.Errorf("got expires_at %v and auth_time %v, want neither", principal.GetExpiresAt(), principal.GetAuthTime())
+				}
+				return
+			}
+			if !principal.GetExpiresAt().AsTime().Equal(expiresAt) {
+				t.Errorf("got expires_at %v, want %v", principal.GetExpiresAt().AsTime(), expiresAt)
+			}
+			if !principal.GetAuthTime().AsTime().Equal(authTime) {
+				t.Errorf("got auth_time %v, want %v", principal.GetAuthTime().AsTime(), authTime)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		requiredLvl uint32
+		wantCode    codes.Code
+	}{
+		{name: "admin", token: "admin-token", requiredLvl: 25},
+		{name: "user below the level", token: "user-token", requiredLvl: 10, wantCode: codes.PermissionDenied},
+		{name: "no level", token: "admin-token", wantCode: codes.InvalidArgument},
+		{name: "level out of range", token: "admin-token", requiredLvl: 1 << 31, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl := New(&accessServ{})
+
+			_, err := impl.Check(withToken(tt.token), &accessDesc.CheckRequest{RequiredLvl: tt.requiredLvl})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %v, want %v", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -5,23 +5,51 @@ import (
 	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
 	"github.com/nogavadu/auth-service/internal/domain/model"
 	"github.com/nogavadu/auth-service/internal/service"
+	apiKeyService "github.com/nogavadu/auth-service/internal/service/apikey"
 	authService "github.com/nogavadu/auth-service/internal/service/auth"
+	federationService "github.com/nogavadu/auth-service/internal/service/federation"
+	identityService "github.com/nogavadu/auth-service/internal/service/identity"
+	samlService "github.com/nogavadu/auth-service/internal/service/saml"
+	serviceAccountService "github.com/nogavadu/auth-service/internal/service/serviceaccount"
 	"github.com/nogavadu/auth-service/internal/utils"
 	authDesc "github.com/nogavadu/auth-service/pkg/auth_v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
 )
 
 type Implementation struct {
 	authDesc.UnimplementedAuthV1Server
-	serv service.AuthService
+	serv                  service.AuthService
+	serviceAccountService service.ServiceAccountService
+	apiKeyService         service.APIKeyService
+	federationService     service.FederationService
+	samlService           service.SAMLService
+	identityService       service.IdentityService
+	accessService         service.AccessService
 }
 
-func New(authService service.AuthService) *Implementation {
+func New(
+	authService service.AuthService,
+	serviceAccountService service.ServiceAccountService,
+	apiKeyService service.APIKeyService,
+	federationService service.FederationService,
+	samlService service.SAMLService,
+	identityService service.IdentityService,
+	accessService service.AccessService,
+) *Implementation {
 	return &Implementation{
-		serv: authService,
+		serv:                  authService,
+		serviceAccountService: serviceAccountService,
+		apiKeyService:         apiKeyService,
+		federationService:     federationService,
+		samlService:           samlService,
+		identityService:       identityService,
+		accessService:         accessService,
 	}
 }
 
@@ -71,6 +99,12 @@ func (i *Implementation) Login(ctx context.Context, req *authDesc.LoginRequest)
 		if errors.Is(err, authService.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
+		if errors.Is(err, authService.ErrAccountDeactivated) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, authService.ErrUserSuspended) {
+			return nil, authz.SuspendedError(err)
+		}
 
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -91,6 +125,12 @@ func (i *Implementation) GetRefreshToken(ctx context.Context, req *authDesc.GetR
 		if errors.Is(err, authService.ErrInvalidRefreshToken) {
 			return nil, status.Error(codes.Aborted, err.Error())
 		}
+		if errors.Is(err, authService.ErrAccountDeactivated) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, authService.ErrUserSuspended) {
+			return nil, authz.SuspendedError(err)
+		}
 
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -111,6 +151,12 @@ func (i *Implementation) GetAccessToken(ctx context.Context, req *authDesc.GetAc
 		if errors.Is(err, authService.ErrInvalidRefreshToken) {
 			return nil, status.Error(codes.Aborted, err.Error())
 		}
+		if errors.Is(err, authService.ErrAccountDeactivated) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, authService.ErrUserSuspended) {
+			return nil, authz.SuspendedError(err)
+		}
 
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -135,5 +181,356 @@ func (i *Implementation) IsUser(ctx context.Context, req *authDesc.IsUserRequest
 		return nil, status.Error(codes.PermissionDenied, "not a same user")
 	}
 
-	return nil, nil
+	return &empty.Empty{}, nil
+}
+
+func (i *Implementation) ClientCredentials(ctx context.Context, req *authDesc.ClientCredentialsRequest) (*authDesc.ClientCredentialsResponse, error) {
+	clientId := req.GetClientId()
+	if err := validator.New().Var(clientId, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "client id is required")
+	}
+	clientSecret := req.GetClientSecret()
+	if err := validator.New().Var(clientSecret, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "client secret is required")
+	}
+
+	tokens, err := i.serviceAccountService.Token(ctx, clientId, clientSecret, req.GetScope())
+	if err != nil {
+		if errors.Is(err, serviceAccountService.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if errors.Is(err, serviceAccountService.ErrInvalidScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &authDesc.ClientCredentialsResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   tokens.TokenType,
+		ExpiresIn:   int64(tokens.ExpiresIn.Seconds()),
+		Scope:       tokens.Scope,
+	}, nil
+}
+
+func (i *Implementation) CreateAPIKey(ctx context.Context, req *authDesc.CreateAPIKeyRequest) (*authDesc.CreateAPIKeyResponse, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+	if principal.ClientId != "" {
+		return nil, status.Error(codes.PermissionDenied, "service accounts cannot own api keys")
+	}
+	// Keys are created by signing in, so a leaked key cannot mint others.
+	if principal.APIKeyId != 0 {
+		return nil, status.Error(codes.PermissionDenied, "api keys cannot create api keys")
+	}
+
+	name := req.GetName()
+	if err = validator.New().Var(name, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.GetExpiresAt().AsTime()
+		expiresAt = &t
+	}
+
+	apiKey, key, err := i.apiKeyService.Create(ctx, principal, name, req.GetScopes(), expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, apiKeyService.ErrInvalidExpiresAt), errors.Is(err, apiKeyService.ErrInvalidScope):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, apiKeyService.ErrScopeNotGranted), errors.Is(err, apiKeyService.ErrOutlivesCaller):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &authDesc.CreateAPIKeyResponse{
+		ApiKey: apiKeyToProto(apiKey),
+		Key:    key,
+	}, nil
+}
+
+func (i *Implementation) ListAPIKeys(ctx context.Context, _ *empty.Empty) (*authDesc.ListAPIKeysResponse, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys, err := i.apiKeyService.List(ctx, principal.UserId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &authDesc.ListAPIKeysResponse{
+		ApiKeys: make([]*authDesc.APIKey, 0, len(apiKeys)),
+	}
+	for _, apiKey := range apiKeys {
+		res.ApiKeys = append(res.ApiKeys, apiKeyToProto(apiKey))
+	}
+
+	return res, nil
+}
+
+func (i *Implementation) RevokeAPIKey(ctx context.Context, req *authDesc.RevokeAPIKeyRequest) (*empty.Empty, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.apiKeyService.Revoke(ctx, principal.UserId, int(req.GetId())); err != nil {
+		if errors.Is(err, apiKeyService.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (i *Implementation) BeginExternalLogin(ctx context.Context, req *authDesc.BeginExternalLoginRequest) (*authDesc.BeginExternalLoginResponse, error) {
+	provider := req.GetProvider()
+	if err := validator.New().Var(provider, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+
+	start, err := i.federationService.BeginExternalLogin(ctx, provider)
+	if err != nil {
+		return nil, externalLoginError(err)
+	}
+
+	return &authDesc.BeginExternalLoginResponse{
+		AuthorizationUrl: start.AuthorizationURL,
+		State:            start.State,
+	}, nil
+}
+
+func (i *Implementation) CompleteExternalLogin(ctx context.Context, req *authDesc.CompleteExternalLoginRequest) (*authDesc.CompleteExternalLoginResponse, error) {
+	provider := req.GetProvider()
+	if err := validator.New().Var(provider, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	state := req.GetState()
+	if err := validator.New().Var(state, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "state is required")
+	}
+	code := req.GetCode()
+	if err := validator.New().Var(code, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	refreshToken, err := i.federationService.CompleteExternalLogin(ctx, provider, state, code)
+	if err != nil {
+		return nil, externalLoginError(err)
+	}
+
+	return &authDesc.CompleteExternalLoginResponse{
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (i *Implementation) BeginSAMLLogin(ctx context.Context, req *authDesc.BeginSAMLLoginRequest) (*authDesc.BeginSAMLLoginResponse, error) {
+	provider := req.GetProvider()
+	if err := validator.New().Var(provider, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+
+	start, err := i.samlService.BeginLogin(ctx, provider)
+	if err != nil {
+		return nil, samlLoginError(err)
+	}
+
+	return &authDesc.BeginSAMLLoginResponse{
+		RedirectUrl: start.RedirectURL,
+		RelayState:  start.RelayState,
+	}, nil
+}
+
+func (i *Implementation) CompleteSAMLLogin(ctx context.Context, req *authDesc.CompleteSAMLLoginRequest) (*authDesc.CompleteSAMLLoginResponse, error) {
+	provider := req.GetProvider()
+	if err := validator.New().Var(provider, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	relayState := req.GetRelayState()
+	if err := validator.New().Var(relayState, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "relay_state is required")
+	}
+	samlResponse := req.GetSamlResponse()
+	if err := validator.New().Var(samlResponse, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "saml_response is required")
+	}
+
+	refreshToken, err := i.samlService.CompleteLogin(ctx, provider, relayState, samlResponse)
+	if err != nil {
+		return nil, samlLoginError(err)
+	}
+
+	return &authDesc.CompleteSAMLLoginResponse{
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (i *Implementation) ListIdentities(ctx context.Context, _ *empty.Empty) (*authDesc.ListIdentitiesResponse, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := i.identityService.List(ctx, principal.UserId)
+	if err != nil {
+		return nil, identityError(err)
+	}
+
+	res := &authDesc.ListIdentitiesResponse{
+		Identities: make([]*authDesc.Identity, 0, len(identities)),
+	}
+	for _, identity := range identities {
+		res.Identities = append(res.Identities, identityToProto(identity))
+	}
+
+	return res, nil
+}
+
+func (i *Implementation) LinkIdentity(ctx context.Context, req *authDesc.LinkIdentityRequest) (*authDesc.Identity, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+	if principal.ClientId != "" {
+		return nil, status.Error(codes.PermissionDenied, "service accounts cannot link identities")
+	}
+
+	provider := req.GetProvider()
+	if err = validator.New().Var(provider, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	state := req.GetState()
+	if err = validator.New().Var(state, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "state is required")
+	}
+	code := req.GetCode()
+	if err = validator.New().Var(code, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	identity, err := i.identityService.Link(ctx, principal, req.GetPassword(), provider, state, code)
+	if err != nil {
+		return nil, identityError(err)
+	}
+
+	return identityToProto(identity), nil
+}
+
+func (i *Implementation) UnlinkIdentity(ctx context.Context, req *authDesc.UnlinkIdentityRequest) (*empty.Empty, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := req.GetProvider()
+	if err = validator.New().Var(provider, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	subject := req.GetSubject()
+	if err = validator.New().Var(subject, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "subject is required")
+	}
+
+	if err = i.identityService.Unlink(ctx, principal.UserId, provider, subject); err != nil {
+		return nil, identityError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func identityError(err error) error {
+	switch {
+	case errors.Is(err, identityService.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, identityService.ErrReauthRequired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, identityService.ErrIdentityInUse):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, identityService.ErrLastMethod):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, identityService.ErrInternal):
+		return status.Error(codes.Internal, err.Error())
+	default:
+		return externalLoginError(err)
+	}
+}
+
+func identityToProto(identity *model.Identity) *authDesc.Identity {
+	res := &authDesc.Identity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    utils.StringPtrToProtoString(identity.Email),
+	}
+	if !identity.CreatedAt.IsZero() {
+		res.CreatedAt = timestamppb.New(identity.CreatedAt)
+	}
+
+	return res
+}
+
+func externalLoginError(err error) error {
+	switch {
+	case errors.Is(err, federationService.ErrUnknownProvider):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, federationService.ErrInvalidState), errors.Is(err, federationService.ErrInvalidIdToken):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, federationService.ErrEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, federationService.ErrProviderFailure):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, authService.ErrAccountDeactivated):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, authService.ErrUserSuspended):
+		return authz.SuspendedError(err)
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func samlLoginError(err error) error {
+	switch {
+	case errors.Is(err, samlService.ErrUnknownProvider):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, samlService.ErrInvalidState), errors.Is(err, samlService.ErrInvalidResponse):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, samlService.ErrEmailNotTrusted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, samlService.ErrProviderFailure):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, authService.ErrAccountDeactivated):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, authService.ErrUserSuspended):
+		return authz.SuspendedError(err)
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func apiKeyToProto(apiKey *model.APIKey) *authDesc.APIKey {
+	res := &authDesc.APIKey{
+		Id:        int64(apiKey.Id),
+		Name:      apiKey.Name,
+		Hint:      apiKey.Hint,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: timestamppb.New(apiKey.CreatedAt),
+	}
+	if apiKey.ExpiresAt != nil {
+		res.ExpiresAt = timestamppb.New(*apiKey.ExpiresAt)
+	}
+	if apiKey.LastUsedAt != nil {
+		res.LastUsedAt = timestamppb.New(*apiKey.LastUsedAt)
+	}
+
+	return res
 }
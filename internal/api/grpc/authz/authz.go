@@ -0,0 +1,97 @@
+// Package authz authorizes callers of the admin RPCs against the
+// permissions of their role.
+package authz
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const authPrefix = "Bearer "
+
+// ReasonUserSuspended is the ErrorInfo reason of requests refused because
+// the user is suspended.
+const ReasonUserSuspended = "USER_SUSPENDED"
+
+// Authenticate checks the bearer token of the incoming request and returns
+// the caller, without permissions. Errors are gRPC statuses.
+func Authenticate(ctx context.Context, serv service.AccessService) (*model.Principal, error) {
+	return authenticate(ctx, serv.Check)
+}
+
+// AuthenticateWithPermissions is Authenticate that also loads the caller's
+// permissions, for handlers that weigh them themselves.
+func AuthenticateWithPermissions(ctx context.Context, serv service.AccessService) (*model.Principal, error) {
+	return authenticate(ctx, serv.CheckV2)
+}
+
+// Require is Authenticate that also demands the caller's role to grant
+// permission on resource.
+func Require(ctx context.Context, serv service.AccessService, permission string, resource string) (*model.Principal, error) {
+	principal, err := AuthenticateWithPermissions(ctx, serv)
+	if err != nil {
+		return nil, err
+	}
+
+	if !principal.HasPermission(permission, resource) {
+		return nil, status.Error(codes.PermissionDenied, accessService.ErrPermissionDenied.Error())
+	}
+
+	return principal, nil
+}
+
+type checkFunc func(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error)
+
+func authenticate(ctx context.Context, check checkFunc) (*model.Principal, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "metadata is not provided")
+	}
+
+	authHeader, ok := md["authorization"]
+	if !ok || len(authHeader) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization header is not provided")
+	}
+
+	if !strings.HasPrefix(authHeader[0], authPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization header format")
+	}
+
+	principal, err := check(ctx, strings.TrimPrefix(authHeader[0], authPrefix), 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, accessService.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case errors.Is(err, accessService.ErrUserSuspended):
+			return nil, SuspendedError(err)
+		case errors.Is(err, accessService.ErrInternal):
+			return nil, status.Error(codes.Internal, err.Error())
+		default:
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	return principal, nil
+}
+
+// SuspendedError is PermissionDenied with an ErrorInfo detail, so clients can
+// tell a suspension apart from missing permissions.
+func SuspendedError(err error) error {
+	st, detailsErr := status.New(codes.PermissionDenied, err.Error()).WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonUserSuspended,
+		Domain: "auth-service",
+	})
+	if detailsErr != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return st.Err()
+}
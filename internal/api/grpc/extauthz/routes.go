@@ -0,0 +1,85 @@
+package extauthz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+type Route struct {
+	Prefix      string   `json:"prefix"`
+	Methods     []string `json:"methods,omitempty"`
+	Public      bool     `json:"public,omitempty"`
+	RequiredLvl int      `json:"required_lvl,omitempty"`
+	Permission  string   `json:"permission,omitempty"`
+	Resource    string   `json:"resource,omitempty"`
+}
+
+type Routes struct {
+	Routes []*Route `json:"routes"`
+}
+
+// LoadRoutes reads per-route requirements from a JSON file. An empty path
+// yields no routes, so every request only has to be authenticated.
+func LoadRoutes(path string) (*Routes, error) {
+	const op = "extauthz.LoadRoutes"
+
+	routes := &Routes{}
+	if path == "" {
+		return routes, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = json.Unmarshal(data, routes); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, route := range routes.Routes {
+		if !strings.HasPrefix(route.Prefix, "/") {
+			return nil, fmt.Errorf("%s: route prefix %q must start with /", op, route.Prefix)
+		}
+		for i, method := range route.Methods {
+			route.Methods[i] = strings.ToUpper(method)
+		}
+	}
+
+	return routes, nil
+}
+
+// Match returns the route with the longest prefix matching the request.
+// Prefixes match whole path segments: "/admin" covers "/admin" and
+// "/admin/users" but not "/administrator".
+func (r *Routes) Match(method string, path string) *Route {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	var matched *Route
+	for _, route := range r.Routes {
+		if !route.matchesPath(path) {
+			continue
+		}
+		if len(route.Methods) > 0 && !slices.Contains(route.Methods, strings.ToUpper(method)) {
+			continue
+		}
+		if matched == nil || len(route.Prefix) > len(matched.Prefix) {
+			matched = route
+		}
+	}
+
+	if matched == nil {
+		return &Route{Prefix: "/"}
+	}
+
+	return matched
+}
+
+func (r *Route) matchesPath(path string) bool {
+	return path == r.Prefix || strings.HasPrefix(path, strings.TrimSuffix(r.Prefix, "/")+"/")
+}
@@ -0,0 +1,27 @@
+package extauthz
+
+import "testing"
+
+func TestRoutesMatch(t *testing.T) {
+	routes := testRoutes()
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: "GET", path: "/admin", want: "/admin"},
+		{method: "GET", path: "/admin/users?page=2", want: "/admin"},
+		{method: "GET", path: "/administrator-public", want: "/"},
+		{method: "GET", path: "/publications", want: "/"},
+		{method: "GET", path: "/users/7", want: "/users/"},
+		{method: "get", path: "/users/", want: "/users/"},
+		{method: "GET", path: "/users", want: "/"},
+		{method: "POST", path: "/users/7", want: "/"},
+	}
+	for _, tt := range tests {
+		if got := routes.Match(tt.method, tt.path); got.Prefix != tt.want {
+			t.Errorf("%s %s: matched %q, want %q", tt.method, tt.path, got.Prefix, tt.want)
+		}
+	}
+}
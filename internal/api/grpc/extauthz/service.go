@@ -0,0 +1,113 @@
+package extauthz
+
+import (
+	"context"
+	"errors"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"strconv"
+	"strings"
+)
+
+const (
+	authPrefix = "Bearer "
+
+	UserIdHeader   = "x-user-id"
+	UserRoleHeader = "x-user-role"
+)
+
+type Implementation struct {
+	authv3.UnimplementedAuthorizationServer
+	serv   service.AccessService
+	routes *Routes
+}
+
+func New(accessService service.AccessService, routes *Routes) *Implementation {
+	return &Implementation{
+		serv:   accessService,
+		routes: routes,
+	}
+}
+
+func (i *Implementation) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+
+	route := i.routes.Match(httpReq.GetMethod(), httpReq.GetPath())
+	if route.Public {
+		return allow(nil), nil
+	}
+
+	authHeader := httpReq.GetHeaders()["authorization"]
+	if !strings.HasPrefix(authHeader, authPrefix) {
+		return deny(codes.Unauthenticated, typev3.StatusCode_Unauthorized, "authorization header is not provided"), nil
+	}
+	accessToken := strings.TrimPrefix(authHeader, authPrefix)
+
+	check := i.serv.Check
+	if route.Permission != "" {
+		check = i.serv.CheckV2
+	}
+
+	principal, err := check(ctx, accessToken, route.RequiredLvl)
+	if err != nil {
+		switch {
+		case errors.Is(err, accessService.ErrInvalidToken):
+			return deny(codes.Unauthenticated, typev3.StatusCode_Unauthorized, err.Error()), nil
+		case errors.Is(err, accessService.ErrPermissionDenied), errors.Is(err, accessService.ErrUserSuspended):
+			return deny(codes.PermissionDenied, typev3.StatusCode_Forbidden, err.Error()), nil
+		default:
+			return deny(codes.Unavailable, typev3.StatusCode_ServiceUnavailable, err.Error()), nil
+		}
+	}
+
+	if route.Permission != "" && !principal.HasPermission(route.Permission, route.Resource) {
+		return deny(codes.PermissionDenied, typev3.StatusCode_Forbidden, accessService.ErrPermissionDenied.Error()), nil
+	}
+
+	return allow(map[string]string{
+		UserIdHeader:   strconv.Itoa(principal.UserId),
+		UserRoleHeader: principal.Role,
+	}), nil
+}
+
+func allow(headers map[string]string) *authv3.CheckResponse {
+	options := make([]*corev3.HeaderValueOption, 0, len(headers))
+	for key, value := range headers {
+		options = append(options, &corev3.HeaderValueOption{
+			Header:       &corev3.HeaderValue{Key: key, Value: value},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}
+
+	okResponse := &authv3.OkHttpResponse{
+		Headers: options,
+	}
+	// Identity headers sent by the client must not reach public upstreams either.
+	if len(headers) == 0 {
+		okResponse.HeadersToRemove = []string{UserIdHeader, UserRoleHeader}
+	}
+
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{
+			OkResponse: okResponse,
+		},
+	}
+}
+
+func deny(code codes.Code, httpCode typev3.StatusCode, message string) *authv3.CheckResponse {
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(code), Message: message},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status: &typev3.HttpStatus{Code: httpCode},
+				Body:   message,
+			},
+		},
+	}
+}
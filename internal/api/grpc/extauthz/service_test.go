@@ -0,0 +1,137 @@
+package extauthz
+
+import (
+	"context"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	"google.golang.org/grpc/codes"
+	"testing"
+)
+
+// accessServ knows two tokens: a moderator's, who may read users, and an
+// admin's.
+type accessServ struct {
+	service.AccessService
+}
+
+var principals = map[string]*model.Principal{
+	"moderator-token": {
+		UserId:      7,
+		Role:        "moderator",
+		RoleLevel:   5,
+		Permissions: []*model.Permission{{Permission: "users:read", Resource: "*"}},
+	},
+	"admin-token": {
+		UserId:      1,
+		Role:        "admin",
+		RoleLevel:   10,
+		Permissions: []*model.Permission{{Permission: "*", Resource: "*"}},
+	},
+}
+
+func (s *accessServ) Check(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	principal, err := s.CheckV2(ctx, accessToken, requiredLvl)
+	if err != nil {
+		return nil, err
+	}
+
+	withoutPermissions := *principal
+	withoutPermissions.Permissions = nil
+
+	return &withoutPermissions, nil
+}
+
+func (s *accessServ) CheckV2(_ context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	principal, ok := principals[accessToken]
+	if !ok {
+		return nil, accessService.ErrInvalidToken
+	}
+	if principal.RoleLevel < requiredLvl {
+		return nil, accessService.ErrPermissionDenied
+	}
+
+	return principal, nil
+}
+
+func testRoutes() *Routes {
+	return &Routes{
+		Routes: []*Route{
+			{Prefix: "/public", Public: true},
+			{Prefix: "/admin", RequiredLvl: 10},
+			{Prefix: "/users/", Methods: []string{"GET"}, Permission: "users:read", Resource: "*"},
+			{Prefix: "/users/", Methods: []string{"DELETE"}, Permission: "users:manage", Resource: "*"},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	impl := New(&accessServ{}, testRoutes())
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		wantCode      codes.Code
+		wantHTTP      typev3.StatusCode
+		wantUserId    string
+	}{
+		{name: "public", method: "GET", path: "/public/docs", wantCode: codes.OK},
+		{name: "no token", method: "GET", path: "/", wantCode: codes.Unauthenticated, wantHTTP: typev3.StatusCode_Unauthorized},
+		{name: "invalid token", method: "GET", path: "/", authorization: "Bearer nope", wantCode: codes.Unauthenticated, wantHTTP: typev3.StatusCode_Unauthorized},
+		{name: "authenticated", method: "GET", path: "/", authorization: "Bearer moderator-token", wantCode: codes.OK, wantUserId: "7"},
+		{name: "insufficient level", method: "GET", path: "/admin/users", authorization: "Bearer moderator-token", wantCode: codes.PermissionDenied, wantHTTP: typev3.StatusCode_Forbidden},
+		{name: "sufficient level", method: "GET", path: "/admin/users", authorization: "Bearer admin-token", wantCode: codes.OK, wantUserId: "1"},
+		{name: "prefix is not a segment", method: "GET", path: "/administrator-public", authorization: "Bearer moderator-token", wantCode: codes.OK, wantUserId: "7"},
+		{name: "permission granted", method: "GET", path: "/users/3", authorization: "Bearer moderator-token", wantCode: codes.OK, wantUserId: "7"},
+		{name: "permission missing", method: "DELETE", path: "/users/3", authorization: "Bearer moderator-token", wantCode: codes.PermissionDenied, wantHTTP: typev3.StatusCode_Forbidden},
+		{name: "wildcard permission", method: "DELETE", path: "/users/3", authorization: "Bearer admin-token", wantCode: codes.OK, wantUserId: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.authorization != "" {
+				headers["authorization"] = tt.authorization
+			}
+
+			resp, err := impl.Check(context.Background(), &authv3.CheckRequest{
+				Attributes: &authv3.AttributeContext{
+					Request: &authv3.AttributeContext_Request{
+						Http: &authv3.AttributeContext_HttpRequest{
+							Method:  tt.method,
+							Path:    tt.path,
+							Headers: headers,
+						},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := codes.Code(resp.GetStatus().GetCode()); got != tt.wantCode {
+				t.Fatalf("got %s, want %s: %s", got, tt.wantCode, resp.GetStatus().GetMessage())
+			}
+			if tt.wantCode != codes.OK {
+				if got := resp.GetDeniedResponse().GetStatus().GetCode(); got != tt.wantHTTP {
+					t.Errorf("got HTTP %s, want %s", got, tt.wantHTTP)
+				}
+				return
+			}
+
+			upstream := map[string]string{}
+			for _, option := range resp.GetOkResponse().GetHeaders() {
+				upstream[option.GetHeader().GetKey()] = option.GetHeader().GetValue()
+			}
+			if got := upstream[UserIdHeader]; got != tt.wantUserId {
+				t.Errorf("got %s %q, want %q", UserIdHeader, got, tt.wantUserId)
+			}
+			if tt.wantUserId == "" && len(resp.GetOkResponse().GetHeadersToRemove()) == 0 {
+				t.Error("identity headers are not stripped from public requests")
+			}
+		})
+	}
+}
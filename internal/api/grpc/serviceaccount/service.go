@@ -0,0 +1,126 @@
+package serviceaccount
+
+import (
+	"context"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	serviceAccountService "github.com/nogavadu/auth-service/internal/service/serviceaccount"
+	serviceAccountDesc "github.com/nogavadu/auth-service/pkg/service_account_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+)
+
+const managePermission = model.PermissionServiceAccountsManage
+
+type Implementation struct {
+	serviceAccountDesc.UnimplementedServiceAccountV1Server
+	serv          service.ServiceAccountService
+	accessService service.AccessService
+}
+
+func New(serviceAccountService service.ServiceAccountService, accessService service.AccessService) *Implementation {
+	return &Implementation{
+		serv:          serviceAccountService,
+		accessService: accessService,
+	}
+}
+
+func (i *Implementation) Create(ctx context.Context, req *serviceAccountDesc.CreateRequest) (*serviceAccountDesc.CreateResponse, error) {
+	principal, err := authz.Require(ctx, i.accessService, managePermission, "*")
+	if err != nil {
+		return nil, err
+	}
+	// Service accounts must not mint more of themselves.
+	if principal.ClientId != "" {
+		return nil, status.Error(codes.PermissionDenied, "service accounts cannot create service accounts")
+	}
+
+	name := req.GetName()
+	if err = validator.New().Var(name, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	role := req.GetRole()
+	if err = validator.New().Var(role, "required"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	}
+
+	account, secret, err := i.serv.Create(ctx, principal, &model.ServiceAccountInfo{
+		Name:   name,
+		Role:   role,
+		Scopes: req.GetScopes(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceAccountService.ErrInvalidRole):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, serviceAccountService.ErrForbidden):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &serviceAccountDesc.CreateResponse{
+		ServiceAccount: toProto(account),
+		ClientSecret:   secret,
+	}, nil
+}
+
+func (i *Implementation) RotateSecret(ctx context.Context, req *serviceAccountDesc.RotateSecretRequest) (*serviceAccountDesc.RotateSecretResponse, error) {
+	id := req.GetId()
+	if _, err := authz.Require(ctx, i.accessService, managePermission, strconv.FormatInt(id, 10)); err != nil {
+		return nil, err
+	}
+
+	secret, err := i.serv.RotateSecret(ctx, int(id))
+	if err != nil {
+		return nil, serviceAccountError(err)
+	}
+
+	return &serviceAccountDesc.RotateSecretResponse{
+		ClientSecret: secret,
+	}, nil
+}
+
+func (i *Implementation) Disable(ctx context.Context, req *serviceAccountDesc.DisableRequest) (*emptypb.Empty, error) {
+	id := req.GetId()
+	if _, err := authz.Require(ctx, i.accessService, managePermission, strconv.FormatInt(id, 10)); err != nil {
+		return nil, err
+	}
+
+	if err := i.serv.Disable(ctx, int(id)); err != nil {
+		return nil, serviceAccountError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func serviceAccountError(err error) error {
+	if errors.Is(err, serviceAccountService.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func toProto(account *model.ServiceAccount) *serviceAccountDesc.ServiceAccount {
+	res := &serviceAccountDesc.ServiceAccount{
+		Id:        int64(account.Id),
+		ClientId:  account.ClientId,
+		Name:      account.Name,
+		Role:      account.Role,
+		Scopes:    account.Scopes,
+		CreatedAt: timestamppb.New(account.CreatedAt),
+	}
+	if account.DisabledAt != nil {
+		res.DisabledAt = timestamppb.New(*account.DisabledAt)
+	}
+
+	return res
+}
@@ -2,23 +2,56 @@ package user
 
 import (
 	"context"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
 	"github.com/nogavadu/auth-service/internal/domain/model"
 	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	userService "github.com/nogavadu/auth-service/internal/service/user"
 	"github.com/nogavadu/auth-service/internal/utils"
 	userDesc "github.com/nogavadu/auth-service/pkg/user_v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"strconv"
+	"strings"
 )
 
+const (
+	readPermission    = model.PermissionUsersRead
+	managePermission  = model.PermissionUsersManage
+	suspendPermission = model.PermissionUsersSuspend
+)
+
+var sortFields = map[userDesc.UserSortField]string{
+	userDesc.UserSortField_USER_SORT_FIELD_UNSPECIFIED: model.UserSortById,
+	userDesc.UserSortField_USER_SORT_FIELD_ID:          model.UserSortById,
+	userDesc.UserSortField_USER_SORT_FIELD_EMAIL:       model.UserSortByEmail,
+	userDesc.UserSortField_USER_SORT_FIELD_NAME:        model.UserSortByName,
+	userDesc.UserSortField_USER_SORT_FIELD_CREATED_AT:  model.UserSortByCreatedAt,
+}
+
+var statuses = map[userDesc.UserStatus]string{
+	userDesc.UserStatus_USER_STATUS_UNSPECIFIED: "",
+	userDesc.UserStatus_USER_STATUS_ACTIVE:      model.UserStatusActive,
+	userDesc.UserStatus_USER_STATUS_DEACTIVATED: model.UserStatusDeactivated,
+	userDesc.UserStatus_USER_STATUS_DELETED:     model.UserStatusDeleted,
+}
+
 type Implementation struct {
 	userDesc.UnimplementedUserV1Server
-	serv service.UserService
+	serv          service.UserService
+	accessService service.AccessService
 }
 
-func New(userService service.UserService) *Implementation {
+func New(userService service.UserService, accessService service.AccessService) *Implementation {
 	return &Implementation{
-		serv: userService,
+		serv:          userService,
+		accessService: accessService,
 	}
 }
 
@@ -30,37 +63,520 @@ func (i *Implementation) GetById(ctx context.Context, request *userDesc.GetByIdR
 	}
 
 	return &userDesc.GetByIdResponse{
-		User: &userDesc.User{
-			Id: int64(user.Id),
-			Info: &userDesc.UserInfo{
-				Name:   utils.StringPtrToProtoString(user.Name),
-				Email:  user.Email,
-				Avatar: utils.StringPtrToProtoString(user.Avatar),
-				Role:   user.Role,
-			},
-		},
+		User: userToProto(user),
 	}, nil
 }
 
-func (i *Implementation) Update(ctx context.Context, request *userDesc.UpdateRequest) (*emptypb.Empty, error) {
-	err := i.serv.Update(ctx, int(request.GetId()), &model.UserUpdateInput{
-		Name:   utils.ProtoStringToPtrString(request.GetUpdateInput().GetName()),
-		Email:  utils.ProtoStringToPtrString(request.GetUpdateInput().GetEmail()),
-		Avatar: utils.ProtoStringToPtrString(request.GetUpdateInput().GetAvatar()),
-		Role:   utils.ProtoStringToPtrString(request.GetUpdateInput().GetRole()),
+func (i *Implementation) ListUsers(ctx context.Context, request *userDesc.ListUsersRequest) (*userDesc.ListUsersResponse, error) {
+	if _, err := authz.Require(ctx, i.accessService, readPermission, "*"); err != nil {
+		return nil, err
+	}
+
+	sortBy, ok := sortFields[request.GetSortBy()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid sort_by")
+	}
+	userStatus, ok := statuses[request.GetStatus()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid status")
+	}
+	if request.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	page, err := i.serv.List(ctx, &model.UserFilter{
+		Role:            request.GetRole(),
+		Email:           request.GetEmail(),
+		Name:            request.GetName(),
+		CreatedAfter:    utils.ProtoTimestampToPtrTime(request.GetCreatedAfter()),
+		CreatedBefore:   utils.ProtoTimestampToPtrTime(request.GetCreatedBefore()),
+		LastLoginAfter:  utils.ProtoTimestampToPtrTime(request.GetLastLoginAfter()),
+		LastLoginBefore: utils.ProtoTimestampToPtrTime(request.GetLastLoginBefore()),
+		Status:          userStatus,
+		Suspended:       utils.ProtoBoolValueToPtrBool(request.GetSuspended()),
+		Verified:        utils.ProtoBoolValueToPtrBool(request.GetVerified()),
+		SortBy:          sortBy,
+		Desc:            request.GetDescending(),
+		PageSize:        int(request.GetPageSize()),
+		PageToken:       request.GetPageToken(),
 	})
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		switch {
+		case errors.Is(err, userService.ErrInvalidPageToken),
+			errors.Is(err, userService.ErrInvalidSort),
+			errors.Is(err, userService.ErrInvalidStatus),
+			errors.Is(err, userService.ErrUnknownRole):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
 	}
 
-	return &emptypb.Empty{}, nil
+	res := &userDesc.ListUsersResponse{
+		Users:         make([]*userDesc.User, 0, len(page.Users)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, user := range page.Users {
+		res.Users = append(res.Users, userToProto(user))
+	}
+
+	return res, nil
+}
+
+func (i *Implementation) Update(ctx context.Context, request *userDesc.UpdateRequest) (*userDesc.UpdateResponse, error) {
+	input, err := updateInputFromProto(request.GetUpdateInput(), request.GetUpdateMask().GetPaths())
+	if err != nil {
+		return nil, err
+	}
+
+	// Users may edit their own profile. Their role, and their email, which
+	// they change through RequestEmailChange so that the new address is
+	// confirmed first, take users:manage.
+	principal, privileged, err := i.authorizeSelf(ctx, request.GetId(), managePermission)
+	if err != nil {
+		return nil, err
+	}
+	if !privileged && (input.Email != nil || input.Role != nil) {
+		return nil, status.Error(codes.PermissionDenied, accessService.ErrPermissionDenied.Error())
+	}
+
+	user, err := i.serv.Update(ctx, principal, int(request.GetId()), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, userService.ErrUnknownRole):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, userService.ErrEmailTaken):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		default:
+			return nil, userError(err)
+		}
+	}
+
+	return &userDesc.UpdateResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+const avatarNotWritableMsg = "avatar can only be cleared, upload one with UploadAvatar"
+
+// updateInputFromProto takes the fields named by mask, or the ones that are
+// set when there is no mask, and validates them.
+func updateInputFromProto(in *userDesc.UserUpdateInput, mask []string) (*model.UserUpdateInput, error) {
+	input := &model.UserUpdateInput{}
+	if len(mask) == 0 {
+		input.Name = utils.ProtoStringToPtrString(in.GetName())
+		input.Email = utils.ProtoStringToPtrString(in.GetEmail())
+		input.Role = utils.ProtoStringToPtrString(in.GetRole())
+		if in.GetAvatar() != nil {
+			return nil, status.Error(codes.InvalidArgument, avatarNotWritableMsg)
+		}
+	}
+
+	for _, path := range mask {
+		switch path {
+		case "name":
+			input.Name = utils.ProtoStringToPtrString(in.GetName())
+			input.ClearName = input.Name == nil
+		case "avatar":
+			if in.GetAvatar() != nil {
+				return nil, status.Error(codes.InvalidArgument, avatarNotWritableMsg)
+			}
+			input.ClearAvatar = true
+		case "email":
+			if input.Email = utils.ProtoStringToPtrString(in.GetEmail()); input.Email == nil {
+				return nil, status.Error(codes.InvalidArgument, "email cannot be cleared")
+			}
+		case "role":
+			if input.Role = utils.ProtoStringToPtrString(in.GetRole()); input.Role == nil {
+				return nil, status.Error(codes.InvalidArgument, "role cannot be cleared")
+			}
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unknown field %q in update_mask", path)
+		}
+	}
+
+	validate := validator.New()
+	if input.Name != nil && validate.Var(*input.Name, "min=1,max=100") != nil {
+		return nil, status.Error(codes.InvalidArgument, "name must be 1 to 100 characters long")
+	}
+	if input.Email != nil && validate.Var(*input.Email, "required,email") != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid email")
+	}
+	if input.Role != nil && *input.Role == "" {
+		return nil, status.Error(codes.InvalidArgument, "role must not be empty")
+	}
+
+	return input, nil
 }
 
 func (i *Implementation) Delete(ctx context.Context, request *userDesc.DeleteRequest) (*emptypb.Empty, error) {
-	err := i.serv.Delete(ctx, int(request.GetId()))
+	// Users may delete their own account; anyone else's takes users:manage
+	// and outranking them.
+	principal, _, err := i.authorizeSelf(ctx, request.GetId(), managePermission)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.serv.Delete(ctx, principal, int(request.GetId())); err != nil {
+		return nil, userError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (i *Implementation) DeactivateUser(ctx context.Context, request *userDesc.DeactivateUserRequest) (*emptypb.Empty, error) {
+	id := request.GetId()
+	principal, err := authz.Require(ctx, i.accessService, managePermission, strconv.FormatInt(id, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.serv.Deactivate(ctx, principal, int(id)); err != nil {
+		return nil, userError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (i *Implementation) ReactivateUser(ctx context.Context, request *userDesc.ReactivateUserRequest) (*emptypb.Empty, error) {
+	id := request.GetId()
+	principal, err := authz.Require(ctx, i.accessService, managePermission, strconv.FormatInt(id, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.serv.Reactivate(ctx, principal, int(id)); err != nil {
+		return nil, userError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (i *Implementation) RestoreUser(ctx context.Context, request *userDesc.RestoreUserRequest) (*emptypb.Empty, error) {
+	id := request.GetId()
+	principal, err := authz.Require(ctx, i.accessService, managePermission, strconv.FormatInt(id, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.serv.Restore(ctx, principal, int(id)); err != nil {
+		return nil, userError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (i *Implementation) SuspendUser(ctx context.Context, request *userDesc.SuspendUserRequest) (*userDesc.Suspension, error) {
+	userId := request.GetUserId()
+	principal, err := authz.Require(ctx, i.accessService, suspendPermission, strconv.FormatInt(userId, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	reason := strings.TrimSpace(request.GetReason())
+	if reason == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+
+	suspension, err := i.serv.Suspend(ctx, principal, int(userId), reason, utils.ProtoTimestampToPtrTime(request.GetUntil()))
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return suspensionToProto(suspension), nil
+}
+
+func (i *Implementation) UnsuspendUser(ctx context.Context, request *userDesc.UnsuspendUserRequest) (*emptypb.Empty, error) {
+	userId := request.GetUserId()
+	principal, err := authz.Require(ctx, i.accessService, suspendPermission, strconv.FormatInt(userId, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.serv.Unsuspend(ctx, principal, int(userId)); err != nil {
+		return nil, userError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (i *Implementation) ListSuspensions(ctx context.Context, request *userDesc.ListSuspensionsRequest) (*userDesc.ListSuspensionsResponse, error) {
+	userId := request.GetUserId()
+	if _, err := authz.Require(ctx, i.accessService, readPermission, strconv.FormatInt(userId, 10)); err != nil {
+		return nil, err
+	}
+
+	suspensions, err := i.serv.ListSuspensions(ctx, int(userId))
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, userError(err)
+	}
+
+	res := &userDesc.ListSuspensionsResponse{
+		Suspensions: make([]*userDesc.Suspension, 0, len(suspensions)),
+	}
+	for _, suspension := range suspensions {
+		res.Suspensions = append(res.Suspensions, suspensionToProto(suspension))
+	}
+
+	return res, nil
+}
+
+func (i *Implementation) GetUserAttributes(ctx context.Context, request *userDesc.GetUserAttributesRequest) (*userDesc.UserAttributes, error) {
+	userId := request.GetUserId()
+	_, privileged, err := i.authorizeSelf(ctx, userId, readPermission)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := i.serv.GetAttributes(ctx, int(userId), request.GetReadMask().GetPaths(), privileged)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return attributesToProto(userId, attributes)
+}
+
+func (i *Implementation) UpdateUserAttributes(ctx context.Context, request *userDesc.UpdateUserAttributesRequest) (*userDesc.UserAttributes, error) {
+	userId := request.GetUserId()
+	_, privileged, err := i.authorizeSelf(ctx, userId, managePermission)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := i.serv.UpdateAttributes(
+		ctx,
+		int(userId),
+		request.GetAttributes().AsMap(),
+		request.GetUpdateMask().GetPaths(),
+		privileged,
+	)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return attributesToProto(userId, attributes)
+}
+
+func (i *Implementation) RequestEmailChange(ctx context.Context, request *userDesc.RequestEmailChangeRequest) (*emptypb.Empty, error) {
+	principal, err := authz.Authenticate(ctx, i.accessService)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the owner can change their email, and an API key of theirs only
+	// with the scope any other change to the account needs.
+	userId := request.GetUserId()
+	if principal.ClientId != "" || int64(principal.UserId) != userId || (principal.Scoped() && !principal.HasScope(managePermission)) {
+		return nil, status.Error(codes.PermissionDenied, accessService.ErrPermissionDenied.Error())
+	}
+
+	newEmail := strings.TrimSpace(request.GetNewEmail())
+	if validator.New().Var(newEmail, "required,email") != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid email")
+	}
+
+	if err = i.serv.RequestEmailChange(ctx, int(userId), newEmail); err != nil {
+		return nil, userError(err)
 	}
 
 	return &emptypb.Empty{}, nil
 }
+
+func (i *Implementation) UploadAvatar(stream userDesc.UserV1_UploadAvatarServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.InvalidArgument, "user_id is required")
+		}
+
+		return err
+	}
+	userIdData, ok := first.GetData().(*userDesc.UploadAvatarRequest_UserId)
+	if !ok {
+		return status.Error(codes.InvalidArgument, "the first message must carry user_id")
+	}
+
+	userId := userIdData.UserId
+	if _, _, err = i.authorizeSelf(ctx, userId, managePermission); err != nil {
+		return err
+	}
+
+	r := &chunkReader{stream: stream}
+	avatar, err := i.serv.UploadAvatar(ctx, int(userId), r)
+	if err != nil {
+		if r.err != nil {
+			return r.err
+		}
+
+		return userError(err)
+	}
+
+	return stream.SendAndClose(avatarToProto(avatar))
+}
+
+// chunkReader reads the image chunks of an UploadAvatar stream. err keeps
+// the error of the stream, so that it is not masked by the service.
+type chunkReader struct {
+	stream userDesc.UserV1_UploadAvatarServer
+	buf    []byte
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				r.err = err
+			}
+
+			return 0, err
+		}
+
+		chunk, ok := msg.GetData().(*userDesc.UploadAvatarRequest_Chunk)
+		if !ok {
+			r.err = status.Error(codes.InvalidArgument, "only the first message may carry user_id")
+			return 0, r.err
+		}
+		r.buf = chunk.Chunk
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (i *Implementation) ConfirmEmailChange(ctx context.Context, request *userDesc.ConfirmEmailChangeRequest) (*userDesc.ConfirmEmailChangeResponse, error) {
+	if request.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	user, err := i.serv.ConfirmEmailChange(ctx, request.GetToken())
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &userDesc.ConfirmEmailChangeResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+// authorizeSelf lets users at their own resources, and callers with
+// permission at anyone's. Along with the caller, it reports whether the
+// permission let them in, which for attributes gives access to the
+// admin-only ones.
+func (i *Implementation) authorizeSelf(ctx context.Context, userId int64, permission string) (*model.Principal, bool, error) {
+	principal, err := authz.AuthenticateWithPermissions(ctx, i.accessService)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if principal.HasPermission(permission, strconv.FormatInt(userId, 10)) {
+		return principal, true, nil
+	}
+	// An API key reaches its owner's resources only within its scopes.
+	if principal.ClientId == "" && int64(principal.UserId) == userId && (!principal.Scoped() || principal.HasScope(permission)) {
+		return principal, false, nil
+	}
+
+	return nil, false, status.Error(codes.PermissionDenied, accessService.ErrPermissionDenied.Error())
+}
+
+func userError(err error) error {
+	switch {
+	case errors.Is(err, userService.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, userService.ErrInvalidUntil),
+		errors.Is(err, userService.ErrUnknownAttribute),
+		errors.Is(err, userService.ErrInvalidAttribute),
+		errors.Is(err, userService.ErrSameEmail),
+		errors.Is(err, userService.ErrInvalidEmailChangeToken),
+		errors.Is(err, userService.ErrAvatarTooLarge),
+		errors.Is(err, userService.ErrUnsupportedImage),
+		errors.Is(err, userService.ErrInvalidImage):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, userService.ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, userService.ErrForbidden),
+		errors.Is(err, userService.ErrRoleForbidden),
+		errors.Is(err, userService.ErrAttributeForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, userService.ErrNotDeleted),
+		errors.Is(err, userService.ErrPurged),
+		errors.Is(err, userService.ErrNotSuspended):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func userToProto(user *model.User) *userDesc.User {
+	return &userDesc.User{
+		Id: int64(user.Id),
+		Info: &userDesc.UserInfo{
+			Name:          utils.StringPtrToProtoString(user.Name),
+			Email:         user.Email,
+			Avatar:        utils.StringPtrToProtoString(user.Avatar),
+			Role:          user.Role,
+			CreatedAt:     timestamppb.New(user.CreatedAt),
+			UpdatedAt:     timestamppb.New(user.UpdatedAt),
+			LastLoginAt:   utils.TimePtrToProtoTimestamp(user.LastLoginAt),
+			Status:        userStatusToProto(user.Status),
+			DeactivatedAt: utils.TimePtrToProtoTimestamp(user.DeactivatedAt),
+			DeletedAt:     utils.TimePtrToProtoTimestamp(user.DeletedAt),
+			EmailVerified: user.EmailVerified,
+		},
+	}
+}
+
+func userStatusToProto(userStatus string) userDesc.UserStatus {
+	for protoStatus, s := range statuses {
+		if s != "" && s == userStatus {
+			return protoStatus
+		}
+	}
+
+	return userDesc.UserStatus_USER_STATUS_UNSPECIFIED
+}
+
+func suspensionToProto(suspension *model.Suspension) *userDesc.Suspension {
+	return &userDesc.Suspension{
+		Id:          int64(suspension.Id),
+		UserId:      int64(suspension.UserId),
+		Reason:      suspension.Reason,
+		SuspendedBy: utils.IntPtrToProtoInt64(suspension.SuspendedBy),
+		CreatedAt:   timestamppb.New(suspension.CreatedAt),
+		Until:       utils.TimePtrToProtoTimestamp(suspension.Until),
+		LiftedAt:    utils.TimePtrToProtoTimestamp(suspension.LiftedAt),
+		LiftedBy:    utils.IntPtrToProtoInt64(suspension.LiftedBy),
+	}
+}
+
+func avatarToProto(avatar *model.Avatar) *userDesc.Avatar {
+	res := &userDesc.Avatar{
+		Url:      avatar.URL,
+		Variants: make([]*userDesc.AvatarVariant, 0, len(avatar.Variants)),
+	}
+	for _, variant := range avatar.Variants {
+		res.Variants = append(res.Variants, &userDesc.AvatarVariant{
+			Size: int32(variant.Size),
+			Url:  variant.URL,
+		})
+	}
+
+	return res
+}
+
+func attributesToProto(userId int64, attributes map[string]interface{}) (*userDesc.UserAttributes, error) {
+	res, err := structpb.NewStruct(attributes)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userDesc.UserAttributes{
+		UserId:     userId,
+		Attributes: res,
+	}, nil
+}
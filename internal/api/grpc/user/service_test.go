@@ -0,0 +1,192 @@
+package user
+
+import (
+	"context"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	userDesc "github.com/nogavadu/auth-service/pkg/user_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+// accessServ knows a plain user's token, two scoped API keys of theirs, and
+// an admin's.
+type accessServ struct {
+	service.AccessService
+}
+
+var principals = map[string]*model.Principal{
+	"user-token": {
+		UserId: 7,
+		Role:   "user",
+	},
+	"read-key": {
+		UserId:   7,
+		Role:     "user",
+		APIKeyId: 3,
+		Scopes:   []string{"users:read"},
+	},
+	"manage-key": {
+		UserId:   7,
+		Role:     "user",
+		APIKeyId: 4,
+		Scopes:   []string{"users:manage"},
+	},
+	"admin-token": {
+		UserId:      1,
+		Role:        "admin",
+		Permissions: []*model.Permission{{Permission: "*", Resource: "*"}},
+	},
+}
+
+func (s *accessServ) Check(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	return s.CheckV2(ctx, accessToken, requiredLvl)
+}
+
+func (s *accessServ) CheckV2(_ context.Context, accessToken string, _ int) (*model.Principal, error) {
+	principal, ok := principals[accessToken]
+	if !ok {
+		return nil, accessService.ErrInvalidToken
+	}
+
+	return principal, nil
+}
+
+// userServ records the users it deleted, updated or changed the email of.
+type userServ struct {
+	service.UserService
+	deleted      []int
+	updated      []int
+	emailChanged []int
+}
+
+func (s *userServ) RequestEmailChange(_ context.Context, id int, _ string) error {
+	s.emailChanged = append(s.emailChanged, id)
+	return nil
+}
+
+func (s *userServ) Update(_ context.Context, _ *model.Principal, id int, _ *model.UserUpdateInput) (*model.User, error) {
+	s.updated = append(s.updated, id)
+	return &model.User{Id: id}, nil
+}
+
+func (s *userServ) Delete(_ context.Context, _ *model.Principal, id int) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		id       int64
+		wantCode codes.Code
+	}{
+		{name: "self", token: "user-token", id: 7},
+		{name: "someone else", token: "user-token", id: 8, wantCode: codes.PermissionDenied},
+		{name: "self with a read-only key", token: "read-key", id: 7, wantCode: codes.PermissionDenied},
+		{name: "admin", token: "admin-token", id: 8},
+		{name: "unauthenticated", token: "bogus", id: 7, wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serv := &userServ{}
+			impl := New(serv, &accessServ{})
+
+			_, err := impl.Delete(withToken(tt.token), &userDesc.DeleteRequest{Id: tt.id})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %v, want %v", code, tt.wantCode)
+			}
+
+			if deleted := len(serv.deleted) == 1 && serv.deleted[0] == int(tt.id); deleted != (tt.wantCode == codes.OK) {
+				t.Errorf("got deleted %v", serv.deleted)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	name := &userDesc.UserUpdateInput{Name: wrapperspb.String("Jane")}
+	email := &userDesc.UserUpdateInput{Email: wrapperspb.String("jane@example.com")}
+	role := &userDesc.UserUpdateInput{Role: wrapperspb.String("admin")}
+
+	tests := []struct {
+		name     string
+		token    string
+		id       int64
+		input    *userDesc.UserUpdateInput
+		mask     []string
+		wantCode codes.Code
+	}{
+		{name: "own name", token: "user-token", id: 7, input: name},
+		{name: "someone else's name", token: "user-token", id: 8, input: name, wantCode: codes.PermissionDenied},
+		{name: "own name with a read-only key", token: "read-key", id: 7, input: name, wantCode: codes.PermissionDenied},
+		{name: "own email", token: "user-token", id: 7, input: email, wantCode: codes.PermissionDenied},
+		{name: "own role", token: "user-token", id: 7, input: role, wantCode: codes.PermissionDenied},
+		{name: "own role by mask", token: "user-token", id: 7, input: role, mask: []string{"name", "role"}, wantCode: codes.PermissionDenied},
+		{name: "admin sets a role", token: "admin-token", id: 8, input: role},
+		{name: "admin sets an email", token: "admin-token", id: 8, input: email},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serv := &userServ{}
+			impl := New(serv, &accessServ{})
+
+			request := &userDesc.UpdateRequest{Id: tt.id, UpdateInput: tt.input}
+			if tt.mask != nil {
+				request.UpdateMask = &fieldmaskpb.FieldMask{Paths: tt.mask}
+			}
+
+			_, err := impl.Update(withToken(tt.token), request)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %v, want %v", code, tt.wantCode)
+			}
+
+			if updated := len(serv.updated) == 1; updated != (tt.wantCode == codes.OK) {
+				t.Errorf("got updated %v", serv.updated)
+			}
+		})
+	}
+}
+
+func TestRequestEmailChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		id       int64
+		wantCode codes.Code
+	}{
+		{name: "self", token: "user-token", id: 7},
+		{name: "self with a manage key", token: "manage-key", id: 7},
+		{name: "self with a read-only key", token: "read-key", id: 7, wantCode: codes.PermissionDenied},
+		{name: "someone else", token: "user-token", id: 8, wantCode: codes.PermissionDenied},
+		{name: "admin for someone else", token: "admin-token", id: 8, wantCode: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serv := &userServ{}
+			impl := New(serv, &accessServ{})
+
+			_, err := impl.RequestEmailChange(withToken(tt.token), &userDesc.RequestEmailChangeRequest{
+				UserId:   tt.id,
+				NewEmail: "new@example.com",
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %v, want %v", code, tt.wantCode)
+			}
+
+			if requested := len(serv.emailChanged) == 1; requested != (tt.wantCode == codes.OK) {
+				t.Errorf("got email changes %v", serv.emailChanged)
+			}
+		})
+	}
+}
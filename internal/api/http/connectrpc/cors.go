@@ -0,0 +1,48 @@
+package connectrpc
+
+import (
+	"github.com/rs/cors"
+	"net/http"
+)
+
+var (
+	allowedMethods = []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+	allowedHeaders = []string{
+		"Authorization",
+		"Content-Type",
+		"Connect-Protocol-Version",
+		"Connect-Timeout-Ms",
+		"Grpc-Timeout",
+		"X-Grpc-Web",
+		"X-User-Agent",
+	}
+	exposedHeaders = []string{
+		"Grpc-Status",
+		"Grpc-Message",
+		"Grpc-Status-Details-Bin",
+	}
+)
+
+// CORS wraps h so that browsers served from one of allowedOrigins may call it.
+// With no origins configured cross-origin requests are rejected.
+func CORS(h http.Handler, allowedOrigins []string) http.Handler {
+	options := cors.Options{
+		AllowedOrigins:   allowedOrigins,
+		AllowedMethods:   allowedMethods,
+		AllowedHeaders:   allowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: true,
+		MaxAge:           7200,
+	}
+	if len(allowedOrigins) == 0 {
+		// rs/cors treats an empty list as "*".
+		options.AllowOriginFunc = func(string) bool { return false }
+	}
+
+	return cors.New(options).Handler(h)
+}
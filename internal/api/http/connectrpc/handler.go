@@ -0,0 +1,171 @@
+package connectrpc
+
+import (
+	"connectrpc.com/connect"
+	"context"
+	"errors"
+	descAccess "github.com/nogavadu/auth-service/pkg/access_v1"
+	descAuth "github.com/nogavadu/auth-service/pkg/auth_v1"
+	descServiceAccount "github.com/nogavadu/auth-service/pkg/service_account_v1"
+	descUser "github.com/nogavadu/auth-service/pkg/user_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Register mounts the gRPC service implementations on mux so that browsers
+// can call them with the Connect or gRPC-Web protocol (and plain gRPC over
+// HTTP/2). Requests are dispatched to the same implementations that back the
+// native gRPC server.
+func Register(
+	mux *http.ServeMux,
+	auth descAuth.AuthV1Server,
+	access descAccess.AccessV1Server,
+	user descUser.UserV1Server,
+	serviceAccount descServiceAccount.ServiceAccountV1Server,
+) {
+	handle(mux, descAuth.AuthV1_Register_FullMethodName, auth.Register)
+	handle(mux, descAuth.AuthV1_Login_FullMethodName, auth.Login)
+	handle(mux, descAuth.AuthV1_GetRefreshToken_FullMethodName, auth.GetRefreshToken)
+	handle(mux, descAuth.AuthV1_GetAccessToken_FullMethodName, auth.GetAccessToken)
+	handle(mux, descAuth.AuthV1_IsUser_FullMethodName, auth.IsUser)
+	handle(mux, descAuth.AuthV1_ClientCredentials_FullMethodName, auth.ClientCredentials)
+	handle(mux, descAuth.AuthV1_CreateAPIKey_FullMethodName, auth.CreateAPIKey)
+	handle(mux, descAuth.AuthV1_ListAPIKeys_FullMethodName, auth.ListAPIKeys)
+	handle(mux, descAuth.AuthV1_RevokeAPIKey_FullMethodName, auth.RevokeAPIKey)
+	handle(mux, descAuth.AuthV1_BeginExternalLogin_FullMethodName, auth.BeginExternalLogin)
+	handle(mux, descAuth.AuthV1_CompleteExternalLogin_FullMethodName, auth.CompleteExternalLogin)
+	handle(mux, descAuth.AuthV1_BeginSAMLLogin_FullMethodName, auth.BeginSAMLLogin)
+	handle(mux, descAuth.AuthV1_CompleteSAMLLogin_FullMethodName, auth.CompleteSAMLLogin)
+	handle(mux, descAuth.AuthV1_ListIdentities_FullMethodName, auth.ListIdentities)
+	handle(mux, descAuth.AuthV1_LinkIdentity_FullMethodName, auth.LinkIdentity)
+	handle(mux, descAuth.AuthV1_UnlinkIdentity_FullMethodName, auth.UnlinkIdentity)
+
+	handle(mux, descAccess.AccessV1_Check_FullMethodName, access.Check)
+	handle(mux, descAccess.AccessV1_CheckV2_FullMethodName, access.CheckV2)
+	handle(mux, descAccess.AccessV1_BatchCheck_FullMethodName, access.BatchCheck)
+
+	handle(mux, descUser.UserV1_GetById_FullMethodName, user.GetById)
+	handle(mux, descUser.UserV1_ListUsers_FullMethodName, user.ListUsers)
+	handle(mux, descUser.UserV1_Update_FullMethodName, user.Update)
+	handle(mux, descUser.UserV1_Delete_FullMethodName, user.Delete)
+	handle(mux, descUser.UserV1_DeactivateUser_FullMethodName, user.DeactivateUser)
+	handle(mux, descUser.UserV1_ReactivateUser_FullMethodName, user.ReactivateUser)
+	handle(mux, descUser.UserV1_RestoreUser_FullMethodName, user.RestoreUser)
+	handle(mux, descUser.UserV1_SuspendUser_FullMethodName, user.SuspendUser)
+	handle(mux, descUser.UserV1_UnsuspendUser_FullMethodName, user.UnsuspendUser)
+	handle(mux, descUser.UserV1_ListSuspensions_FullMethodName, user.ListSuspensions)
+	handle(mux, descUser.UserV1_GetUserAttributes_FullMethodName, user.GetUserAttributes)
+	handle(mux, descUser.UserV1_UpdateUserAttributes_FullMethodName, user.UpdateUserAttributes)
+	handle(mux, descUser.UserV1_RequestEmailChange_FullMethodName, user.RequestEmailChange)
+	handle(mux, descUser.UserV1_ConfirmEmailChange_FullMethodName, user.ConfirmEmailChange)
+	handleClientStream(mux, descUser.UserV1_UploadAvatar_FullMethodName, user.UploadAvatar)
+
+	handle(mux, descServiceAccount.ServiceAccountV1_Create_FullMethodName, serviceAccount.Create)
+	handle(mux, descServiceAccount.ServiceAccountV1_RotateSecret_FullMethodName, serviceAccount.RotateSecret)
+	handle(mux, descServiceAccount.ServiceAccountV1_Disable_FullMethodName, serviceAccount.Disable)
+}
+
+func handle[Req, Res any](mux *http.ServeMux, procedure string, method func(context.Context, *Req) (*Res, error)) {
+	mux.Handle(procedure, connect.NewUnaryHandler(
+		procedure,
+		func(ctx context.Context, req *connect.Request[Req]) (*connect.Response[Res], error) {
+			ctx = metadata.NewIncomingContext(ctx, headerToMetadata(req.Header()))
+
+			res, err := method(ctx, req.Msg)
+			if err != nil {
+				return nil, toConnectError(err)
+			}
+
+			return connect.NewResponse(res), nil
+		},
+	))
+}
+
+// handleClientStream is handle for client-streaming methods. Connect only
+// streams over HTTP/2, so these are not available to browsers.
+func handleClientStream[Req, Res any](mux *http.ServeMux, procedure string, method func(grpc.ClientStreamingServer[Req, Res]) error) {
+	mux.Handle(procedure, connect.NewClientStreamHandler(
+		procedure,
+		func(ctx context.Context, stream *connect.ClientStream[Req]) (*connect.Response[Res], error) {
+			adapter := &clientStream[Req, Res]{
+				ctx:    metadata.NewIncomingContext(ctx, headerToMetadata(stream.RequestHeader())),
+				stream: stream,
+			}
+
+			if err := method(adapter); err != nil {
+				return nil, toConnectError(err)
+			}
+			if adapter.res == nil {
+				return nil, connect.NewError(connect.CodeInternal, errors.New("no response sent"))
+			}
+
+			return connect.NewResponse(adapter.res), nil
+		},
+	))
+}
+
+// clientStream presents a Connect client stream as the gRPC one the
+// implementations expect. They only receive and close, the other methods of
+// grpc.ServerStream are left unimplemented.
+type clientStream[Req, Res any] struct {
+	grpc.ServerStream
+
+	ctx    context.Context
+	stream *connect.ClientStream[Req]
+	res    *Res
+}
+
+func (s *clientStream[Req, Res]) Context() context.Context {
+	return s.ctx
+}
+
+func (s *clientStream[Req, Res]) Recv() (*Req, error) {
+	if !s.stream.Receive() {
+		if err := s.stream.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, io.EOF
+	}
+
+	return s.stream.Msg(), nil
+}
+
+func (s *clientStream[Req, Res]) SendAndClose(res *Res) error {
+	s.res = res
+	return nil
+}
+
+func headerToMetadata(header http.Header) metadata.MD {
+	md := make(metadata.MD, len(header))
+	for key, values := range header {
+		md.Append(strings.ToLower(key), values...)
+	}
+
+	return md
+}
+
+func toConnectError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return connect.NewError(connect.CodeUnknown, err)
+	}
+
+	// gRPC and Connect share the same numeric code space.
+	connectErr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+
+	// Details such as the ErrorInfo of a suspended user go along as they are.
+	for _, detail := range st.Proto().GetDetails() {
+		errorDetail, err := connect.NewErrorDetail(detail)
+		if err != nil {
+			continue
+		}
+		connectErr.AddDetail(errorDetail)
+	}
+
+	return connectErr
+}
@@ -0,0 +1,187 @@
+package connectrpc
+
+import (
+	"connectrpc.com/connect"
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
+	descAccess "github.com/nogavadu/auth-service/pkg/access_v1"
+	descAuth "github.com/nogavadu/auth-service/pkg/auth_v1"
+	descServiceAccount "github.com/nogavadu/auth-service/pkg/service_account_v1"
+	descUser "github.com/nogavadu/auth-service/pkg/user_v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestToConnectErrorForwardsDetails(t *testing.T) {
+	var connectErr *connect.Error
+	if !errors.As(toConnectError(authz.SuspendedError(errors.New("user is suspended"))), &connectErr) {
+		t.Fatal("not a connect error")
+	}
+	if connectErr.Code() != connect.CodePermissionDenied {
+		t.Errorf("got code %v, want %v", connectErr.Code(), connect.CodePermissionDenied)
+	}
+
+	details := connectErr.Details()
+	if len(details) != 1 {
+		t.Fatalf("got %d details, want 1", len(details))
+	}
+	value, err := details[0].Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, ok := value.(*errdetails.ErrorInfo); !ok || info.GetReason() != authz.ReasonUserSuspended {
+		t.Errorf("got detail %v", value)
+	}
+}
+
+// accessServer answers CheckV2 for the bearer token good-token only.
+type accessServer struct {
+	descAccess.UnimplementedAccessV1Server
+}
+
+func (s *accessServer) CheckV2(ctx context.Context, _ *descAccess.CheckRequest) (*descAccess.CheckResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if authorization := md.Get("authorization"); len(authorization) == 0 || authorization[0] != "Bearer good-token" {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return &descAccess.CheckResponse{Principal: &descAccess.Principal{UserId: 7}}, nil
+}
+
+// userServer takes avatar uploads and reports their size as the url.
+type userServer struct {
+	descUser.UnimplementedUserV1Server
+}
+
+func (s *userServer) UploadAvatar(stream grpc.ClientStreamingServer[descUser.UploadAvatarRequest, descUser.Avatar]) error {
+	size := 0
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		size += len(req.GetChunk())
+	}
+
+	return stream.SendAndClose(&descUser.Avatar{Url: strconv.Itoa(size)})
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	Register(
+		mux,
+		descAuth.UnimplementedAuthV1Server{},
+		&accessServer{},
+		&userServer{},
+		descServiceAccount.UnimplementedServiceAccountV1Server{},
+	)
+
+	// Client streams need HTTP/2.
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUnary(t *testing.T) {
+	server := newTestServer(t)
+
+	protocols := map[string][]connect.ClientOption{
+		"connect":  nil,
+		"grpc-web": {connect.WithGRPCWeb()},
+		"grpc":     {connect.WithGRPC()},
+	}
+	tests := []struct {
+		name     string
+		token    string
+		wantCode connect.Code
+	}{
+		{name: "authorized", token: "good-token"},
+		{name: "unauthorized", token: "bogus", wantCode: connect.CodeUnauthenticated},
+	}
+	for protocol, opts := range protocols {
+		client := connect.NewClient[descAccess.CheckRequest, descAccess.CheckResponse](
+			server.Client(),
+			server.URL+descAccess.AccessV1_CheckV2_FullMethodName,
+			opts...,
+		)
+
+		for _, tt := range tests {
+			t.Run(protocol+" "+tt.name, func(t *testing.T) {
+				req := connect.NewRequest(&descAccess.CheckRequest{})
+				req.Header().Set("Authorization", "Bearer "+tt.token)
+
+				res, err := client.CallUnary(context.Background(), req)
+				if tt.wantCode != 0 {
+					if code := connect.CodeOf(err); code != tt.wantCode {
+						t.Fatalf("got code %v, want %v", code, tt.wantCode)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Msg.GetPrincipal().GetUserId() != 7 {
+					t.Errorf("got principal %v", res.Msg.GetPrincipal())
+				}
+			})
+		}
+	}
+}
+
+func TestUnimplemented(t *testing.T) {
+	server := newTestServer(t)
+	client := connect.NewClient[descUser.GetByIdRequest, descUser.GetByIdResponse](
+		server.Client(),
+		server.URL+descUser.UserV1_GetById_FullMethodName,
+	)
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&descUser.GetByIdRequest{Id: 7}))
+	if code := connect.CodeOf(err); code != connect.CodeUnimplemented {
+		t.Fatalf("got code %v, want %v", code, connect.CodeUnimplemented)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	server := newTestServer(t)
+	client := connect.NewClient[descUser.UploadAvatarRequest, descUser.Avatar](
+		server.Client(),
+		server.URL+descUser.UserV1_UploadAvatar_FullMethodName,
+	)
+
+	stream := client.CallClientStream(context.Background())
+	messages := []*descUser.UploadAvatarRequest{
+		{Data: &descUser.UploadAvatarRequest_UserId{UserId: 7}},
+		{Data: &descUser.UploadAvatarRequest_Chunk{Chunk: []byte("abc")}},
+		{Data: &descUser.UploadAvatarRequest_Chunk{Chunk: []byte("de")}},
+	}
+	for _, msg := range messages {
+		if err := stream.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := stream.CloseAndReceive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Msg.GetUrl() != "5" {
+		t.Errorf("server received %s bytes, want 5", res.Msg.GetUrl())
+	}
+}
@@ -0,0 +1,97 @@
+package forwardauth
+
+import (
+	"errors"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	Path = "/auth/verify"
+
+	authPrefix = "Bearer "
+
+	UserIdHeader    = "X-User-Id"
+	UserEmailHeader = "X-User-Email"
+	UserRoleHeader  = "X-User-Role"
+
+	// ErrorReasonHeader carries the reason of a refusal the client can act
+	// on, e.g. USER_SUSPENDED, like the ErrorInfo detail of the gRPC API.
+	ErrorReasonHeader = "X-Auth-Error-Reason"
+)
+
+// Implementation answers nginx auth_request and Traefik ForwardAuth
+// subrequests. Requirements are passed as query parameters of the verify
+// URL, e.g. /auth/verify?required_lvl=10&permission=users:read.
+type Implementation struct {
+	serv service.AccessService
+}
+
+func New(accessService service.AccessService) *Implementation {
+	return &Implementation{
+		serv: accessService,
+	}
+}
+
+func (i *Implementation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var requiredLvl int
+	if lvl := query.Get("required_lvl"); lvl != "" {
+		var err error
+		if requiredLvl, err = strconv.Atoi(lvl); err != nil || requiredLvl < 0 {
+			http.Error(w, "invalid required_lvl", http.StatusBadRequest)
+			return
+		}
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, authPrefix) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authorization header is not provided", http.StatusUnauthorized)
+		return
+	}
+	accessToken := strings.TrimPrefix(authHeader, authPrefix)
+
+	permission := query.Get("permission")
+	check := i.serv.Check
+	if permission != "" {
+		check = i.serv.CheckV2
+	}
+
+	principal, err := check(r.Context(), accessToken, requiredLvl)
+	if err != nil {
+		switch {
+		case errors.Is(err, accessService.ErrInvalidToken):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, accessService.ErrUserSuspended):
+			w.Header().Set(ErrorReasonHeader, authz.ReasonUserSuspended)
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, accessService.ErrPermissionDenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if permission != "" && !principal.HasPermission(permission, query.Get("resource")) {
+		http.Error(w, accessService.ErrPermissionDenied.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set(UserIdHeader, strconv.Itoa(principal.UserId))
+	w.Header().Set(UserEmailHeader, principal.Email)
+	w.Header().Set(UserRoleHeader, principal.Role)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,117 @@
+package forwardauth
+
+import (
+	"context"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// accessServ knows a moderator's token, who may read users, an admin's, and
+// a suspended user's.
+type accessServ struct {
+	service.AccessService
+}
+
+var principals = map[string]*model.Principal{
+	"moderator-token": {
+		UserId:      7,
+		Email:       "jane@example.com",
+		Role:        "moderator",
+		RoleLevel:   5,
+		Permissions: []*model.Permission{{Permission: "users:read", Resource: "*"}},
+	},
+	"admin-token": {
+		UserId:      1,
+		Email:       "admin@example.com",
+		Role:        "admin",
+		RoleLevel:   10,
+		Permissions: []*model.Permission{{Permission: "*", Resource: "*"}},
+	},
+}
+
+func (s *accessServ) Check(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	principal, err := s.CheckV2(ctx, accessToken, requiredLvl)
+	if err != nil {
+		return nil, err
+	}
+
+	withoutPermissions := *principal
+	withoutPermissions.Permissions = nil
+
+	return &withoutPermissions, nil
+}
+
+func (s *accessServ) CheckV2(_ context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	if accessToken == "suspended-token" {
+		return nil, accessService.ErrUserSuspended
+	}
+	principal, ok := principals[accessToken]
+	if !ok {
+		return nil, accessService.ErrInvalidToken
+	}
+	if principal.RoleLevel < requiredLvl {
+		return nil, accessService.ErrPermissionDenied
+	}
+
+	return principal, nil
+}
+
+func TestServeHTTP(t *testing.T) {
+	handler := New(&accessServ{})
+
+	tests := []struct {
+		name          string
+		method        string
+		query         string
+		authorization string
+		wantStatus    int
+		wantUserId    string
+		wantChallenge string
+		wantReason    string
+	}{
+		{name: "authenticated", authorization: "Bearer moderator-token", wantStatus: http.StatusOK, wantUserId: "7"},
+		{name: "head", method: http.MethodHead, authorization: "Bearer moderator-token", wantStatus: http.StatusOK, wantUserId: "7"},
+		{name: "level met", query: "required_lvl=10", authorization: "Bearer admin-token", wantStatus: http.StatusOK, wantUserId: "1"},
+		{name: "level not met", query: "required_lvl=10", authorization: "Bearer moderator-token", wantStatus: http.StatusForbidden},
+		{name: "permission granted", query: "permission=users:read&resource=8", authorization: "Bearer moderator-token", wantStatus: http.StatusOK, wantUserId: "7"},
+		{name: "permission missing", query: "permission=users:manage&resource=8", authorization: "Bearer moderator-token", wantStatus: http.StatusForbidden},
+		{name: "invalid level", query: "required_lvl=-1", authorization: "Bearer admin-token", wantStatus: http.StatusBadRequest},
+		{name: "no token", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "invalid token", authorization: "Bearer bogus", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token"`},
+		{name: "suspended user", authorization: "Bearer suspended-token", wantStatus: http.StatusForbidden, wantReason: authz.ReasonUserSuspended},
+		{name: "post", method: http.MethodPost, authorization: "Bearer admin-token", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, Path+"?"+tt.query, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get(UserIdHeader); got != tt.wantUserId {
+				t.Errorf("got user id %q, want %q", got, tt.wantUserId)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("got challenge %q, want %q", got, tt.wantChallenge)
+			}
+			if got := w.Header().Get(ErrorReasonHeader); got != tt.wantReason {
+				t.Errorf("got reason %q, want %q", got, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/api/grpc/authz"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorHandlerForwardsDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	errorHandler(context.Background(), nil, nil, rec, httptest.NewRequest(http.MethodGet, "/v1/users/7", nil), authz.SuspendedError(errors.New("user is suspended")))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	var body struct {
+		Code    string `json:"code"`
+		Reason  string `json:"reason"`
+		Details []struct {
+			Type   string `json:"@type"`
+			Reason string `json:"reason"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "PermissionDenied" || body.Reason != authz.ReasonUserSuspended {
+		t.Errorf("got code %q and reason %q", body.Code, body.Reason)
+	}
+	if len(body.Details) != 1 || body.Details[0].Type != "type.googleapis.com/google.rpc.ErrorInfo" || body.Details[0].Reason != authz.ReasonUserSuspended {
+		t.Errorf("got details %+v", body.Details)
+	}
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	descAccess "github.com/nogavadu/auth-service/pkg/access_v1"
+	descAuth "github.com/nogavadu/auth-service/pkg/auth_v1"
+	descServiceAccount "github.com/nogavadu/auth-service/pkg/service_account_v1"
+	descUser "github.com/nogavadu/auth-service/pkg/user_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"net/http"
+)
+
+// New returns an HTTP/JSON handler that transcodes requests to the gRPC
+// server listening on grpcAddress according to the google.api.http rules.
+func New(ctx context.Context, grpcAddress string) (http.Handler, error) {
+	const op = "gateway.New"
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithErrorHandler(errorHandler),
+	)
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+
+	if err := descAuth.RegisterAuthV1HandlerFromEndpoint(ctx, mux, grpcAddress, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := descAccess.RegisterAccessV1HandlerFromEndpoint(ctx, mux, grpcAddress, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := descUser.RegisterUserV1HandlerFromEndpoint(ctx, mux, grpcAddress, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := descServiceAccount.RegisterServiceAccountV1HandlerFromEndpoint(ctx, mux, grpcAddress, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return mux, nil
+}
@@ -0,0 +1,31 @@
+package jwks
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/nogavadu/auth-service/internal/utils"
+	"net/http"
+)
+
+const Path = "/.well-known/jwks.json"
+
+type Implementation struct {
+	keys []*rsa.PublicKey
+}
+
+func New(keys ...*rsa.PublicKey) *Implementation {
+	return &Implementation{
+		keys: keys,
+	}
+}
+
+func (i *Implementation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(utils.PublicJWKSet(i.keys...))
+}
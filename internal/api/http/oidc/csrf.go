@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	csrfCookie = "oidc_csrf"
+	csrfField  = "csrf_token"
+)
+
+// csrfToken sets a fresh random cookie and returns the form token that binds
+// it to the request the form was rendered for, such as an authorize request.
+// Another site can neither read the cookie nor, without it, forge the token.
+func csrfToken(w http.ResponseWriter, path string, request string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    secret,
+		Path:     path,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return csrfMAC(secret, request), nil
+}
+
+// checkCSRF reports whether the posted form token belongs to the cookie and
+// the request.
+func checkCSRF(r *http.Request, request string) bool {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(csrfMAC(cookie.Value, request)), []byte(r.PostForm.Get(csrfField)))
+}
+
+func csrfMAC(secret string, request string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(request))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	oidcService "github.com/nogavadu/auth-service/internal/service/oidc"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+func (i *Implementation) deviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, oidcService.ErrInvalidRequest)
+		return
+	}
+
+	clientId, clientSecret := r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	if basicId, basicSecret, ok := r.BasicAuth(); ok {
+		clientId, _ = url.QueryUnescape(basicId)
+		clientSecret, _ = url.QueryUnescape(basicSecret)
+	}
+
+	auth, err := i.serv.DeviceAuthorization(r.Context(), clientId, clientSecret, r.PostForm.Get("scope"))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(&deviceAuthorizationResponse{
+		DeviceCode:              auth.DeviceCode,
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		ExpiresIn:               int64(auth.ExpiresIn.Seconds()),
+		Interval:                int64(auth.Interval.Seconds()),
+	})
+}
+
+var deviceCodeTemplate = template.Must(template.New("device_code").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+<form method="get" action="{{.Action}}">
+{{if .Error}}<p>{{.Error}}</p>
+{{end}}<label>Code shown on your device <input type="text" name="user_code" autocomplete="off" required></label>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+var deviceLoginTemplate = template.Must(template.New("device_login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>{{.Request.ClientName}} is asking for access to your account{{if .Request.Scope}} ({{.Request.Scope}}){{end}}.</p>
+<p>Check that your device shows the code <strong>{{.Request.UserCode}}</strong>.</p>
+<input type="hidden" name="user_code" value="{{.Request.UserCode}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{if .Error}}<p>{{.Error}}</p>
+{{end}}<label>Email <input type="email" name="email" required></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+var deviceDoneTemplate = template.Must(template.New("device_done").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body><p>{{if .}}Your device is connected. You can return to it now.{{else}}Access was denied.{{end}}</p></body>
+</html>
+`))
+
+// device is the verification page where the user enters the code shown on
+// the device, logs in and allows or denies the request.
+func (i *Implementation) device(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		renderError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	userCode := r.Form.Get("user_code")
+	if userCode == "" {
+		renderDevicePage(w, http.StatusOK, deviceCodeTemplate, nil, "")
+		return
+	}
+
+	req, err := i.serv.GetDeviceRequest(r.Context(), userCode)
+	if err != nil {
+		if errors.Is(err, oidcService.ErrInvalidUserCode) {
+			renderDevicePage(w, http.StatusNotFound, deviceCodeTemplate, nil, err.Error())
+			return
+		}
+
+		renderError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderDevicePage(w, http.StatusOK, deviceLoginTemplate, req, "")
+		return
+	}
+
+	if !checkCSRF(r, req.UserCode) {
+		renderDevicePage(w, http.StatusForbidden, deviceLoginTemplate, req, "the page has expired, please sign in again")
+		return
+	}
+
+	approve := r.PostForm.Get("decision") == "approve"
+	err = i.serv.AuthorizeDevice(r.Context(), userCode, r.PostForm.Get("email"), r.PostForm.Get("password"), approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidcService.ErrAccessDenied):
+			renderDevicePage(w, http.StatusUnauthorized, deviceLoginTemplate, req, "invalid email or password")
+		case errors.Is(err, oidcService.ErrInvalidUserCode):
+			renderDevicePage(w, http.StatusNotFound, deviceCodeTemplate, nil, err.Error())
+		default:
+			renderError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = deviceDoneTemplate.Execute(w, approve)
+}
+
+func renderDevicePage(w http.ResponseWriter, status int, tmpl *template.Template, req *model.DeviceRequest, errMsg string) {
+	// The login form is bound to the user code it asks about.
+	var token string
+	if req != nil {
+		var err error
+		if token, err = csrfToken(w, DevicePath, req.UserCode); err != nil {
+			renderError(w, http.StatusInternalServerError, oidcService.ErrInternal.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_ = tmpl.Execute(w, map[string]any{
+		"Action":    DevicePath,
+		"Request":   req,
+		"CSRFToken": token,
+		"Error":     errMsg,
+	})
+}
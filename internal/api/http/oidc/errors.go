@@ -0,0 +1,135 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	oidcService "github.com/nogavadu/auth-service/internal/service/oidc"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type oauthError struct {
+	Error string `json:"error"`
+}
+
+// errorCode maps service errors onto the error codes of RFC 6749 section 5.2
+// and RFC 8628 section 3.5.
+func errorCode(err error) (string, int) {
+	switch {
+	case errors.Is(err, oidcService.ErrInvalidRequest):
+		return "invalid_request", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrInvalidClient):
+		return "invalid_client", http.StatusUnauthorized
+	case errors.Is(err, oidcService.ErrInvalidGrant):
+		return "invalid_grant", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrInvalidScope):
+		return "invalid_scope", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrUnsupportedGrantType):
+		return "unsupported_grant_type", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrUnsupportedResponseType):
+		return "unsupported_response_type", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrAccessDenied):
+		return "access_denied", http.StatusForbidden
+	case errors.Is(err, oidcService.ErrAuthorizationPending):
+		return "authorization_pending", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrSlowDown):
+		return "slow_down", http.StatusBadRequest
+	case errors.Is(err, oidcService.ErrExpiredToken):
+		return "expired_token", http.StatusBadRequest
+	default:
+		return "server_error", http.StatusInternalServerError
+	}
+}
+
+func writeOAuthError(w http.ResponseWriter, err error) {
+	code, status := errorCode(err)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Basic")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&oauthError{Error: code})
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, req *model.AuthorizeRequest, err error) {
+	code, _ := errorCode(err)
+
+	params := url.Values{"error": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	http.Redirect(w, r, withQuery(req.RedirectURI, params), http.StatusFound)
+}
+
+func renderLogin(w http.ResponseWriter, status int, req *model.AuthorizeRequest, errMsg string) {
+	params := authorizeParams(req)
+
+	token, err := csrfToken(w, AuthorizePath, authorizeBinding(req))
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, oidcService.ErrInternal.Error())
+		return
+	}
+	params[csrfField] = token
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_ = loginTemplate.Execute(w, map[string]any{
+		"Action": AuthorizePath,
+		"Params": params,
+		"Error":  errMsg,
+	})
+}
+
+// authorizeParams are the parameters of the authorize request that the login
+// form posts back.
+func authorizeParams(req *model.AuthorizeRequest) map[string]string {
+	params := map[string]string{
+		"client_id":     req.ClientId,
+		"redirect_uri":  req.RedirectURI,
+		"response_type": req.ResponseType,
+		"scope":         req.Scope,
+	}
+	for name, value := range map[string]string{
+		"state":                 req.State,
+		"nonce":                 req.Nonce,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+
+	return params
+}
+
+// authorizeBinding is what the CSRF token of the login form binds to.
+func authorizeBinding(req *model.AuthorizeRequest) string {
+	values := url.Values{}
+	for name, value := range authorizeParams(req) {
+		values.Set(name, value)
+	}
+
+	return values.Encode()
+}
+
+func renderError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = errorTemplate.Execute(w, msg)
+}
+
+func withQuery(rawURL string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+
+	return rawURL + sep + params.Encode()
+}
@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/api/http/jwks"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	oidcService "github.com/nogavadu/auth-service/internal/service/oidc"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	AuthorizePath = "/authorize"
+	TokenPath     = "/token"
+	UserInfoPath  = "/userinfo"
+
+	DeviceAuthorizationPath = "/device_authorization"
+	DevicePath              = oidcService.DeviceVerificationPath
+
+	authPrefix = "Bearer "
+)
+
+type Implementation struct {
+	issuer string
+	serv   service.OIDCService
+}
+
+func New(issuer string, oidcService service.OIDCService) *Implementation {
+	return &Implementation{
+		issuer: issuer,
+		serv:   oidcService,
+	}
+}
+
+// Register mounts the discovery document and the authorization, token and
+// userinfo endpoints on mux.
+func (i *Implementation) Register(mux *http.ServeMux) {
+	mux.HandleFunc(DiscoveryPath, i.discovery)
+	mux.HandleFunc(AuthorizePath, i.authorize)
+	mux.HandleFunc(TokenPath, i.token)
+	mux.HandleFunc(UserInfoPath, i.userInfo)
+	mux.HandleFunc(DeviceAuthorizationPath, i.deviceAuthorization)
+	mux.HandleFunc(DevicePath, i.device)
+}
+
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+func (i *Implementation) discovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(&discoveryDocument{
+		Issuer:                            i.issuer,
+		AuthorizationEndpoint:             i.issuer + AuthorizePath,
+		TokenEndpoint:                     i.issuer + TokenPath,
+		UserInfoEndpoint:                  i.issuer + UserInfoPath,
+		DeviceAuthorizationEndpoint:       i.issuer + DeviceAuthorizationPath,
+		JWKSURI:                           i.issuer + jwks.Path,
+		ResponseTypesSupported:            []string{oidcService.ResponseTypeCode},
+		GrantTypesSupported:               []string{oidcService.GrantTypeAuthorizationCode, oidcService.GrantTypeRefreshToken, oidcService.GrantTypeClientCredentials, oidcService.GrantTypeDeviceCode},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"RS256"},
+		ScopesSupported:                   []string{oidcService.ScopeOpenId, oidcService.ScopeEmail, oidcService.ScopeProfile},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oidcService.CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "name", "picture"},
+	})
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}{{if .Error}}<p>{{.Error}}</p>
+{{end}}<label>Email <input type="email" name="email" required></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorization error</title></head>
+<body><p>{{.}}</p></body>
+</html>
+`))
+
+func (i *Implementation) authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		renderError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	req := &model.AuthorizeRequest{
+		ClientId:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		ResponseType:        r.Form.Get("response_type"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		Nonce:               r.Form.Get("nonce"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+	}
+
+	if err := i.serv.ValidateAuthorizeRequest(r.Context(), req); err != nil {
+		// An unknown client or redirect URI must never be redirected to.
+		if errors.Is(err, oidcService.ErrInvalidClient) || errors.Is(err, oidcService.ErrInvalidRedirectURI) {
+			renderError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		redirectError(w, r, req, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderLogin(w, http.StatusOK, req, "")
+		return
+	}
+
+	// The form must come from a login page rendered for this very request.
+	if !checkCSRF(r, authorizeBinding(req)) {
+		renderLogin(w, http.StatusForbidden, req, "the sign-in page has expired, please sign in again")
+		return
+	}
+
+	code, err := i.serv.Authorize(r.Context(), req, r.PostForm.Get("email"), r.PostForm.Get("password"))
+	if err != nil {
+		if errors.Is(err, oidcService.ErrAccessDenied) {
+			renderLogin(w, http.StatusUnauthorized, req, "invalid email or password")
+			return
+		}
+
+		redirectError(w, r, req, err)
+		return
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	http.Redirect(w, r, withQuery(req.RedirectURI, params), http.StatusFound)
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+func (i *Implementation) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, oidcService.ErrInvalidRequest)
+		return
+	}
+
+	req := &model.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientId:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+		DeviceCode:   r.PostForm.Get("device_code"),
+	}
+	if clientId, clientSecret, ok := r.BasicAuth(); ok {
+		req.ClientId, _ = url.QueryUnescape(clientId)
+		req.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+
+	tokens, err := i.serv.Token(r.Context(), req)
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(&tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		RefreshToken: tokens.RefreshToken,
+		IdToken:      tokens.IdToken,
+		Scope:        tokens.Scope,
+	})
+}
+
+type userInfoResponse struct {
+	Sub     string  `json:"sub"`
+	Email   string  `json:"email,omitempty"`
+	Name    *string `json:"name,omitempty"`
+	Picture *string `json:"picture,omitempty"`
+}
+
+func (i *Implementation) userInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, authPrefix) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authorization header is not provided", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := i.serv.UserInfo(r.Context(), strings.TrimPrefix(authHeader, authPrefix))
+	if err != nil {
+		if errors.Is(err, oidcService.ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&userInfoResponse{
+		Sub:     strconv.Itoa(user.Id),
+		Email:   user.Email,
+		Name:    user.Name,
+		Picture: user.Avatar,
+	})
+}
@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"context"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// oidcServ accepts every authorize request and issues the code "code".
+type oidcServ struct {
+	service.OIDCService
+	authorized int
+}
+
+func (s *oidcServ) ValidateAuthorizeRequest(_ context.Context, _ *model.AuthorizeRequest) error {
+	return nil
+}
+
+func (s *oidcServ) Authorize(_ context.Context, _ *model.AuthorizeRequest, _ string, _ string) (string, error) {
+	s.authorized++
+	return "code", nil
+}
+
+var csrfInput = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func TestAuthorizeCSRF(t *testing.T) {
+	request := url.Values{
+		"client_id":     {"app"},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+		"state":         {"xyz"},
+	}
+
+	tests := []struct {
+		name       string
+		noCookie   bool
+		noToken    bool
+		tamper     func(form url.Values)
+		wantStatus int
+	}{
+		{name: "rendered form", wantStatus: http.StatusFound},
+		{name: "no cookie", noCookie: true, wantStatus: http.StatusForbidden},
+		{name: "no token", noToken: true, wantStatus: http.StatusForbidden},
+		{name: "other request", tamper: func(form url.Values) { form.Set("redirect_uri", "https://app.example.com/other") }, wantStatus: http.StatusForbidden},
+		{name: "other state", tamper: func(form url.Values) { form.Set("state", "abc") }, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serv := &oidcServ{}
+			mux := http.NewServeMux()
+			New("https://auth.example.com", serv).Register(mux)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, AuthorizePath+"?"+request.Encode(), nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("login page: got status %d", rec.Code)
+			}
+			match := csrfInput.FindStringSubmatch(rec.Body.String())
+			if match == nil {
+				t.Fatal("no csrf token in the login form")
+			}
+
+			form := url.Values{"email": {"jane@example.com"}, "password": {"secret"}}
+			for name, values := range request {
+				form[name] = values
+			}
+			if !tt.noToken {
+				form.Set(csrfField, match[1])
+			}
+			if tt.tamper != nil {
+				tt.tamper(form)
+			}
+
+			post := httptest.NewRequest(http.MethodPost, AuthorizePath, strings.NewReader(form.Encode()))
+			post.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if !tt.noCookie {
+				for _, cookie := range rec.Result().Cookies() {
+					post.AddCookie(cookie)
+				}
+			}
+
+			rec = httptest.NewRecorder()
+			mux.ServeHTTP(rec, post)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if authorized := serv.authorized == 1; authorized != (tt.wantStatus == http.StatusFound) {
+				t.Errorf("got %d authorizations", serv.authorized)
+			}
+		})
+	}
+}
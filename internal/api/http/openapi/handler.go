@@ -0,0 +1,27 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+const Path = "/openapi.json"
+
+//go:embed api.swagger.json
+var spec []byte
+
+type Implementation struct{}
+
+func New() *Implementation {
+	return &Implementation{}
+}
+
+func (i *Implementation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(spec)
+}
@@ -0,0 +1,43 @@
+package saml
+
+import (
+	"errors"
+	"github.com/nogavadu/auth-service/internal/service"
+	samlService "github.com/nogavadu/auth-service/internal/service/saml"
+	"net/http"
+)
+
+// Path serves the SP metadata of each SAML provider for the IdP to import.
+const Path = "/saml/{provider}/metadata"
+
+type Implementation struct {
+	serv service.SAMLService
+}
+
+func New(samlService service.SAMLService) *Implementation {
+	return &Implementation{
+		serv: samlService,
+	}
+}
+
+func (i *Implementation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	metadata, err := i.serv.Metadata(r.PathValue("provider"))
+	if err != nil {
+		if errors.Is(err, samlService.ErrUnknownProvider) {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(metadata)
+}
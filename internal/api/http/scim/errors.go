@@ -0,0 +1,80 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	scimService "github.com/nogavadu/auth-service/internal/service/scim"
+	"net/http"
+	"strconv"
+)
+
+const (
+	scimTypeInvalidFilter = "invalidFilter"
+	scimTypeInvalidSyntax = "invalidSyntax"
+	scimTypeInvalidValue  = "invalidValue"
+	scimTypeInvalidPath   = "invalidPath"
+	scimTypeUniqueness    = "uniqueness"
+)
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// requestError is a client error found while reading the request.
+type requestError struct {
+	scimType string
+	detail   string
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func badRequest(scimType string, detail string) error {
+	return &requestError{
+		scimType: scimType,
+		detail:   detail,
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, scimType string, detail string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	writeJSON(w, status, &errorResponse{
+		Schemas:  []string{errorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// writeServiceError is the single place where errors are translated to SCIM
+// errors.
+func writeServiceError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		writeError(w, http.StatusBadRequest, reqErr.scimType, reqErr.detail)
+	case errors.Is(err, scimService.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "", err.Error())
+	case errors.Is(err, scimService.ErrNotFound):
+		writeError(w, http.StatusNotFound, "", err.Error())
+	case errors.Is(err, scimService.ErrConflict):
+		writeError(w, http.StatusConflict, scimTypeUniqueness, err.Error())
+	case errors.Is(err, scimService.ErrInvalidValue):
+		writeError(w, http.StatusBadRequest, scimTypeInvalidValue, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "", http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
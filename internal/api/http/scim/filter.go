@@ -0,0 +1,88 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// comparison is one "attribute eq value" of a filter. Attributes are
+// lowercased, as SCIM attribute names are case-insensitive.
+type comparison struct {
+	attr  string
+	value interface{}
+}
+
+// parseFilter parses the subset of the SCIM filter grammar identity
+// providers use to look resources up: equality comparisons joined by "and",
+// e.g. `userName eq "jane@example.com" and active eq true`.
+func parseFilter(filter string) ([]*comparison, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var comparisons []*comparison
+	for len(tokens) > 0 {
+		if len(comparisons) > 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return nil, badRequest(scimTypeInvalidFilter, "only \"and\" is supported to combine comparisons")
+			}
+			tokens = tokens[1:]
+		}
+
+		if len(tokens) < 3 {
+			return nil, badRequest(scimTypeInvalidFilter, "incomplete comparison")
+		}
+		if !strings.EqualFold(tokens[1], "eq") {
+			return nil, badRequest(scimTypeInvalidFilter, "only the \"eq\" operator is supported")
+		}
+
+		var value interface{}
+		if err = json.Unmarshal([]byte(tokens[2]), &value); err != nil {
+			return nil, badRequest(scimTypeInvalidFilter, "invalid value "+tokens[2])
+		}
+
+		comparisons = append(comparisons, &comparison{
+			attr:  strings.ToLower(tokens[0]),
+			value: value,
+		})
+		tokens = tokens[3:]
+	}
+
+	return comparisons, nil
+}
+
+// tokenizeFilter splits the filter on spaces, keeping quoted strings, which
+// stay JSON-encoded, whole.
+func tokenizeFilter(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		switch {
+		case filter[i] == ' ':
+			i++
+		case filter[i] == '"':
+			end := i + 1
+			for ; end < len(filter) && filter[end] != '"'; end++ {
+				if filter[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(filter) {
+				return nil, badRequest(scimTypeInvalidFilter, "unterminated string")
+			}
+
+			tokens = append(tokens, filter[i:end+1])
+			i = end + 1
+		default:
+			end := strings.IndexByte(filter[i:], ' ')
+			if end < 0 {
+				end = len(filter) - i
+			}
+
+			tokens = append(tokens, filter[i:i+end])
+			i += end
+		}
+	}
+
+	return tokens, nil
+}
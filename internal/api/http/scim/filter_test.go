@@ -0,0 +1,68 @@
+package scim
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// scimTypeOf is the scimType of a request error, "" for any other error.
+func scimTypeOf(err error) string {
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		return ""
+	}
+
+	return reqErr.scimType
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       string
+		want         []*comparison
+		wantScimType string
+	}{
+		{name: "empty"},
+		{name: "string", filter: `userName eq "jane@example.com"`, want: []*comparison{{attr: "username", value: "jane@example.com"}}},
+		{name: "boolean", filter: `active eq false`, want: []*comparison{{attr: "active", value: false}}},
+		{
+			name:   "and",
+			filter: `userName eq "jane@example.com" and active eq true`,
+			want:   []*comparison{{attr: "username", value: "jane@example.com"}, {attr: "active", value: true}},
+		},
+		{
+			// Attributes and operators are case-insensitive.
+			name:   "mixed case",
+			filter: `Emails.Value EQ "jane@example.com" AND Active Eq true`,
+			want:   []*comparison{{attr: "emails.value", value: "jane@example.com"}, {attr: "active", value: true}},
+		},
+		{
+			name:   "quoted spaces and quotes",
+			filter: `displayName eq "The \"Core\" Team"`,
+			want:   []*comparison{{attr: "displayname", value: `The "Core" Team`}},
+		},
+		{name: "extra spaces", filter: `  id   eq   "7"  `, want: []*comparison{{attr: "id", value: "7"}}},
+		{name: "or", filter: `userName eq "jane@example.com" or active eq true`, wantScimType: scimTypeInvalidFilter},
+		{name: "contains", filter: `userName co "jane"`, wantScimType: scimTypeInvalidFilter},
+		{name: "starts with", filter: `userName sw "jane"`, wantScimType: scimTypeInvalidFilter},
+		{name: "not equal", filter: `active ne true`, wantScimType: scimTypeInvalidFilter},
+		{name: "present", filter: `userName pr`, wantScimType: scimTypeInvalidFilter},
+		{name: "grouping", filter: `(userName eq "jane@example.com")`, wantScimType: scimTypeInvalidFilter},
+		{name: "not", filter: `not userName eq "jane@example.com"`, wantScimType: scimTypeInvalidFilter},
+		{name: "dangling and", filter: `active eq true and`, wantScimType: scimTypeInvalidFilter},
+		{name: "unquoted string", filter: `userName eq jane`, wantScimType: scimTypeInvalidFilter},
+		{name: "unterminated string", filter: `userName eq "jane`, wantScimType: scimTypeInvalidFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons, err := parseFilter(tt.filter)
+			if got := scimTypeOf(err); got != tt.wantScimType || (err != nil) != (tt.wantScimType != "") {
+				t.Fatalf("got error %v, want scimType %q", err, tt.wantScimType)
+			}
+			if !reflect.DeepEqual(comparisons, tt.want) {
+				t.Errorf("got %v, want %v", comparisons, tt.want)
+			}
+		})
+	}
+}
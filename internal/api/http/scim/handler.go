@@ -0,0 +1,580 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	Path = "/scim/v2"
+
+	UsersPath                 = Path + "/Users"
+	UserPath                  = UsersPath + "/{id}"
+	GroupsPath                = Path + "/Groups"
+	GroupPath                 = GroupsPath + "/{id}"
+	ServiceProviderConfigPath = Path + "/ServiceProviderConfig"
+
+	contentType = "application/scim+json"
+	authPrefix  = "Bearer "
+
+	maxBodySize = 1 << 20
+)
+
+type Implementation struct {
+	serv service.SCIMService
+}
+
+func New(scimService service.SCIMService) *Implementation {
+	return &Implementation{
+		serv: scimService,
+	}
+}
+
+// Register mounts the SCIM 2.0 Users and Groups endpoints on mux.
+func (i *Implementation) Register(mux *http.ServeMux) {
+	mux.HandleFunc(UsersPath, i.authenticated(i.users))
+	mux.HandleFunc(UserPath, i.authenticated(i.user))
+	mux.HandleFunc(GroupsPath, i.authenticated(i.groups))
+	mux.HandleFunc(GroupPath, i.authenticated(i.group))
+	mux.HandleFunc(ServiceProviderConfigPath, i.authenticated(i.serviceProviderConfig))
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, client *model.SCIMClient)
+
+// authenticated resolves the SCIM client from the bearer token before
+// calling next.
+func (i *Implementation) authenticated(next handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, authPrefix) {
+			writeError(w, http.StatusUnauthorized, "", "bearer token required")
+			return
+		}
+
+		client, err := i.serv.Authenticate(strings.TrimPrefix(header, authPrefix))
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		next(w, r, client)
+	}
+}
+
+func (i *Implementation) users(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	switch r.Method {
+	case http.MethodGet:
+		i.listUsers(w, r, client)
+	case http.MethodPost:
+		i.createUser(w, r, client)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (i *Implementation) user(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	id, ok := resourceId(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "", "resource not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := i.serv.GetUser(r.Context(), client, id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toUserResource(baseURL(r), user))
+	case http.MethodPut:
+		i.replaceUser(w, r, client, id)
+	case http.MethodPatch:
+		i.patchUser(w, r, client, id)
+	case http.MethodDelete:
+		if err := i.serv.DeleteUser(r.Context(), client, id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (i *Implementation) listUsers(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	filter, err := userFilterFromQuery(r)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	var page *model.SCIMUserPage
+	if filter != nil {
+		page, err = i.serv.ListUsers(r.Context(), client, filter)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	} else {
+		page = &model.SCIMUserPage{}
+	}
+
+	base := baseURL(r)
+	resources := make([]interface{}, 0, len(page.Users))
+	for _, user := range page.Users {
+		resources = append(resources, toUserResource(base, user))
+	}
+
+	writeJSON(w, http.StatusOK, newListResponse(page.Total, startIndex(r), resources))
+}
+
+func (i *Implementation) createUser(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	var resource userResource
+	if err := decodeBody(w, r, &resource); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	input, err := resource.input()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	user, err := i.serv.CreateUser(r.Context(), client, input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	res := toUserResource(baseURL(r), user)
+	w.Header().Set("Location", res.Meta.Location)
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// replaceUser handles PUT, which sets every attribute this service keeps: an
+// omitted name is cleared.
+func (i *Implementation) replaceUser(w http.ResponseWriter, r *http.Request, client *model.SCIMClient, id int) {
+	var resource userResource
+	if err := decodeBody(w, r, &resource); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	input, err := resource.input()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	patch := &model.SCIMUserPatch{
+		Email:     &input.Email,
+		Name:      input.Name,
+		ClearName: input.Name == nil,
+		Password:  input.Password,
+		Active:    &input.Active,
+	}
+
+	user, err := i.serv.PatchUser(r.Context(), client, id, patch)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUserResource(baseURL(r), user))
+}
+
+func (i *Implementation) patchUser(w http.ResponseWriter, r *http.Request, client *model.SCIMClient, id int) {
+	var req patchRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	patch, err := req.userPatch()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	user, err := i.serv.PatchUser(r.Context(), client, id, patch)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUserResource(baseURL(r), user))
+}
+
+func (i *Implementation) groups(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	switch r.Method {
+	case http.MethodGet:
+		i.listGroups(w, r, client)
+	case http.MethodPost:
+		i.createGroup(w, r, client)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (i *Implementation) group(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	id, ok := resourceId(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "", "resource not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		group, err := i.serv.GetGroup(r.Context(), client, id, withMembers(r))
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toGroupResource(baseURL(r), group))
+	case http.MethodPut:
+		i.replaceGroup(w, r, client, id)
+	case http.MethodPatch:
+		i.patchGroup(w, r, client, id)
+	case http.MethodDelete:
+		if err := i.serv.DeleteGroup(r.Context(), client, id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (i *Implementation) listGroups(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	filter, err := groupFilterFromQuery(r)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	var page *model.SCIMGroupPage
+	if filter != nil {
+		page, err = i.serv.ListGroups(r.Context(), client, filter)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	} else {
+		page = &model.SCIMGroupPage{}
+	}
+
+	base := baseURL(r)
+	resources := make([]interface{}, 0, len(page.Groups))
+	for _, group := range page.Groups {
+		resources = append(resources, toGroupResource(base, group))
+	}
+
+	writeJSON(w, http.StatusOK, newListResponse(page.Total, startIndex(r), resources))
+}
+
+func (i *Implementation) createGroup(w http.ResponseWriter, r *http.Request, client *model.SCIMClient) {
+	var resource groupResource
+	if err := decodeBody(w, r, &resource); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	name, members, err := resource.input()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	group, err := i.serv.CreateGroup(r.Context(), client, name, members)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	res := toGroupResource(baseURL(r), group)
+	w.Header().Set("Location", res.Meta.Location)
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// replaceGroup handles PUT: the group gets the given name and exactly the
+// given members.
+func (i *Implementation) replaceGroup(w http.ResponseWriter, r *http.Request, client *model.SCIMClient, id int) {
+	var resource groupResource
+	if err := decodeBody(w, r, &resource); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	name, members, err := resource.input()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	patch := &model.SCIMGroupPatch{
+		Name: &name,
+		Members: []*model.SCIMMembersChange{
+			{Op: model.SCIMMembersReplace, UserIds: members},
+		},
+	}
+
+	i.applyGroupPatch(w, r, client, id, patch)
+}
+
+func (i *Implementation) patchGroup(w http.ResponseWriter, r *http.Request, client *model.SCIMClient, id int) {
+	var req patchRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	patch, err := req.groupPatch()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	i.applyGroupPatch(w, r, client, id, patch)
+}
+
+func (i *Implementation) applyGroupPatch(w http.ResponseWriter, r *http.Request, client *model.SCIMClient, id int, patch *model.SCIMGroupPatch) {
+	group, err := i.serv.PatchGroup(r.Context(), client, id, patch)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	// PatchGroup leaves the members out, as large groups are expensive to
+	// list; they are read again only when the client wants them.
+	if withMembers(r) {
+		group, err = i.serv.GetGroup(r.Context(), client, id, true)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toGroupResource(baseURL(r), group))
+}
+
+func (i *Implementation) serviceProviderConfig(w http.ResponseWriter, r *http.Request, _ *model.SCIMClient) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newServiceProviderConfig())
+}
+
+// userFilterFromQuery reads the filter and paging parameters of a Users
+// listing. It returns nil when the filter cannot match anything, such as an
+// id that is not a number.
+func userFilterFromQuery(r *http.Request) (*model.SCIMUserFilter, error) {
+	filter := &model.SCIMUserFilter{
+		StartIndex: startIndex(r),
+	}
+
+	var err error
+	if filter.Count, err = count(r); err != nil {
+		return nil, err
+	}
+
+	comparisons, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range comparisons {
+		switch c.attr {
+		case "username", "emails", "emails.value":
+			email, ok := c.value.(string)
+			if !ok {
+				return nil, badRequest(scimTypeInvalidFilter, c.attr+" must be compared to a string")
+			}
+			if filter.Email != "" && !strings.EqualFold(filter.Email, email) {
+				return nil, nil
+			}
+
+			filter.Email = email
+		case "active":
+			active, ok := c.value.(bool)
+			if !ok {
+				return nil, badRequest(scimTypeInvalidFilter, "active must be compared to a boolean")
+			}
+			if filter.Active != nil && *filter.Active != active {
+				return nil, nil
+			}
+
+			filter.Active = &active
+		case "id":
+			id, ok := idValue(c.value)
+			if !ok || (filter.Id != nil && *filter.Id != id) {
+				return nil, nil
+			}
+
+			filter.Id = &id
+		default:
+			return nil, badRequest(scimTypeInvalidFilter, "filtering on "+c.attr+" is not supported")
+		}
+	}
+
+	return filter, nil
+}
+
+// groupFilterFromQuery is userFilterFromQuery for Groups listings.
+func groupFilterFromQuery(r *http.Request) (*model.SCIMGroupFilter, error) {
+	filter := &model.SCIMGroupFilter{
+		StartIndex:  startIndex(r),
+		WithMembers: withMembers(r),
+	}
+
+	var err error
+	if filter.Count, err = count(r); err != nil {
+		return nil, err
+	}
+
+	comparisons, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range comparisons {
+		switch c.attr {
+		case "displayname":
+			name, ok := c.value.(string)
+			if !ok {
+				return nil, badRequest(scimTypeInvalidFilter, "displayName must be compared to a string")
+			}
+			if filter.Name != "" && !strings.EqualFold(filter.Name, name) {
+				return nil, nil
+			}
+
+			filter.Name = name
+		case "id":
+			id, ok := idValue(c.value)
+			if !ok || (filter.Id != nil && *filter.Id != id) {
+				return nil, nil
+			}
+
+			filter.Id = &id
+		default:
+			return nil, badRequest(scimTypeInvalidFilter, "filtering on "+c.attr+" is not supported")
+		}
+	}
+
+	return filter, nil
+}
+
+// startIndex is the 1-based index of the first result; SCIM treats values
+// below 1 as 1.
+func startIndex(r *http.Request) int {
+	index, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || index < 1 {
+		return 1
+	}
+
+	return index
+}
+
+func count(r *http.Request) (*int, error) {
+	value := r.URL.Query().Get("count")
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, badRequest(scimTypeInvalidValue, "count must be a number")
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	return &n, nil
+}
+
+// withMembers reports whether the client left members in the response,
+// which large groups make expensive.
+func withMembers(r *http.Request) bool {
+	for _, attrs := range r.URL.Query()["excludedAttributes"] {
+		for _, attr := range strings.Split(attrs, ",") {
+			if strings.EqualFold(strings.TrimSpace(attr), "members") {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func resourceId(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// idValue reads a resource id, which SCIM carries as a string.
+func idValue(value interface{}) (int, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			return err
+		}
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return badRequest(scimTypeInvalidSyntax, "request body too large")
+		}
+
+		return badRequest(scimTypeInvalidSyntax, "invalid JSON body")
+	}
+
+	return nil
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "", http.StatusText(http.StatusMethodNotAllowed))
+}
+
+// baseURL is the absolute URL of the SCIM root, for resource locations.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host + Path
+}
@@ -0,0 +1,216 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	scimService "github.com/nogavadu/auth-service/internal/service/scim"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scimServ knows the token "idp-token" and user 7, and records the filters
+// and patches it is given.
+type scimServ struct {
+	service.SCIMService
+	createErr error
+	filter    *model.SCIMUserFilter
+	patch     *model.SCIMUserPatch
+}
+
+func (s *scimServ) Authenticate(token string) (*model.SCIMClient, error) {
+	if token != "idp-token" {
+		return nil, scimService.ErrUnauthorized
+	}
+
+	return &model.SCIMClient{Name: "idp", MaxRoleLevel: 10}, nil
+}
+
+func (s *scimServ) CreateUser(_ context.Context, _ *model.SCIMClient, _ *model.SCIMUserInput) (*model.SCIMUser, error) {
+	return nil, s.createErr
+}
+
+func (s *scimServ) ListUsers(_ context.Context, _ *model.SCIMClient, filter *model.SCIMUserFilter) (*model.SCIMUserPage, error) {
+	s.filter = filter
+	return &model.SCIMUserPage{}, nil
+}
+
+func (s *scimServ) PatchUser(_ context.Context, _ *model.SCIMClient, id int, patch *model.SCIMUserPatch) (*model.SCIMUser, error) {
+	if id != 7 {
+		return nil, scimService.ErrNotFound
+	}
+	s.patch = patch
+
+	return &model.SCIMUser{User: model.User{Id: id, UserInfo: model.UserInfo{Email: "jane@example.com"}}}, nil
+}
+
+// serve sends the request with the given bearer token, none when it is
+// empty.
+func serve(serv *scimServ, method string, target string, body string, token string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	New(serv).Register(mux)
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+
+	return rec
+}
+
+func TestErrorResponse(t *testing.T) {
+	const userBody = `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "jane@example.com"}`
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		token        string
+		createErr    error
+		wantStatus   int
+		wantScimType string
+	}{
+		{name: "no token", method: http.MethodGet, target: UsersPath, wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, target: UsersPath, token: "bogus", wantStatus: http.StatusUnauthorized},
+		{name: "unsupported filter", method: http.MethodGet, target: UsersPath + `?filter=userName+co+"jane"`, wantStatus: http.StatusBadRequest, wantScimType: scimTypeInvalidFilter},
+		{name: "invalid json", method: http.MethodPost, target: UsersPath, body: `{"userName":`, wantStatus: http.StatusBadRequest, wantScimType: scimTypeInvalidSyntax},
+		{name: "invalid patch", method: http.MethodPatch, target: UsersPath + "/7", body: `{"Operations": [{"op": "remove"}]}`, wantStatus: http.StatusBadRequest, wantScimType: scimTypeInvalidPath},
+		{name: "not found", method: http.MethodPatch, target: UsersPath + "/8", body: `{"Operations": [{"op": "replace", "path": "active", "value": false}]}`, wantStatus: http.StatusNotFound},
+		{name: "conflict", method: http.MethodPost, target: UsersPath, body: userBody, createErr: scimService.ErrConflict, wantStatus: http.StatusConflict, wantScimType: scimTypeUniqueness},
+		{name: "invalid value", method: http.MethodPost, target: UsersPath, body: userBody, createErr: scimService.ErrInvalidValue, wantStatus: http.StatusBadRequest, wantScimType: scimTypeInvalidValue},
+		{name: "internal", method: http.MethodPost, target: UsersPath, body: userBody, createErr: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+		{name: "method not allowed", method: http.MethodDelete, target: UsersPath, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.token
+			if token == "" && tt.wantStatus != http.StatusUnauthorized {
+				token = "idp-token"
+			}
+			rec := serve(&scimServ{createErr: tt.createErr}, tt.method, tt.target, tt.body, token)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != contentType {
+				t.Errorf("got content type %q, want %q", got, contentType)
+			}
+			wantChallenge := ""
+			if tt.wantStatus == http.StatusUnauthorized {
+				wantChallenge = "Bearer"
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != wantChallenge {
+				t.Errorf("got challenge %q, want %q", got, wantChallenge)
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body["schemas"], []interface{}{errorSchema}) {
+				t.Errorf("got schemas %v, want [%s]", body["schemas"], errorSchema)
+			}
+			// SCIM carries the status as a string.
+			if status := body["status"]; status != strconv.Itoa(tt.wantStatus) {
+				t.Errorf("got status %#v, want %q", status, strconv.Itoa(tt.wantStatus))
+			}
+			if scimType, _ := body["scimType"].(string); scimType != tt.wantScimType {
+				t.Errorf("got scimType %q, want %q", scimType, tt.wantScimType)
+			}
+			if detail, _ := body["detail"].(string); detail == "" {
+				t.Error("got no detail")
+			}
+		})
+	}
+}
+
+func TestListUsersFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		// want is nil when the service is not asked, as the filter cannot
+		// match.
+		want         *model.SCIMUserFilter
+		wantScimType string
+	}{
+		{name: "none", want: &model.SCIMUserFilter{StartIndex: 1}},
+		{name: "userName", filter: `userName eq "jane@example.com"`, want: &model.SCIMUserFilter{Email: "jane@example.com", StartIndex: 1}},
+		{name: "emails", filter: `emails eq "jane@example.com"`, want: &model.SCIMUserFilter{Email: "jane@example.com", StartIndex: 1}},
+		{
+			name:   "emails.value and active",
+			filter: `emails.value eq "jane@example.com" and active eq false`,
+			want:   &model.SCIMUserFilter{Email: "jane@example.com", Active: ptr(false), StartIndex: 1},
+		},
+		{
+			name:   "same email twice",
+			filter: `userName eq "jane@example.com" and emails.value eq "Jane@Example.com"`,
+			want:   &model.SCIMUserFilter{Email: "Jane@Example.com", StartIndex: 1},
+		},
+		{name: "id", filter: `id eq "7"`, want: &model.SCIMUserFilter{Id: ptr(7), StartIndex: 1}},
+		{name: "other emails", filter: `userName eq "jane@example.com" and emails eq "john@example.com"`},
+		{name: "contradicting active", filter: `active eq true and active eq false`},
+		{name: "id that is no number", filter: `id eq "jane"`},
+		{name: "email compared to a boolean", filter: `userName eq true`, wantScimType: scimTypeInvalidFilter},
+		{name: "active compared to a string", filter: `active eq "yes"`, wantScimType: scimTypeInvalidFilter},
+		{name: "unsupported path", filter: `name.familyName eq "Doe"`, wantScimType: scimTypeInvalidFilter},
+		{name: "externalId", filter: `externalId eq "abc"`, wantScimType: scimTypeInvalidFilter},
+		{name: "unsupported operator", filter: `userName sw "jane"`, wantScimType: scimTypeInvalidFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serv := &scimServ{}
+			rec := serve(serv, http.MethodGet, UsersPath+"?"+url.Values{"filter": {tt.filter}}.Encode(), "", "idp-token")
+
+			if tt.wantScimType != "" {
+				if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"scimType":"`+tt.wantScimType+`"`) {
+					t.Fatalf("got status %d and body %s, want a %s error", rec.Code, rec.Body, tt.wantScimType)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusOK)
+			}
+			if !reflect.DeepEqual(serv.filter, tt.want) {
+				t.Errorf("got filter %+v, want %+v", serv.filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	serv := &scimServ{}
+	rec := serve(serv, http.MethodPatch, UsersPath+"/7", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "Add", "value": {"name": {"givenName": "Jane", "familyName": "Doe"}}},
+			{"op": "Remove", "path": "emails"}
+		]
+	}`, "idp-token")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d and body %s", rec.Code, rec.Body)
+	}
+	if want := (&model.SCIMUserPatch{Active: ptr(false), Name: ptr("Jane Doe")}); !reflect.DeepEqual(serv.patch, want) {
+		t.Errorf("got patch %+v, want %+v", serv.patch, want)
+	}
+
+	var user userResource
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != "7" || user.UserName != "jane@example.com" || user.Meta.Location != "http://example.com"+UsersPath+"/7" {
+		t.Errorf("got user %+v", user)
+	}
+}
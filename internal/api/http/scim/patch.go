@@ -0,0 +1,271 @@
+package scim
+
+import (
+	"encoding/json"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"strings"
+)
+
+const (
+	patchOpAdd     = "add"
+	patchOpReplace = "replace"
+	patchOpRemove  = "remove"
+)
+
+type patchRequest struct {
+	Schemas    []string          `json:"schemas"`
+	Operations []*patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// operations checks the operations and normalizes their ops, which some
+// identity providers capitalize.
+func (p *patchRequest) operations() ([]*patchOperation, error) {
+	if len(p.Operations) == 0 {
+		return nil, badRequest(scimTypeInvalidValue, "no operations")
+	}
+
+	for _, op := range p.Operations {
+		op.Op = strings.ToLower(op.Op)
+		switch op.Op {
+		case patchOpAdd, patchOpReplace:
+			if op.Value == nil {
+				return nil, badRequest(scimTypeInvalidValue, op.Op+" requires a value")
+			}
+		case patchOpRemove:
+			if op.Path == "" {
+				return nil, badRequest(scimTypeInvalidPath, "remove requires a path")
+			}
+		default:
+			return nil, badRequest(scimTypeInvalidValue, "unsupported op "+op.Op)
+		}
+	}
+
+	return p.Operations, nil
+}
+
+// userPatch turns the operations into a user patch. Attributes the service
+// does not keep are ignored, so that identity providers can send their
+// usual mappings.
+func (p *patchRequest) userPatch() (*model.SCIMUserPatch, error) {
+	ops, err := p.operations()
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &model.SCIMUserPatch{}
+	for _, op := range ops {
+		if op.Path != "" {
+			err = applyUserAttribute(patch, op.Op, op.Path, op.Value)
+		} else {
+			err = forEachAttribute(op.Value, func(attr string, value json.RawMessage) error {
+				return applyUserAttribute(patch, op.Op, attr, value)
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return patch, nil
+}
+
+func applyUserAttribute(patch *model.SCIMUserPatch, op string, attr string, value json.RawMessage) error {
+	attr = strings.ToLower(attr)
+	if op == patchOpRemove {
+		switch attr {
+		case "displayname", "name", "name.formatted":
+			patch.Name = nil
+			patch.ClearName = true
+			return nil
+		case "username", "active", "password":
+			return badRequest(scimTypeInvalidValue, attr+" cannot be removed")
+		default:
+			return nil
+		}
+	}
+
+	switch attr {
+	case "username":
+		var userName string
+		if err := json.Unmarshal(value, &userName); err != nil {
+			return badRequest(scimTypeInvalidValue, "userName must be a string")
+		}
+
+		email, err := emailValue(userName)
+		if err != nil {
+			return err
+		}
+
+		patch.Email = &email
+	case "active":
+		var raw interface{}
+		_ = json.Unmarshal(value, &raw)
+
+		active, ok := boolValue(raw)
+		if !ok {
+			return badRequest(scimTypeInvalidValue, "active must be a boolean")
+		}
+
+		patch.Active = &active
+	case "displayname", "name.formatted":
+		var name string
+		if err := json.Unmarshal(value, &name); err != nil {
+			return badRequest(scimTypeInvalidValue, attr+" must be a string")
+		}
+
+		setName(patch, name)
+	case "name":
+		var name nameValue
+		if err := json.Unmarshal(value, &name); err != nil {
+			return badRequest(scimTypeInvalidValue, "name must be an object")
+		}
+
+		resource := &userResource{Name: &name}
+		if formatted := resource.displayName(); formatted != nil {
+			setName(patch, *formatted)
+		}
+	case "password":
+		var password string
+		if err := json.Unmarshal(value, &password); err != nil || password == "" {
+			return badRequest(scimTypeInvalidValue, "password must be a non-empty string")
+		}
+
+		patch.Password = &password
+	}
+
+	return nil
+}
+
+func setName(patch *model.SCIMUserPatch, name string) {
+	if name == "" {
+		patch.Name = nil
+		patch.ClearName = true
+		return
+	}
+
+	patch.Name = &name
+	patch.ClearName = false
+}
+
+// groupPatch turns the operations into a group patch. Members can be
+// removed one at a time with a `members[value eq "id"]` path.
+func (p *patchRequest) groupPatch() (*model.SCIMGroupPatch, error) {
+	ops, err := p.operations()
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &model.SCIMGroupPatch{}
+	for _, op := range ops {
+		if op.Path != "" {
+			err = applyGroupAttribute(patch, op.Op, op.Path, op.Value)
+		} else {
+			err = forEachAttribute(op.Value, func(attr string, value json.RawMessage) error {
+				return applyGroupAttribute(patch, op.Op, attr, value)
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return patch, nil
+}
+
+func applyGroupAttribute(patch *model.SCIMGroupPatch, op string, attr string, value json.RawMessage) error {
+	if memberFilter, ok := strings.CutPrefix(attr, "members["); ok {
+		return removeFilteredMember(patch, op, memberFilter)
+	}
+
+	switch strings.ToLower(attr) {
+	case "displayname":
+		if op == patchOpRemove {
+			return badRequest(scimTypeInvalidValue, "displayName cannot be removed")
+		}
+
+		var name string
+		if err := json.Unmarshal(value, &name); err != nil || strings.TrimSpace(name) == "" {
+			return badRequest(scimTypeInvalidValue, "displayName must be a non-empty string")
+		}
+
+		patch.Name = &name
+	case "members":
+		change := &model.SCIMMembersChange{
+			Op: op,
+		}
+
+		// Removing members without saying which removes them all.
+		if op != patchOpRemove || !isNull(value) {
+			var members []*memberValue
+			if err := json.Unmarshal(value, &members); err != nil {
+				return badRequest(scimTypeInvalidValue, "members must be a list")
+			}
+
+			var err error
+			if change.UserIds, err = memberIds(members); err != nil {
+				return err
+			}
+		}
+
+		patch.Members = append(patch.Members, change)
+	default:
+		return badRequest(scimTypeInvalidPath, "unsupported path "+attr)
+	}
+
+	return nil
+}
+
+// removeFilteredMember handles the `members[value eq "id"]` path, given
+// without its "members[" prefix.
+func removeFilteredMember(patch *model.SCIMGroupPatch, op string, memberFilter string) error {
+	memberFilter, ok := strings.CutSuffix(memberFilter, "]")
+	if !ok || op != patchOpRemove {
+		return badRequest(scimTypeInvalidPath, "member filters are only supported to remove a member")
+	}
+
+	comparisons, err := parseFilter(memberFilter)
+	if err != nil {
+		return err
+	}
+	if len(comparisons) != 1 || comparisons[0].attr != "value" {
+		return badRequest(scimTypeInvalidFilter, "members can only be filtered on value")
+	}
+
+	userId, ok := idValue(comparisons[0].value)
+	if !ok {
+		return badRequest(scimTypeInvalidValue, "invalid member")
+	}
+
+	patch.Members = append(patch.Members, &model.SCIMMembersChange{
+		Op:      model.SCIMMembersRemove,
+		UserIds: []int{userId},
+	})
+	return nil
+}
+
+// forEachAttribute calls fn with each attribute of a path-less operation,
+// whose value is an object of the attributes to change.
+func forEachAttribute(value json.RawMessage, fn func(attr string, value json.RawMessage) error) error {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(value, &attrs); err != nil {
+		return badRequest(scimTypeInvalidValue, "value must be an object when there is no path")
+	}
+
+	for attr, v := range attrs {
+		if err := fn(attr, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return len(value) == 0 || string(value) == "null"
+}
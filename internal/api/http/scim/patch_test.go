@@ -0,0 +1,144 @@
+package scim
+
+import (
+	"encoding/json"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"reflect"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func decodePatch(t *testing.T, body string) *patchRequest {
+	t.Helper()
+
+	var req patchRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	return &req
+}
+
+func TestUserPatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		operations   string
+		want         *model.SCIMUserPatch
+		wantScimType string
+	}{
+		{name: "replace active", operations: `[{"op": "replace", "path": "active", "value": false}]`, want: &model.SCIMUserPatch{Active: ptr(false)}},
+		{
+			// As Azure AD sends it.
+			name:       "capitalized op and string boolean",
+			operations: `[{"op": "Replace", "path": "active", "value": "False"}]`,
+			want:       &model.SCIMUserPatch{Active: ptr(false)},
+		},
+		{name: "add active", operations: `[{"op": "add", "path": "active", "value": true}]`, want: &model.SCIMUserPatch{Active: ptr(true)}},
+		{name: "remove active", operations: `[{"op": "remove", "path": "active"}]`, wantScimType: scimTypeInvalidValue},
+		{name: "active not a boolean", operations: `[{"op": "replace", "path": "active", "value": "maybe"}]`, wantScimType: scimTypeInvalidValue},
+		{
+			name:       "replace without a path",
+			operations: `[{"op": "replace", "value": {"active": false, "userName": "john@example.com"}}]`,
+			want:       &model.SCIMUserPatch{Active: ptr(false), Email: ptr("john@example.com")},
+		},
+		{name: "userName not an email", operations: `[{"op": "replace", "path": "userName", "value": "john"}]`, wantScimType: scimTypeInvalidValue},
+		{name: "remove userName", operations: `[{"op": "remove", "path": "userName"}]`, wantScimType: scimTypeInvalidValue},
+		{
+			// A user has a single email, their userName, so emails are
+			// ignored.
+			name:       "replace emails",
+			operations: `[{"op": "replace", "path": "emails", "value": [{"value": "john@example.com", "primary": true}]}]`,
+			want:       &model.SCIMUserPatch{},
+		},
+		{
+			name:       "add emails",
+			operations: `[{"op": "add", "path": "emails[type eq \"work\"].value", "value": "john@example.com"}]`,
+			want:       &model.SCIMUserPatch{},
+		},
+		{name: "remove emails", operations: `[{"op": "remove", "path": "emails"}]`, want: &model.SCIMUserPatch{}},
+		{name: "replace displayName", operations: `[{"op": "replace", "path": "displayName", "value": "Jane Doe"}]`, want: &model.SCIMUserPatch{Name: ptr("Jane Doe")}},
+		{name: "replace name.formatted", operations: `[{"op": "replace", "path": "name.formatted", "value": "Jane Doe"}]`, want: &model.SCIMUserPatch{Name: ptr("Jane Doe")}},
+		{
+			name:       "add name parts",
+			operations: `[{"op": "add", "path": "name", "value": {"givenName": "Jane", "familyName": "Doe"}}]`,
+			want:       &model.SCIMUserPatch{Name: ptr("Jane Doe")},
+		},
+		{name: "replace with an empty name", operations: `[{"op": "replace", "path": "displayName", "value": ""}]`, want: &model.SCIMUserPatch{ClearName: true}},
+		{name: "remove name", operations: `[{"op": "remove", "path": "name"}]`, want: &model.SCIMUserPatch{ClearName: true}},
+		{
+			name:       "remove then set the name",
+			operations: `[{"op": "remove", "path": "displayName"}, {"op": "add", "path": "displayName", "value": "Jane"}]`,
+			want:       &model.SCIMUserPatch{Name: ptr("Jane")},
+		},
+		{name: "name not an object", operations: `[{"op": "replace", "path": "name", "value": "Jane"}]`, wantScimType: scimTypeInvalidValue},
+		{name: "replace password", operations: `[{"op": "replace", "path": "password", "value": "s3cret"}]`, want: &model.SCIMUserPatch{Password: ptr("s3cret")}},
+		{name: "unknown attribute", operations: `[{"op": "replace", "path": "title", "value": "CTO"}]`, want: &model.SCIMUserPatch{}},
+		{name: "no operations", operations: `[]`, wantScimType: scimTypeInvalidValue},
+		{name: "unsupported op", operations: `[{"op": "move", "path": "active", "value": true}]`, wantScimType: scimTypeInvalidValue},
+		{name: "replace without a value", operations: `[{"op": "replace", "path": "active"}]`, wantScimType: scimTypeInvalidValue},
+		{name: "remove without a path", operations: `[{"op": "remove", "value": {"active": true}}]`, wantScimType: scimTypeInvalidPath},
+		{name: "value not an object without a path", operations: `[{"op": "replace", "value": false}]`, wantScimType: scimTypeInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := decodePatch(t, `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": `+tt.operations+`}`)
+
+			patch, err := req.userPatch()
+			if got := scimTypeOf(err); got != tt.wantScimType || (err != nil) != (tt.wantScimType != "") {
+				t.Fatalf("got error %v, want scimType %q", err, tt.wantScimType)
+			}
+			if !reflect.DeepEqual(patch, tt.want) {
+				t.Errorf("got patch %+v, want %+v", patch, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupPatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		operations   string
+		want         *model.SCIMGroupPatch
+		wantScimType string
+	}{
+		{name: "rename", operations: `[{"op": "replace", "path": "displayName", "value": "Support"}]`, want: &model.SCIMGroupPatch{Name: ptr("Support")}},
+		{name: "empty name", operations: `[{"op": "replace", "path": "displayName", "value": " "}]`, wantScimType: scimTypeInvalidValue},
+		{name: "remove name", operations: `[{"op": "remove", "path": "displayName"}]`, wantScimType: scimTypeInvalidValue},
+		{
+			name:       "add members",
+			operations: `[{"op": "add", "path": "members", "value": [{"value": "7"}, {"value": "8"}]}]`,
+			want:       &model.SCIMGroupPatch{Members: []*model.SCIMMembersChange{{Op: model.SCIMMembersAdd, UserIds: []int{7, 8}}}},
+		},
+		{
+			name:       "remove all members",
+			operations: `[{"op": "remove", "path": "members"}]`,
+			want:       &model.SCIMGroupPatch{Members: []*model.SCIMMembersChange{{Op: model.SCIMMembersRemove}}},
+		},
+		{
+			name:       "remove a filtered member",
+			operations: `[{"op": "remove", "path": "members[value eq \"7\"]"}]`,
+			want:       &model.SCIMGroupPatch{Members: []*model.SCIMMembersChange{{Op: model.SCIMMembersRemove, UserIds: []int{7}}}},
+		},
+		{name: "replace a filtered member", operations: `[{"op": "replace", "path": "members[value eq \"7\"]", "value": {"value": "8"}}]`, wantScimType: scimTypeInvalidPath},
+		{name: "member filtered on display", operations: `[{"op": "remove", "path": "members[display eq \"jane\"]"}]`, wantScimType: scimTypeInvalidFilter},
+		{name: "member filter with another operator", operations: `[{"op": "remove", "path": "members[value co \"7\"]"}]`, wantScimType: scimTypeInvalidFilter},
+		{name: "invalid member", operations: `[{"op": "add", "path": "members", "value": [{"value": "jane"}]}]`, wantScimType: scimTypeInvalidValue},
+		{name: "unsupported path", operations: `[{"op": "replace", "path": "externalId", "value": "x"}]`, wantScimType: scimTypeInvalidPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := decodePatch(t, `{"Operations": `+tt.operations+`}`)
+
+			patch, err := req.groupPatch()
+			if got := scimTypeOf(err); got != tt.wantScimType || (err != nil) != (tt.wantScimType != "") {
+				t.Fatalf("got error %v, want scimType %q", err, tt.wantScimType)
+			}
+			if !reflect.DeepEqual(patch, tt.want) {
+				t.Errorf("got patch %+v, want %+v", patch, tt.want)
+			}
+		})
+	}
+}
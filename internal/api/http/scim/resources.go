@@ -0,0 +1,302 @@
+package scim
+
+import (
+	"encoding/json"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	userSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	spConfigSchema     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+type userResource struct {
+	Schemas     []string       `json:"schemas"`
+	Id          string         `json:"id,omitempty"`
+	UserName    string         `json:"userName"`
+	Name        *nameValue     `json:"name,omitempty"`
+	DisplayName *string        `json:"displayName,omitempty"`
+	Emails      []*multiValue  `json:"emails,omitempty"`
+	Active      *flexBool      `json:"active,omitempty"`
+	Password    *string        `json:"password,omitempty"`
+	Groups      []*memberValue `json:"groups,omitempty"`
+	Meta        *resourceMeta  `json:"meta,omitempty"`
+}
+
+type nameValue struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type multiValue struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// memberValue references a user from a group or a group from a user.
+type memberValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type resourceMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location"`
+}
+
+// flexBool is a boolean that some identity providers send as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, ok := boolValue(value)
+	if !ok {
+		return badRequest(scimTypeInvalidValue, "invalid boolean "+string(data))
+	}
+
+	*b = flexBool(parsed)
+	return nil
+}
+
+func boolValue(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+// input reads a user sent to be created or replaced. The user name is the
+// email; emails are ignored, as a user has a single one.
+func (u *userResource) input() (*model.SCIMUserInput, error) {
+	email, err := emailValue(u.UserName)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &model.SCIMUserInput{
+		Email:    email,
+		Name:     u.displayName(),
+		Password: u.Password,
+		Active:   true,
+	}
+	if u.Active != nil {
+		input.Active = bool(*u.Active)
+	}
+
+	return input, nil
+}
+
+// displayName picks the name of the user out of the ways SCIM can carry it.
+func (u *userResource) displayName() *string {
+	if u.DisplayName != nil && *u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name == nil {
+		return nil
+	}
+
+	name := u.Name.Formatted
+	if name == "" {
+		name = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+	if name == "" {
+		return nil
+	}
+
+	return &name
+}
+
+func emailValue(value string) (string, error) {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		return "", badRequest(scimTypeInvalidValue, "userName must be an email address")
+	}
+
+	return value, nil
+}
+
+func toUserResource(base string, user *model.SCIMUser) *userResource {
+	id := strconv.Itoa(user.Id)
+	active := flexBool(user.Status == model.UserStatusActive)
+
+	res := &userResource{
+		Schemas:     []string{userSchema},
+		Id:          id,
+		UserName:    user.Email,
+		DisplayName: user.Name,
+		Emails: []*multiValue{
+			{Value: user.Email, Primary: true},
+		},
+		Active: &active,
+		Meta: &resourceMeta{
+			ResourceType: "User",
+			Created:      &user.CreatedAt,
+			LastModified: &user.UpdatedAt,
+			Location:     base + "/Users/" + id,
+		},
+	}
+	if user.Name != nil {
+		res.Name = &nameValue{
+			Formatted: *user.Name,
+		}
+	}
+	if user.Group != nil {
+		groupId := strconv.Itoa(user.Group.Id)
+		res.Groups = []*memberValue{
+			{Value: groupId, Display: user.Group.Name, Ref: base + "/Groups/" + groupId},
+		}
+	}
+
+	return res
+}
+
+type groupResource struct {
+	Schemas     []string       `json:"schemas"`
+	Id          string         `json:"id,omitempty"`
+	DisplayName string         `json:"displayName"`
+	Members     []*memberValue `json:"members,omitempty"`
+	Meta        *resourceMeta  `json:"meta,omitempty"`
+}
+
+func (g *groupResource) input() (string, []int, error) {
+	if strings.TrimSpace(g.DisplayName) == "" {
+		return "", nil, badRequest(scimTypeInvalidValue, "displayName is required")
+	}
+
+	members, err := memberIds(g.Members)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return g.DisplayName, members, nil
+}
+
+func memberIds(members []*memberValue) ([]int, error) {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, ok := idValue(member.Value)
+		if !ok {
+			return nil, badRequest(scimTypeInvalidValue, "invalid member "+member.Value)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func toGroupResource(base string, group *model.SCIMGroup) *groupResource {
+	id := strconv.Itoa(group.Id)
+
+	res := &groupResource{
+		Schemas:     []string{groupSchema},
+		Id:          id,
+		DisplayName: group.Name,
+		Meta: &resourceMeta{
+			ResourceType: "Group",
+			Location:     base + "/Groups/" + id,
+		},
+	}
+	for _, member := range group.Members {
+		userId := strconv.Itoa(member.UserId)
+		res.Members = append(res.Members, &memberValue{
+			Value:   userId,
+			Display: member.Email,
+			Ref:     base + "/Users/" + userId,
+		})
+	}
+
+	return res
+}
+
+type listResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+func newListResponse(total int, startIndex int, resources []interface{}) *listResponse {
+	return &listResponse{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type filterSupported struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type bulkSupported struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type authenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type serviceProviderConfig struct {
+	Schemas               []string                `json:"schemas"`
+	Patch                 supported               `json:"patch"`
+	Bulk                  bulkSupported           `json:"bulk"`
+	Filter                filterSupported         `json:"filter"`
+	ChangePassword        supported               `json:"changePassword"`
+	Sort                  supported               `json:"sort"`
+	Etag                  supported               `json:"etag"`
+	AuthenticationSchemes []*authenticationScheme `json:"authenticationSchemes"`
+}
+
+func newServiceProviderConfig() *serviceProviderConfig {
+	return &serviceProviderConfig{
+		Schemas: []string{spConfigSchema},
+		Patch:   supported{Supported: true},
+		Bulk:    bulkSupported{Supported: false, MaxPayloadSize: maxBodySize},
+		Filter: filterSupported{
+			Supported:  true,
+			MaxResults: 1000,
+		},
+		ChangePassword: supported{Supported: true},
+		AuthenticationSchemes: []*authenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "Bearer token",
+				Description: "A token issued to the SCIM client",
+				Primary:     true,
+			},
+		},
+	}
+}
@@ -1,12 +1,17 @@
 package config
 
-import "time"
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"time"
+)
 
 type JWTConfig interface {
 	RefreshTokenSecret() string
 	RefreshTokenExp() time.Duration
 	AccessTokenSecret() string
 	AccessTokenExp() time.Duration
+	AccessTokenKey() *rsa.PrivateKey
 }
 
 type PGConfig interface {
@@ -22,3 +27,103 @@ type HTTPServerConfig interface {
 	Port() int
 	Address() string
 }
+
+type ExtAuthzConfig interface {
+	RoutesFile() string
+}
+
+type CORSConfig interface {
+	AllowedOrigins() []string
+}
+
+type OIDCConfig interface {
+	Issuer() string
+}
+
+type FederationConfig interface {
+	ProvidersFile() string
+}
+
+type AuthenticatorConfig interface {
+	// Order lists the authenticators to try on login, first to last.
+	Order() []string
+}
+
+type LDAPConfig interface {
+	URL() string
+	StartTLS() bool
+	BindDN() string
+	BindPassword() string
+	BaseDN() string
+	UserFilter() string
+	EmailAttribute() string
+	NameAttribute() string
+	GroupAttribute() string
+	GroupRoles() map[string]string
+	DefaultRole() string
+	// TrustEmail lets a directory entry take over the local user with the
+	// same email on first login. Without it such a login is refused.
+	TrustEmail() bool
+}
+
+type SAMLConfig interface {
+	ProvidersFile() string
+	// Certificate and Key sign authentication requests and decrypt
+	// assertions. Both are nil when not configured.
+	Certificate() *x509.Certificate
+	Key() *rsa.PrivateKey
+}
+
+type UserLifecycleConfig interface {
+	// PurgeGracePeriod is how long a deleted user can still be restored
+	// before their personal data is erased.
+	PurgeGracePeriod() time.Duration
+	// PurgeInterval is how often the purger looks for users past the grace
+	// period.
+	PurgeInterval() time.Duration
+}
+
+type UserAttributesConfig interface {
+	SchemaFile() string
+}
+
+type KafkaConfig interface {
+	Brokers() []string
+}
+
+type EmailChangeConfig interface {
+	// Topic receives the confirmation and notice messages, which the mailer
+	// turns into emails.
+	Topic() string
+	TokenTTL() time.Duration
+}
+
+const (
+	AvatarStorageLocal = "local"
+	AvatarStorageS3    = "s3"
+)
+
+type AvatarConfig interface {
+	// Storage is AvatarStorageLocal or AvatarStorageS3.
+	Storage() string
+	// MaxSize is the largest upload accepted, in bytes.
+	MaxSize() int64
+	LocalDir() string
+	// PublicURL is the base URL avatars are served from. Empty means the
+	// default of the storage.
+	PublicURL() string
+}
+
+type S3Config interface {
+	Endpoint() string
+	Region() string
+	Bucket() string
+	AccessKeyId() string
+	SecretAccessKey() string
+}
+
+type SCIMConfig interface {
+	// ClientsFile is the JSON registry of SCIM clients. SCIM is disabled
+	// when it is empty.
+	ClientsFile() string
+}
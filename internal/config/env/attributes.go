@@ -0,0 +1,24 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+)
+
+const (
+	userAttributesSchemaFileEnv = "USER_ATTRIBUTES_SCHEMA_FILE"
+)
+
+type userAttributesConfig struct {
+	schemaFile string
+}
+
+func NewUserAttributesConfig() (config.UserAttributesConfig, error) {
+	return &userAttributesConfig{
+		schemaFile: os.Getenv(userAttributesSchemaFileEnv),
+	}, nil
+}
+
+func (c *userAttributesConfig) SchemaFile() string {
+	return c.schemaFile
+}
@@ -0,0 +1,37 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+	"strings"
+)
+
+const (
+	authenticatorsEnv = "AUTHENTICATORS"
+
+	defaultAuthenticator = "password"
+)
+
+type authenticatorConfig struct {
+	order []string
+}
+
+func NewAuthenticatorConfig() (config.AuthenticatorConfig, error) {
+	var order []string
+	for _, name := range strings.Split(os.Getenv(authenticatorsEnv), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			order = append(order, name)
+		}
+	}
+	if len(order) == 0 {
+		order = []string{defaultAuthenticator}
+	}
+
+	return &authenticatorConfig{
+		order: order,
+	}, nil
+}
+
+func (c *authenticatorConfig) Order() []string {
+	return c.order
+}
@@ -0,0 +1,62 @@
+package env
+
+import (
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+	"strconv"
+)
+
+const (
+	avatarStorageEnv   = "AVATAR_STORAGE"
+	avatarMaxSizeEnv   = "AVATAR_MAX_SIZE"
+	avatarLocalDirEnv  = "AVATAR_LOCAL_DIR"
+	avatarPublicURLEnv = "AVATAR_PUBLIC_URL"
+)
+
+type avatarConfig struct {
+	storage   string
+	maxSize   int64
+	localDir  string
+	publicURL string
+}
+
+func NewAvatarConfig() (config.AvatarConfig, error) {
+	const op = "config.NewAvatarConfig"
+
+	storage := getEnvDefault(avatarStorageEnv, config.AvatarStorageLocal)
+	if storage != config.AvatarStorageLocal && storage != config.AvatarStorageS3 {
+		return nil, fmt.Errorf("%s: %s: unknown storage %q", op, avatarStorageEnv, storage)
+	}
+
+	maxSize, err := strconv.ParseInt(getEnvDefault(avatarMaxSizeEnv, "5242880"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, avatarMaxSizeEnv, err)
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("%s: %s: must be positive", op, avatarMaxSizeEnv)
+	}
+
+	return &avatarConfig{
+		storage:   storage,
+		maxSize:   maxSize,
+		localDir:  getEnvDefault(avatarLocalDirEnv, "data/avatars"),
+		publicURL: os.Getenv(avatarPublicURLEnv),
+	}, nil
+}
+
+func (c *avatarConfig) Storage() string {
+	return c.storage
+}
+
+func (c *avatarConfig) MaxSize() int64 {
+	return c.maxSize
+}
+
+func (c *avatarConfig) LocalDir() string {
+	return c.localDir
+}
+
+func (c *avatarConfig) PublicURL() string {
+	return c.publicURL
+}
@@ -0,0 +1,32 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+	"strings"
+)
+
+const (
+	corsAllowedOriginsEnv = "CORS_ALLOWED_ORIGINS"
+)
+
+type corsConfig struct {
+	allowedOrigins []string
+}
+
+func NewCORSConfig() (config.CORSConfig, error) {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv(corsAllowedOriginsEnv), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	return &corsConfig{
+		allowedOrigins: origins,
+	}, nil
+}
+
+func (c *corsConfig) AllowedOrigins() []string {
+	return c.allowedOrigins
+}
@@ -0,0 +1,42 @@
+package env
+
+import (
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/config"
+	"time"
+)
+
+const (
+	emailChangeTopicEnv    = "EMAIL_CHANGE_TOPIC"
+	emailChangeTokenTTLEnv = "EMAIL_CHANGE_TOKEN_TTL"
+)
+
+type emailChangeConfig struct {
+	topic    string
+	tokenTTL time.Duration
+}
+
+func NewEmailChangeConfig() (config.EmailChangeConfig, error) {
+	const op = "config.NewEmailChangeConfig"
+
+	tokenTTL, err := time.ParseDuration(getEnvDefault(emailChangeTokenTTLEnv, "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, emailChangeTokenTTLEnv, err)
+	}
+	if tokenTTL <= 0 {
+		return nil, fmt.Errorf("%s: %s: must be positive", op, emailChangeTokenTTLEnv)
+	}
+
+	return &emailChangeConfig{
+		topic:    getEnvDefault(emailChangeTopicEnv, "email-changes-topic"),
+		tokenTTL: tokenTTL,
+	}, nil
+}
+
+func (c *emailChangeConfig) Topic() string {
+	return c.topic
+}
+
+func (c *emailChangeConfig) TokenTTL() time.Duration {
+	return c.tokenTTL
+}
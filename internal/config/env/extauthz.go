@@ -0,0 +1,24 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+)
+
+const (
+	extAuthzRoutesFileEnv = "EXT_AUTHZ_ROUTES_FILE"
+)
+
+type extAuthzConfig struct {
+	routesFile string
+}
+
+func NewExtAuthzConfig() (config.ExtAuthzConfig, error) {
+	return &extAuthzConfig{
+		routesFile: os.Getenv(extAuthzRoutesFileEnv),
+	}, nil
+}
+
+func (c *extAuthzConfig) RoutesFile() string {
+	return c.routesFile
+}
@@ -0,0 +1,24 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+)
+
+const (
+	federationProvidersFileEnv = "FEDERATION_PROVIDERS_FILE"
+)
+
+type federationConfig struct {
+	providersFile string
+}
+
+func NewFederationConfig() (config.FederationConfig, error) {
+	return &federationConfig{
+		providersFile: os.Getenv(federationProvidersFileEnv),
+	}, nil
+}
+
+func (c *federationConfig) ProvidersFile() string {
+	return c.providersFile
+}
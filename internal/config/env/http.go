@@ -9,8 +9,8 @@ import (
 )
 
 const (
-	httpHostEnv = "GRPC_SERVER_HOST"
-	httpPortEnv = "GRPC_SERVER_PORT"
+	httpHostEnv = "HTTP_SERVER_HOST"
+	httpPortEnv = "HTTP_SERVER_PORT"
 )
 
 type httpServerConfig struct {
@@ -18,7 +18,7 @@ type httpServerConfig struct {
 	port int
 }
 
-func NewHTTPServerConfig() (config.GRPCServerConfig, error) {
+func NewHTTPServerConfig() (config.HTTPServerConfig, error) {
 	const op = "config.NewHTTPServerConfig"
 
 	host := os.Getenv(httpHostEnv)
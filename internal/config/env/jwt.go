@@ -1,7 +1,9 @@
 package env
 
 import (
+	"crypto/rsa"
 	"fmt"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/nogavadu/auth-service/internal/config"
 	"os"
 	"time"
@@ -12,6 +14,7 @@ const (
 	refreshTokenExpEnv    = "REFRESH_TOKEN_EXP"
 	accessTokenSecretEnv  = "ACCESS_TOKEN_SECRET"
 	accessTokenExpEnv     = "ACCESS_TOKEN_EXP"
+	accessTokenKeyEnv     = "ACCESS_TOKEN_KEY_FILE"
 )
 
 type jwtConfig struct {
@@ -19,6 +22,7 @@ type jwtConfig struct {
 	refreshTokenExp    time.Duration
 	accessTokenSecret  string
 	accessTokenExp     time.Duration
+	accessTokenKey     *rsa.PrivateKey
 }
 
 func NewJWTConfig() (config.JWTConfig, error) {
@@ -47,16 +51,30 @@ func NewJWTConfig() (config.JWTConfig, error) {
 	if accessTokenExp == "" {
 		return nil, fmt.Errorf("%s: %s: failed to get env variable", op, accessTokenExpEnv)
 	}
-	accessTokenExpTime, err := time.ParseDuration(refreshTokenExp)
+	accessTokenExpTime, err := time.ParseDuration(accessTokenExp)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %s: %w", op, accessTokenExpEnv, err)
 	}
 
+	var accessTokenKey *rsa.PrivateKey
+	if keyFile := os.Getenv(accessTokenKeyEnv); keyFile != "" {
+		keyPEM, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, accessTokenKeyEnv, err)
+		}
+
+		accessTokenKey, err = jwt.ParseRSAPrivateKeyFromPEM(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, accessTokenKeyEnv, err)
+		}
+	}
+
 	return &jwtConfig{
 		refreshTokenSecret: refreshTokenSecret,
 		refreshTokenExp:    refreshTokenExpTime,
 		accessTokenSecret:  accessTokenSecret,
 		accessTokenExp:     accessTokenExpTime,
+		accessTokenKey:     accessTokenKey,
 	}, nil
 }
 
@@ -75,3 +93,7 @@ func (j *jwtConfig) RefreshTokenExp() time.Duration {
 func (j *jwtConfig) AccessTokenExp() time.Duration {
 	return j.accessTokenExp
 }
+
+func (j *jwtConfig) AccessTokenKey() *rsa.PrivateKey {
+	return j.accessTokenKey
+}
@@ -0,0 +1,37 @@
+package env
+
+import (
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/config"
+	"strings"
+)
+
+const (
+	kafkaBrokersEnv = "KAFKA_BROKERS"
+)
+
+type kafkaConfig struct {
+	brokers []string
+}
+
+func NewKafkaConfig() (config.KafkaConfig, error) {
+	const op = "config.NewKafkaConfig"
+
+	var brokers []string
+	for _, broker := range strings.Split(getEnvDefault(kafkaBrokersEnv, "kafka1:29091,kafka2:29092"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("%s: %s: no brokers", op, kafkaBrokersEnv)
+	}
+
+	return &kafkaConfig{
+		brokers: brokers,
+	}, nil
+}
+
+func (c *kafkaConfig) Brokers() []string {
+	return c.brokers
+}
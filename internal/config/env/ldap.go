@@ -0,0 +1,149 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+	"strconv"
+)
+
+const (
+	ldapURLEnv            = "LDAP_URL"
+	ldapStartTLSEnv       = "LDAP_START_TLS"
+	ldapBindDNEnv         = "LDAP_BIND_DN"
+	ldapBindPasswordEnv   = "LDAP_BIND_PASSWORD"
+	ldapBaseDNEnv         = "LDAP_BASE_DN"
+	ldapUserFilterEnv     = "LDAP_USER_FILTER"
+	ldapEmailAttributeEnv = "LDAP_EMAIL_ATTRIBUTE"
+	ldapNameAttributeEnv  = "LDAP_NAME_ATTRIBUTE"
+	ldapGroupAttributeEnv = "LDAP_GROUP_ATTRIBUTE"
+	ldapGroupRolesEnv     = "LDAP_GROUP_ROLES"
+	ldapDefaultRoleEnv    = "LDAP_DEFAULT_ROLE"
+	ldapTrustEmailEnv     = "LDAP_TRUST_EMAIL"
+)
+
+type ldapConfig struct {
+	url            string
+	startTLS       bool
+	bindDN         string
+	bindPassword   string
+	baseDN         string
+	userFilter     string
+	emailAttribute string
+	nameAttribute  string
+	groupAttribute string
+	groupRoles     map[string]string
+	defaultRole    string
+	trustEmail     bool
+}
+
+func NewLDAPConfig() (config.LDAPConfig, error) {
+	const op = "config.NewLDAPConfig"
+
+	url := os.Getenv(ldapURLEnv)
+	if url == "" {
+		return nil, fmt.Errorf("%s: %s: failed to get env variable", op, ldapURLEnv)
+	}
+
+	baseDN := os.Getenv(ldapBaseDNEnv)
+	if baseDN == "" {
+		return nil, fmt.Errorf("%s: %s: failed to get env variable", op, ldapBaseDNEnv)
+	}
+
+	var startTLS bool
+	if v := os.Getenv(ldapStartTLSEnv); v != "" {
+		var err error
+		if startTLS, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, ldapStartTLSEnv, err)
+		}
+	}
+
+	var trustEmail bool
+	if v := os.Getenv(ldapTrustEmailEnv); v != "" {
+		var err error
+		if trustEmail, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, ldapTrustEmailEnv, err)
+		}
+	}
+
+	// Group DNs contain commas and equals signs, so the mapping is JSON:
+	// {"cn=admins,ou=groups,dc=example,dc=org": "admin"}.
+	groupRoles := map[string]string{}
+	if v := os.Getenv(ldapGroupRolesEnv); v != "" {
+		if err := json.Unmarshal([]byte(v), &groupRoles); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, ldapGroupRolesEnv, err)
+		}
+	}
+
+	return &ldapConfig{
+		url:            url,
+		startTLS:       startTLS,
+		bindDN:         os.Getenv(ldapBindDNEnv),
+		bindPassword:   os.Getenv(ldapBindPasswordEnv),
+		baseDN:         baseDN,
+		userFilter:     getEnvDefault(ldapUserFilterEnv, "(&(objectClass=person)(mail=%s))"),
+		emailAttribute: getEnvDefault(ldapEmailAttributeEnv, "mail"),
+		nameAttribute:  getEnvDefault(ldapNameAttributeEnv, "cn"),
+		groupAttribute: getEnvDefault(ldapGroupAttributeEnv, "memberOf"),
+		groupRoles:     groupRoles,
+		defaultRole:    os.Getenv(ldapDefaultRoleEnv),
+		trustEmail:     trustEmail,
+	}, nil
+}
+
+func getEnvDefault(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
+
+func (c *ldapConfig) URL() string {
+	return c.url
+}
+
+func (c *ldapConfig) StartTLS() bool {
+	return c.startTLS
+}
+
+func (c *ldapConfig) BindDN() string {
+	return c.bindDN
+}
+
+func (c *ldapConfig) BindPassword() string {
+	return c.bindPassword
+}
+
+func (c *ldapConfig) BaseDN() string {
+	return c.baseDN
+}
+
+func (c *ldapConfig) UserFilter() string {
+	return c.userFilter
+}
+
+func (c *ldapConfig) EmailAttribute() string {
+	return c.emailAttribute
+}
+
+func (c *ldapConfig) NameAttribute() string {
+	return c.nameAttribute
+}
+
+func (c *ldapConfig) GroupAttribute() string {
+	return c.groupAttribute
+}
+
+func (c *ldapConfig) GroupRoles() map[string]string {
+	return c.groupRoles
+}
+
+func (c *ldapConfig) DefaultRole() string {
+	return c.defaultRole
+}
+
+func (c *ldapConfig) TrustEmail() bool {
+	return c.trustEmail
+}
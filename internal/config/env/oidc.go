@@ -0,0 +1,25 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+	"strings"
+)
+
+const (
+	oidcIssuerEnv = "OIDC_ISSUER"
+)
+
+type oidcConfig struct {
+	issuer string
+}
+
+func NewOIDCConfig() (config.OIDCConfig, error) {
+	return &oidcConfig{
+		issuer: strings.TrimSuffix(os.Getenv(oidcIssuerEnv), "/"),
+	}, nil
+}
+
+func (c *oidcConfig) Issuer() string {
+	return c.issuer
+}
@@ -0,0 +1,68 @@
+package env
+
+import (
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+)
+
+const (
+	s3EndpointEnv        = "S3_ENDPOINT"
+	s3RegionEnv          = "S3_REGION"
+	s3BucketEnv          = "S3_BUCKET"
+	s3AccessKeyIdEnv     = "S3_ACCESS_KEY_ID"
+	s3SecretAccessKeyEnv = "S3_SECRET_ACCESS_KEY"
+)
+
+type s3Config struct {
+	endpoint        string
+	region          string
+	bucket          string
+	accessKeyId     string
+	secretAccessKey string
+}
+
+func NewS3Config() (config.S3Config, error) {
+	const op = "config.NewS3Config"
+
+	cfg := &s3Config{
+		endpoint:        os.Getenv(s3EndpointEnv),
+		region:          getEnvDefault(s3RegionEnv, "us-east-1"),
+		bucket:          os.Getenv(s3BucketEnv),
+		accessKeyId:     os.Getenv(s3AccessKeyIdEnv),
+		secretAccessKey: os.Getenv(s3SecretAccessKeyEnv),
+	}
+
+	for env, value := range map[string]string{
+		s3EndpointEnv:        cfg.endpoint,
+		s3BucketEnv:          cfg.bucket,
+		s3AccessKeyIdEnv:     cfg.accessKeyId,
+		s3SecretAccessKeyEnv: cfg.secretAccessKey,
+	} {
+		if value == "" {
+			return nil, fmt.Errorf("%s: %s: failed to get env variable", op, env)
+		}
+	}
+
+	return cfg, nil
+}
+
+func (c *s3Config) Endpoint() string {
+	return c.endpoint
+}
+
+func (c *s3Config) Region() string {
+	return c.region
+}
+
+func (c *s3Config) Bucket() string {
+	return c.bucket
+}
+
+func (c *s3Config) AccessKeyId() string {
+	return c.accessKeyId
+}
+
+func (c *s3Config) SecretAccessKey() string {
+	return c.secretAccessKey
+}
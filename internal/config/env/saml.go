@@ -0,0 +1,76 @@
+package env
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+)
+
+const (
+	samlProvidersFileEnv = "SAML_PROVIDERS_FILE"
+	samlCertFileEnv      = "SAML_SP_CERT_FILE"
+	samlKeyFileEnv       = "SAML_SP_KEY_FILE"
+)
+
+type samlConfig struct {
+	providersFile string
+	certificate   *x509.Certificate
+	key           *rsa.PrivateKey
+}
+
+func NewSAMLConfig() (config.SAMLConfig, error) {
+	const op = "config.NewSAMLConfig"
+
+	certFile, keyFile := os.Getenv(samlCertFileEnv), os.Getenv(samlKeyFileEnv)
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("%s: %s and %s must be set together", op, samlCertFileEnv, samlKeyFileEnv)
+	}
+
+	cfg := &samlConfig{
+		providersFile: os.Getenv(samlProvidersFileEnv),
+	}
+	if certFile == "" {
+		return cfg, nil
+	}
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, samlCertFileEnv, err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, samlCertFileEnv, errors.New("no PEM data"))
+	}
+	cfg.certificate, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, samlCertFileEnv, err)
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, samlKeyFileEnv, err)
+	}
+	cfg.key, err = jwt.ParseRSAPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, samlKeyFileEnv, err)
+	}
+
+	return cfg, nil
+}
+
+func (c *samlConfig) ProvidersFile() string {
+	return c.providersFile
+}
+
+func (c *samlConfig) Certificate() *x509.Certificate {
+	return c.certificate
+}
+
+func (c *samlConfig) Key() *rsa.PrivateKey {
+	return c.key
+}
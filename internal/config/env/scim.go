@@ -0,0 +1,24 @@
+package env
+
+import (
+	"github.com/nogavadu/auth-service/internal/config"
+	"os"
+)
+
+const (
+	scimClientsFileEnv = "SCIM_CLIENTS_FILE"
+)
+
+type scimConfig struct {
+	clientsFile string
+}
+
+func NewSCIMConfig() (config.SCIMConfig, error) {
+	return &scimConfig{
+		clientsFile: os.Getenv(scimClientsFileEnv),
+	}, nil
+}
+
+func (c *scimConfig) ClientsFile() string {
+	return c.clientsFile
+}
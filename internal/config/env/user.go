@@ -0,0 +1,50 @@
+package env
+
+import (
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/config"
+	"time"
+)
+
+const (
+	userPurgeGracePeriodEnv = "USER_PURGE_GRACE_PERIOD"
+	userPurgeIntervalEnv    = "USER_PURGE_INTERVAL"
+)
+
+type userLifecycleConfig struct {
+	purgeGracePeriod time.Duration
+	purgeInterval    time.Duration
+}
+
+func NewUserLifecycleConfig() (config.UserLifecycleConfig, error) {
+	const op = "config.NewUserLifecycleConfig"
+
+	purgeGracePeriod, err := time.ParseDuration(getEnvDefault(userPurgeGracePeriodEnv, "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, userPurgeGracePeriodEnv, err)
+	}
+	if purgeGracePeriod < 0 {
+		return nil, fmt.Errorf("%s: %s: must not be negative", op, userPurgeGracePeriodEnv)
+	}
+
+	purgeInterval, err := time.ParseDuration(getEnvDefault(userPurgeIntervalEnv, "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, userPurgeIntervalEnv, err)
+	}
+	if purgeInterval <= 0 {
+		return nil, fmt.Errorf("%s: %s: must be positive", op, userPurgeIntervalEnv)
+	}
+
+	return &userLifecycleConfig{
+		purgeGracePeriod: purgeGracePeriod,
+		purgeInterval:    purgeInterval,
+	}, nil
+}
+
+func (c *userLifecycleConfig) PurgeGracePeriod() time.Duration {
+	return c.purgeGracePeriod
+}
+
+func (c *userLifecycleConfig) PurgeInterval() time.Duration {
+	return c.purgeInterval
+}
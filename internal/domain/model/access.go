@@ -0,0 +1,118 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Permissions checked by the service itself. Roles may also be granted
+// permissions that only downstream services check.
+const (
+	PermissionUsersRead             = "users:read"
+	PermissionUsersManage           = "users:manage"
+	PermissionUsersSuspend          = "users:suspend"
+	PermissionServiceAccountsManage = "service_accounts:manage"
+)
+
+var BuiltinPermissions = []string{
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionUsersSuspend,
+	PermissionServiceAccountsManage,
+}
+
+type AccessItem struct {
+	RequiredLvl *int
+	Permission  *string
+	Resource    string
+}
+
+type AccessDecision struct {
+	Allowed bool
+	Reason  string
+}
+
+type Permission struct {
+	Permission string
+	Resource   string
+}
+
+type Principal struct {
+	UserId      int
+	Email       string
+	Role        string
+	RoleLevel   int
+	Permissions []*Permission
+	SessionId   string
+	AuthTime    time.Time
+	ClientId    string
+	// APIKeyId is set when the principal authenticated with an API key.
+	APIKeyId  int
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// HasPermission reports whether the principal's role grants permission on
+// resource. Service accounts and API keys also need a scope that covers the
+// permission.
+func (p *Principal) HasPermission(permission string, resource string) bool {
+	if p.Scoped() && !p.HasScope(permission) {
+		return false
+	}
+
+	for _, granted := range p.Permissions {
+		if granted.Matches(permission, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scoped tells whether the principal acts with a subset of its role, as
+// granted by its scopes.
+func (p *Principal) Scoped() bool {
+	return p.ClientId != "" || p.APIKeyId != 0
+}
+
+// ScopePattern reports whether scope names a permission in known, either
+// exactly or as a "*"-suffixed pattern that covers at least one of them.
+func ScopePattern(scope string, known []string) bool {
+	if scope == "*" {
+		return true
+	}
+
+	for _, permission := range known {
+		if matchPattern(scope, permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasScope reports whether one of the principal's scopes covers permission.
+// Like grants, scopes may end with "*", and "*" alone covers everything.
+func (p *Principal) HasScope(permission string) bool {
+	for _, scope := range p.Scopes {
+		if matchPattern(scope, permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches reports whether the grant covers permission on resource. Grants may
+// end with "*" to cover every value with that prefix, e.g. "users:*".
+func (p *Permission) Matches(permission string, resource string) bool {
+	return matchPattern(p.Permission, permission) && matchPattern(p.Resource, resource)
+}
+
+func matchPattern(pattern string, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+
+	return pattern == value
+}
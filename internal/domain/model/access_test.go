@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestPrincipalHasPermission(t *testing.T) {
+	moderator := []*Permission{{Permission: "users:read", Resource: "*"}}
+	admin := []*Permission{{Permission: "*", Resource: "*"}}
+
+	tests := []struct {
+		name       string
+		principal  *Principal
+		permission string
+		want       bool
+	}{
+		{name: "granted", principal: &Principal{Permissions: moderator}, permission: "users:read", want: true},
+		{name: "not granted", principal: &Principal{Permissions: moderator}, permission: "users:manage"},
+		{name: "user ignores scopes", principal: &Principal{Permissions: admin, Scopes: []string{"users:read"}}, permission: "users:manage", want: true},
+		{name: "service account in scope", principal: &Principal{ClientId: "sa_1", Permissions: admin, Scopes: []string{"users:read"}}, permission: "users:read", want: true},
+		{name: "service account out of scope", principal: &Principal{ClientId: "sa_1", Permissions: admin, Scopes: []string{"users:read"}}, permission: "users:manage"},
+		{name: "service account without scopes", principal: &Principal{ClientId: "sa_1", Permissions: admin}, permission: "users:read"},
+		{name: "wildcard scope", principal: &Principal{ClientId: "sa_1", Permissions: admin, Scopes: []string{"*"}}, permission: "users:manage", want: true},
+		{name: "prefix scope", principal: &Principal{ClientId: "sa_1", Permissions: admin, Scopes: []string{"users:*"}}, permission: "users:suspend", want: true},
+		{name: "api key in scope", principal: &Principal{APIKeyId: 3, Permissions: moderator, Scopes: []string{"users:read"}}, permission: "users:read", want: true},
+		{name: "api key without scopes", principal: &Principal{APIKeyId: 3, Permissions: moderator}, permission: "users:read"},
+		{name: "scope beyond role", principal: &Principal{ClientId: "sa_1", Permissions: moderator, Scopes: []string{"*"}}, permission: "users:manage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.HasPermission(tt.permission, "*"); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
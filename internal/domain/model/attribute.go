@@ -0,0 +1,54 @@
+package model
+
+const (
+	AttributeTypeString     = "string"
+	AttributeTypeNumber     = "number"
+	AttributeTypeInteger    = "integer"
+	AttributeTypeBoolean    = "boolean"
+	AttributeTypeStringList = "string_list"
+)
+
+const (
+	// AttributeVisibilityPublic attributes are read and written by the user
+	// themselves as well as by admins.
+	AttributeVisibilityPublic = "public"
+	// AttributeVisibilityAdmin attributes are only seen and set by callers
+	// with the users:read and users:manage permissions.
+	AttributeVisibilityAdmin = "admin"
+)
+
+// AttributeDefinition registers a custom user attribute. Keys that are not
+// registered cannot be stored.
+type AttributeDefinition struct {
+	Key        string `json:"key"`
+	Type       string `json:"type"`
+	Visibility string `json:"visibility"`
+	// InClaims copies the attribute into the access tokens of the user.
+	InClaims bool `json:"in_claims"`
+}
+
+type AttributeSchema struct {
+	Attributes []*AttributeDefinition `json:"attributes"`
+}
+
+func (s *AttributeSchema) Get(key string) *AttributeDefinition {
+	for _, def := range s.Attributes {
+		if def.Key == key {
+			return def
+		}
+	}
+
+	return nil
+}
+
+// ClaimKeys lists the attributes that go into access tokens.
+func (s *AttributeSchema) ClaimKeys() []string {
+	var keys []string
+	for _, def := range s.Attributes {
+		if def.InClaims {
+			keys = append(keys, def.Key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,14 @@
+package model
+
+// Avatar is an uploaded avatar. URL, which is also the user's avatar, points
+// at the largest variant.
+type Avatar struct {
+	URL      string           `json:"url"`
+	Variants []*AvatarVariant `json:"variants"`
+}
+
+// AvatarVariant is the avatar scaled to Size by Size pixels.
+type AvatarVariant struct {
+	Size int    `json:"size"`
+	URL  string `json:"url"`
+}
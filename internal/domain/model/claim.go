@@ -7,4 +7,41 @@ type UserClaims struct {
 	Id    int    `json:"id"`
 	Email string `json:"Email"`
 	Role  string `json:"role"`
+
+	SessionId string `json:"sid,omitempty"`
+	// AuthTime is when the user last entered credentials; it survives
+	// refresh token rotation.
+	AuthTime int64 `json:"auth_time,omitempty"`
+	// Attributes are the custom user attributes registered to go into
+	// access tokens.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// ClientId and Scope are set on tokens issued to service accounts
+	// through the client credentials grant.
+	ClientId string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	// APIKeyId is set on the claims an API key resolves to; it never
+	// appears in a token.
+	APIKeyId int `json:"-"`
+}
+
+type IdTokenClaims struct {
+	jwt.StandardClaims
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Picture  string `json:"picture,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// ClientAccessTokenClaims are the claims of access tokens issued to OpenID
+// clients. They are bound to the client through "aud" and "client_id" and
+// name the scope granted instead of the user's role.
+type ClientAccessTokenClaims struct {
+	jwt.StandardClaims
+	ClientId string `json:"client_id"`
+	Scope    string `json:"scope"`
+	AuthTime int64  `json:"auth_time,omitempty"`
 }
@@ -0,0 +1,26 @@
+package model
+
+// ExternalProvider is an upstream OpenID Connect identity provider users can
+// log in with.
+type ExternalProvider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+type ExternalLoginStart struct {
+	AuthorizationURL string
+	State            string
+}
+
+// ExternalIdentity is a user as asserted by an upstream identity provider.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
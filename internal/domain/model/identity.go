@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ProviderPassword names the local email and password login method.
+const ProviderPassword = "password"
+
+// Identity is one way a user can log in: the local password or an account
+// at an external identity provider.
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     *string   `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+type AuthorizeRequest struct {
+	ClientId            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+type TokenRequest struct {
+	GrantType    string
+	ClientId     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	DeviceCode   string
+}
+
+type TokenSet struct {
+	AccessToken  string
+	RefreshToken string
+	IdToken      string
+	TokenType    string
+	ExpiresIn    time.Duration
+	Scope        string
+}
+
+// DeviceAuthorization is the response to a device authorization request
+// (RFC 8628 section 3.2).
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+// DeviceRequest is a pending device authorization as shown to the user who
+// is asked to approve it.
+type DeviceRequest struct {
+	UserCode   string
+	ClientName string
+	Scope      string
+}
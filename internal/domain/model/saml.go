@@ -0,0 +1,36 @@
+package model
+
+// SAMLProvider is an upstream SAML 2.0 identity provider users can log in
+// with. The service acts as the service provider (SP) towards it.
+type SAMLProvider struct {
+	Name string `json:"name"`
+	// EntityId identifies this service to the IdP.
+	EntityId string `json:"entity_id"`
+	// ACSURL receives the IdP's HTTP-POST response and hands it to
+	// CompleteSAMLLogin.
+	ACSURL string `json:"acs_url"`
+	// Exactly one of IdPMetadataURL and IdPMetadataFile is set.
+	IdPMetadataURL  string `json:"idp_metadata_url"`
+	IdPMetadataFile string `json:"idp_metadata_file"`
+	// SignRequests signs AuthnRequests with the SP key.
+	SignRequests bool `json:"sign_requests"`
+	// TrustEmail lets the IdP log into existing users with the same email.
+	TrustEmail bool `json:"trust_email"`
+
+	// Attribute names (or friendly names) in the assertion. The email falls
+	// back to the NameID.
+	EmailAttribute string `json:"email_attribute"`
+	NameAttribute  string `json:"name_attribute"`
+	RoleAttribute  string `json:"role_attribute"`
+	// RoleMapping maps role attribute values to local role names. The
+	// highest-level match wins, DefaultRole applies when none match.
+	RoleMapping map[string]string `json:"role_mapping"`
+	DefaultRole string            `json:"default_role"`
+}
+
+// SAMLLoginStart is an AuthnRequest ready to be sent with the HTTP-Redirect
+// binding.
+type SAMLLoginStart struct {
+	RedirectURL string
+	RelayState  string
+}
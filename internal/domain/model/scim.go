@@ -0,0 +1,107 @@
+package model
+
+// SCIMClient is an identity provider allowed to provision users over SCIM.
+type SCIMClient struct {
+	Name string `json:"name"`
+	// TokenSHA256 is the hex SHA-256 of the client's bearer token, so that
+	// the registry holds no secrets.
+	TokenSHA256 string `json:"token_sha256"`
+	// MaxRoleLevel bounds what the client manages: users whose role is above
+	// it and groups (roles) above it are out of its reach.
+	MaxRoleLevel int `json:"max_role_level"`
+}
+
+type SCIMClients struct {
+	Clients []*SCIMClient `json:"clients"`
+}
+
+// SCIMUser is a user together with their group. Users hold a single role,
+// so they are in at most one group; Group is unset for the default role,
+// which is not exposed as a group.
+type SCIMUser struct {
+	User
+	Group *SCIMGroupRef
+}
+
+type SCIMGroupRef struct {
+	Id   int
+	Name string
+}
+
+type SCIMUserInput struct {
+	Email    string
+	Name     *string
+	Password *string
+	Active   bool
+}
+
+// SCIMUserPatch holds the changes of a PATCH request; unset fields are left
+// as they are.
+type SCIMUserPatch struct {
+	Email     *string
+	Name      *string
+	ClearName bool
+	Password  *string
+	Active    *bool
+}
+
+type SCIMUserFilter struct {
+	Id     *int
+	Email  string
+	Active *bool
+	// StartIndex is 1-based, as in SCIM. Count is the page size, nil for the
+	// default; zero only counts the matches.
+	StartIndex int
+	Count      *int
+}
+
+type SCIMUserPage struct {
+	Users []*SCIMUser
+	Total int
+}
+
+type SCIMGroup struct {
+	Id   int
+	Name string
+	// Members is nil when they were not requested.
+	Members []*SCIMMember
+}
+
+type SCIMMember struct {
+	UserId int
+	Email  string
+}
+
+type SCIMGroupFilter struct {
+	Id   *int
+	Name string
+	// StartIndex is 1-based, as in SCIM. Count is the page size, nil for the
+	// default; zero only counts the matches.
+	StartIndex int
+	Count      *int
+	// WithMembers lists the members of each group.
+	WithMembers bool
+}
+
+type SCIMGroupPage struct {
+	Groups []*SCIMGroup
+	Total  int
+}
+
+const (
+	SCIMMembersAdd     = "add"
+	SCIMMembersRemove  = "remove"
+	SCIMMembersReplace = "replace"
+)
+
+// SCIMMembersChange is one membership operation of a group PATCH. Removing
+// with no UserIds removes every member.
+type SCIMMembersChange struct {
+	Op      string
+	UserIds []int
+}
+
+type SCIMGroupPatch struct {
+	Name    *string
+	Members []*SCIMMembersChange
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type ServiceAccount struct {
+	Id         int        `json:"id"`
+	ClientId   string     `json:"client_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	ServiceAccountInfo
+}
+
+type ServiceAccountInfo struct {
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type Suspension struct {
+	Id     int    `json:"id"`
+	UserId int    `json:"user_id"`
+	Reason string `json:"reason"`
+	// SuspendedBy and LiftedBy are unset when a service account acted.
+	SuspendedBy *int      `json:"suspended_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Until is unset for a ban that lasts until it is lifted.
+	Until    *time.Time `json:"until,omitempty"`
+	LiftedAt *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy *int       `json:"lifted_by,omitempty"`
+}
@@ -1,8 +1,21 @@
 package model
 
+import "time"
+
 type User struct {
 	Id int `json:"id"`
 	UserInfo
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	Status      string     `json:"status"`
+	// EmailVerified tells whether the user proved they own their email.
+	EmailVerified bool `json:"email_verified"`
+	// DeletedAt is set while the user awaits the purge and after it.
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	// Attributes is only filled with the attributes that go into tokens.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
 }
 
 type UserInfo struct {
@@ -18,4 +31,52 @@ type UserUpdateInput struct {
 	Avatar   *string `json:"avatar,omitempty"`
 	Password *string `json:"password,omitempty"`
 	Role     *string `json:"roleId,omitempty"`
+	// ClearName and ClearAvatar unset the fields; Name and Avatar are
+	// nil then.
+	ClearName   bool `json:"clearName,omitempty"`
+	ClearAvatar bool `json:"clearAvatar,omitempty"`
+}
+
+const (
+	UserSortById        = "id"
+	UserSortByEmail     = "email"
+	UserSortByName      = "name"
+	UserSortByCreatedAt = "created_at"
+)
+
+const (
+	UserStatusActive      = "active"
+	UserStatusDeactivated = "deactivated"
+	UserStatusDeleted     = "deleted"
+)
+
+// UserFilter selects a page of users. Empty fields do not filter.
+type UserFilter struct {
+	Role  string
+	Email string
+	Name  string
+	// Time ranges include the lower and exclude the upper bound.
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	LastLoginAfter  *time.Time
+	LastLoginBefore *time.Time
+	// Status narrows the page to one status. Deleted users are only listed
+	// when it is UserStatusDeleted.
+	Status string
+	// Suspended narrows the page to users that are, or are not, under an
+	// active suspension.
+	Suspended *bool
+	// Verified narrows the page to users whose email is, or is not,
+	// verified.
+	Verified *bool
+
+	SortBy    string
+	Desc      bool
+	PageSize  int
+	PageToken string
+}
+
+type UserPage struct {
+	Users         []*User
+	NextPageToken string
 }
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type APIKey struct {
+	Id         int        `db:"id"`
+	UserId     int        `db:"user_id"`
+	Name       string     `db:"name"`
+	KeyHash    string     `db:"key_hash"`
+	Hint       string     `db:"hint"`
+	Scopes     []string   `db:"scopes"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+type APIKeyInfo struct {
+	UserId    int
+	Name      string
+	KeyHash   string
+	Hint      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
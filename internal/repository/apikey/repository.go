@@ -0,0 +1,182 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	apiKeyRepoModel "github.com/nogavadu/auth-service/internal/repository/apikey/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+// lastUsedPrecision bounds how often last_used_at is written for a busy key.
+const lastUsedPrecision = "1 minute"
+
+var columns = []string{"id", "user_id", "name", "key_hash", "hint", "scopes", "expires_at", "last_used_at", "created_at", "revoked_at"}
+
+type apiKeyRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.APIKeyRepository {
+	return &apiKeyRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, info *apiKeyRepoModel.APIKeyInfo) (int, error) {
+	const op = "apiKeyRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("api_keys").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"user_id":    info.UserId,
+			"name":       info.Name,
+			"key_hash":   info.KeyHash,
+			"hint":       info.Hint,
+			"scopes":     info.Scopes,
+			"expires_at": info.ExpiresAt,
+		}).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var id int
+	if err = r.dbc.DB().ScanOneContext(ctx, &id, query, args...); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (r *apiKeyRepository) GetById(ctx context.Context, id int) (*apiKeyRepoModel.APIKey, error) {
+	return r.getBy(ctx, "apiKeyRepository.GetById", sq.Eq{"id": id})
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*apiKeyRepoModel.APIKey, error) {
+	return r.getBy(ctx, "apiKeyRepository.GetByHash", sq.Eq{"key_hash": keyHash})
+}
+
+func (r *apiKeyRepository) ListByUserId(ctx context.Context, userId int) ([]*apiKeyRepoModel.APIKey, error) {
+	const op = "apiKeyRepository.ListByUserId"
+
+	queryRaw, args, err := sq.
+		Select(columns...).
+		PlaceholderFormat(sq.Dollar).
+		From("api_keys").
+		Where(sq.Eq{"user_id": userId, "revoked_at": nil}).
+		OrderBy("id").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var keys []*apiKeyRepoModel.APIKey
+	if err = r.dbc.DB().ScanAllContext(ctx, &keys, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, userId int, id int) error {
+	const op = "apiKeyRepository.Revoke"
+
+	queryRaw, args, err := sq.
+		Update("api_keys").
+		PlaceholderFormat(sq.Dollar).
+		Set("revoked_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id, "user_id": userId, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) Touch(ctx context.Context, id int) error {
+	const op = "apiKeyRepository.Touch"
+
+	queryRaw, args, err := sq.
+		Update("api_keys").
+		PlaceholderFormat(sq.Dollar).
+		Set("last_used_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Or{
+			sq.Eq{"last_used_at": nil},
+			sq.Expr("last_used_at < now() - interval '" + lastUsedPrecision + "'"),
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) getBy(ctx context.Context, op string, where sq.Eq) (*apiKeyRepoModel.APIKey, error) {
+	queryRaw, args, err := sq.
+		Select(columns...).
+		PlaceholderFormat(sq.Dollar).
+		From("api_keys").
+		Where(where).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var key apiKeyRepoModel.APIKey
+	if err = r.dbc.DB().ScanOneContext(ctx, &key, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type AuthCode struct {
+	CodeHash            string     `db:"code_hash"`
+	ClientId            string     `db:"client_id"`
+	UserId              int        `db:"user_id"`
+	RedirectURI         string     `db:"redirect_uri"`
+	Scope               string     `db:"scope"`
+	Nonce               *string    `db:"nonce"`
+	CodeChallenge       *string    `db:"code_challenge"`
+	CodeChallengeMethod *string    `db:"code_challenge_method"`
+	AuthTime            time.Time  `db:"auth_time"`
+	ExpiresAt           time.Time  `db:"expires_at"`
+	UsedAt              *time.Time `db:"used_at"`
+}
@@ -0,0 +1,89 @@
+package authcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	authCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/authcode/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+type authCodeRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.AuthCodeRepository {
+	return &authCodeRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *authCodeRepository) Create(ctx context.Context, code *authCodeRepoModel.AuthCode) error {
+	const op = "authCodeRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("authorization_codes").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"code_hash":             code.CodeHash,
+			"client_id":             code.ClientId,
+			"user_id":               code.UserId,
+			"redirect_uri":          code.RedirectURI,
+			"scope":                 code.Scope,
+			"nonce":                 code.Nonce,
+			"code_challenge":        code.CodeChallenge,
+			"code_challenge_method": code.CodeChallengeMethod,
+			"auth_time":             code.AuthTime,
+			"expires_at":            code.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Consume marks the code as used and returns it. A code can be consumed only once.
+func (r *authCodeRepository) Consume(ctx context.Context, codeHash string) (*authCodeRepoModel.AuthCode, error) {
+	const op = "authCodeRepository.Consume"
+
+	queryRaw, args, err := sq.
+		Update("authorization_codes").
+		PlaceholderFormat(sq.Dollar).
+		Set("used_at", sq.Expr("now()")).
+		Where(sq.Eq{"code_hash": codeHash, "used_at": nil}).
+		Suffix("RETURNING code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, code_challenge_method, auth_time, expires_at, used_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var code authCodeRepoModel.AuthCode
+	if err = r.dbc.DB().ScanOneContext(ctx, &code, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &code, nil
+}
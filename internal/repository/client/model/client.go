@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type Client struct {
+	Id               int       `db:"id"`
+	ClientId         string    `db:"client_id"`
+	ClientSecretHash *string   `db:"client_secret_hash"`
+	Name             string    `db:"name"`
+	RedirectURIs     []string  `db:"redirect_uris"`
+	CreatedAt        time.Time `db:"created_at"`
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	clientRepoModel "github.com/nogavadu/auth-service/internal/repository/client/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+type clientRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.ClientRepository {
+	return &clientRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *clientRepository) GetByClientId(ctx context.Context, clientId string) (*clientRepoModel.Client, error) {
+	const op = "clientRepository.GetByClientId"
+
+	queryRaw, args, err := sq.
+		Select("id", "client_id", "client_secret_hash", "name", "redirect_uris", "created_at").
+		PlaceholderFormat(sq.Dollar).
+		From("clients").
+		Where(sq.Eq{"client_id": clientId}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var client clientRepoModel.Client
+	if err = r.dbc.DB().ScanOneContext(ctx, &client, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &client, nil
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusDenied   = "denied"
+)
+
+type DeviceCode struct {
+	DeviceCodeHash  string     `db:"device_code_hash"`
+	UserCode        string     `db:"user_code"`
+	ClientId        string     `db:"client_id"`
+	Scope           string     `db:"scope"`
+	Status          string     `db:"status"`
+	UserId          *int       `db:"user_id"`
+	AuthTime        *time.Time `db:"auth_time"`
+	IntervalSeconds int        `db:"interval_seconds"`
+	ExpiresAt       time.Time  `db:"expires_at"`
+	LastPolledAt    *time.Time `db:"last_polled_at"`
+	UsedAt          *time.Time `db:"used_at"`
+}
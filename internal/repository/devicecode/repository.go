@@ -0,0 +1,228 @@
+package devicecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	deviceCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/devicecode/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"time"
+)
+
+type deviceCodeRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.DeviceCodeRepository {
+	return &deviceCodeRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *deviceCodeRepository) Create(ctx context.Context, code *deviceCodeRepoModel.DeviceCode) error {
+	const op = "deviceCodeRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("device_codes").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"device_code_hash": code.DeviceCodeHash,
+			"user_code":        code.UserCode,
+			"client_id":        code.ClientId,
+			"scope":            code.Scope,
+			"interval_seconds": code.IntervalSeconds,
+			"expires_at":       code.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repo.PgErrAlreadyExistsCode {
+			return fmt.Errorf("%s: %w", op, repo.ErrAlreadyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *deviceCodeRepository) GetByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (*deviceCodeRepoModel.DeviceCode, error) {
+	const op = "deviceCodeRepository.GetByDeviceCodeHash"
+
+	return r.get(ctx, op, sq.Eq{"device_code_hash": deviceCodeHash})
+}
+
+func (r *deviceCodeRepository) GetByUserCode(ctx context.Context, userCode string) (*deviceCodeRepoModel.DeviceCode, error) {
+	const op = "deviceCodeRepository.GetByUserCode"
+
+	return r.get(ctx, op, sq.Eq{"user_code": userCode})
+}
+
+// Poll records a token request for the code and sets the interval the
+// client has to wait before the next one.
+func (r *deviceCodeRepository) Poll(ctx context.Context, deviceCodeHash string, intervalSeconds int) error {
+	const op = "deviceCodeRepository.Poll"
+
+	queryRaw, args, err := sq.
+		Update("device_codes").
+		PlaceholderFormat(sq.Dollar).
+		Set("last_polled_at", sq.Expr("now()")).
+		Set("interval_seconds", intervalSeconds).
+		Where(sq.Eq{"device_code_hash": deviceCodeHash}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Decide approves or denies a pending, unexpired code. userId and authTime
+// are set only on approval.
+func (r *deviceCodeRepository) Decide(ctx context.Context, userCode string, status string, userId *int, authTime *time.Time) error {
+	const op = "deviceCodeRepository.Decide"
+
+	queryRaw, args, err := sq.
+		Update("device_codes").
+		PlaceholderFormat(sq.Dollar).
+		Set("status", status).
+		Set("user_id", userId).
+		Set("auth_time", authTime).
+		Where(sq.Eq{"user_code": userCode, "status": deviceCodeRepoModel.StatusPending}).
+		Where(sq.Expr("expires_at > now()")).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Consume marks an approved code as used and returns it. A code can be consumed only once.
+func (r *deviceCodeRepository) Consume(ctx context.Context, deviceCodeHash string) (*deviceCodeRepoModel.DeviceCode, error) {
+	const op = "deviceCodeRepository.Consume"
+
+	queryRaw, args, err := sq.
+		Update("device_codes").
+		PlaceholderFormat(sq.Dollar).
+		Set("used_at", sq.Expr("now()")).
+		Where(sq.Eq{
+			"device_code_hash": deviceCodeHash,
+			"status":           deviceCodeRepoModel.StatusApproved,
+			"used_at":          nil,
+		}).
+		Suffix("RETURNING device_code_hash, user_code, client_id, scope, status, user_id, auth_time, interval_seconds, expires_at, last_polled_at, used_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var code deviceCodeRepoModel.DeviceCode
+	if err = r.dbc.DB().ScanOneContext(ctx, &code, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &code, nil
+}
+
+// DeleteExpired deletes codes that have expired or have been used, and
+// returns how many it deleted.
+func (r *deviceCodeRepository) DeleteExpired(ctx context.Context) (int, error) {
+	const op = "deviceCodeRepository.DeleteExpired"
+
+	queryRaw, args, err := sq.
+		Delete("device_codes").
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Or{
+			sq.Expr("expires_at <= now()"),
+			sq.NotEq{"used_at": nil},
+		}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (r *deviceCodeRepository) get(ctx context.Context, op string, where sq.Eq) (*deviceCodeRepoModel.DeviceCode, error) {
+	queryRaw, args, err := sq.
+		Select("device_code_hash", "user_code", "client_id", "scope", "status", "user_id", "auth_time", "interval_seconds", "expires_at", "last_polled_at", "used_at").
+		From("device_codes").
+		PlaceholderFormat(sq.Dollar).
+		Where(where).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var code deviceCodeRepoModel.DeviceCode
+	if err = r.dbc.DB().ScanOneContext(ctx, &code, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &code, nil
+}
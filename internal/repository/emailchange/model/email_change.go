@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type EmailChange struct {
+	TokenHash   string     `db:"token_hash"`
+	UserId      int        `db:"user_id"`
+	OldEmail    string     `db:"old_email"`
+	NewEmail    string     `db:"new_email"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	CancelledAt *time.Time `db:"cancelled_at"`
+}
@@ -0,0 +1,138 @@
+package emailchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	emailChangeRepoModel "github.com/nogavadu/auth-service/internal/repository/emailchange/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+type emailChangeRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.EmailChangeRepository {
+	return &emailChangeRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *emailChangeRepository) Create(ctx context.Context, change *emailChangeRepoModel.EmailChange) error {
+	const op = "emailChangeRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("email_changes").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"token_hash": change.TokenHash,
+			"user_id":    change.UserId,
+			"old_email":  change.OldEmail,
+			"new_email":  change.NewEmail,
+			"expires_at": change.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelPending cancels every unconfirmed change of the user, so that only
+// the most recently requested one can be confirmed.
+func (r *emailChangeRepository) CancelPending(ctx context.Context, userId int) error {
+	const op = "emailChangeRepository.CancelPending"
+
+	queryRaw, args, err := sq.
+		Update("email_changes").
+		PlaceholderFormat(sq.Dollar).
+		Set("cancelled_at", sq.Expr("now()")).
+		Where(sq.Eq{"user_id": userId, "confirmed_at": nil, "cancelled_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Consume marks the change as confirmed and returns it. A change can be
+// consumed only once and not after it has been cancelled.
+func (r *emailChangeRepository) Consume(ctx context.Context, tokenHash string) (*emailChangeRepoModel.EmailChange, error) {
+	const op = "emailChangeRepository.Consume"
+
+	queryRaw, args, err := sq.
+		Update("email_changes").
+		PlaceholderFormat(sq.Dollar).
+		Set("confirmed_at", sq.Expr("now()")).
+		Where(sq.Eq{"token_hash": tokenHash, "confirmed_at": nil, "cancelled_at": nil}).
+		Suffix("RETURNING token_hash, user_id, old_email, new_email, created_at, expires_at, confirmed_at, cancelled_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var change emailChangeRepoModel.EmailChange
+	if err = r.dbc.DB().ScanOneContext(ctx, &change, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &change, nil
+}
+
+// DeleteByUserIds deletes every change of the users, so that purging them
+// leaves none of their addresses behind.
+func (r *emailChangeRepository) DeleteByUserIds(ctx context.Context, userIds []int) error {
+	const op = "emailChangeRepository.DeleteByUserIds"
+
+	queryRaw, args, err := sq.
+		Delete("email_changes").
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Eq{"user_id": userIds}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
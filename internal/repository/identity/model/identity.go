@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+type Identity struct {
+	Provider  string    `db:"provider"`
+	Subject   string    `db:"subject"`
+	UserId    int       `db:"user_id"`
+	Email     *string   `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -0,0 +1,167 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+type identityRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.IdentityRepository {
+	return &identityRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *identityRepository) Create(ctx context.Context, identity *identityRepoModel.Identity) error {
+	const op = "identityRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("user_identities").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"provider": identity.Provider,
+			"subject":  identity.Subject,
+			"user_id":  identity.UserId,
+			"email":    identity.Email,
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repo.PgErrAlreadyExistsCode {
+			return fmt.Errorf("%s: %w", op, repo.ErrAlreadyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *identityRepository) Get(ctx context.Context, provider string, subject string) (*identityRepoModel.Identity, error) {
+	const op = "identityRepository.Get"
+
+	queryRaw, args, err := sq.
+		Select("provider", "subject", "user_id", "email", "created_at").
+		PlaceholderFormat(sq.Dollar).
+		From("user_identities").
+		Where(sq.Eq{"provider": provider, "subject": subject}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var identity identityRepoModel.Identity
+	if err = r.dbc.DB().ScanOneContext(ctx, &identity, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &identity, nil
+}
+
+func (r *identityRepository) ListByUserId(ctx context.Context, userId int) ([]*identityRepoModel.Identity, error) {
+	const op = "identityRepository.ListByUserId"
+
+	queryRaw, args, err := sq.
+		Select("provider", "subject", "user_id", "email", "created_at").
+		PlaceholderFormat(sq.Dollar).
+		From("user_identities").
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("created_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var identities []*identityRepoModel.Identity
+	if err = r.dbc.DB().ScanAllContext(ctx, &identities, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identities, nil
+}
+
+func (r *identityRepository) Delete(ctx context.Context, userId int, provider string, subject string) error {
+	const op = "identityRepository.Delete"
+
+	queryRaw, args, err := sq.
+		Delete("user_identities").
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Eq{"user_id": userId, "provider": provider, "subject": subject}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *identityRepository) DeleteByUserIds(ctx context.Context, userIds []int) error {
+	const op = "identityRepository.DeleteByUserIds"
+
+	queryRaw, args, err := sq.
+		Delete("user_identities").
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Eq{"user_id": userIds}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
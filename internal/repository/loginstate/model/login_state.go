@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type LoginState struct {
+	StateHash    string     `db:"state_hash"`
+	Provider     string     `db:"provider"`
+	Nonce        string     `db:"nonce"`
+	CodeVerifier string     `db:"code_verifier"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	UsedAt       *time.Time `db:"used_at"`
+}
@@ -0,0 +1,84 @@
+package loginstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	loginStateRepoModel "github.com/nogavadu/auth-service/internal/repository/loginstate/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+type loginStateRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.LoginStateRepository {
+	return &loginStateRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *loginStateRepository) Create(ctx context.Context, state *loginStateRepoModel.LoginState) error {
+	const op = "loginStateRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("external_login_states").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"state_hash":    state.StateHash,
+			"provider":      state.Provider,
+			"nonce":         state.Nonce,
+			"code_verifier": state.CodeVerifier,
+			"expires_at":    state.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Consume marks the state as used and returns it. A state can be consumed only once.
+func (r *loginStateRepository) Consume(ctx context.Context, stateHash string) (*loginStateRepoModel.LoginState, error) {
+	const op = "loginStateRepository.Consume"
+
+	queryRaw, args, err := sq.
+		Update("external_login_states").
+		PlaceholderFormat(sq.Dollar).
+		Set("used_at", sq.Expr("now()")).
+		Where(sq.Eq{"state_hash": stateHash, "used_at": nil}).
+		Suffix("RETURNING state_hash, provider, nonce, code_verifier, expires_at, used_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var state loginStateRepoModel.LoginState
+	if err = r.dbc.DB().ScanOneContext(ctx, &state, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	TokenHash string    `db:"token_hash"`
+	ClientId  string    `db:"client_id"`
+	UserId    int       `db:"user_id"`
+	Scope     string    `db:"scope"`
+	AuthTime  time.Time `db:"auth_time"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
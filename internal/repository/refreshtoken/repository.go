@@ -0,0 +1,84 @@
+package refreshtoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	refreshTokenRepoModel "github.com/nogavadu/auth-service/internal/repository/refreshtoken/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+type refreshTokenRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.RefreshTokenRepository {
+	return &refreshTokenRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *refreshTokenRepoModel.RefreshToken) error {
+	const op = "refreshTokenRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("oauth_refresh_tokens").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"token_hash": token.TokenHash,
+			"client_id":  token.ClientId,
+			"user_id":    token.UserId,
+			"scope":      token.Scope,
+			"auth_time":  token.AuthTime,
+			"expires_at": token.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Consume deletes the token's grant and returns it. A grant can be consumed only once.
+func (r *refreshTokenRepository) Consume(ctx context.Context, tokenHash string) (*refreshTokenRepoModel.RefreshToken, error) {
+	const op = "refreshTokenRepository.Consume"
+
+	queryRaw, args, err := sq.
+		Delete("oauth_refresh_tokens").
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Eq{"token_hash": tokenHash}).
+		Suffix("RETURNING token_hash, client_id, user_id, scope, auth_time, expires_at, created_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var token refreshTokenRepoModel.RefreshToken
+	if err = r.dbc.DB().ScanOneContext(ctx, &token, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &token, nil
+}
@@ -3,8 +3,19 @@ package repository
 import (
 	"context"
 	"errors"
+	apiKeyRepoModel "github.com/nogavadu/auth-service/internal/repository/apikey/model"
+	authCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/authcode/model"
+	clientRepoModel "github.com/nogavadu/auth-service/internal/repository/client/model"
+	deviceCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/devicecode/model"
+	emailChangeRepoModel "github.com/nogavadu/auth-service/internal/repository/emailchange/model"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	loginStateRepoModel "github.com/nogavadu/auth-service/internal/repository/loginstate/model"
+	refreshTokenRepoModel "github.com/nogavadu/auth-service/internal/repository/refreshtoken/model"
 	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	serviceAccountRepoModel "github.com/nogavadu/auth-service/internal/repository/serviceaccount/model"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
 	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"time"
 )
 
 const (
@@ -21,11 +32,97 @@ type UserRepository interface {
 	Create(ctx context.Context, userInfo *userRepoModel.UserInfo) (int, error)
 	GetByEmail(ctx context.Context, email string) (*userRepoModel.User, error)
 	GetById(ctx context.Context, id int) (*userRepoModel.User, error)
+	Lock(ctx context.Context, id int) error
+	List(ctx context.Context, filter *userRepoModel.ListFilter) ([]*userRepoModel.User, error)
+	Count(ctx context.Context, filter *userRepoModel.ListFilter) (int, error)
 	Update(ctx context.Context, id int, input *userRepoModel.UserUpdateInput) error
+	TouchLogin(ctx context.Context, id int) error
+	UpdateAttributes(ctx context.Context, id int, set map[string]interface{}, remove []string) error
+	SetAvatar(ctx context.Context, id int, avatar *string, avatarKey *string) (*string, error)
+	RevokeSessions(ctx context.Context, id int) error
+	SetDeactivated(ctx context.Context, id int, deactivated bool) error
 	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) error
+	Purge(ctx context.Context, deletedBefore time.Time, limit uint64) ([]*userRepoModel.PurgedUser, error)
 }
 
 type RoleRepository interface {
 	GetByName(ctx context.Context, name string) (*roleRepoModel.Role, error)
 	GetById(ctx context.Context, id int) (*roleRepoModel.Role, error)
+	GetPermissions(ctx context.Context, roleId int) ([]*roleRepoModel.Permission, error)
+	ListPermissionNames(ctx context.Context) ([]string, error)
+	List(ctx context.Context, filter *roleRepoModel.ListFilter) ([]*roleRepoModel.Role, error)
+	Count(ctx context.Context, filter *roleRepoModel.ListFilter) (int, error)
+	Create(ctx context.Context, name string, level int) (int, error)
+	Rename(ctx context.Context, id int, name string) error
+	Delete(ctx context.Context, id int) error
+}
+
+type ClientRepository interface {
+	GetByClientId(ctx context.Context, clientId string) (*clientRepoModel.Client, error)
+}
+
+type AuthCodeRepository interface {
+	Create(ctx context.Context, code *authCodeRepoModel.AuthCode) error
+	Consume(ctx context.Context, codeHash string) (*authCodeRepoModel.AuthCode, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *refreshTokenRepoModel.RefreshToken) error
+	Consume(ctx context.Context, tokenHash string) (*refreshTokenRepoModel.RefreshToken, error)
+}
+
+type DeviceCodeRepository interface {
+	Create(ctx context.Context, code *deviceCodeRepoModel.DeviceCode) error
+	GetByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (*deviceCodeRepoModel.DeviceCode, error)
+	GetByUserCode(ctx context.Context, userCode string) (*deviceCodeRepoModel.DeviceCode, error)
+	Poll(ctx context.Context, deviceCodeHash string, intervalSeconds int) error
+	Decide(ctx context.Context, userCode string, status string, userId *int, authTime *time.Time) error
+	Consume(ctx context.Context, deviceCodeHash string) (*deviceCodeRepoModel.DeviceCode, error)
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+type ServiceAccountRepository interface {
+	Create(ctx context.Context, info *serviceAccountRepoModel.ServiceAccountInfo) (int, error)
+	GetById(ctx context.Context, id int) (*serviceAccountRepoModel.ServiceAccount, error)
+	GetByClientId(ctx context.Context, clientId string) (*serviceAccountRepoModel.ServiceAccount, error)
+	UpdateSecret(ctx context.Context, id int, clientSecretHash string) error
+	Disable(ctx context.Context, id int) error
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, info *apiKeyRepoModel.APIKeyInfo) (int, error)
+	GetById(ctx context.Context, id int) (*apiKeyRepoModel.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*apiKeyRepoModel.APIKey, error)
+	ListByUserId(ctx context.Context, userId int) ([]*apiKeyRepoModel.APIKey, error)
+	Revoke(ctx context.Context, userId int, id int) error
+	Touch(ctx context.Context, id int) error
+}
+
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *identityRepoModel.Identity) error
+	Get(ctx context.Context, provider string, subject string) (*identityRepoModel.Identity, error)
+	ListByUserId(ctx context.Context, userId int) ([]*identityRepoModel.Identity, error)
+	Delete(ctx context.Context, userId int, provider string, subject string) error
+	DeleteByUserIds(ctx context.Context, userIds []int) error
+}
+
+type SuspensionRepository interface {
+	Create(ctx context.Context, info *suspensionRepoModel.SuspensionInfo) (int, error)
+	GetById(ctx context.Context, id int) (*suspensionRepoModel.Suspension, error)
+	GetActive(ctx context.Context, userId int) (*suspensionRepoModel.Suspension, error)
+	ListByUserId(ctx context.Context, userId int) ([]*suspensionRepoModel.Suspension, error)
+	Lift(ctx context.Context, userId int, liftedBy *int) error
+}
+
+type EmailChangeRepository interface {
+	Create(ctx context.Context, change *emailChangeRepoModel.EmailChange) error
+	CancelPending(ctx context.Context, userId int) error
+	Consume(ctx context.Context, tokenHash string) (*emailChangeRepoModel.EmailChange, error)
+	DeleteByUserIds(ctx context.Context, userIds []int) error
+}
+
+type LoginStateRepository interface {
+	Create(ctx context.Context, state *loginStateRepoModel.LoginState) error
+	Consume(ctx context.Context, stateHash string) (*loginStateRepoModel.LoginState, error)
 }
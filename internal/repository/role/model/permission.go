@@ -0,0 +1,7 @@
+package model
+
+type Permission struct {
+	RoleId     int    `db:"role_id"`
+	Permission string `db:"permission"`
+	Resource   string `db:"resource"`
+}
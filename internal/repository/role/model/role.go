@@ -5,3 +5,14 @@ type Role struct {
 	Name  string `db:"name"`
 	Level int    `db:"level"`
 }
+
+// ListFilter selects a page of roles, ordered by id.
+type ListFilter struct {
+	// Name matches the whole name, case-insensitively.
+	Name      string
+	MaxLevel  *int
+	ExcludeId *int
+
+	Offset uint64
+	Limit  uint64
+}
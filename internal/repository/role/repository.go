@@ -6,6 +6,7 @@ import (
 	"fmt"
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	repo "github.com/nogavadu/auth-service/internal/repository"
 	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
 	"github.com/nogavadu/platform_common/pkg/db"
@@ -82,3 +83,219 @@ func (r *roleRepository) GetByName(ctx context.Context, name string) (*roleRepoM
 
 	return &role, nil
 }
+
+func (r *roleRepository) GetPermissions(ctx context.Context, roleId int) ([]*roleRepoModel.Permission, error) {
+	const op = "roleRepository.GetPermissions"
+
+	queryRaw, args, err := sq.
+		Select("role_id", "permission", "resource").
+		PlaceholderFormat(sq.Dollar).
+		From("role_permissions").
+		Where(sq.Eq{"role_id": roleId}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var permissions []*roleRepoModel.Permission
+	if err = r.dbc.DB().ScanAllContext(ctx, &permissions, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+// ListPermissionNames returns every permission granted to some role.
+func (r *roleRepository) ListPermissionNames(ctx context.Context) ([]string, error) {
+	const op = "roleRepository.ListPermissionNames"
+
+	queryRaw, args, err := sq.
+		Select("DISTINCT permission").
+		PlaceholderFormat(sq.Dollar).
+		From("role_permissions").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var names []string
+	if err = r.dbc.DB().ScanAllContext(ctx, &names, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return names, nil
+}
+
+func (r *roleRepository) List(ctx context.Context, filter *roleRepoModel.ListFilter) ([]*roleRepoModel.Role, error) {
+	const op = "roleRepository.List"
+
+	queryRaw, args, err := applyListFilter(sq.
+		Select("id", "name", "level").
+		PlaceholderFormat(sq.Dollar).
+		From("roles"), filter).
+		OrderBy("id").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var roles []*roleRepoModel.Role
+	if err = r.dbc.DB().ScanAllContext(ctx, &roles, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// Count is the number of roles matching the filter, regardless of its offset
+// and limit.
+func (r *roleRepository) Count(ctx context.Context, filter *roleRepoModel.ListFilter) (int, error) {
+	const op = "roleRepository.Count"
+
+	queryRaw, args, err := applyListFilter(sq.
+		Select("count(*)").
+		PlaceholderFormat(sq.Dollar).
+		From("roles"), filter).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var count int
+	if err = r.dbc.DB().ScanOneContext(ctx, &count, query, args...); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+func applyListFilter(builder sq.SelectBuilder, filter *roleRepoModel.ListFilter) sq.SelectBuilder {
+	if filter.Name != "" {
+		builder = builder.Where(sq.Expr("lower(name) = lower(?)", filter.Name))
+	}
+	if filter.MaxLevel != nil {
+		builder = builder.Where(sq.LtOrEq{"level": *filter.MaxLevel})
+	}
+	if filter.ExcludeId != nil {
+		builder = builder.Where(sq.NotEq{"id": *filter.ExcludeId})
+	}
+
+	return builder
+}
+
+func (r *roleRepository) Create(ctx context.Context, name string, level int) (int, error) {
+	const op = "roleRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("roles").
+		PlaceholderFormat(sq.Dollar).
+		Columns("name", "level").
+		Values(name, level).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var id int
+	if err = r.dbc.DB().ScanOneContext(ctx, &id, query, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repo.PgErrAlreadyExistsCode {
+			return 0, fmt.Errorf("%s: %w", op, repo.ErrAlreadyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (r *roleRepository) Rename(ctx context.Context, id int, name string) error {
+	const op = "roleRepository.Rename"
+
+	queryRaw, args, err := sq.
+		Update("roles").
+		PlaceholderFormat(sq.Dollar).
+		Set("name", name).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repo.PgErrAlreadyExistsCode {
+			return fmt.Errorf("%s: %w", op, repo.ErrAlreadyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Delete removes the role. Its users fall back to the default role.
+func (r *roleRepository) Delete(ctx context.Context, id int) error {
+	const op = "roleRepository.Delete"
+
+	queryRaw, args, err := sq.
+		Delete("roles").
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
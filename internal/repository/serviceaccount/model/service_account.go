@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type ServiceAccount struct {
+	Id               int        `db:"id"`
+	ClientId         string     `db:"client_id"`
+	ClientSecretHash string     `db:"client_secret_hash"`
+	Name             string     `db:"name"`
+	RoleId           int        `db:"role_id"`
+	Scopes           []string   `db:"scopes"`
+	CreatedAt        time.Time  `db:"created_at"`
+	DisabledAt       *time.Time `db:"disabled_at"`
+}
+
+type ServiceAccountInfo struct {
+	ClientId         string
+	ClientSecretHash string
+	Name             string
+	RoleId           int
+	Scopes           []string
+}
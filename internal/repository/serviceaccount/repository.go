@@ -0,0 +1,153 @@
+package serviceaccount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	serviceAccountRepoModel "github.com/nogavadu/auth-service/internal/repository/serviceaccount/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+var columns = []string{"id", "client_id", "client_secret_hash", "name", "role_id", "scopes", "created_at", "disabled_at"}
+
+type serviceAccountRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.ServiceAccountRepository {
+	return &serviceAccountRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *serviceAccountRepository) Create(ctx context.Context, info *serviceAccountRepoModel.ServiceAccountInfo) (int, error) {
+	const op = "serviceAccountRepository.Create"
+
+	values := map[string]interface{}{
+		"client_id":          info.ClientId,
+		"client_secret_hash": info.ClientSecretHash,
+		"name":               info.Name,
+		"scopes":             info.Scopes,
+	}
+
+	if info.RoleId != 0 {
+		values["role_id"] = info.RoleId
+	}
+
+	queryRaw, args, err := sq.
+		Insert("service_accounts").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(values).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var id int
+	if err = r.dbc.DB().ScanOneContext(ctx, &id, query, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repo.PgErrAlreadyExistsCode {
+			return 0, fmt.Errorf("%s: %w", op, repo.ErrAlreadyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (r *serviceAccountRepository) GetById(ctx context.Context, id int) (*serviceAccountRepoModel.ServiceAccount, error) {
+	return r.getBy(ctx, "serviceAccountRepository.GetById", sq.Eq{"id": id})
+}
+
+func (r *serviceAccountRepository) GetByClientId(ctx context.Context, clientId string) (*serviceAccountRepoModel.ServiceAccount, error) {
+	return r.getBy(ctx, "serviceAccountRepository.GetByClientId", sq.Eq{"client_id": clientId})
+}
+
+func (r *serviceAccountRepository) UpdateSecret(ctx context.Context, id int, clientSecretHash string) error {
+	const op = "serviceAccountRepository.UpdateSecret"
+
+	queryRaw, args, err := sq.
+		Update("service_accounts").
+		PlaceholderFormat(sq.Dollar).
+		Set("client_secret_hash", clientSecretHash).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	return r.exec(ctx, op, queryRaw, args...)
+}
+
+func (r *serviceAccountRepository) Disable(ctx context.Context, id int) error {
+	const op = "serviceAccountRepository.Disable"
+
+	queryRaw, args, err := sq.
+		Update("service_accounts").
+		PlaceholderFormat(sq.Dollar).
+		Set("disabled_at", sq.Expr("COALESCE(disabled_at, now())")).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	return r.exec(ctx, op, queryRaw, args...)
+}
+
+func (r *serviceAccountRepository) getBy(ctx context.Context, op string, where sq.Eq) (*serviceAccountRepoModel.ServiceAccount, error) {
+	queryRaw, args, err := sq.
+		Select(columns...).
+		PlaceholderFormat(sq.Dollar).
+		From("service_accounts").
+		Where(where).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var account serviceAccountRepoModel.ServiceAccount
+	if err = r.dbc.DB().ScanOneContext(ctx, &account, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &account, nil
+}
+
+func (r *serviceAccountRepository) exec(ctx context.Context, op string, queryRaw string, args ...interface{}) error {
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
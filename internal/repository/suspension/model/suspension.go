@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type Suspension struct {
+	Id          int        `db:"id"`
+	UserId      int        `db:"user_id"`
+	Reason      string     `db:"reason"`
+	SuspendedBy *int       `db:"suspended_by"`
+	CreatedAt   time.Time  `db:"created_at"`
+	Until       *time.Time `db:"until"`
+	LiftedAt    *time.Time `db:"lifted_at"`
+	LiftedBy    *int       `db:"lifted_by"`
+}
+
+type SuspensionInfo struct {
+	UserId      int
+	Reason      string
+	SuspendedBy *int
+	// Until is nil for a ban that lasts until it is lifted.
+	Until *time.Time
+}
@@ -0,0 +1,160 @@
+package suspension
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	repo "github.com/nogavadu/auth-service/internal/repository"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+)
+
+var columns = []string{"id", "user_id", "reason", "suspended_by", "created_at", "until", "lifted_at", "lifted_by"}
+
+// active matches suspensions that are neither lifted nor expired.
+var active = sq.And{
+	sq.Eq{"lifted_at": nil},
+	sq.Or{sq.Eq{"until": nil}, sq.Expr("until > now()")},
+}
+
+type suspensionRepository struct {
+	dbc db.Client
+}
+
+func New(dbc db.Client) repo.SuspensionRepository {
+	return &suspensionRepository{
+		dbc: dbc,
+	}
+}
+
+func (r *suspensionRepository) Create(ctx context.Context, info *suspensionRepoModel.SuspensionInfo) (int, error) {
+	const op = "suspensionRepository.Create"
+
+	queryRaw, args, err := sq.
+		Insert("user_suspensions").
+		PlaceholderFormat(sq.Dollar).
+		SetMap(map[string]interface{}{
+			"user_id":      info.UserId,
+			"reason":       info.Reason,
+			"suspended_by": info.SuspendedBy,
+			"until":        info.Until,
+		}).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var id int
+	if err = r.dbc.DB().ScanOneContext(ctx, &id, query, args...); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (r *suspensionRepository) GetById(ctx context.Context, id int) (*suspensionRepoModel.Suspension, error) {
+	return r.getBy(ctx, "suspensionRepository.GetById", sq.Eq{"id": id})
+}
+
+// GetActive returns the user's current suspension, the latest one if they
+// somehow overlap.
+func (r *suspensionRepository) GetActive(ctx context.Context, userId int) (*suspensionRepoModel.Suspension, error) {
+	return r.getBy(ctx, "suspensionRepository.GetActive", sq.And{sq.Eq{"user_id": userId}, active})
+}
+
+func (r *suspensionRepository) ListByUserId(ctx context.Context, userId int) ([]*suspensionRepoModel.Suspension, error) {
+	const op = "suspensionRepository.ListByUserId"
+
+	queryRaw, args, err := sq.
+		Select(columns...).
+		PlaceholderFormat(sq.Dollar).
+		From("user_suspensions").
+		Where(sq.Eq{"user_id": userId}).
+		OrderBy("created_at DESC", "id DESC").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var suspensions []*suspensionRepoModel.Suspension
+	if err = r.dbc.DB().ScanAllContext(ctx, &suspensions, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return suspensions, nil
+}
+
+// Lift ends every active suspension of the user.
+func (r *suspensionRepository) Lift(ctx context.Context, userId int, liftedBy *int) error {
+	const op = "suspensionRepository.Lift"
+
+	queryRaw, args, err := sq.
+		Update("user_suspensions").
+		PlaceholderFormat(sq.Dollar).
+		Set("lifted_at", sq.Expr("now()")).
+		Set("lifted_by", liftedBy).
+		Where(sq.Eq{"user_id": userId}).
+		Where(active).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *suspensionRepository) getBy(ctx context.Context, op string, where sq.Sqlizer) (*suspensionRepoModel.Suspension, error) {
+	queryRaw, args, err := sq.
+		Select(columns...).
+		PlaceholderFormat(sq.Dollar).
+		From("user_suspensions").
+		Where(where).
+		OrderBy("created_at DESC", "id DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var suspension suspensionRepoModel.Suspension
+	if err = r.dbc.DB().ScanOneContext(ctx, &suspension, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &suspension, nil
+}
@@ -1,8 +1,36 @@
 package model
 
+import "time"
+
 type User struct {
 	Id int `db:"id"`
 	UserInfo
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	LastLoginAt *time.Time `db:"last_login_at"`
+	// DeletedAt starts the grace period, PurgedAt ends it: from then on the
+	// row is only a tombstone without personal data.
+	DeactivatedAt *time.Time `db:"deactivated_at"`
+	DeletedAt     *time.Time `db:"deleted_at"`
+	PurgedAt      *time.Time `db:"purged_at"`
+	// Tokens of sessions started before SessionsRevokedAt are rejected.
+	SessionsRevokedAt *time.Time `db:"sessions_revoked_at"`
+	// Attributes holds the custom attributes, which are validated against
+	// the attribute schema by the service.
+	Attributes map[string]interface{} `db:"attributes"`
+}
+
+// Disabled tells whether the user is deactivated or deleted, and so must
+// not be issued any tokens.
+func (u *User) Disabled() bool {
+	return u.DeactivatedAt != nil || u.DeletedAt != nil
+}
+
+// SessionRevoked tells whether a session authenticated at authTime has been
+// revoked. Tokens only carry whole seconds, so a session started in the same
+// second as the revocation counts as revoked.
+func (u *User) SessionRevoked(authTime time.Time) bool {
+	return u.SessionsRevokedAt != nil && !authTime.After(u.SessionsRevokedAt.Truncate(time.Second))
 }
 
 type UserInfo struct {
@@ -11,6 +39,8 @@ type UserInfo struct {
 	PassHash string  `db:"password_hash"`
 	Avatar   *string `db:"avatar"`
 	RoleId   int     `db:"role"`
+	// EmailVerifiedAt is set once the user proved they own Email.
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
 }
 
 type UserUpdateInput struct {
@@ -19,4 +49,70 @@ type UserUpdateInput struct {
 	Password *string `db:"password"`
 	Avatar   *string `db:"avatar"`
 	RoleId   *int    `db:"role"`
+	// ClearName and ClearAvatar set the columns to NULL.
+	ClearName   bool
+	ClearAvatar bool
+	// EmailVerified marks Email as proven to be the user's; writing an
+	// email without it leaves the user unverified.
+	EmailVerified bool
+}
+
+// PurgedUser is a user whose personal data has just been erased. AvatarKey
+// locates the avatar blobs, which are still to be deleted.
+type PurgedUser struct {
+	Id        int     `db:"id"`
+	AvatarKey *string `db:"avatar_key"`
+}
+
+const (
+	SortById        = "id"
+	SortByEmail     = "email"
+	SortByName      = "name"
+	SortByCreatedAt = "created_at"
+)
+
+const (
+	StatusActive      = "active"
+	StatusDeactivated = "deactivated"
+	StatusDeleted     = "deleted"
+)
+
+// ListFilter selects a page of users. Email and Name match substrings,
+// case-insensitively.
+type ListFilter struct {
+	RoleId *int
+	Email  string
+	Name   string
+	// EmailIs matches the whole email, case-insensitively.
+	EmailIs string
+	// MaxRoleLevel leaves out users whose role is above this level.
+	MaxRoleLevel *int
+	// Time ranges are inclusive of the lower and exclusive of the upper bound.
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	LastLoginAfter  *time.Time
+	LastLoginBefore *time.Time
+	// Status narrows the listing to one status. Deleted users are left out
+	// unless it is StatusDeleted.
+	Status string
+	// Suspended selects users with (true) or without (false) an active
+	// suspension.
+	Suspended *bool
+	// Verified selects users with (true) or without (false) a verified
+	// email.
+	Verified *bool
+
+	SortBy string
+	Desc   bool
+	// After continues the listing behind this position of the sort.
+	After *ListCursor
+	// Offset skips rows, for clients that page by index.
+	Offset uint64
+	Limit  uint64
+}
+
+type ListCursor struct {
+	// Key is the sort value: a string, or a time.Time for SortByCreatedAt.
+	Key interface{}
+	Id  int
 }
@@ -10,6 +10,8 @@ import (
 	repo "github.com/nogavadu/auth-service/internal/repository"
 	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
 	"github.com/nogavadu/platform_common/pkg/db"
+	"strings"
+	"time"
 )
 
 type userRepository struct {
@@ -33,6 +35,13 @@ func (r *userRepository) Create(ctx context.Context, info *userRepoModel.UserInf
 	if info.Name != nil {
 		values["name"] = *info.Name
 	}
+	if info.EmailVerifiedAt != nil {
+		values["email_verified_at"] = *info.EmailVerifiedAt
+	}
+	// Zero leaves the column default.
+	if info.RoleId != 0 {
+		values["role"] = info.RoleId
+	}
 
 	queryRaw, args, err := sq.
 		Insert("users").
@@ -66,7 +75,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*userRep
 	const op = "userRepository.GetByEmail"
 
 	queryRaw, args, err := sq.
-		Select("id", "name", "email", "avatar", "password_hash", "role").
+		Select("id", "name", "email", "avatar", "password_hash", "role", "created_at", "updated_at", "last_login_at", "deactivated_at", "deleted_at", "purged_at", "sessions_revoked_at", "attributes", "email_verified_at").
 		PlaceholderFormat(sq.Dollar).
 		From("users").
 		Where(sq.Eq{"email": email}).
@@ -97,7 +106,7 @@ func (r *userRepository) GetById(ctx context.Context, id int) (*userRepoModel.Us
 	const op = "userRepository.GetById"
 
 	queryRaw, args, err := sq.
-		Select("id", "name", "email", "avatar", "password_hash", "role").
+		Select("id", "name", "email", "avatar", "password_hash", "role", "created_at", "updated_at", "last_login_at", "deactivated_at", "deleted_at", "purged_at", "sessions_revoked_at", "attributes", "email_verified_at").
 		PlaceholderFormat(sq.Dollar).
 		From("users").
 		Where(sq.Eq{"id": id}).
@@ -124,18 +133,122 @@ func (r *userRepository) GetById(ctx context.Context, id int) (*userRepoModel.Us
 	return &user, nil
 }
 
+// Lock takes a row lock on the user until the surrounding transaction ends,
+// serializing changes that must see each other, like removing login methods.
+func (r *userRepository) Lock(ctx context.Context, id int) error {
+	const op = "userRepository.Lock"
+
+	queryRaw, args, err := sq.
+		Select("id").
+		PlaceholderFormat(sq.Dollar).
+		From("users").
+		Where(sq.Eq{"id": id}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var lockedId int
+	if err = r.dbc.DB().ScanOneContext(ctx, &lockedId, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sortExpressions keeps the sort key non-null, so it can take part in the
+// keyset comparison of the cursor.
+var sortExpressions = map[string]string{
+	userRepoModel.SortById:        "id",
+	userRepoModel.SortByEmail:     "email",
+	userRepoModel.SortByName:      "COALESCE(name, '')",
+	userRepoModel.SortByCreatedAt: "created_at",
+}
+
+func (r *userRepository) List(ctx context.Context, filter *userRepoModel.ListFilter) ([]*userRepoModel.User, error) {
+	const op = "userRepository.List"
+
+	sortExpr, ok := sortExpressions[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown sort %q", op, filter.SortBy)
+	}
+
+	builder := applyListFilter(sq.
+		Select("id", "name", "email", "avatar", "password_hash", "role", "created_at", "updated_at", "last_login_at", "deactivated_at", "deleted_at", "purged_at", "sessions_revoked_at", "attributes", "email_verified_at").
+		PlaceholderFormat(sq.Dollar).
+		From("users"), filter)
+
+	cmp, order := ">", "ASC"
+	if filter.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	if filter.After != nil {
+		if filter.SortBy == userRepoModel.SortById {
+			builder = builder.Where(sq.Expr("id "+cmp+" ?", filter.After.Id))
+		} else {
+			builder = builder.Where(sq.Expr("("+sortExpr+", id) "+cmp+" (?, ?)", filter.After.Key, filter.After.Id))
+		}
+	}
+
+	if filter.SortBy == userRepoModel.SortById {
+		builder = builder.OrderBy("id " + order)
+	} else {
+		builder = builder.OrderBy(sortExpr+" "+order, "id "+order)
+	}
+
+	queryRaw, args, err := builder.
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var users []*userRepoModel.User
+	if err = r.dbc.DB().ScanAllContext(ctx, &users, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
 func (r *userRepository) Update(ctx context.Context, id int, input *userRepoModel.UserUpdateInput) error {
 	const op = "userRepository.Update"
 
 	values := map[string]interface{}{}
 	if input.Email != nil {
 		values["email"] = *input.Email
+		if input.EmailVerified {
+			values["email_verified_at"] = sq.Expr("now()")
+		} else {
+			values["email_verified_at"] = nil
+		}
 	}
 	if input.Name != nil {
 		values["name"] = *input.Name
+	} else if input.ClearName {
+		values["name"] = nil
 	}
 	if input.Avatar != nil {
 		values["avatar"] = *input.Avatar
+	} else if input.ClearAvatar {
+		values["avatar"] = nil
 	}
 	if input.Password != nil {
 		values["password_hash"] = *input.Password
@@ -144,12 +257,45 @@ func (r *userRepository) Update(ctx context.Context, id int, input *userRepoMode
 		values["role"] = *input.RoleId
 	}
 
-	fmt.Println(values)
-
 	queryRaw, args, err := sq.
 		Update("users").
 		PlaceholderFormat(sq.Dollar).
 		SetMap(values).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id, "deleted_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repo.PgErrAlreadyExistsCode {
+			return fmt.Errorf("%s: %w", op, repo.ErrAlreadyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *userRepository) TouchLogin(ctx context.Context, id int) error {
+	const op = "userRepository.TouchLogin"
+
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("last_login_at", sq.Expr("now()")).
 		Where(sq.Eq{"id": id}).
 		ToSql()
 	if err != nil {
@@ -161,21 +307,203 @@ func (r *userRepository) Update(ctx context.Context, id int, input *userRepoMode
 		QueryRaw: queryRaw,
 	}
 
-	_, err = r.dbc.DB().ExecContext(ctx, query, args...)
+	if _, err = r.dbc.DB().ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateAttributes merges set into the user's attributes and removes the
+// keys in remove, without reading them first.
+func (r *userRepository) UpdateAttributes(ctx context.Context, id int, set map[string]interface{}, remove []string) error {
+	const op = "userRepository.UpdateAttributes"
+
+	if set == nil {
+		set = map[string]interface{}{}
+	}
+	if remove == nil {
+		remove = []string{}
+	}
+
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("attributes", sq.Expr("(attributes - ?::text[]) || ?::jsonb", remove, set)).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id, "deleted_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
 
 	return nil
 }
 
+// SetAvatar sets the avatar URL and the key of its blobs, both nil to clear
+// the avatar, and returns the previous key.
+func (r *userRepository) SetAvatar(ctx context.Context, id int, avatar *string, avatarKey *string) (*string, error) {
+	const op = "userRepository.SetAvatar"
+
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("avatar", avatar).
+		Set("avatar_key", avatarKey).
+		Set("updated_at", sq.Expr("now()")).
+		FromSelect(
+			sq.Select("id", "avatar_key").
+				From("users").
+				Where(sq.Eq{"id": id, "deleted_at": nil}).
+				Suffix("FOR UPDATE"),
+			"previous",
+		).
+		Where("users.id = previous.id").
+		Suffix("RETURNING previous.avatar_key").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var previousKey *string
+	if err = r.dbc.DB().ScanOneContext(ctx, &previousKey, query, args...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return previousKey, nil
+}
+
+// RevokeSessions invalidates the user's refresh and access tokens issued so
+// far.
+func (r *userRepository) RevokeSessions(ctx context.Context, id int) error {
+	const op = "userRepository.RevokeSessions"
+
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("sessions_revoked_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetDeactivated deactivates or reactivates a user that is not deleted.
+func (r *userRepository) SetDeactivated(ctx context.Context, id int, deactivated bool) error {
+	const op = "userRepository.SetDeactivated"
+
+	var deactivatedAt interface{}
+	if deactivated {
+		deactivatedAt = sq.Expr("COALESCE(deactivated_at, now())")
+	}
+
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("deactivated_at", deactivatedAt).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id, "deleted_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Delete marks a user as deleted. The row stays until Purge erases its
+// personal data, so Restore can undo this in the meantime.
 func (r *userRepository) Delete(ctx context.Context, id int) error {
 	const op = "userRepository.Delete"
 
 	queryRaw, args, err := sq.
-		Delete("users").
+		Update("users").
 		PlaceholderFormat(sq.Dollar).
-		Where(sq.Eq{"id": id}).
+		Set("deleted_at", sq.Expr("now()")).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id, "deleted_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *userRepository) Restore(ctx context.Context, id int) error {
+	const op = "userRepository.Restore"
+
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("deleted_at", nil).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id, "purged_at": nil}).
+		Where(sq.NotEq{"deleted_at": nil}).
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("%s: failed to build query: %w", op, err)
@@ -186,10 +514,150 @@ func (r *userRepository) Delete(ctx context.Context, id int) error {
 		QueryRaw: queryRaw,
 	}
 
-	_, err = r.dbc.DB().ExecContext(ctx, query, args...)
+	tag, err := r.dbc.DB().ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repo.ErrNotFound)
+	}
 
 	return nil
 }
+
+// Purge erases the personal data of up to limit users deleted before the
+// given time and returns their ids. The email is replaced rather than
+// cleared, since it has to stay unique. Rows locked by a concurrent purge
+// are skipped.
+func (r *userRepository) Purge(ctx context.Context, deletedBefore time.Time, limit uint64) ([]*userRepoModel.PurgedUser, error) {
+	const op = "userRepository.Purge"
+
+	// The avatar key is returned from before the update, so that the caller
+	// can delete the blobs.
+	queryRaw, args, err := sq.
+		Update("users").
+		PlaceholderFormat(sq.Dollar).
+		Set("email", sq.Expr("'purged-' || users.id || '@invalid'")).
+		Set("name", nil).
+		Set("avatar", nil).
+		Set("avatar_key", nil).
+		Set("email_verified_at", nil).
+		Set("password_hash", "").
+		Set("attributes", sq.Expr("'{}'::jsonb")).
+		Set("purged_at", sq.Expr("now()")).
+		Set("updated_at", sq.Expr("now()")).
+		FromSelect(
+			sq.Select("id", "avatar_key").
+				From("users").
+				Where(sq.Expr("deleted_at < ? AND purged_at IS NULL", deletedBefore)).
+				OrderBy("deleted_at").
+				Limit(limit).
+				Suffix("FOR UPDATE SKIP LOCKED"),
+			"purged",
+		).
+		Where("users.id = purged.id").
+		Suffix("RETURNING users.id, purged.avatar_key").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var users []*userRepoModel.PurgedUser
+	if err = r.dbc.DB().ScanAllContext(ctx, &users, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// Count is the number of users matching the filter, regardless of its
+// cursor, offset and limit.
+func (r *userRepository) Count(ctx context.Context, filter *userRepoModel.ListFilter) (int, error) {
+	const op = "userRepository.Count"
+
+	queryRaw, args, err := applyListFilter(sq.
+		Select("count(*)").
+		PlaceholderFormat(sq.Dollar).
+		From("users"), filter).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	query := db.Query{
+		Name:     op,
+		QueryRaw: queryRaw,
+	}
+
+	var count int
+	if err = r.dbc.DB().ScanOneContext(ctx, &count, query, args...); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+func applyListFilter(builder sq.SelectBuilder, filter *userRepoModel.ListFilter) sq.SelectBuilder {
+	if filter.RoleId != nil {
+		builder = builder.Where(sq.Eq{"role": *filter.RoleId})
+	}
+	if filter.EmailIs != "" {
+		builder = builder.Where(sq.Expr("lower(email) = lower(?)", filter.EmailIs))
+	}
+	if filter.MaxRoleLevel != nil {
+		builder = builder.Where(sq.Expr("role IN (SELECT id FROM roles WHERE level <= ?)", *filter.MaxRoleLevel))
+	}
+	if filter.Email != "" {
+		builder = builder.Where(sq.ILike{"email": "%" + escapeLike(filter.Email) + "%"})
+	}
+	if filter.Name != "" {
+		builder = builder.Where(sq.ILike{"name": "%" + escapeLike(filter.Name) + "%"})
+	}
+	switch filter.Status {
+	case userRepoModel.StatusActive:
+		builder = builder.Where(sq.Eq{"deactivated_at": nil, "deleted_at": nil})
+	case userRepoModel.StatusDeactivated:
+		builder = builder.Where(sq.And{sq.NotEq{"deactivated_at": nil}, sq.Eq{"deleted_at": nil}})
+	case userRepoModel.StatusDeleted:
+		builder = builder.Where(sq.NotEq{"deleted_at": nil})
+	default:
+		builder = builder.Where(sq.Eq{"deleted_at": nil})
+	}
+	if filter.Suspended != nil {
+		suspended := "EXISTS (SELECT 1 FROM user_suspensions s WHERE s.user_id = users.id AND s.lifted_at IS NULL AND (s.until IS NULL OR s.until > now()))"
+		if !*filter.Suspended {
+			suspended = "NOT " + suspended
+		}
+		builder = builder.Where(sq.Expr(suspended))
+	}
+	if filter.Verified != nil {
+		if *filter.Verified {
+			builder = builder.Where(sq.NotEq{"email_verified_at": nil})
+		} else {
+			builder = builder.Where(sq.Eq{"email_verified_at": nil})
+		}
+	}
+	if filter.CreatedAfter != nil {
+		builder = builder.Where(sq.GtOrEq{"created_at": *filter.CreatedAfter})
+	}
+	if filter.CreatedBefore != nil {
+		builder = builder.Where(sq.Lt{"created_at": *filter.CreatedBefore})
+	}
+	if filter.LastLoginAfter != nil {
+		builder = builder.Where(sq.GtOrEq{"last_login_at": *filter.LastLoginAfter})
+	}
+	if filter.LastLoginBefore != nil {
+		builder = builder.Where(sq.Lt{"last_login_at": *filter.LastLoginBefore})
+	}
+
+	return builder
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -2,20 +2,32 @@ package access
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
 	"github.com/nogavadu/auth-service/internal/repository"
 	"github.com/nogavadu/auth-service/internal/service"
 	"github.com/nogavadu/auth-service/internal/utils"
 	"log/slog"
+	"strings"
 	"time"
 )
 
 var (
 	ErrInvalidToken     = errors.New("invalid token")
 	ErrPermissionDenied = errors.New("access denied")
+	ErrUserSuspended    = errors.New("user is suspended")
 	ErrInternal         = errors.New("internal error")
 )
 
+const (
+	ReasonAllowed           = "allowed"
+	ReasonInsufficientLevel = "insufficient role level"
+	ReasonMissingPermission = "missing permission"
+	ReasonInvalidItem       = "neither level nor permission is specified"
+)
+
 type accessService struct {
 	log *slog.Logger
 
@@ -23,9 +35,13 @@ type accessService struct {
 	refreshTokenExpTime time.Duration
 	accessTokenSecret   string
 	accessTokenExpTime  time.Duration
+	accessTokenKey      *rsa.PrivateKey
 
-	userRepo repository.UserRepository
-	roleRepo repository.RoleRepository
+	userRepo           repository.UserRepository
+	roleRepo           repository.RoleRepository
+	serviceAccountRepo repository.ServiceAccountRepository
+	apiKeyRepo         repository.APIKeyRepository
+	suspensionRepo     repository.SuspensionRepository
 }
 
 func New(
@@ -34,8 +50,12 @@ func New(
 	refreshTokenExp time.Duration,
 	accessTokenSecret string,
 	accessTokenExp time.Duration,
+	accessTokenKey *rsa.PrivateKey,
 	userRepo repository.UserRepository,
 	roleRepo repository.RoleRepository,
+	serviceAccountRepo repository.ServiceAccountRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	suspensionRepo repository.SuspensionRepository,
 ) service.AccessService {
 	return &accessService{
 		log:                 log,
@@ -43,34 +63,296 @@ func New(
 		refreshTokenExpTime: refreshTokenExp,
 		accessTokenSecret:   accessTokenSecret,
 		accessTokenExpTime:  accessTokenExp,
+		accessTokenKey:      accessTokenKey,
 		userRepo:            userRepo,
 		roleRepo:            roleRepo,
+		serviceAccountRepo:  serviceAccountRepo,
+		apiKeyRepo:          apiKeyRepo,
+		suspensionRepo:      suspensionRepo,
 	}
 }
 
-func (s *accessService) Check(ctx context.Context, accessToken string, requiredLvl int) error {
+// Check authenticates the token and demands the role level. The principal
+// comes without permissions, which cost a query that most checks don't need.
+func (s *accessService) Check(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
 	const op = "accessService.Check"
 
+	return s.check(ctx, op, accessToken, requiredLvl, false)
+}
+
+// CheckV2 is Check that also loads the permissions of the principal's role.
+func (s *accessService) CheckV2(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error) {
+	const op = "accessService.CheckV2"
+
+	return s.check(ctx, op, accessToken, requiredLvl, true)
+}
+
+func (s *accessService) check(ctx context.Context, op string, accessToken string, requiredLvl int, withPermissions bool) (*model.Principal, error) {
 	log := s.log.With(slog.String("op", op))
 
-	claims, err := utils.VerifyToken(accessToken, s.accessTokenSecret)
+	claims, err := s.verifyAccessToken(ctx, accessToken)
 	if err != nil {
-		return ErrInvalidToken
+		if errors.Is(err, ErrInternal) {
+			log.Error("failed to verify access token", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+		if errors.Is(err, ErrUserSuspended) {
+			return nil, ErrUserSuspended
+		}
+
+		return nil, ErrInvalidToken
 	}
 
 	role, err := s.roleRepo.GetByName(ctx, claims.Role)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			return ErrInvalidToken
+			return nil, ErrInvalidToken
 		}
 
 		log.Error("failed to get role", slog.String("error", err.Error()))
-		return ErrInternal
+		return nil, ErrInternal
 	}
 
 	if role.Level < requiredLvl {
-		return ErrPermissionDenied
+		return nil, ErrPermissionDenied
+	}
+
+	principal := &model.Principal{
+		UserId:    claims.Id,
+		Email:     claims.Email,
+		Role:      role.Name,
+		RoleLevel: role.Level,
+		SessionId: claims.SessionId,
+		ClientId:  claims.ClientId,
+		APIKeyId:  claims.APIKeyId,
+		Scopes:    strings.Fields(claims.Scope),
+	}
+	if withPermissions {
+		principal.Permissions, err = s.getPermissions(ctx, int(role.ID))
+		if err != nil {
+			log.Error("failed to get role permissions", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+	}
+	if claims.ExpiresAt != 0 {
+		principal.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+	if claims.AuthTime != 0 {
+		principal.AuthTime = time.Unix(claims.AuthTime, 0)
+	}
+
+	return principal, nil
+}
+
+func (s *accessService) BatchCheck(ctx context.Context, accessToken string, items []*model.AccessItem) ([]*model.AccessDecision, error) {
+	const op = "accessService.BatchCheck"
+
+	log := s.log.With(slog.String("op", op))
+
+	claims, err := s.verifyAccessToken(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, ErrInternal) {
+			log.Error("failed to verify access token", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+		if errors.Is(err, ErrUserSuspended) {
+			return nil, ErrUserSuspended
+		}
+
+		return nil, ErrInvalidToken
+	}
+
+	role, err := s.roleRepo.GetByName(ctx, claims.Role)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+
+		log.Error("failed to get role", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	principal := &model.Principal{
+		ClientId: claims.ClientId,
+		APIKeyId: claims.APIKeyId,
+		Scopes:   strings.Fields(claims.Scope),
+	}
+	for _, item := range items {
+		if item.Permission == nil {
+			continue
+		}
+
+		principal.Permissions, err = s.getPermissions(ctx, int(role.ID))
+		if err != nil {
+			log.Error("failed to get role permissions", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+		break
+	}
+
+	decisions := make([]*model.AccessDecision, 0, len(items))
+	for _, item := range items {
+		switch {
+		case item.RequiredLvl != nil:
+			if role.Level < *item.RequiredLvl {
+				decisions = append(decisions, &model.AccessDecision{Reason: ReasonInsufficientLevel})
+				continue
+			}
+		case item.Permission != nil:
+			if !principal.HasPermission(*item.Permission, item.Resource) {
+				decisions = append(decisions, &model.AccessDecision{Reason: ReasonMissingPermission})
+				continue
+			}
+		default:
+			decisions = append(decisions, &model.AccessDecision{Reason: ReasonInvalidItem})
+			continue
+		}
+
+		decisions = append(decisions, &model.AccessDecision{Allowed: true, Reason: ReasonAllowed})
+	}
+
+	return decisions, nil
+}
+
+func (s *accessService) getPermissions(ctx context.Context, roleId int) ([]*model.Permission, error) {
+	repoPermissions, err := s.roleRepo.GetPermissions(ctx, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]*model.Permission, 0, len(repoPermissions))
+	for _, p := range repoPermissions {
+		permissions = append(permissions, &model.Permission{
+			Permission: p.Permission,
+			Resource:   p.Resource,
+		})
+	}
+
+	return permissions, nil
+}
+
+func (s *accessService) verifyAccessToken(ctx context.Context, accessToken string) (*model.UserClaims, error) {
+	if utils.IsAPIKey(accessToken) {
+		return s.verifyAPIKey(ctx, accessToken)
+	}
+
+	var claims *model.UserClaims
+	var err error
+	if s.accessTokenKey != nil {
+		claims, err = utils.VerifyRSAToken(accessToken, &s.accessTokenKey.PublicKey)
+	} else {
+		claims, err = utils.VerifyToken(accessToken, s.accessTokenSecret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens of a disabled service account stop working immediately rather
+	// than when they expire.
+	if claims.ClientId != "" {
+		account, err := s.serviceAccountRepo.GetByClientId(ctx, claims.ClientId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrInvalidToken
+			}
+
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		if account.DisabledAt != nil {
+			return nil, ErrInvalidToken
+		}
+
+		return claims, nil
+	}
+
+	// The same goes for users who were deactivated, suspended or had their
+	// sessions revoked.
+	user, err := s.userRepo.GetById(ctx, claims.Id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if user.Disabled() || user.SessionRevoked(time.Unix(claims.AuthTime, 0)) {
+		return nil, ErrInvalidToken
+	}
+	if err = s.checkSuspension(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (s *accessService) checkSuspension(ctx context.Context, userId int) error {
+	_, err := s.suspensionRepo.GetActive(ctx, userId)
+	if err == nil {
+		return ErrUserSuspended
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
 	}
 
 	return nil
 }
+
+// verifyAPIKey resolves an opaque API key to the claims its owner would have
+// in an access token, so the rest of the checks treat both alike.
+func (s *accessService) verifyAPIKey(ctx context.Context, apiKey string) (*model.UserClaims, error) {
+	const op = "accessService.verifyAPIKey"
+
+	key, err := s.apiKeyRepo.GetByHash(ctx, utils.HashAPIKey(apiKey))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetById(ctx, key.UserId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if user.Disabled() {
+		return nil, ErrInvalidToken
+	}
+	if err = s.checkSuspension(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	role, err := s.roleRepo.GetById(ctx, user.RoleId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	if err = s.apiKeyRepo.Touch(ctx, key.Id); err != nil {
+		s.log.Warn("failed to update api key last use", slog.String("op", op), slog.String("error", err.Error()))
+	}
+
+	claims := &model.UserClaims{
+		Id:       user.Id,
+		Email:    user.Email,
+		Role:     role.Name,
+		Scope:    strings.Join(key.Scopes, " "),
+		APIKeyId: key.Id,
+	}
+	if key.ExpiresAt != nil {
+		claims.ExpiresAt = key.ExpiresAt.Unix()
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,244 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	apiKeyRepoModel "github.com/nogavadu/auth-service/internal/repository/apikey/model"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/utils"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "test-access-secret"
+
+	moderatorId = 42
+	// suspendedId is a moderator under a suspension.
+	suspendedId = 43
+)
+
+var moderatorRole = &roleRepoModel.Role{ID: 2, Name: "moderator", Level: 10}
+
+type userRepo struct {
+	repository.UserRepository
+}
+
+func (r *userRepo) GetById(_ context.Context, id int) (*userRepoModel.User, error) {
+	if id != moderatorId && id != suspendedId {
+		return nil, repository.ErrNotFound
+	}
+
+	return &userRepoModel.User{Id: id, UserInfo: userRepoModel.UserInfo{RoleId: int(moderatorRole.ID)}}, nil
+}
+
+// roleRepo knows the moderator role and counts the permission lookups.
+type roleRepo struct {
+	repository.RoleRepository
+	permissionLookups int
+}
+
+func (r *roleRepo) GetByName(_ context.Context, name string) (*roleRepoModel.Role, error) {
+	if name != moderatorRole.Name {
+		return nil, repository.ErrNotFound
+	}
+
+	return moderatorRole, nil
+}
+
+func (r *roleRepo) GetById(_ context.Context, id int) (*roleRepoModel.Role, error) {
+	if id != int(moderatorRole.ID) {
+		return nil, repository.ErrNotFound
+	}
+
+	return moderatorRole, nil
+}
+
+func (r *roleRepo) GetPermissions(_ context.Context, _ int) ([]*roleRepoModel.Permission, error) {
+	r.permissionLookups++
+
+	return []*roleRepoModel.Permission{
+		{Permission: "users:read", Resource: "*"},
+		{Permission: "users:suspend", Resource: "7"},
+	}, nil
+}
+
+// apiKeyRepo knows one key of the moderator's, scoped to users:read.
+type apiKeyRepo struct {
+	repository.APIKeyRepository
+	hash string
+}
+
+func (r *apiKeyRepo) GetByHash(_ context.Context, keyHash string) (*apiKeyRepoModel.APIKey, error) {
+	if keyHash != r.hash {
+		return nil, repository.ErrNotFound
+	}
+
+	return &apiKeyRepoModel.APIKey{Id: 1, UserId: moderatorId, KeyHash: r.hash, Scopes: []string{"users:read"}}, nil
+}
+
+func (r *apiKeyRepo) Touch(context.Context, int) error {
+	return nil
+}
+
+type suspensionRepo struct {
+	repository.SuspensionRepository
+}
+
+func (r *suspensionRepo) GetActive(_ context.Context, userId int) (*suspensionRepoModel.Suspension, error) {
+	if userId != suspendedId {
+		return nil, repository.ErrNotFound
+	}
+
+	return &suspensionRepoModel.Suspension{Id: 1, UserId: userId}, nil
+}
+
+type testEnv struct {
+	serv     *accessService
+	roleRepo *roleRepo
+	apiKey   string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	apiKey, apiKeyHash, _, err := utils.NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := &roleRepo{}
+	return &testEnv{
+		serv: &accessService{
+			log:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+			accessTokenSecret: testSecret,
+			userRepo:          &userRepo{},
+			roleRepo:          roles,
+			apiKeyRepo:        &apiKeyRepo{hash: apiKeyHash},
+			suspensionRepo:    &suspensionRepo{},
+		},
+		roleRepo: roles,
+		apiKey:   apiKey,
+	}
+}
+
+func accessToken(t *testing.T, userId int) string {
+	t.Helper()
+
+	user := &model.User{Id: userId, UserInfo: model.UserInfo{Role: moderatorRole.Name}}
+	token, err := utils.GenerateToken(user, "session-1", time.Now(), testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func level(lvl int) *int {
+	return &lvl
+}
+
+func permission(p string) *string {
+	return &p
+}
+
+func TestBatchCheck(t *testing.T) {
+	items := []*model.AccessItem{
+		{RequiredLvl: level(10)},
+		{RequiredLvl: level(11)},
+		{Permission: permission("users:read"), Resource: "8"},
+		{Permission: permission("users:suspend"), Resource: "7"},
+		{Permission: permission("users:suspend"), Resource: "8"},
+		{Resource: "8"},
+	}
+
+	tests := []struct {
+		name        string
+		token       func(env *testEnv) string
+		wantErr     error
+		wantReasons []string
+	}{
+		{
+			name:  "access token",
+			token: func(*testEnv) string { return accessToken(t, moderatorId) },
+			wantReasons: []string{
+				ReasonAllowed,
+				ReasonInsufficientLevel,
+				ReasonAllowed,
+				ReasonAllowed,
+				ReasonMissingPermission,
+				ReasonInvalidItem,
+			},
+		},
+		{
+			// The key's scopes cut its owner's permissions down to users:read.
+			name:  "api key",
+			token: func(env *testEnv) string { return env.apiKey },
+			wantReasons: []string{
+				ReasonAllowed,
+				ReasonInsufficientLevel,
+				ReasonAllowed,
+				ReasonMissingPermission,
+				ReasonMissingPermission,
+				ReasonInvalidItem,
+			},
+		},
+		{name: "suspended user", token: func(*testEnv) string { return accessToken(t, suspendedId) }, wantErr: ErrUserSuspended},
+		{name: "unknown user", token: func(*testEnv) string { return accessToken(t, 44) }, wantErr: ErrInvalidToken},
+		{name: "bogus token", token: func(*testEnv) string { return "bogus" }, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+
+			decisions, err := env.serv.BatchCheck(context.Background(), tt.token(env), items)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if len(decisions) != len(items) {
+				t.Fatalf("got %d decisions, want %d", len(decisions), len(items))
+			}
+			for i, decision := range decisions {
+				if decision.Reason != tt.wantReasons[i] {
+					t.Errorf("item %d: got reason %q, want %q", i, decision.Reason, tt.wantReasons[i])
+				}
+				if decision.Allowed != (tt.wantReasons[i] == ReasonAllowed) {
+					t.Errorf("item %d: got allowed %v for reason %q", i, decision.Allowed, decision.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchCheckLoadsPermissionsOnlyWhenNeeded(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       []*model.AccessItem
+		wantLookups int
+	}{
+		{name: "levels only", items: []*model.AccessItem{{RequiredLvl: level(1)}, {RequiredLvl: level(5)}}},
+		{name: "permissions", items: []*model.AccessItem{{Permission: permission("users:read")}, {Permission: permission("users:suspend")}}, wantLookups: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+
+			if _, err := env.serv.BatchCheck(context.Background(), accessToken(t, moderatorId), tt.items); err != nil {
+				t.Fatal(err)
+			}
+			if env.roleRepo.permissionLookups != tt.wantLookups {
+				t.Errorf("looked permissions up %d times, want %d", env.roleRepo.permissionLookups, tt.wantLookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	apiKeyRepoModel "github.com/nogavadu/auth-service/internal/repository/apikey/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/utils"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("api key not found")
+	ErrInvalidExpiresAt = errors.New("expires_at must be in the future")
+	ErrInvalidScope     = errors.New("unknown scope")
+	ErrScopeNotGranted  = errors.New("scope is not granted to the caller")
+	ErrOutlivesCaller   = errors.New("expires_at is after the caller's credentials expire")
+	ErrInternal         = errors.New("internal error")
+)
+
+type apiKeyService struct {
+	log *slog.Logger
+
+	apiKeyRepo repository.APIKeyRepository
+	roleRepo   repository.RoleRepository
+}
+
+func New(
+	log *slog.Logger,
+	apiKeyRepo repository.APIKeyRepository,
+	roleRepo repository.RoleRepository,
+) service.APIKeyService {
+	return &apiKeyService{
+		log:        log,
+		apiKeyRepo: apiKeyRepo,
+		roleRepo:   roleRepo,
+	}
+}
+
+// Create issues an API key to owner. A key never gets more than the
+// credentials it was created with: a scoped owner can only pass on its own
+// scopes, and the key expires no later than they do.
+func (s *apiKeyService) Create(ctx context.Context, owner *model.Principal, name string, scopes []string, expiresAt *time.Time) (*model.APIKey, string, error) {
+	const op = "apiKeyService.Create"
+	log := s.log.With(slog.String("op", op))
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidExpiresAt
+	}
+
+	if err := s.checkScopes(ctx, scopes); err != nil {
+		if errors.Is(err, ErrInvalidScope) {
+			return nil, "", ErrInvalidScope
+		}
+
+		log.Error("failed to list permissions", slog.String("error", err.Error()))
+		return nil, "", ErrInternal
+	}
+
+	if owner.Scoped() {
+		for _, scope := range scopes {
+			if !owner.HasScope(scope) {
+				return nil, "", ErrScopeNotGranted
+			}
+		}
+
+		if !owner.ExpiresAt.IsZero() {
+			if expiresAt == nil {
+				expiresAt = &owner.ExpiresAt
+			} else if expiresAt.After(owner.ExpiresAt) {
+				return nil, "", ErrOutlivesCaller
+			}
+		}
+	}
+
+	key, keyHash, hint, err := utils.NewAPIKey()
+	if err != nil {
+		log.Error("failed to generate api key", slog.String("error", err.Error()))
+		return nil, "", ErrInternal
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	id, err := s.apiKeyRepo.Create(ctx, &apiKeyRepoModel.APIKeyInfo{
+		UserId:    owner.UserId,
+		Name:      name,
+		KeyHash:   keyHash,
+		Hint:      hint,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Error("failed to create api key", slog.String("error", err.Error()))
+		return nil, "", ErrInternal
+	}
+
+	repoKey, err := s.apiKeyRepo.GetById(ctx, id)
+	if err != nil {
+		log.Error("failed to get api key", slog.String("error", err.Error()))
+		return nil, "", ErrInternal
+	}
+
+	return toModel(repoKey), key, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userId int) ([]*model.APIKey, error) {
+	const op = "apiKeyService.List"
+	log := s.log.With(slog.String("op", op))
+
+	repoKeys, err := s.apiKeyRepo.ListByUserId(ctx, userId)
+	if err != nil {
+		log.Error("failed to list api keys", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	keys := make([]*model.APIKey, 0, len(repoKeys))
+	for _, k := range repoKeys {
+		keys = append(keys, toModel(k))
+	}
+
+	return keys, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userId int, id int) error {
+	const op = "apiKeyService.Revoke"
+	log := s.log.With(slog.String("op", op))
+
+	if err := s.apiKeyRepo.Revoke(ctx, userId, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		log.Error("failed to revoke api key", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// checkScopes makes sure every scope names a permission that some role can
+// be granted, so typos don't silently produce keys that allow nothing.
+func (s *apiKeyService) checkScopes(ctx context.Context, scopes []string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	known, err := s.roleRepo.ListPermissionNames(ctx)
+	if err != nil {
+		return err
+	}
+	known = slices.DeleteFunc(append(known, model.BuiltinPermissions...), func(permission string) bool {
+		return strings.HasSuffix(permission, "*")
+	})
+
+	for _, scope := range scopes {
+		if !model.ScopePattern(scope, known) {
+			return ErrInvalidScope
+		}
+	}
+
+	return nil
+}
+
+func toModel(key *apiKeyRepoModel.APIKey) *model.APIKey {
+	return &model.APIKey{
+		Id:         key.Id,
+		UserId:     key.UserId,
+		Name:       key.Name,
+		Hint:       key.Hint,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,96 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	apiKeyRepoModel "github.com/nogavadu/auth-service/internal/repository/apikey/model"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+type apiKeyRepo struct {
+	repository.APIKeyRepository
+	keys []*apiKeyRepoModel.APIKey
+}
+
+func (r *apiKeyRepo) Create(_ context.Context, info *apiKeyRepoModel.APIKeyInfo) (int, error) {
+	r.keys = append(r.keys, &apiKeyRepoModel.APIKey{
+		Id:        len(r.keys) + 1,
+		UserId:    info.UserId,
+		Name:      info.Name,
+		Scopes:    info.Scopes,
+		ExpiresAt: info.ExpiresAt,
+	})
+
+	return len(r.keys), nil
+}
+
+func (r *apiKeyRepo) GetById(_ context.Context, id int) (*apiKeyRepoModel.APIKey, error) {
+	return r.keys[id-1], nil
+}
+
+// roleRepo grants one permission beyond the built-in ones, and a wildcard
+// that must not make every scope valid.
+type roleRepo struct {
+	repository.RoleRepository
+}
+
+func (r *roleRepo) ListPermissionNames(_ context.Context) ([]string, error) {
+	return []string{"*", "orders:read"}, nil
+}
+
+func TestCreate(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	later := expiresAt.Add(time.Hour)
+	earlier := expiresAt.Add(-time.Minute)
+
+	user := &model.Principal{UserId: 7}
+	scopedKey := &model.Principal{UserId: 7, APIKeyId: 1, Scopes: []string{"users:*"}, ExpiresAt: expiresAt}
+
+	tests := []struct {
+		name          string
+		owner         *model.Principal
+		scopes        []string
+		expiresAt     *time.Time
+		wantErr       error
+		wantExpiresAt *time.Time
+	}{
+		{name: "built-in scope", owner: user, scopes: []string{"users:read"}},
+		{name: "role permission scope", owner: user, scopes: []string{"orders:read"}},
+		{name: "wildcard scope", owner: user, scopes: []string{"*"}},
+		{name: "pattern scope", owner: user, scopes: []string{"orders:*"}},
+		{name: "unknown scope", owner: user, scopes: []string{"users:reed"}, wantErr: ErrInvalidScope},
+		{name: "pattern matching nothing", owner: user, scopes: []string{"invoices:*"}, wantErr: ErrInvalidScope},
+		{name: "within the owner's scopes", owner: scopedKey, scopes: []string{"users:read"}, wantExpiresAt: &expiresAt},
+		{name: "beyond the owner's scopes", owner: scopedKey, scopes: []string{"orders:read"}, wantErr: ErrScopeNotGranted},
+		{name: "broader than the owner's scopes", owner: scopedKey, scopes: []string{"*"}, wantErr: ErrScopeNotGranted},
+		{name: "expires before the owner", owner: scopedKey, scopes: []string{"users:read"}, expiresAt: &earlier, wantExpiresAt: &earlier},
+		{name: "outlives the owner", owner: scopedKey, scopes: []string{"users:read"}, expiresAt: &later, wantErr: ErrOutlivesCaller},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), &apiKeyRepo{}, &roleRepo{})
+
+			apiKey, _, err := s.Create(context.Background(), tt.owner, "ci", tt.scopes, tt.expiresAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !slices.Equal(apiKey.Scopes, tt.scopes) {
+				t.Errorf("got scopes %v, want %v", apiKey.Scopes, tt.scopes)
+			}
+			if (apiKey.ExpiresAt == nil) != (tt.wantExpiresAt == nil) ||
+				apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.Equal(*tt.wantExpiresAt) {
+				t.Errorf("got expires_at %v, want %v", apiKey.ExpiresAt, tt.wantExpiresAt)
+			}
+		})
+	}
+}
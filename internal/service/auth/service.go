@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"github.com/IBM/sarama"
@@ -9,6 +10,7 @@ import (
 	"github.com/nogavadu/auth-service/internal/repository"
 	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
 	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/service/authenticator"
 	"github.com/nogavadu/auth-service/internal/utils"
 	"github.com/nogavadu/platform_common/pkg/db"
 	"golang.org/x/crypto/bcrypt"
@@ -20,6 +22,9 @@ var (
 	ErrAlreadyExists       = errors.New("already exists")
 	ErrInvalidCredentials  = errors.New("invalid credentials")
 	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrAccountDeactivated  = errors.New("account is deactivated")
+	ErrUserSuspended       = errors.New("user is suspended")
+	ErrSessionRevoked      = errors.New("session is revoked")
 	ErrInternal            = errors.New("internal error")
 )
 
@@ -30,10 +35,14 @@ type authService struct {
 	refreshTokenExpTime time.Duration
 	accessTokenSecret   string
 	accessTokenExpTime  time.Duration
+	accessTokenKey      *rsa.PrivateKey
+	claimAttributes     []string
 
-	userRepo  repository.UserRepository
-	roleRepo  repository.RoleRepository
-	txManager db.TxManager
+	authenticators []service.Authenticator
+	userRepo       repository.UserRepository
+	roleRepo       repository.RoleRepository
+	suspensionRepo repository.SuspensionRepository
+	txManager      db.TxManager
 
 	registrationsProducer sarama.SyncProducer
 }
@@ -44,27 +53,29 @@ func New(
 	refreshTokenExp time.Duration,
 	accessTokenSecret string,
 	accessTokenExp time.Duration,
+	accessTokenKey *rsa.PrivateKey,
+	claimAttributes []string,
+	authenticators []service.Authenticator,
 	userRepo repository.UserRepository,
 	roleRepo repository.RoleRepository,
+	suspensionRepo repository.SuspensionRepository,
 	txManager db.TxManager,
+	registrationsProducer sarama.SyncProducer,
 ) service.AuthService {
-	var addresses = []string{"kafka1:29091", "kafka2:29092"}
-
-	producer, err := sarama.NewSyncProducer(addresses, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-
 	return &authService{
 		log:                   log,
 		refreshTokenSecret:    refreshTokenSecret,
 		refreshTokenExpTime:   refreshTokenExp,
 		accessTokenSecret:     accessTokenSecret,
 		accessTokenExpTime:    accessTokenExp,
+		accessTokenKey:        accessTokenKey,
+		claimAttributes:       claimAttributes,
+		authenticators:        authenticators,
 		userRepo:              userRepo,
 		roleRepo:              roleRepo,
+		suspensionRepo:        suspensionRepo,
 		txManager:             txManager,
-		registrationsProducer: producer,
+		registrationsProducer: registrationsProducer,
 	}
 }
 
@@ -85,7 +96,7 @@ func (s *authService) Register(ctx context.Context, userInfo *model.UserInfo, pa
 		var errTx error
 		defer func() {
 			if errTx != nil {
-				log.Error(fmt.Sprintf("%s: failed transaction, %w", op, errTx))
+				log.Error(fmt.Sprintf("%s: failed transaction, %v", op, errTx))
 			}
 		}()
 
@@ -121,12 +132,51 @@ func (s *authService) Register(ctx context.Context, userInfo *model.UserInfo, pa
 }
 
 func (s *authService) Login(ctx context.Context, email string, password string) (string, error) {
-	const op = "authService.Login"
+	userId, err := s.Authenticate(ctx, email, password)
+	if err != nil {
+		return "", err
+	}
+
+	return s.IssueRefreshToken(ctx, userId)
+}
+
+// Authenticate checks the credentials and returns the id of the user they
+// belong to, without starting a session.
+func (s *authService) Authenticate(ctx context.Context, email string, password string) (int, error) {
+	const op = "authService.Authenticate"
+
+	log := s.log.With(slog.String("op", op))
+
+	// Authenticators are tried in the configured order; the first one that
+	// accepts the credentials wins. A backend that is down does not block
+	// the ones after it.
+	unavailable := 0
+	for _, a := range s.authenticators {
+		userId, err := a.Authenticate(ctx, email, password)
+		if err == nil {
+			_, err = s.activeUser(ctx, userId)
+			return userId, err
+		}
+
+		if errors.Is(err, authenticator.ErrUnavailable) {
+			unavailable++
+			log.Error("authenticator is unavailable", slog.String("authenticator", a.Name()), slog.String("error", err.Error()))
+		}
+	}
+
+	if len(s.authenticators) > 0 && unavailable == len(s.authenticators) {
+		return 0, ErrInternal
+	}
+
+	return 0, ErrInvalidCredentials
+}
+
+func (s *authService) IssueRefreshToken(ctx context.Context, userId int) (string, error) {
+	const op = "authService.IssueRefreshToken"
 
 	log := s.log.With(slog.String("op", op))
 
 	var user model.User
-	var role string
 	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
 		var errTx error
 		defer func() {
@@ -135,7 +185,7 @@ func (s *authService) Login(ctx context.Context, email string, password string)
 			}
 		}()
 
-		repoUser, errTx := s.userRepo.GetByEmail(ctx, email)
+		repoUser, errTx := s.userRepo.GetById(ctx, userId)
 		if errTx != nil {
 			if errors.Is(errTx, repository.ErrNotFound) {
 				return ErrInvalidCredentials
@@ -143,6 +193,9 @@ func (s *authService) Login(ctx context.Context, email string, password string)
 
 			return ErrInternal
 		}
+		if err := s.checkUser(ctx, repoUser); err != nil {
+			return err
+		}
 
 		repoRole, errTx := s.roleRepo.GetById(ctx, repoUser.RoleId)
 		if errTx != nil {
@@ -152,7 +205,6 @@ func (s *authService) Login(ctx context.Context, email string, password string)
 
 			return ErrInternal
 		}
-		role = repoRole.Name
 
 		user = model.User{
 			Id: repoUser.Id,
@@ -160,36 +212,31 @@ func (s *authService) Login(ctx context.Context, email string, password string)
 				Name:   repoUser.Name,
 				Email:  repoUser.Email,
 				Avatar: repoUser.Avatar,
-				Role:   role,
+				Role:   repoRole.Name,
 			},
 		}
 
-		if !utils.VerifyPassword(repoUser.PassHash, password) {
-			return ErrInvalidCredentials
-		}
-
 		return nil
 	})
 	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrAccountDeactivated) || errors.Is(err, ErrUserSuspended) {
+			return "", err
+		}
+
 		return "", ErrInternal
 	}
 
-	refreshToken, err := utils.GenerateToken(
-		&model.User{
-			Id: user.Id,
-			UserInfo: model.UserInfo{
-				Email: user.Email,
-				Role:  role,
-			},
-		},
-		s.refreshTokenSecret,
-		s.refreshTokenExpTime,
-	)
+	refreshToken, err := s.newRefreshToken(&user)
 	if err != nil {
 		log.Error("failed to generate jwt token", slog.String("err", err.Error()))
 		return "", ErrInternal
 	}
 
+	// Every login flow ends here, so this is where the last login is recorded.
+	if err = s.userRepo.TouchLogin(ctx, userId); err != nil {
+		log.Warn("failed to record login", slog.String("error", err.Error()))
+	}
+
 	return refreshToken, nil
 }
 
@@ -197,7 +244,7 @@ func (s *authService) GetRefreshToken(ctx context.Context, refreshToken string)
 	const op = "authService.GetRefreshToken"
 	log := s.log.With(slog.String("op", op))
 
-	claims, err := utils.VerifyToken(refreshToken, s.refreshTokenSecret)
+	claims, err := utils.VerifyRefreshToken(refreshToken, s.refreshTokenSecret)
 	if err != nil {
 		return "", ErrInvalidRefreshToken
 	}
@@ -213,18 +260,26 @@ func (s *authService) GetRefreshToken(ctx context.Context, refreshToken string)
 		}()
 
 		repoUser, errTx := s.userRepo.GetById(ctx, claims.Id)
-		if err != nil {
-			if errors.Is(err, repository.ErrNotFound) {
-				return ErrInvalidCredentials
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidRefreshToken
 			}
 
 			return ErrInternal
 		}
+		// Sessions of a deactivated or suspended user end with their
+		// current access token.
+		if err := s.checkUser(ctx, repoUser); err != nil {
+			return err
+		}
+		if repoUser.SessionRevoked(unixTime(claims.AuthTime)) {
+			return ErrInvalidRefreshToken
+		}
 
 		repoRole, errTx := s.roleRepo.GetById(ctx, repoUser.RoleId)
 		if errTx != nil {
-			if errors.Is(err, repository.ErrNotFound) {
-				return ErrInvalidCredentials
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidRefreshToken
 			}
 
 			return ErrInternal
@@ -244,10 +299,14 @@ func (s *authService) GetRefreshToken(ctx context.Context, refreshToken string)
 		return nil
 	})
 	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrAccountDeactivated) || errors.Is(err, ErrUserSuspended) {
+			return "", err
+		}
+
 		return "", ErrInternal
 	}
 
-	newRefreshToken, err := utils.GenerateToken(
+	newRefreshToken, err := utils.GenerateRefreshToken(
 		&model.User{
 			Id: user.Id,
 			UserInfo: model.UserInfo{
@@ -255,6 +314,8 @@ func (s *authService) GetRefreshToken(ctx context.Context, refreshToken string)
 				Role:  role,
 			},
 		},
+		claims.SessionId,
+		unixTime(claims.AuthTime),
 		s.refreshTokenSecret,
 		s.refreshTokenExpTime,
 	)
@@ -270,13 +331,14 @@ func (s *authService) GetAccessToken(ctx context.Context, refreshToken string) (
 	const op = "authService.GetAccessToken"
 	log := s.log.With(slog.String("op", op))
 
-	claims, err := utils.VerifyToken(refreshToken, s.refreshTokenSecret)
+	claims, err := utils.VerifyRefreshToken(refreshToken, s.refreshTokenSecret)
 	if err != nil {
 		return "", ErrInvalidRefreshToken
 	}
 
 	var user model.User
 	var role string
+	var attributes map[string]interface{}
 	err = s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
 		var errTx error
 		defer func() {
@@ -286,23 +348,32 @@ func (s *authService) GetAccessToken(ctx context.Context, refreshToken string) (
 		}()
 
 		repoUser, errTx := s.userRepo.GetById(ctx, claims.Id)
-		if err != nil {
-			if errors.Is(err, repository.ErrNotFound) {
-				return ErrInvalidCredentials
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidRefreshToken
 			}
 
 			return ErrInternal
 		}
+		// Sessions of a deactivated or suspended user end with their
+		// current access token.
+		if err := s.checkUser(ctx, repoUser); err != nil {
+			return err
+		}
+		if repoUser.SessionRevoked(unixTime(claims.AuthTime)) {
+			return ErrInvalidRefreshToken
+		}
 
 		repoRole, errTx := s.roleRepo.GetById(ctx, repoUser.RoleId)
 		if errTx != nil {
-			if errors.Is(err, repository.ErrNotFound) {
-				return ErrInvalidCredentials
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidRefreshToken
 			}
 
 			return ErrInternal
 		}
 		role = repoRole.Name
+		attributes = s.tokenAttributes(repoUser.Attributes)
 
 		user = model.User{
 			Id: repoUser.Id,
@@ -317,20 +388,21 @@ func (s *authService) GetAccessToken(ctx context.Context, refreshToken string) (
 		return nil
 	})
 	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrAccountDeactivated) || errors.Is(err, ErrUserSuspended) {
+			return "", err
+		}
+
 		return "", ErrInternal
 	}
 
-	accessToken, err := utils.GenerateToken(
-		&model.User{
-			Id: user.Id,
-			UserInfo: model.UserInfo{
-				Email: user.Email,
-				Role:  role,
-			},
+	accessToken, err := s.generateAccessToken(&model.User{
+		Id: user.Id,
+		UserInfo: model.UserInfo{
+			Email: user.Email,
+			Role:  role,
 		},
-		s.accessTokenSecret,
-		s.accessTokenExpTime,
-	)
+		Attributes: attributes,
+	}, claims.SessionId, unixTime(claims.AuthTime))
 	if err != nil {
 		log.Error("failed to generate jwt token", slog.String("err", err.Error()))
 		return "", ErrInternal
@@ -343,7 +415,7 @@ func (s *authService) IsUser(ctx context.Context, userId int, refreshToken strin
 	const op = "authService.IsUser"
 	log := s.log.With(slog.String("op", op))
 
-	claims, err := utils.VerifyToken(refreshToken, s.refreshTokenSecret)
+	claims, err := utils.VerifyRefreshToken(refreshToken, s.refreshTokenSecret)
 	if err != nil {
 		log.Error("failed to verify jwt token", slog.String("err", err.Error()))
 		return ErrInvalidRefreshToken
@@ -356,3 +428,106 @@ func (s *authService) IsUser(ctx context.Context, userId int, refreshToken strin
 
 	return nil
 }
+
+// CheckSession refuses a session that began at authTime once its user was
+// deactivated or suspended, or had their sessions revoked since.
+func (s *authService) CheckSession(ctx context.Context, userId int, authTime time.Time) error {
+	user, err := s.activeUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.SessionRevoked(authTime) {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+// activeUser gets the user and passes them through checkUser.
+func (s *authService) activeUser(ctx context.Context, userId int) (*userRepoModel.User, error) {
+	const op = "authService.activeUser"
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+
+		s.log.Error("failed to get user", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if err = s.checkUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkUser refuses tokens to deactivated, deleted and suspended users.
+func (s *authService) checkUser(ctx context.Context, user *userRepoModel.User) error {
+	const op = "authService.checkUser"
+
+	if user.Disabled() {
+		return ErrAccountDeactivated
+	}
+
+	_, err := s.suspensionRepo.GetActive(ctx, user.Id)
+	if err == nil {
+		return ErrUserSuspended
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		s.log.Error("failed to get suspension", slog.String("op", op), slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// tokenAttributes picks the attributes registered to go into access tokens.
+func (s *authService) tokenAttributes(attributes map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(s.claimAttributes))
+	for _, key := range s.claimAttributes {
+		if value, ok := attributes[key]; ok {
+			res[key] = value
+		}
+	}
+
+	return res
+}
+
+func (s *authService) newRefreshToken(user *model.User) (string, error) {
+	sessionId, err := utils.NewSessionId()
+	if err != nil {
+		return "", err
+	}
+
+	return utils.GenerateRefreshToken(
+		&model.User{
+			Id: user.Id,
+			UserInfo: model.UserInfo{
+				Email: user.Email,
+				Role:  user.Role,
+			},
+		},
+		sessionId,
+		time.Now(),
+		s.refreshTokenSecret,
+		s.refreshTokenExpTime,
+	)
+}
+
+func (s *authService) generateAccessToken(user *model.User, sessionId string, authTime time.Time) (string, error) {
+	if s.accessTokenKey != nil {
+		return utils.GenerateRSAToken(user, sessionId, authTime, s.accessTokenKey, s.accessTokenExpTime)
+	}
+
+	return utils.GenerateToken(user, sessionId, authTime, s.accessTokenSecret, s.accessTokenExpTime)
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, 0)
+}
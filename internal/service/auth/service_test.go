@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/repository"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const (
+	activeId      = 1
+	deactivatedId = 2
+	suspendedId   = 3
+)
+
+// userRepo knows an active, a deactivated and a suspended user, and records
+// the logins it was told about.
+type userRepo struct {
+	repository.UserRepository
+	touchErr error
+	touched  []int
+}
+
+func (r *userRepo) GetById(_ context.Context, id int) (*userRepoModel.User, error) {
+	user := &userRepoModel.User{Id: id, UserInfo: userRepoModel.UserInfo{Email: "jane@example.com", RoleId: 1}}
+	switch id {
+	case activeId, suspendedId:
+	case deactivatedId:
+		deactivatedAt := time.Now()
+		user.DeactivatedAt = &deactivatedAt
+	default:
+		return nil, repository.ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (r *userRepo) TouchLogin(_ context.Context, id int) error {
+	if r.touchErr != nil {
+		return r.touchErr
+	}
+	r.touched = append(r.touched, id)
+
+	return nil
+}
+
+type roleRepo struct {
+	repository.RoleRepository
+}
+
+func (r *roleRepo) GetById(_ context.Context, _ int) (*roleRepoModel.Role, error) {
+	return &roleRepoModel.Role{ID: 1, Name: "user", Level: 1}, nil
+}
+
+type suspensionRepo struct {
+	repository.SuspensionRepository
+}
+
+func (r *suspensionRepo) GetActive(_ context.Context, userId int) (*suspensionRepoModel.Suspension, error) {
+	if userId != suspendedId {
+		return nil, repository.ErrNotFound
+	}
+
+	return &suspensionRepoModel.Suspension{Id: 1, UserId: userId}, nil
+}
+
+type txManager struct{}
+
+func (txManager) ReadCommitted(ctx context.Context, f db.Handler) error {
+	return f(ctx)
+}
+
+func TestIssueRefreshTokenTouchesLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		userId      int
+		touchErr    error
+		wantErr     error
+		wantTouched bool
+	}{
+		{name: "active user", userId: activeId, wantTouched: true},
+		// A failed touch is logged; it does not fail the login.
+		{name: "touch failed", userId: activeId, touchErr: errors.New("connection reset")},
+		{name: "deactivated user", userId: deactivatedId, wantErr: ErrAccountDeactivated},
+		{name: "suspended user", userId: suspendedId, wantErr: ErrUserSuspended},
+		{name: "unknown user", userId: 4, wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &userRepo{touchErr: tt.touchErr}
+			s := &authService{
+				log:                 slog.New(slog.NewTextHandler(io.Discard, nil)),
+				refreshTokenSecret:  "test-refresh-secret",
+				refreshTokenExpTime: time.Hour,
+				userRepo:            users,
+				roleRepo:            &roleRepo{},
+				suspensionRepo:      &suspensionRepo{},
+				txManager:           txManager{},
+			}
+
+			token, err := s.IssueRefreshToken(context.Background(), tt.userId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && token == "" {
+				t.Error("got no refresh token")
+			}
+			if touched := len(users.touched) == 1 && users.touched[0] == tt.userId; touched != tt.wantTouched {
+				t.Errorf("got touched logins %v, want touched %v", users.touched, tt.wantTouched)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Package authenticator holds the errors shared by the login backends that
+// implement service.Authenticator.
+package authenticator
+
+import "errors"
+
+var (
+	// ErrUserNotFound means the backend does not know the user, so the next
+	// authenticator should be tried.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredentials means the backend knows the user but the
+	// password does not match.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUnavailable means the backend could not be consulted.
+	ErrUnavailable = errors.New("authenticator is unavailable")
+)
@@ -0,0 +1,294 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/nogavadu/auth-service/internal/config"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/service/authenticator"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Name = "ldap"
+
+	dialTimeout = 5 * time.Second
+)
+
+// ldapAuthenticator finds the user with a service account search, verifies
+// the password by binding as the user and provisions the user locally on
+// first login. The role follows the user's directory groups on every login.
+//
+// Local users are tied to directory entries by DN. An entry only takes over
+// an existing local user with the same email when TrustEmail is set.
+type ldapAuthenticator struct {
+	log *slog.Logger
+	cfg config.LDAPConfig
+
+	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
+	identityRepo repository.IdentityRepository
+	txManager    db.TxManager
+}
+
+type entry struct {
+	dn     string
+	email  string
+	name   string
+	groups []string
+}
+
+func New(
+	log *slog.Logger,
+	cfg config.LDAPConfig,
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	identityRepo repository.IdentityRepository,
+	txManager db.TxManager,
+) service.Authenticator {
+	return &ldapAuthenticator{
+		log:          log,
+		cfg:          cfg,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		identityRepo: identityRepo,
+		txManager:    txManager,
+	}
+}
+
+func (a *ldapAuthenticator) Name() string {
+	return Name
+}
+
+func (a *ldapAuthenticator) Authenticate(ctx context.Context, email string, password string) (int, error) {
+	// An empty password would make the bind unauthenticated and succeed.
+	if password == "" {
+		return 0, authenticator.ErrInvalidCredentials
+	}
+
+	user, err := a.verify(email, password)
+	if err != nil {
+		return 0, err
+	}
+
+	userId, err := a.provision(ctx, user)
+	if err != nil {
+		if errors.Is(err, authenticator.ErrUserNotFound) {
+			return 0, err
+		}
+
+		return 0, fmt.Errorf("%w: %v", authenticator.ErrUnavailable, err)
+	}
+
+	return userId, nil
+}
+
+func (a *ldapAuthenticator) verify(email string, password string) (*entry, error) {
+	conn, err := ldap.DialURL(a.cfg.URL(), ldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", authenticator.ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	if a.cfg.StartTLS() {
+		u, err := url.Parse(a.cfg.URL())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", authenticator.ErrUnavailable, err)
+		}
+		if err = conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return nil, fmt.Errorf("%w: %v", authenticator.ErrUnavailable, err)
+		}
+	}
+
+	if a.cfg.BindDN() != "" {
+		if err = conn.Bind(a.cfg.BindDN(), a.cfg.BindPassword()); err != nil {
+			return nil, fmt.Errorf("%w: service bind: %v", authenticator.ErrUnavailable, err)
+		}
+	}
+
+	res, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.BaseDN(),
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		int(dialTimeout.Seconds()),
+		false,
+		fmt.Sprintf(a.cfg.UserFilter(), ldap.EscapeFilter(email)),
+		[]string{a.cfg.EmailAttribute(), a.cfg.NameAttribute(), a.cfg.GroupAttribute()},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, authenticator.ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("%w: search: %v", authenticator.ErrUnavailable, err)
+	}
+	if len(res.Entries) != 1 {
+		// Several matches mean an ambiguous filter; never guess.
+		return nil, authenticator.ErrUserNotFound
+	}
+	found := res.Entries[0]
+
+	if err = conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, authenticator.ErrInvalidCredentials
+		}
+
+		return nil, fmt.Errorf("%w: user bind: %v", authenticator.ErrUnavailable, err)
+	}
+
+	user := &entry{
+		dn:     found.DN,
+		email:  found.GetAttributeValue(a.cfg.EmailAttribute()),
+		name:   found.GetAttributeValue(a.cfg.NameAttribute()),
+		groups: found.GetAttributeValues(a.cfg.GroupAttribute()),
+	}
+	if user.email == "" {
+		user.email = email
+	}
+
+	return user, nil
+}
+
+// provision returns the local user linked to the directory entry, linking or
+// creating one on first login.
+func (a *ldapAuthenticator) provision(ctx context.Context, user *entry) (int, error) {
+	userId, err := a.link(ctx, user)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		// A concurrent login linked the entry first, and the retry finds
+		// whichever user it was linked to.
+		userId, err = a.link(ctx, user)
+	}
+
+	return userId, err
+}
+
+func (a *ldapAuthenticator) link(ctx context.Context, user *entry) (int, error) {
+	var userId int
+	err := a.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		roleId, errTx := a.roleId(ctx, user.groups)
+		if errTx != nil {
+			return errTx
+		}
+
+		identity, errTx := a.identityRepo.Get(ctx, Name, user.dn)
+		switch {
+		case errTx == nil:
+			userId = identity.UserId
+			return a.updateRole(ctx, userId, roleId)
+		case !errors.Is(errTx, repository.ErrNotFound):
+			return errTx
+		}
+
+		repoUser, errTx := a.userRepo.GetByEmail(ctx, user.email)
+		switch {
+		case errTx == nil:
+			// Whoever controls the directory entry's mail attribute would
+			// otherwise sign in as the local user.
+			if !a.cfg.TrustEmail() {
+				a.log.Warn("ldap entry matches an unlinked local user", slog.String("dn", user.dn))
+				return authenticator.ErrUserNotFound
+			}
+			userId = repoUser.Id
+		case errors.Is(errTx, repository.ErrNotFound):
+			info := &userRepoModel.UserInfo{
+				Email: user.email,
+			}
+			if user.name != "" {
+				info.Name = &user.name
+			}
+
+			if userId, errTx = a.userRepo.Create(ctx, info); errTx != nil {
+				return errTx
+			}
+		default:
+			return errTx
+		}
+
+		errTx = a.identityRepo.Create(ctx, &identityRepoModel.Identity{
+			Provider: Name,
+			Subject:  user.dn,
+			UserId:   userId,
+			Email:    &user.email,
+		})
+		if errTx != nil {
+			return errTx
+		}
+
+		return a.updateRole(ctx, userId, roleId)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (a *ldapAuthenticator) updateRole(ctx context.Context, userId int, roleId *int) error {
+	if roleId == nil {
+		return nil
+	}
+
+	return a.userRepo.Update(ctx, userId, &userRepoModel.UserUpdateInput{RoleId: roleId})
+}
+
+// roleId maps the user's groups to the highest-level configured role, or to
+// the default role when no group matches. nil leaves the role unchanged.
+func (a *ldapAuthenticator) roleId(ctx context.Context, groups []string) (*int, error) {
+	var roleName string
+	level := -1
+	for group, name := range a.cfg.GroupRoles() {
+		if !containsFold(groups, group) {
+			continue
+		}
+
+		role, err := a.roleRepo.GetByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				a.log.Warn("ldap group is mapped to an unknown role", slog.String("group", group), slog.String("role", name))
+				continue
+			}
+
+			return nil, err
+		}
+		if role.Level > level {
+			roleName, level = role.Name, role.Level
+		}
+	}
+	if roleName == "" {
+		roleName = a.cfg.DefaultRole()
+	}
+	if roleName == "" {
+		return nil, nil
+	}
+
+	role, err := a.roleRepo.GetByName(ctx, roleName)
+	if err != nil {
+		return nil, err
+	}
+	id := int(role.ID)
+
+	return &id, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,565 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service/authenticator"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	baseDN          = "dc=example,dc=org"
+	serviceDN       = "cn=service,dc=example,dc=org"
+	servicePassword = "service-secret"
+	adminsGroup     = "cn=admins,ou=groups,dc=example,dc=org"
+)
+
+type directoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// directory is an in-process LDAP server that answers simple binds and
+// subtree searches over a fixed set of entries, and records the filters it
+// was sent.
+type directory struct {
+	listener net.Listener
+	entries  []*directoryEntry
+
+	mu      sync.Mutex
+	filters []string
+}
+
+func newDirectory(t *testing.T, entries ...*directoryEntry) *directory {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	d := &directory{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+
+	return d
+}
+
+func (d *directory) URL() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *directory) Filters() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.filters...)
+}
+
+func (d *directory) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageId, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := d.bind(op.Children[1].Data.String(), op.Children[2].Data.String())
+			if _, err = conn.Write(response(messageId, result(ldap.ApplicationBindResponse, code))); err != nil {
+				return
+			}
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				return
+			}
+			d.mu.Lock()
+			d.filters = append(d.filters, filter)
+			d.mu.Unlock()
+
+			for _, e := range d.entries {
+				if !matches(op.Children[6], e) {
+					continue
+				}
+				if _, err = conn.Write(response(messageId, searchEntry(e))); err != nil {
+					return
+				}
+			}
+			if _, err = conn.Write(response(messageId, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (d *directory) bind(dn string, password string) uint16 {
+	if dn == serviceDN && password == servicePassword {
+		return ldap.LDAPResultSuccess
+	}
+	for _, e := range d.entries {
+		if e.dn == dn && e.password == password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+
+	return ldap.LDAPResultInvalidCredentials
+}
+
+func (e *directoryEntry) values(attribute string) []string {
+	for name, values := range e.attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func matches(filter *ber.Packet, e *directoryEntry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(child, e) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(child, e) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matches(filter.Children[0], e)
+	case ldap.FilterPresent:
+		return len(e.values(filter.Data.String())) > 0
+	case ldap.FilterEqualityMatch:
+		for _, v := range e.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(v, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterSubstrings:
+		for _, v := range e.values(filter.Children[0].Data.String()) {
+			if matchesSubstrings(strings.ToLower(v), filter.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func matchesSubstrings(value string, substrings []*ber.Packet) bool {
+	for _, s := range substrings {
+		part := strings.ToLower(s.Data.String())
+		switch s.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, part) {
+				return false
+			}
+			value = value[len(part):]
+		case ldap.FilterSubstringsAny:
+			i := strings.Index(value, part)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(part):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, part) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func response(messageId int64, op *ber.Packet) []byte {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "Message ID"))
+	packet.AppendChild(op)
+
+	return packet.Bytes()
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+
+	return packet
+}
+
+func searchEntry(e *directoryEntry) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "Object Name"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range e.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	packet.AppendChild(attributes)
+
+	return packet
+}
+
+type ldapConfig struct {
+	url        string
+	trustEmail bool
+}
+
+func (c *ldapConfig) URL() string            { return c.url }
+func (c *ldapConfig) StartTLS() bool         { return false }
+func (c *ldapConfig) BindDN() string         { return serviceDN }
+func (c *ldapConfig) BindPassword() string   { return servicePassword }
+func (c *ldapConfig) BaseDN() string         { return baseDN }
+func (c *ldapConfig) UserFilter() string     { return "(&(objectClass=person)(mail=%s))" }
+func (c *ldapConfig) EmailAttribute() string { return "mail" }
+func (c *ldapConfig) NameAttribute() string  { return "cn" }
+func (c *ldapConfig) GroupAttribute() string { return "memberOf" }
+func (c *ldapConfig) DefaultRole() string    { return "user" }
+func (c *ldapConfig) TrustEmail() bool       { return c.trustEmail }
+
+func (c *ldapConfig) GroupRoles() map[string]string {
+	return map[string]string{adminsGroup: "admin"}
+}
+
+type txManager struct{}
+
+func (txManager) ReadCommitted(ctx context.Context, f db.Handler) error {
+	return f(ctx)
+}
+
+type userRepo struct {
+	repository.UserRepository
+	users []*userRepoModel.User
+}
+
+func (r *userRepo) Create(_ context.Context, info *userRepoModel.UserInfo) (int, error) {
+	r.users = append(r.users, &userRepoModel.User{Id: len(r.users) + 1, UserInfo: *info})
+	return len(r.users), nil
+}
+
+func (r *userRepo) GetByEmail(_ context.Context, email string) (*userRepoModel.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r *userRepo) Update(_ context.Context, id int, input *userRepoModel.UserUpdateInput) error {
+	if input.RoleId != nil {
+		r.users[id-1].RoleId = *input.RoleId
+	}
+
+	return nil
+}
+
+type roleRepo struct {
+	repository.RoleRepository
+}
+
+var roles = map[string]*roleRepoModel.Role{
+	"user":  {ID: 1, Name: "user", Level: 1},
+	"admin": {ID: 3, Name: "admin", Level: 10},
+}
+
+func (r *roleRepo) GetByName(_ context.Context, name string) (*roleRepoModel.Role, error) {
+	role, ok := roles[name]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return role, nil
+}
+
+// identityRepo can simulate a concurrent login that links the entry to
+// racingUserId just before Create.
+type identityRepo struct {
+	repository.IdentityRepository
+	identities   []*identityRepoModel.Identity
+	racingUserId int
+}
+
+func (r *identityRepo) Create(_ context.Context, identity *identityRepoModel.Identity) error {
+	if r.racingUserId != 0 {
+		r.identities = append(r.identities, &identityRepoModel.Identity{Provider: identity.Provider, Subject: identity.Subject, UserId: r.racingUserId})
+		r.racingUserId = 0
+	}
+
+	for _, existing := range r.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return repository.ErrAlreadyExists
+		}
+	}
+	r.identities = append(r.identities, identity)
+
+	return nil
+}
+
+func (r *identityRepo) Get(_ context.Context, provider string, subject string) (*identityRepoModel.Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+const aliceDN = "uid=alice,ou=people,dc=example,dc=org"
+
+func alice() *directoryEntry {
+	return &directoryEntry{
+		dn:       aliceDN,
+		password: "alice-secret",
+		attributes: map[string][]string{
+			"objectClass": {"person"},
+			"mail":        {"alice@example.com"},
+			"cn":          {"Alice"},
+			"memberOf":    {adminsGroup},
+		},
+	}
+}
+
+type testEnv struct {
+	directory    *directory
+	auth         *ldapAuthenticator
+	userRepo     *userRepo
+	identityRepo *identityRepo
+}
+
+func newTestEnv(t *testing.T, trustEmail bool) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		directory: newDirectory(t, alice(), &directoryEntry{
+			dn:       "uid=bob,ou=people,dc=example,dc=org",
+			password: "bob-secret",
+			attributes: map[string][]string{
+				"objectClass": {"person"},
+				"mail":        {"bob@example.com"},
+				"cn":          {"Bob"},
+			},
+		}),
+		userRepo:     &userRepo{},
+		identityRepo: &identityRepo{},
+	}
+	env.auth = New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&ldapConfig{url: env.directory.URL(), trustEmail: trustEmail},
+		env.userRepo,
+		&roleRepo{},
+		env.identityRepo,
+		txManager{},
+	).(*ldapAuthenticator)
+
+	return env
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "valid", email: "alice@example.com", password: "alice-secret"},
+		{name: "email is case-insensitive", email: "ALICE@example.com", password: "alice-secret"},
+		{name: "wrong password", email: "alice@example.com", password: "bob-secret", wantErr: authenticator.ErrInvalidCredentials},
+		{name: "empty password", email: "alice@example.com", wantErr: authenticator.ErrInvalidCredentials},
+		{name: "unknown user", email: "carol@example.com", password: "alice-secret", wantErr: authenticator.ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, false)
+
+			_, err := env.auth.Authenticate(context.Background(), tt.email, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticateEscapesFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		wantFilter string
+	}{
+		{name: "wildcard", email: "*", wantFilter: `(&(objectClass=person)(mail=\2a))`},
+		{name: "prefix wildcard", email: "alice*", wantFilter: `(&(objectClass=person)(mail=alice\2a))`},
+		{name: "injected clause", email: "x)(|(mail=*", wantFilter: `(&(objectClass=person)(mail=x\29\28|\28mail=\2a))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, false)
+
+			// Alice's password would let anyone in whom the filter matched as her.
+			_, err := env.auth.Authenticate(context.Background(), tt.email, "alice-secret")
+			if !errors.Is(err, authenticator.ErrUserNotFound) {
+				t.Fatalf("got error %v, want %v", err, authenticator.ErrUserNotFound)
+			}
+
+			filters := env.directory.Filters()
+			if len(filters) != 1 || filters[0] != tt.wantFilter {
+				t.Errorf("got filters %q, want [%q]", filters, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestAuthenticateProvisioning(t *testing.T) {
+	t.Run("creates a user on first login", func(t *testing.T) {
+		env := newTestEnv(t, false)
+
+		userId, err := env.auth.Authenticate(context.Background(), "alice@example.com", "alice-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(env.userRepo.users) != 1 || userId != 1 {
+			t.Fatalf("got user %d of %d, want the only one", userId, len(env.userRepo.users))
+		}
+		user := env.userRepo.users[0]
+		if user.Email != "alice@example.com" || user.Name == nil || *user.Name != "Alice" || user.PassHash != "" {
+			t.Errorf("unexpected user %+v", user.UserInfo)
+		}
+		if user.RoleId != int(roles["admin"].ID) {
+			t.Errorf("got role %d, want the admin role mapped from the group", user.RoleId)
+		}
+		if len(env.identityRepo.identities) != 1 || env.identityRepo.identities[0].Subject != aliceDN {
+			t.Errorf("the entry's DN is not linked")
+		}
+
+		// The next login finds the user by DN.
+		if userId, err = env.auth.Authenticate(context.Background(), "alice@example.com", "alice-secret"); err != nil || userId != 1 {
+			t.Fatalf("got user %d, error %v on the next login", userId, err)
+		}
+		if len(env.userRepo.users) != 1 || len(env.identityRepo.identities) != 1 {
+			t.Error("returning user was provisioned again")
+		}
+	})
+
+	t.Run("follows the linked DN over the email", func(t *testing.T) {
+		env := newTestEnv(t, false)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "alice@example.com", RoleId: 1}},
+			{Id: 2, UserInfo: userRepoModel.UserInfo{Email: "alice.old@example.com", RoleId: 1}},
+		}
+		env.identityRepo.identities = []*identityRepoModel.Identity{{Provider: Name, Subject: aliceDN, UserId: 2}}
+
+		userId, err := env.auth.Authenticate(context.Background(), "alice@example.com", "alice-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if userId != 2 {
+			t.Errorf("got user %d, want the linked user 2", userId)
+		}
+		if env.userRepo.users[0].RoleId != 1 {
+			t.Error("the role of the user with the same email changed")
+		}
+	})
+
+	t.Run("refuses an unlinked local user", func(t *testing.T) {
+		env := newTestEnv(t, false)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "alice@example.com", PassHash: "hash", RoleId: 1}},
+		}
+
+		_, err := env.auth.Authenticate(context.Background(), "alice@example.com", "alice-secret")
+		if !errors.Is(err, authenticator.ErrUserNotFound) {
+			t.Fatalf("got error %v, want %v", err, authenticator.ErrUserNotFound)
+		}
+
+		if len(env.identityRepo.identities) != 0 {
+			t.Error("the entry was linked to the local user")
+		}
+		if env.userRepo.users[0].RoleId != 1 {
+			t.Error("the local user's role changed")
+		}
+	})
+
+	t.Run("links a local user when email is trusted", func(t *testing.T) {
+		env := newTestEnv(t, true)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "alice@example.com", PassHash: "hash", RoleId: 1}},
+		}
+
+		userId, err := env.auth.Authenticate(context.Background(), "alice@example.com", "alice-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if userId != 1 || len(env.userRepo.users) != 1 {
+			t.Errorf("got user %d of %d, want the local user", userId, len(env.userRepo.users))
+		}
+		if len(env.identityRepo.identities) != 1 || env.identityRepo.identities[0].UserId != 1 {
+			t.Error("the entry is not linked to the local user")
+		}
+	})
+
+	t.Run("defers to a concurrent link", func(t *testing.T) {
+		env := newTestEnv(t, true)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "alice@example.com", RoleId: 1}},
+			{Id: 2, UserInfo: userRepoModel.UserInfo{Email: "alice.old@example.com", RoleId: 1}},
+		}
+		env.identityRepo.racingUserId = 2
+
+		userId, err := env.auth.Authenticate(context.Background(), "alice@example.com", "alice-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if userId != 2 {
+			t.Errorf("got user %d, want user 2 the entry was linked to", userId)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/repository"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/service/authenticator"
+	"github.com/nogavadu/auth-service/internal/utils"
+)
+
+const Name = "password"
+
+// passwordAuthenticator checks the bcrypt password hash stored in users.
+type passwordAuthenticator struct {
+	userRepo repository.UserRepository
+}
+
+func New(userRepo repository.UserRepository) service.Authenticator {
+	return &passwordAuthenticator{
+		userRepo: userRepo,
+	}
+}
+
+func (a *passwordAuthenticator) Name() string {
+	return Name
+}
+
+func (a *passwordAuthenticator) Authenticate(ctx context.Context, email string, password string) (int, error) {
+	user, err := a.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, authenticator.ErrUserNotFound
+		}
+
+		return 0, fmt.Errorf("%w: %v", authenticator.ErrUnavailable, err)
+	}
+
+	// Users provisioned from another backend have no local password.
+	if user.PassHash == "" {
+		return 0, authenticator.ErrUserNotFound
+	}
+
+	if !utils.VerifyPassword(user.PassHash, password) {
+		return 0, authenticator.ErrInvalidCredentials
+	}
+
+	return user.Id, nil
+}
@@ -0,0 +1,45 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"os"
+)
+
+type providersFile struct {
+	Providers []*model.ExternalProvider `json:"providers"`
+}
+
+// LoadProviders reads the upstream identity providers from a JSON file. An
+// empty path yields no providers, which disables federated login.
+func LoadProviders(path string) ([]*model.ExternalProvider, error) {
+	const op = "federation.LoadProviders"
+
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var file providersFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[string]bool, len(file.Providers))
+	for _, p := range file.Providers {
+		if p.Name == "" || p.Issuer == "" || p.ClientId == "" || p.RedirectURL == "" {
+			return nil, fmt.Errorf("%s: provider %q: name, issuer, client_id and redirect_url are required", op, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: duplicate provider %q", op, p.Name)
+		}
+		seen[p.Name] = true
+	}
+
+	return file.Providers, nil
+}
@@ -0,0 +1,336 @@
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	loginStateRepoModel "github.com/nogavadu/auth-service/internal/repository/loginstate/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	authService "github.com/nogavadu/auth-service/internal/service/auth"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"golang.org/x/oauth2"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const stateExpTime = 10 * time.Minute
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrInvalidState     = errors.New("invalid or expired login state")
+	ErrInvalidIdToken   = errors.New("invalid id token")
+	ErrEmailNotVerified = errors.New("email is not verified by the identity provider")
+	ErrProviderFailure  = errors.New("identity provider is unavailable")
+	ErrInternal         = errors.New("internal error")
+)
+
+type externalClaims struct {
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+type federationService struct {
+	log *slog.Logger
+
+	providers map[string]*provider
+
+	authService service.AuthService
+
+	userRepo       repository.UserRepository
+	identityRepo   repository.IdentityRepository
+	loginStateRepo repository.LoginStateRepository
+	txManager      db.TxManager
+}
+
+// provider discovers its upstream configuration on first use, so the
+// service starts even when an IdP is temporarily unreachable.
+type provider struct {
+	cfg *model.ExternalProvider
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func New(
+	log *slog.Logger,
+	providers []*model.ExternalProvider,
+	authService service.AuthService,
+	userRepo repository.UserRepository,
+	identityRepo repository.IdentityRepository,
+	loginStateRepo repository.LoginStateRepository,
+	txManager db.TxManager,
+) service.FederationService {
+	s := &federationService{
+		log:            log,
+		providers:      make(map[string]*provider, len(providers)),
+		authService:    authService,
+		userRepo:       userRepo,
+		identityRepo:   identityRepo,
+		loginStateRepo: loginStateRepo,
+		txManager:      txManager,
+	}
+	for _, p := range providers {
+		s.providers[p.Name] = &provider{cfg: p}
+	}
+
+	return s
+}
+
+func (s *federationService) BeginExternalLogin(ctx context.Context, providerName string) (*model.ExternalLoginStart, error) {
+	const op = "federationService.BeginExternalLogin"
+	log := s.log.With(slog.String("op", op), slog.String("provider", providerName))
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	oauthConfig, _, err := p.discover(ctx)
+	if err != nil {
+		log.Error("failed to discover provider", slog.String("error", err.Error()))
+		return nil, ErrProviderFailure
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate state", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate nonce", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.loginStateRepo.Create(ctx, &loginStateRepoModel.LoginState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(stateExpTime),
+	})
+	if err != nil {
+		log.Error("failed to save login state", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return &model.ExternalLoginStart{
+		AuthorizationURL: oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)),
+		State:            state,
+	}, nil
+}
+
+func (s *federationService) CompleteExternalLogin(ctx context.Context, providerName string, state string, code string) (string, error) {
+	const op = "federationService.CompleteExternalLogin"
+	log := s.log.With(slog.String("op", op), slog.String("provider", providerName))
+
+	identity, err := s.VerifyExternalLogin(ctx, providerName, state, code)
+	if err != nil {
+		return "", err
+	}
+
+	userId, err := s.linkUser(ctx, identity)
+	if err != nil {
+		if errors.Is(err, ErrEmailNotVerified) || errors.Is(err, ErrInvalidIdToken) {
+			return "", err
+		}
+
+		log.Error("failed to link user", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+
+	refreshToken, err := s.authService.IssueRefreshToken(ctx, userId)
+	if err != nil {
+		if errors.Is(err, authService.ErrAccountDeactivated) || errors.Is(err, authService.ErrUserSuspended) {
+			return "", err
+		}
+
+		return "", ErrInternal
+	}
+
+	return refreshToken, nil
+}
+
+// VerifyExternalLogin finishes the upstream authorization code flow started
+// by BeginExternalLogin and returns the verified external identity.
+func (s *federationService) VerifyExternalLogin(ctx context.Context, providerName string, state string, code string) (*model.ExternalIdentity, error) {
+	const op = "federationService.VerifyExternalLogin"
+	log := s.log.With(slog.String("op", op), slog.String("provider", providerName))
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	loginState, err := s.loginStateRepo.Consume(ctx, hashToken(state))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidState
+		}
+
+		log.Error("failed to consume login state", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if loginState.Provider != providerName || time.Now().After(loginState.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	oauthConfig, verifier, err := p.discover(ctx)
+	if err != nil {
+		log.Error("failed to discover provider", slog.String("error", err.Error()))
+		return nil, ErrProviderFailure
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		log.Warn("failed to exchange code", slog.String("error", err.Error()))
+		return nil, ErrInvalidState
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrInvalidIdToken
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		log.Warn("failed to verify id token", slog.String("error", err.Error()))
+		return nil, ErrInvalidIdToken
+	}
+	if idToken.Nonce != loginState.Nonce {
+		return nil, ErrInvalidIdToken
+	}
+
+	var claims externalClaims
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, ErrInvalidIdToken
+	}
+
+	return &model.ExternalIdentity{
+		Provider:      providerName,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// linkUser returns the local user behind the external identity. Unknown
+// identities are linked to the user with the same verified email, or to a
+// newly created user without a password.
+func (s *federationService) linkUser(ctx context.Context, external *model.ExternalIdentity) (int, error) {
+	const op = "federationService.linkUser"
+
+	var userId int
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		identity, errTx := s.identityRepo.Get(ctx, external.Provider, external.Subject)
+		if errTx == nil {
+			userId = identity.UserId
+			return nil
+		}
+		if !errors.Is(errTx, repository.ErrNotFound) {
+			return errTx
+		}
+
+		if external.Email == "" {
+			return fmt.Errorf("%w: no email claim", ErrInvalidIdToken)
+		}
+
+		user, errTx := s.userRepo.GetByEmail(ctx, external.Email)
+		switch {
+		case errTx == nil:
+			// Taking over an existing account needs proof the IdP owns the address.
+			if !external.EmailVerified {
+				return ErrEmailNotVerified
+			}
+			userId = user.Id
+		case errors.Is(errTx, repository.ErrNotFound):
+			info := &userRepoModel.UserInfo{
+				Email: external.Email,
+			}
+			if external.Name != "" {
+				info.Name = &external.Name
+			}
+			if external.EmailVerified {
+				now := time.Now()
+				info.EmailVerifiedAt = &now
+			}
+
+			userId, errTx = s.userRepo.Create(ctx, info)
+			if errTx != nil {
+				return errTx
+			}
+		default:
+			return errTx
+		}
+
+		return s.identityRepo.Create(ctx, &identityRepoModel.Identity{
+			Provider: external.Provider,
+			Subject:  external.Subject,
+			UserId:   userId,
+			Email:    &external.Email,
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userId, nil
+}
+
+func (p *provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	// The provider keeps using this context to refresh its keys.
+	upstream, err := oidc.NewProvider(context.WithoutCancel(ctx), p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientId,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     upstream.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       scopes,
+	}
+	p.verifier = upstream.Verifier(&oidc.Config{ClientID: p.cfg.ClientId})
+
+	return p.oauth, p.verifier, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
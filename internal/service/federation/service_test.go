@@ -0,0 +1,370 @@
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	loginStateRepoModel "github.com/nogavadu/auth-service/internal/repository/loginstate/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const clientId = "auth-service"
+
+// idp is a stub OpenID provider. Its token endpoint answers every code with
+// an ID token for the nonce of the last authorization request, which tests
+// may tamper with through claims and signingKey.
+type idp struct {
+	server *httptest.Server
+
+	signingKey *rsa.PrivateKey
+	nonce      string
+	claims     jwt.MapClaims
+}
+
+func newIdP(t *testing.T) *idp {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &idp{signingKey: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "idp",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		claims := jwt.MapClaims{
+			"iss":            p.server.URL,
+			"sub":            "external-1",
+			"aud":            clientId,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          p.nonce,
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"name":           "Jane",
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "idp"
+		idToken, err := token.SignedString(p.signingKey)
+		if err != nil {
+			t.Error(err)
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"access_token": "upstream-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type txManager struct{}
+
+func (txManager) ReadCommitted(ctx context.Context, f db.Handler) error {
+	return f(ctx)
+}
+
+type userRepo struct {
+	repository.UserRepository
+	users []*userRepoModel.User
+}
+
+func (r *userRepo) Create(_ context.Context, info *userRepoModel.UserInfo) (int, error) {
+	r.users = append(r.users, &userRepoModel.User{Id: len(r.users) + 1, UserInfo: *info})
+	return len(r.users), nil
+}
+
+func (r *userRepo) GetByEmail(_ context.Context, email string) (*userRepoModel.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+type identityRepo struct {
+	repository.IdentityRepository
+	identities []*identityRepoModel.Identity
+}
+
+func (r *identityRepo) Create(_ context.Context, identity *identityRepoModel.Identity) error {
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *identityRepo) Get(_ context.Context, provider string, subject string) (*identityRepoModel.Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+type loginStateRepo struct {
+	states map[string]*loginStateRepoModel.LoginState
+}
+
+func (r *loginStateRepo) Create(_ context.Context, state *loginStateRepoModel.LoginState) error {
+	r.states[state.StateHash] = state
+	return nil
+}
+
+func (r *loginStateRepo) Consume(_ context.Context, stateHash string) (*loginStateRepoModel.LoginState, error) {
+	state, ok := r.states[stateHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(r.states, stateHash)
+
+	return state, nil
+}
+
+// authServ issues "refresh:<user id>" as the refresh token.
+type authServ struct {
+	service.AuthService
+}
+
+func (s *authServ) IssueRefreshToken(_ context.Context, userId int) (string, error) {
+	return "refresh:" + strconv.Itoa(userId), nil
+}
+
+type testEnv struct {
+	idp          *idp
+	serv         service.FederationService
+	userRepo     *userRepo
+	identityRepo *identityRepo
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		idp:          newIdP(t),
+		userRepo:     &userRepo{},
+		identityRepo: &identityRepo{},
+	}
+	env.serv = New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		[]*model.ExternalProvider{{
+			Name:        "stub",
+			Issuer:      env.idp.server.URL,
+			ClientId:    clientId,
+			RedirectURL: "https://auth.example.com/callback",
+		}},
+		&authServ{},
+		env.userRepo,
+		env.identityRepo,
+		&loginStateRepo{states: map[string]*loginStateRepoModel.LoginState{}},
+		txManager{},
+	)
+
+	return env
+}
+
+// begin starts a login and hands its nonce to the IdP, as the user's
+// browser would through the authorization URL.
+func (env *testEnv) begin(t *testing.T) string {
+	t.Helper()
+
+	start, err := env.serv.BeginExternalLogin(context.Background(), "stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := url.Parse(start.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if query.Get("state") != start.State || query.Get("client_id") != clientId || query.Get("code_challenge") == "" {
+		t.Fatalf("unexpected authorization url %s", start.AuthorizationURL)
+	}
+	env.idp.nonce = query.Get("nonce")
+
+	return start.State
+}
+
+func TestCompleteExternalLogin(t *testing.T) {
+	rogueKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		signingKey *rsa.PrivateKey
+		claims     jwt.MapClaims
+		wantErr    error
+	}{
+		{name: "valid"},
+		{name: "bad signature", signingKey: rogueKey, wantErr: ErrInvalidIdToken},
+		{name: "wrong nonce", claims: jwt.MapClaims{"nonce": "replayed"}, wantErr: ErrInvalidIdToken},
+		{name: "wrong audience", claims: jwt.MapClaims{"aud": "another-client"}, wantErr: ErrInvalidIdToken},
+		{name: "wrong issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}, wantErr: ErrInvalidIdToken},
+		{name: "expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, wantErr: ErrInvalidIdToken},
+		{name: "no email", claims: jwt.MapClaims{"email": ""}, wantErr: ErrInvalidIdToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if tt.signingKey != nil {
+				env.idp.signingKey = tt.signingKey
+			}
+			env.idp.claims = tt.claims
+
+			state := env.begin(t)
+			refreshToken, err := env.serv.CompleteExternalLogin(context.Background(), "stub", state, "code")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(env.userRepo.users) != 0 || len(env.identityRepo.identities) != 0 {
+					t.Error("a rejected login provisioned a user")
+				}
+				return
+			}
+
+			if refreshToken != "refresh:1" {
+				t.Errorf("got refresh token %q, want %q", refreshToken, "refresh:1")
+			}
+		})
+	}
+}
+
+func TestCompleteExternalLoginRejectsReusedState(t *testing.T) {
+	env := newTestEnv(t)
+
+	state := env.begin(t)
+	if _, err := env.serv.CompleteExternalLogin(context.Background(), "stub", state, "code"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.serv.CompleteExternalLogin(context.Background(), "stub", state, "code"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestCompleteExternalLoginProvisioning(t *testing.T) {
+	t.Run("creates a user", func(t *testing.T) {
+		env := newTestEnv(t)
+
+		if _, err := env.serv.CompleteExternalLogin(context.Background(), "stub", env.begin(t), "code"); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(env.userRepo.users) != 1 {
+			t.Fatalf("got %d users, want 1", len(env.userRepo.users))
+		}
+		user := env.userRepo.users[0]
+		if user.Email != "jane@example.com" || user.Name == nil || *user.Name != "Jane" || user.PassHash != "" {
+			t.Errorf("unexpected user %+v", user.UserInfo)
+		}
+		if user.EmailVerifiedAt == nil {
+			t.Errorf("email verified by the provider is not verified")
+		}
+		if len(env.identityRepo.identities) != 1 || env.identityRepo.identities[0].UserId != user.Id {
+			t.Errorf("identity is not linked to the new user")
+		}
+
+		// The next login finds the identity rather than creating anyone.
+		if _, err := env.serv.CompleteExternalLogin(context.Background(), "stub", env.begin(t), "code"); err != nil {
+			t.Fatal(err)
+		}
+		if len(env.userRepo.users) != 1 || len(env.identityRepo.identities) != 1 {
+			t.Errorf("returning user was provisioned again")
+		}
+	})
+
+	t.Run("links a verified email", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "john@example.com"}},
+			{Id: 2, UserInfo: userRepoModel.UserInfo{Email: "jane@example.com", PassHash: "hash"}},
+		}
+
+		refreshToken, err := env.serv.CompleteExternalLogin(context.Background(), "stub", env.begin(t), "code")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if refreshToken != "refresh:2" {
+			t.Errorf("got refresh token %q, want %q", refreshToken, "refresh:2")
+		}
+		if len(env.userRepo.users) != 2 {
+			t.Errorf("a user was created for a known email")
+		}
+	})
+
+	t.Run("refuses an unverified email", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "jane@example.com", PassHash: "hash"}},
+		}
+		env.idp.claims = jwt.MapClaims{"email_verified": false}
+
+		_, err := env.serv.CompleteExternalLogin(context.Background(), "stub", env.begin(t), "code")
+		if !errors.Is(err, ErrEmailNotVerified) {
+			t.Fatalf("got error %v, want %v", err, ErrEmailNotVerified)
+		}
+		if len(env.identityRepo.identities) != 0 {
+			t.Error("identity was linked to an unverified email")
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/utils"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// reauthWindow is how long after entering credentials a user may link a new
+// login method without typing the password again.
+const reauthWindow = 5 * time.Minute
+
+var (
+	ErrNotFound       = errors.New("identity not found")
+	ErrReauthRequired = errors.New("re-authentication required")
+	ErrIdentityInUse  = errors.New("identity is linked to another user")
+	ErrLastMethod     = errors.New("cannot unlink the last login method")
+	ErrInternal       = errors.New("internal error")
+)
+
+type identityService struct {
+	log *slog.Logger
+
+	federationService service.FederationService
+
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	txManager    db.TxManager
+}
+
+func New(
+	log *slog.Logger,
+	federationService service.FederationService,
+	userRepo repository.UserRepository,
+	identityRepo repository.IdentityRepository,
+	txManager db.TxManager,
+) service.IdentityService {
+	return &identityService{
+		log:               log,
+		federationService: federationService,
+		userRepo:          userRepo,
+		identityRepo:      identityRepo,
+		txManager:         txManager,
+	}
+}
+
+func (s *identityService) List(ctx context.Context, userId int) ([]*model.Identity, error) {
+	const op = "identityService.List"
+	log := s.log.With(slog.String("op", op))
+
+	var identities []*model.Identity
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		var errTx error
+		identities, errTx = s.list(ctx, userId)
+		return errTx
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to list identities", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return identities, nil
+}
+
+func (s *identityService) Link(ctx context.Context, principal *model.Principal, password string, provider string, state string, code string) (*model.Identity, error) {
+	const op = "identityService.Link"
+	log := s.log.With(slog.String("op", op))
+
+	user, err := s.userRepo.GetById(ctx, principal.UserId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if password != "" {
+		if !utils.VerifyPassword(user.PassHash, password) {
+			return nil, ErrReauthRequired
+		}
+	} else if principal.AuthTime.IsZero() || time.Since(principal.AuthTime) > reauthWindow {
+		return nil, ErrReauthRequired
+	}
+
+	external, err := s.federationService.VerifyExternalLogin(ctx, provider, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &identityRepoModel.Identity{
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		UserId:   user.Id,
+	}
+	if external.Email != "" {
+		identity.Email = &external.Email
+	}
+
+	createdAt := time.Now()
+	if err = s.identityRepo.Create(ctx, identity); err != nil {
+		if !errors.Is(err, repository.ErrAlreadyExists) {
+			log.Error("failed to create identity", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+
+		existing, err := s.identityRepo.Get(ctx, external.Provider, external.Subject)
+		if err != nil {
+			log.Error("failed to get identity", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+		if existing.UserId != user.Id {
+			return nil, ErrIdentityInUse
+		}
+		identity, createdAt = existing, existing.CreatedAt
+	}
+
+	return &model.Identity{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (s *identityService) Unlink(ctx context.Context, userId int, provider string, subject string) error {
+	const op = "identityService.Unlink"
+	log := s.log.With(slog.String("op", op))
+
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		// Concurrent unlinks would each see another method left and could
+		// together remove them all.
+		if errTx := s.userRepo.Lock(ctx, userId); errTx != nil {
+			return errTx
+		}
+
+		identities, errTx := s.list(ctx, userId)
+		if errTx != nil {
+			return errTx
+		}
+
+		found := false
+		for _, identity := range identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrNotFound
+		}
+		if len(identities) <= 1 {
+			return ErrLastMethod
+		}
+
+		if provider == model.ProviderPassword {
+			noPassword := ""
+			return s.userRepo.Update(ctx, userId, &userRepoModel.UserUpdateInput{
+				Password: &noPassword,
+			})
+		}
+
+		return s.identityRepo.Delete(ctx, userId, provider, subject)
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+		if errors.Is(err, ErrLastMethod) {
+			return ErrLastMethod
+		}
+
+		log.Error("failed to unlink identity", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// list returns the linked identities together with the local password,
+// which is reported as an identity of its own while the user has one.
+func (s *identityService) list(ctx context.Context, userId int) ([]*model.Identity, error) {
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	repoIdentities, err := s.identityRepo.ListByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]*model.Identity, 0, len(repoIdentities)+1)
+	if user.PassHash != "" {
+		identities = append(identities, &model.Identity{
+			Provider: model.ProviderPassword,
+			Subject:  strconv.Itoa(user.Id),
+			Email:    &user.Email,
+		})
+	}
+	for _, identity := range repoIdentities {
+		identities = append(identities, &model.Identity{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	return identities, nil
+}
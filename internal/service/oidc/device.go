@@ -0,0 +1,288 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	deviceCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/devicecode/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DeviceVerificationPath = "/device"
+
+	deviceCodeExpTime  = 10 * time.Minute
+	devicePollInterval = 5 * time.Second
+	deviceSlowDownStep = 5 * time.Second
+
+	// No vowels, so codes cannot spell words, and nothing easily confused
+	// such as 0/O or 1/I (RFC 8628 section 6.1).
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	userCodeAttempts = 3
+)
+
+func (s *oidcService) DeviceAuthorization(ctx context.Context, clientId string, clientSecret string, scope string) (*model.DeviceAuthorization, error) {
+	const op = "oidcService.DeviceAuthorization"
+	log := s.log.With(slog.String("op", op))
+
+	client, err := s.authenticateClient(ctx, clientId, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate device code", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	var userCode string
+	for attempt := 1; ; attempt++ {
+		userCode, err = newUserCode()
+		if err != nil {
+			log.Error("failed to generate user code", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+
+		err = s.deviceCodeRepo.Create(ctx, &deviceCodeRepoModel.DeviceCode{
+			DeviceCodeHash:  hashToken(deviceCode),
+			UserCode:        userCode,
+			ClientId:        client.ClientId,
+			Scope:           scope,
+			IntervalSeconds: int(devicePollInterval.Seconds()),
+			ExpiresAt:       time.Now().Add(deviceCodeExpTime),
+		})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrAlreadyExists) || attempt == userCodeAttempts {
+			log.Error("failed to save device code", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+	}
+
+	verificationURI := s.issuer + DeviceVerificationPath
+	displayCode := formatUserCode(userCode)
+
+	return &model.DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                displayCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {displayCode}}.Encode(),
+		ExpiresIn:               deviceCodeExpTime,
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+func (s *oidcService) GetDeviceRequest(ctx context.Context, userCode string) (*model.DeviceRequest, error) {
+	const op = "oidcService.GetDeviceRequest"
+	log := s.log.With(slog.String("op", op))
+
+	code, err := s.deviceCodeRepo.GetByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidUserCode
+		}
+
+		log.Error("failed to get device code", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if code.Status != deviceCodeRepoModel.StatusPending || time.Now().After(code.ExpiresAt) {
+		return nil, ErrInvalidUserCode
+	}
+
+	client, err := s.clientRepo.GetByClientId(ctx, code.ClientId)
+	if err != nil {
+		log.Error("failed to get client", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return &model.DeviceRequest{
+		UserCode:   formatUserCode(code.UserCode),
+		ClientName: client.Name,
+		Scope:      code.Scope,
+	}, nil
+}
+
+func (s *oidcService) AuthorizeDevice(ctx context.Context, userCode string, email string, password string, approve bool) error {
+	userId, authTime, err := s.authenticate(ctx, email, password)
+	if err != nil {
+		return err
+	}
+
+	return s.decideDevice(ctx, userCode, userId, authTime, approve)
+}
+
+func (s *oidcService) decideDevice(ctx context.Context, userCode string, userId int, authTime time.Time, approve bool) error {
+	const op = "oidcService.decideDevice"
+	log := s.log.With(slog.String("op", op))
+
+	var err error
+	if approve {
+		err = s.deviceCodeRepo.Decide(ctx, normalizeUserCode(userCode), deviceCodeRepoModel.StatusApproved, &userId, &authTime)
+	} else {
+		err = s.deviceCodeRepo.Decide(ctx, normalizeUserCode(userCode), deviceCodeRepoModel.StatusDenied, nil, nil)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidUserCode
+		}
+
+		log.Error("failed to decide device code", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// deviceCode answers a polling device: authorization_pending until the user
+// decides, slow_down when it polls faster than the interval, and tokens
+// exactly once after approval.
+func (s *oidcService) deviceCode(ctx context.Context, req *model.TokenRequest) (*model.TokenSet, error) {
+	const op = "oidcService.deviceCode"
+	log := s.log.With(slog.String("op", op))
+
+	client, err := s.authenticateClient(ctx, req.ClientId, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DeviceCode == "" {
+		return nil, ErrInvalidRequest
+	}
+	deviceCodeHash := hashToken(req.DeviceCode)
+
+	code, err := s.deviceCodeRepo.GetByDeviceCodeHash(ctx, deviceCodeHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidGrant
+		}
+
+		log.Error("failed to get device code", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if code.ClientId != client.ClientId || code.UsedAt != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	now := time.Now()
+	if now.After(code.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	switch code.Status {
+	case deviceCodeRepoModel.StatusDenied:
+		return nil, ErrAccessDenied
+	case deviceCodeRepoModel.StatusPending:
+		interval := time.Duration(code.IntervalSeconds) * time.Second
+		pollErr := ErrAuthorizationPending
+		if code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < interval {
+			interval += deviceSlowDownStep
+			pollErr = ErrSlowDown
+		}
+
+		if err = s.deviceCodeRepo.Poll(ctx, deviceCodeHash, int(interval.Seconds())); err != nil {
+			log.Error("failed to record poll", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+
+		return nil, pollErr
+	}
+
+	code, err = s.deviceCodeRepo.Consume(ctx, deviceCodeHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidGrant
+		}
+
+		log.Error("failed to consume device code", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if code.UserId == nil || code.AuthTime == nil {
+		return nil, ErrInvalidGrant
+	}
+
+	return s.issueTokens(ctx, *code.UserId, client.ClientId, code.Scope, "", *code.AuthTime)
+}
+
+// PurgeDeviceCodes deletes expired and used device codes, which frees their
+// user codes, and returns how many it deleted.
+func (s *oidcService) PurgeDeviceCodes(ctx context.Context) (int, error) {
+	const op = "oidcService.PurgeDeviceCodes"
+
+	deleted, err := s.deviceCodeRepo.DeleteExpired(ctx)
+	if err != nil {
+		s.log.Error("failed to delete device codes", slog.String("op", op), slog.String("error", err.Error()))
+		return 0, ErrInternal
+	}
+
+	return deleted, nil
+}
+
+// RunDeviceCodePurger calls PurgeDeviceCodes once per code lifetime until
+// ctx is done, so no code outlives its expiry by more than that.
+func RunDeviceCodePurger(ctx context.Context, log *slog.Logger, oidcService service.OIDCService) {
+	log = log.With(slog.String("op", "oidc.RunDeviceCodePurger"))
+
+	ticker := time.NewTicker(deviceCodeExpTime)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := oidcService.PurgeDeviceCodes(ctx)
+		if err == nil && deleted > 0 {
+			log.Info("purged device codes", slog.Int("count", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func newUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+
+	var b strings.Builder
+	for range userCodeLength {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+
+	return b.String(), nil
+}
+
+// normalizeUserCode makes user input match the stored code: case and
+// separators do not matter.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if r < 'A' || r > 'Z' {
+			return -1
+		}
+
+		return r
+	}, userCode)
+}
+
+func formatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
@@ -0,0 +1,327 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	deviceCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/devicecode/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deviceCodeRepo keeps device codes in memory, keyed by the hash of the
+// device code.
+type deviceCodeRepo struct {
+	codes map[string]*deviceCodeRepoModel.DeviceCode
+}
+
+func (r *deviceCodeRepo) Create(_ context.Context, code *deviceCodeRepoModel.DeviceCode) error {
+	for _, other := range r.codes {
+		if other.UserCode == code.UserCode {
+			return repository.ErrAlreadyExists
+		}
+	}
+
+	created := *code
+	created.Status = deviceCodeRepoModel.StatusPending
+	r.codes[code.DeviceCodeHash] = &created
+
+	return nil
+}
+
+func (r *deviceCodeRepo) GetByDeviceCodeHash(_ context.Context, deviceCodeHash string) (*deviceCodeRepoModel.DeviceCode, error) {
+	code, ok := r.codes[deviceCodeHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	found := *code
+
+	return &found, nil
+}
+
+func (r *deviceCodeRepo) GetByUserCode(_ context.Context, userCode string) (*deviceCodeRepoModel.DeviceCode, error) {
+	for _, code := range r.codes {
+		if code.UserCode == userCode {
+			found := *code
+			return &found, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r *deviceCodeRepo) Poll(_ context.Context, deviceCodeHash string, intervalSeconds int) error {
+	now := time.Now()
+	r.codes[deviceCodeHash].LastPolledAt = &now
+	r.codes[deviceCodeHash].IntervalSeconds = intervalSeconds
+
+	return nil
+}
+
+func (r *deviceCodeRepo) Decide(_ context.Context, userCode string, status string, userId *int, authTime *time.Time) error {
+	for _, code := range r.codes {
+		if code.UserCode == userCode && code.Status == deviceCodeRepoModel.StatusPending && time.Now().Before(code.ExpiresAt) {
+			code.Status, code.UserId, code.AuthTime = status, userId, authTime
+			return nil
+		}
+	}
+
+	return repository.ErrNotFound
+}
+
+func (r *deviceCodeRepo) Consume(_ context.Context, deviceCodeHash string) (*deviceCodeRepoModel.DeviceCode, error) {
+	code, ok := r.codes[deviceCodeHash]
+	if !ok || code.Status != deviceCodeRepoModel.StatusApproved || code.UsedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	consumed := *code
+
+	return &consumed, nil
+}
+
+func (r *deviceCodeRepo) DeleteExpired(_ context.Context) (int, error) {
+	deleted := 0
+	for hash, code := range r.codes {
+		if code.UsedAt != nil || time.Now().After(code.ExpiresAt) {
+			delete(r.codes, hash)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func newDeviceTestService(t *testing.T) (*oidcService, *deviceCodeRepo) {
+	t.Helper()
+
+	s := newTestService(t)
+	codes := &deviceCodeRepo{codes: map[string]*deviceCodeRepoModel.DeviceCode{}}
+	s.deviceCodeRepo = codes
+
+	return s, codes
+}
+
+func pollDevice(s *oidcService, clientId string, deviceCode string) (*model.TokenSet, error) {
+	return s.Token(context.Background(), &model.TokenRequest{
+		GrantType:  GrantTypeDeviceCode,
+		ClientId:   clientId,
+		DeviceCode: deviceCode,
+	})
+}
+
+func TestDeviceCodePolling(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare acts on the device code before the device polls.
+		prepare  func(s *oidcService, codes *deviceCodeRepo, authorization *model.DeviceAuthorization)
+		clientId string
+		wantErr  error
+	}{
+		{name: "pending", wantErr: ErrAuthorizationPending},
+		{
+			name: "polled too soon",
+			prepare: func(s *oidcService, _ *deviceCodeRepo, authorization *model.DeviceAuthorization) {
+				_, _ = pollDevice(s, "app", authorization.DeviceCode)
+			},
+			wantErr: ErrSlowDown,
+		},
+		{
+			name: "denied",
+			prepare: func(s *oidcService, _ *deviceCodeRepo, authorization *model.DeviceAuthorization) {
+				if err := s.decideDevice(context.Background(), authorization.UserCode, 7, time.Now(), false); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrAccessDenied,
+		},
+		{
+			name: "expired",
+			prepare: func(_ *oidcService, codes *deviceCodeRepo, _ *model.DeviceAuthorization) {
+				for _, code := range codes.codes {
+					code.ExpiresAt = time.Now().Add(-time.Second)
+				}
+			},
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name: "approved",
+			prepare: func(s *oidcService, _ *deviceCodeRepo, authorization *model.DeviceAuthorization) {
+				if err := s.decideDevice(context.Background(), authorization.UserCode, 7, time.Now(), true); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "approved for another client",
+			prepare: func(s *oidcService, _ *deviceCodeRepo, authorization *model.DeviceAuthorization) {
+				if err := s.decideDevice(context.Background(), authorization.UserCode, 7, time.Now(), true); err != nil {
+					t.Fatal(err)
+				}
+			},
+			clientId: "other",
+			wantErr:  ErrInvalidGrant,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, codes := newDeviceTestService(t)
+
+			authorization, err := s.DeviceAuthorization(context.Background(), "app", "", "openid")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(s, codes, authorization)
+			}
+
+			clientId := tt.clientId
+			if clientId == "" {
+				clientId = "app"
+			}
+			tokens, err := pollDevice(s, clientId, authorization.DeviceCode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (tokens.AccessToken == "" || tokens.IdToken == "") {
+				t.Errorf("got tokens %+v", tokens)
+			}
+		})
+	}
+}
+
+func TestDeviceCodeSlowDownWidensTheInterval(t *testing.T) {
+	s, codes := newDeviceTestService(t)
+
+	authorization, err := s.DeviceAuthorization(context.Background(), "app", "", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pollDevice(s, "app", authorization.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("got error %v, want %v", err, ErrAuthorizationPending)
+	}
+	if _, err = pollDevice(s, "app", authorization.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("got error %v, want %v", err, ErrSlowDown)
+	}
+
+	code := codes.codes[hashToken(authorization.DeviceCode)]
+	if want := int((devicePollInterval + deviceSlowDownStep).Seconds()); code.IntervalSeconds != want {
+		t.Errorf("got interval %ds, want %ds", code.IntervalSeconds, want)
+	}
+}
+
+func TestDeviceCodeIsSingleUse(t *testing.T) {
+	s, _ := newDeviceTestService(t)
+
+	authorization, err := s.DeviceAuthorization(context.Background(), "app", "", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.decideDevice(context.Background(), authorization.UserCode, 7, time.Now(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pollDevice(s, "app", authorization.DeviceCode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pollDevice(s, "app", authorization.DeviceCode); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("got error %v on reuse, want %v", err, ErrInvalidGrant)
+	}
+
+	// Neither can the user decide again.
+	if err = s.decideDevice(context.Background(), authorization.UserCode, 8, time.Now(), true); !errors.Is(err, ErrInvalidUserCode) {
+		t.Fatalf("got error %v on a second decision, want %v", err, ErrInvalidUserCode)
+	}
+}
+
+func TestGetDeviceRequest(t *testing.T) {
+	s, _ := newDeviceTestService(t)
+
+	authorization, err := s.DeviceAuthorization(context.Background(), "app", "", "openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Users may type the code in lower case and without the dash.
+	typed := strings.ToLower(strings.ReplaceAll(authorization.UserCode, "-", ""))
+
+	request, err := s.GetDeviceRequest(context.Background(), typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.UserCode != authorization.UserCode || request.Scope != "openid" {
+		t.Errorf("got request %+v", request)
+	}
+
+	if _, err = s.GetDeviceRequest(context.Background(), "BCDF-GHJK"); !errors.Is(err, ErrInvalidUserCode) {
+		t.Errorf("got error %v for an unknown code, want %v", err, ErrInvalidUserCode)
+	}
+}
+
+func TestPurgeDeviceCodes(t *testing.T) {
+	s, codes := newDeviceTestService(t)
+
+	var deviceCodes []string
+	for range 3 {
+		authorization, err := s.DeviceAuthorization(context.Background(), "app", "", "openid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		deviceCodes = append(deviceCodes, authorization.DeviceCode)
+	}
+
+	// The first code expires, the second is used, the third stays pending.
+	codes.codes[hashToken(deviceCodes[0])].ExpiresAt = time.Now().Add(-time.Second)
+	used := time.Now()
+	codes.codes[hashToken(deviceCodes[1])].UsedAt = &used
+
+	deleted, err := s.PurgeDeviceCodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d codes, want 2", deleted)
+	}
+	if _, ok := codes.codes[hashToken(deviceCodes[2])]; !ok || len(codes.codes) != 1 {
+		t.Errorf("pending code was purged")
+	}
+}
+
+// purgingService counts the purges RunDeviceCodePurger asks for.
+type purgingService struct {
+	service.OIDCService
+	purges int
+}
+
+func (s *purgingService) PurgeDeviceCodes(context.Context) (int, error) {
+	s.purges++
+	return 0, nil
+}
+
+func TestRunDeviceCodePurger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	serv := &purgingService{}
+	done := make(chan struct{})
+	go func() {
+		RunDeviceCodePurger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), serv)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger did not stop with its context")
+	}
+	if serv.purges != 1 {
+		t.Errorf("purged %d times, want once on start", serv.purges)
+	}
+}
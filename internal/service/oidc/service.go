@@ -0,0 +1,610 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	authCodeRepoModel "github.com/nogavadu/auth-service/internal/repository/authcode/model"
+	clientRepoModel "github.com/nogavadu/auth-service/internal/repository/client/model"
+	refreshTokenRepoModel "github.com/nogavadu/auth-service/internal/repository/refreshtoken/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	authService "github.com/nogavadu/auth-service/internal/service/auth"
+	serviceAccountService "github.com/nogavadu/auth-service/internal/service/serviceaccount"
+	"github.com/nogavadu/auth-service/internal/utils"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	codeExpTime = time.Minute
+
+	ResponseTypeCode = "code"
+
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+
+	CodeChallengeMethodS256 = "S256"
+
+	ScopeOpenId  = "openid"
+	ScopeEmail   = "email"
+	ScopeProfile = "profile"
+)
+
+var (
+	ErrInvalidClient           = errors.New("invalid_client")
+	ErrInvalidRedirectURI      = errors.New("invalid redirect_uri")
+	ErrInvalidRequest          = errors.New("invalid_request")
+	ErrInvalidGrant            = errors.New("invalid_grant")
+	ErrInvalidScope            = errors.New("invalid_scope")
+	ErrUnsupportedResponseType = errors.New("unsupported_response_type")
+	ErrUnsupportedGrantType    = errors.New("unsupported_grant_type")
+	ErrAccessDenied            = errors.New("access_denied")
+	ErrInvalidToken            = errors.New("invalid_token")
+	ErrAuthorizationPending    = errors.New("authorization_pending")
+	ErrSlowDown                = errors.New("slow_down")
+	ErrExpiredToken            = errors.New("expired_token")
+	ErrInvalidUserCode         = errors.New("invalid or expired user code")
+	ErrInternal                = errors.New("server_error")
+)
+
+type oidcService struct {
+	log *slog.Logger
+
+	issuer              string
+	signingKey          *rsa.PrivateKey
+	accessTokenExpTime  time.Duration
+	refreshTokenExpTime time.Duration
+
+	authService           service.AuthService
+	serviceAccountService service.ServiceAccountService
+
+	userRepo         repository.UserRepository
+	clientRepo       repository.ClientRepository
+	authCodeRepo     repository.AuthCodeRepository
+	deviceCodeRepo   repository.DeviceCodeRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+}
+
+func New(
+	log *slog.Logger,
+	issuer string,
+	signingKey *rsa.PrivateKey,
+	accessTokenExp time.Duration,
+	refreshTokenExp time.Duration,
+	authService service.AuthService,
+	serviceAccountService service.ServiceAccountService,
+	userRepo repository.UserRepository,
+	clientRepo repository.ClientRepository,
+	authCodeRepo repository.AuthCodeRepository,
+	deviceCodeRepo repository.DeviceCodeRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+) service.OIDCService {
+	return &oidcService{
+		log:                   log,
+		issuer:                issuer,
+		signingKey:            signingKey,
+		accessTokenExpTime:    accessTokenExp,
+		refreshTokenExpTime:   refreshTokenExp,
+		authService:           authService,
+		serviceAccountService: serviceAccountService,
+		userRepo:              userRepo,
+		clientRepo:            clientRepo,
+		authCodeRepo:          authCodeRepo,
+		deviceCodeRepo:        deviceCodeRepo,
+		refreshTokenRepo:      refreshTokenRepo,
+	}
+}
+
+func (s *oidcService) ValidateAuthorizeRequest(ctx context.Context, req *model.AuthorizeRequest) error {
+	const op = "oidcService.ValidateAuthorizeRequest"
+	log := s.log.With(slog.String("op", op))
+
+	client, err := s.clientRepo.GetByClientId(ctx, req.ClientId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidClient
+		}
+
+		log.Error("failed to get client", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != ResponseTypeCode {
+		return ErrUnsupportedResponseType
+	}
+
+	if !slices.Contains(strings.Fields(req.Scope), ScopeOpenId) {
+		return ErrInvalidScope
+	}
+
+	if req.CodeChallenge == "" {
+		// Public clients have no secret, so PKCE is their only proof of possession.
+		if client.ClientSecretHash == nil {
+			return ErrInvalidRequest
+		}
+	} else if req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return ErrInvalidRequest
+	}
+
+	return nil
+}
+
+func (s *oidcService) Authorize(ctx context.Context, req *model.AuthorizeRequest, email string, password string) (string, error) {
+	const op = "oidcService.Authorize"
+	log := s.log.With(slog.String("op", op))
+
+	if err := s.ValidateAuthorizeRequest(ctx, req); err != nil {
+		return "", err
+	}
+
+	userId, authTime, err := s.authenticate(ctx, email, password)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate code", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+
+	authCode := &authCodeRepoModel.AuthCode{
+		CodeHash:    hashToken(code),
+		ClientId:    req.ClientId,
+		UserId:      userId,
+		RedirectURI: req.RedirectURI,
+		Scope:       req.Scope,
+		AuthTime:    authTime,
+		ExpiresAt:   time.Now().Add(codeExpTime),
+	}
+	if req.Nonce != "" {
+		authCode.Nonce = &req.Nonce
+	}
+	if req.CodeChallenge != "" {
+		authCode.CodeChallenge = &req.CodeChallenge
+		authCode.CodeChallengeMethod = &req.CodeChallengeMethod
+	}
+
+	if err = s.authCodeRepo.Create(ctx, authCode); err != nil {
+		log.Error("failed to save code", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+
+	return code, nil
+}
+
+// authenticate returns the id of the user the credentials belong to, as the
+// authenticator that accepted them identified them, and the time of the login.
+func (s *oidcService) authenticate(ctx context.Context, email string, password string) (int, time.Time, error) {
+	const op = "oidcService.authenticate"
+
+	userId, err := s.authService.Authenticate(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, authService.ErrInvalidCredentials) || errors.Is(err, authService.ErrAccountDeactivated) || errors.Is(err, authService.ErrUserSuspended) {
+			return 0, time.Time{}, ErrAccessDenied
+		}
+
+		return 0, time.Time{}, ErrInternal
+	}
+
+	// Logins to clients start no first-party session, so they are recorded here.
+	if err = s.userRepo.TouchLogin(ctx, userId); err != nil {
+		s.log.Warn("failed to record login", slog.String("op", op), slog.String("error", err.Error()))
+	}
+
+	return userId, time.Now(), nil
+}
+
+// checkSession refuses tokens for a session the user started at authTime
+// once it has ended.
+func (s *oidcService) checkSession(ctx context.Context, userId int, authTime time.Time) error {
+	err := s.authService.CheckSession(ctx, userId, authTime)
+	if err != nil {
+		if errors.Is(err, authService.ErrInvalidCredentials) || errors.Is(err, authService.ErrAccountDeactivated) ||
+			errors.Is(err, authService.ErrUserSuspended) || errors.Is(err, authService.ErrSessionRevoked) {
+			return ErrInvalidGrant
+		}
+
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (s *oidcService) Token(ctx context.Context, req *model.TokenRequest) (*model.TokenSet, error) {
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, req)
+	case GrantTypeRefreshToken:
+		return s.refresh(ctx, req)
+	case GrantTypeClientCredentials:
+		return s.clientCredentials(ctx, req)
+	case GrantTypeDeviceCode:
+		return s.deviceCode(ctx, req)
+	default:
+		return nil, ErrUnsupportedGrantType
+	}
+}
+
+// UserInfo returns the claims the scope of the access token releases. Only
+// access tokens the provider issued to its clients are accepted.
+func (s *oidcService) UserInfo(ctx context.Context, accessToken string) (*model.User, error) {
+	const op = "oidcService.UserInfo"
+	log := s.log.With(slog.String("op", op))
+
+	claims, err := s.verifyAccessToken(accessToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err = s.checkSession(ctx, userId, time.Unix(claims.AuthTime, 0)); err != nil {
+		if errors.Is(err, ErrInvalidGrant) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return releasedUser(user, claims.Scope), nil
+}
+
+func (s *oidcService) exchangeCode(ctx context.Context, req *model.TokenRequest) (*model.TokenSet, error) {
+	const op = "oidcService.exchangeCode"
+	log := s.log.With(slog.String("op", op))
+
+	client, err := s.authenticateClient(ctx, req.ClientId, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Code == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	code, err := s.authCodeRepo.Consume(ctx, hashToken(req.Code))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidGrant
+		}
+
+		log.Error("failed to consume code", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if code.ClientId != client.ClientId || code.RedirectURI != req.RedirectURI || time.Now().After(code.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+
+	if code.CodeChallenge != nil && !verifyCodeChallenge(*code.CodeChallenge, req.CodeVerifier) {
+		return nil, ErrInvalidGrant
+	}
+
+	var nonce string
+	if code.Nonce != nil {
+		nonce = *code.Nonce
+	}
+
+	return s.issueTokens(ctx, code.UserId, client.ClientId, code.Scope, nonce, code.AuthTime)
+}
+
+// issueTokens returns the tokens of a session the user started at authTime,
+// with an ID token when the openid scope was granted.
+func (s *oidcService) issueTokens(ctx context.Context, userId int, clientId string, scope string, nonce string, authTime time.Time) (*model.TokenSet, error) {
+	if err := s.checkSession(ctx, userId, authTime); err != nil {
+		return nil, err
+	}
+
+	return s.tokenSet(ctx, userId, clientId, scope, scope, nonce, authTime)
+}
+
+// refresh rotates a refresh token issued to the same client. The requested
+// scope may narrow the original grant but never widen it.
+func (s *oidcService) refresh(ctx context.Context, req *model.TokenRequest) (*model.TokenSet, error) {
+	const op = "oidcService.refresh"
+	log := s.log.With(slog.String("op", op))
+
+	client, err := s.authenticateClient(ctx, req.ClientId, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RefreshToken == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	grant, err := s.refreshTokenRepo.Consume(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidGrant
+		}
+
+		log.Error("failed to consume refresh token", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if grant.ClientId != client.ClientId || time.Now().After(grant.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+
+	scope := grant.Scope
+	if req.Scope != "" {
+		granted := strings.Fields(grant.Scope)
+		for _, requested := range strings.Fields(req.Scope) {
+			if !slices.Contains(granted, requested) {
+				return nil, ErrInvalidScope
+			}
+		}
+		scope = req.Scope
+	}
+
+	if err = s.checkSession(ctx, grant.UserId, grant.AuthTime); err != nil {
+		return nil, err
+	}
+
+	return s.tokenSet(ctx, grant.UserId, client.ClientId, grant.Scope, scope, "", grant.AuthTime)
+}
+
+// tokenSet issues an opaque refresh token bound to the client and its
+// granted scope, and returns it with an access token for the scope and, for
+// the openid scope, an ID token.
+func (s *oidcService) tokenSet(
+	ctx context.Context,
+	userId int,
+	clientId string,
+	grantedScope string,
+	scope string,
+	nonce string,
+	authTime time.Time,
+) (*model.TokenSet, error) {
+	const op = "oidcService.tokenSet"
+	log := s.log.With(slog.String("op", op))
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate refresh token", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	err = s.refreshTokenRepo.Create(ctx, &refreshTokenRepoModel.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		ClientId:  clientId,
+		UserId:    userId,
+		Scope:     grantedScope,
+		AuthTime:  authTime,
+		ExpiresAt: time.Now().Add(s.refreshTokenExpTime),
+	})
+	if err != nil {
+		log.Error("failed to save refresh token", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	accessToken, err := s.accessToken(userId, clientId, scope, authTime)
+	if err != nil {
+		log.Error("failed to generate access token", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	tokens := &model.TokenSet{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    s.accessTokenExpTime,
+		Scope:        scope,
+	}
+
+	if slices.Contains(strings.Fields(scope), ScopeOpenId) {
+		tokens.IdToken, err = s.idToken(ctx, userId, clientId, scope, nonce, authTime)
+		if err != nil {
+			log.Error("failed to generate id token", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+	}
+
+	return tokens, nil
+}
+
+func (s *oidcService) clientCredentials(ctx context.Context, req *model.TokenRequest) (*model.TokenSet, error) {
+	if req.ClientId == "" || req.ClientSecret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	tokens, err := s.serviceAccountService.Token(ctx, req.ClientId, req.ClientSecret, req.Scope)
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceAccountService.ErrInvalidCredentials):
+			return nil, ErrInvalidClient
+		case errors.Is(err, serviceAccountService.ErrInvalidScope):
+			return nil, ErrInvalidScope
+		default:
+			return nil, ErrInternal
+		}
+	}
+
+	return tokens, nil
+}
+
+func (s *oidcService) authenticateClient(ctx context.Context, clientId string, clientSecret string) (*clientRepoModel.Client, error) {
+	const op = "oidcService.authenticateClient"
+	log := s.log.With(slog.String("op", op))
+
+	if clientId == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.clientRepo.GetByClientId(ctx, clientId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidClient
+		}
+
+		log.Error("failed to get client", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if client.ClientSecretHash != nil && !utils.VerifyPassword(*client.ClientSecretHash, clientSecret) {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// accessToken issues an access token for the client, which names the user
+// and the scope granted but not the user's role.
+func (s *oidcService) accessToken(userId int, clientId string, scope string, authTime time.Time) (string, error) {
+	now := time.Now()
+	claims := &model.ClientAccessTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.Itoa(userId),
+			Audience:  clientId,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.accessTokenExpTime).Unix(),
+		},
+		ClientId: clientId,
+		Scope:    scope,
+		AuthTime: authTime.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = utils.AccessTokenType
+	token.Header["kid"] = utils.KeyID(&s.signingKey.PublicKey)
+
+	return token.SignedString(s.signingKey)
+}
+
+// verifyAccessToken accepts only access tokens the provider issued to one of
+// its clients.
+func (s *oidcService) verifyAccessToken(accessToken string) (*model.ClientAccessTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(
+		accessToken,
+		&model.ClientAccessTokenClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, ErrInvalidToken
+			}
+
+			return &s.signingKey.PublicKey, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*model.ClientAccessTokenClaims)
+	if !ok || token.Header["typ"] != utils.AccessTokenType || claims.Issuer != s.issuer {
+		return nil, ErrInvalidToken
+	}
+	if claims.ClientId == "" || !claims.VerifyAudience(claims.ClientId, true) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (s *oidcService) idToken(ctx context.Context, userId int, clientId string, scope string, nonce string, authTime time.Time) (string, error) {
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &model.IdTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.Itoa(user.Id),
+			Audience:  clientId,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.accessTokenExpTime).Unix(),
+		},
+		Nonce:    nonce,
+		AuthTime: authTime.Unix(),
+	}
+
+	released := releasedUser(user, scope)
+	claims.Email = released.Email
+	if released.Name != nil {
+		claims.Name = *released.Name
+	}
+	if released.Avatar != nil {
+		claims.Picture = *released.Avatar
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = utils.IdTokenType
+	token.Header["kid"] = utils.KeyID(&s.signingKey.PublicKey)
+
+	return token.SignedString(s.signingKey)
+}
+
+// releasedUser keeps of the user only what the scope releases: the email for
+// the email scope, the name and picture for the profile scope.
+func releasedUser(user *userRepoModel.User, scope string) *model.User {
+	released := &model.User{Id: user.Id}
+
+	scopes := strings.Fields(scope)
+	if slices.Contains(scopes, ScopeEmail) {
+		released.Email = user.Email
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		released.Name = user.Name
+		released.Avatar = user.Avatar
+	}
+
+	return released
+}
+
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,275 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	clientRepoModel "github.com/nogavadu/auth-service/internal/repository/client/model"
+	refreshTokenRepoModel "github.com/nogavadu/auth-service/internal/repository/refreshtoken/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	authService "github.com/nogavadu/auth-service/internal/service/auth"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// authServ ends the sessions of user 9.
+type authServ struct {
+	service.AuthService
+}
+
+func (s *authServ) CheckSession(_ context.Context, userId int, _ time.Time) error {
+	if userId == 9 {
+		return authService.ErrSessionRevoked
+	}
+
+	return nil
+}
+
+type userRepo struct {
+	repository.UserRepository
+}
+
+func (r *userRepo) GetById(_ context.Context, id int) (*userRepoModel.User, error) {
+	name := "Jane"
+
+	return &userRepoModel.User{Id: id, UserInfo: userRepoModel.UserInfo{Name: &name, Email: "user@example.com"}}, nil
+}
+
+// clientRepo knows two public clients.
+type clientRepo struct {
+	repository.ClientRepository
+}
+
+func (r *clientRepo) GetByClientId(_ context.Context, clientId string) (*clientRepoModel.Client, error) {
+	if clientId != "app" && clientId != "other" {
+		return nil, repository.ErrNotFound
+	}
+
+	return &clientRepoModel.Client{ClientId: clientId}, nil
+}
+
+type refreshTokenRepo struct {
+	tokens map[string]*refreshTokenRepoModel.RefreshToken
+}
+
+func (r *refreshTokenRepo) Create(_ context.Context, token *refreshTokenRepoModel.RefreshToken) error {
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *refreshTokenRepo) Consume(_ context.Context, tokenHash string) (*refreshTokenRepoModel.RefreshToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(r.tokens, tokenHash)
+
+	return token, nil
+}
+
+func newTestService(t *testing.T) *oidcService {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		"https://auth.example.com",
+		key,
+		time.Minute,
+		time.Hour,
+		&authServ{},
+		nil,
+		&userRepo{},
+		&clientRepo{},
+		nil,
+		nil,
+		&refreshTokenRepo{tokens: map[string]*refreshTokenRepoModel.RefreshToken{}},
+	).(*oidcService)
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		clientId  string
+		scope     string
+		wantErr   error
+		wantScope string
+	}{
+		{name: "same client", clientId: "app", wantScope: "openid email"},
+		{name: "narrowed scope", clientId: "app", scope: "openid", wantScope: "openid"},
+		{name: "widened scope", clientId: "app", scope: "openid profile", wantErr: ErrInvalidScope},
+		{name: "other client", clientId: "other", wantErr: ErrInvalidGrant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			ctx := context.Background()
+
+			issued, err := s.tokenSet(ctx, 7, "app", "openid email", "openid email", "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tokens, err := s.Token(ctx, &model.TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientId:     tt.clientId,
+				RefreshToken: issued.RefreshToken,
+				Scope:        tt.scope,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tokens.Scope != tt.wantScope {
+				t.Errorf("got scope %q, want %q", tokens.Scope, tt.wantScope)
+			}
+			if tokens.IdToken == "" {
+				t.Error("no id token for the openid scope")
+			}
+
+			// The rotated token keeps the original grant, and the old one
+			// cannot be used again.
+			if _, err = s.Token(ctx, &model.TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientId:     "app",
+				RefreshToken: issued.RefreshToken,
+			}); !errors.Is(err, ErrInvalidGrant) {
+				t.Errorf("reused refresh token: got error %v, want %v", err, ErrInvalidGrant)
+			}
+			rotated, err := s.Token(ctx, &model.TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientId:     "app",
+				RefreshToken: tokens.RefreshToken,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rotated.Scope != "openid email" {
+				t.Errorf("got rotated scope %q, want %q", rotated.Scope, "openid email")
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsTokensNotIssuedToClients(t *testing.T) {
+	s := newTestService(t)
+
+	_, err := s.Token(context.Background(), &model.TokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		ClientId:     "app",
+		RefreshToken: "first-party-refresh-token",
+	})
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestRefreshEndsWithTheSession(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	issued, err := s.tokenSet(ctx, 9, "app", "openid", "openid", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Token(ctx, &model.TokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		ClientId:     "app",
+		RefreshToken: issued.RefreshToken,
+	})
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestAccessTokenIsBoundToTheClient(t *testing.T) {
+	s := newTestService(t)
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tokens, err := s.tokenSet(context.Background(), 7, "app", "openid email", "openid email", "", authTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.verifyAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Audience != "app" || claims.ClientId != "app" {
+		t.Errorf("got audience %q and client %q, want app", claims.Audience, claims.ClientId)
+	}
+	if claims.Scope != "openid email" {
+		t.Errorf("got scope %q, want %q", claims.Scope, "openid email")
+	}
+	if claims.AuthTime != authTime.Unix() {
+		t.Errorf("got auth time %d, want %d", claims.AuthTime, authTime.Unix())
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		userId    int
+		scope     string
+		idToken   bool
+		wantErr   error
+		wantEmail string
+		wantName  bool
+	}{
+		{name: "openid only", userId: 7, scope: "openid"},
+		{name: "email", userId: 7, scope: "openid email", wantEmail: "user@example.com"},
+		{name: "profile", userId: 7, scope: "openid profile", wantName: true},
+		{name: "id token", userId: 7, scope: "openid email", idToken: true, wantErr: ErrInvalidToken},
+		{name: "ended session", userId: 9, scope: "openid email", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			ctx := context.Background()
+
+			tokens, err := s.tokenSet(ctx, tt.userId, "app", tt.scope, tt.scope, "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := tokens.AccessToken
+			if tt.idToken {
+				token = tokens.IdToken
+			}
+
+			user, err := s.UserInfo(ctx, token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if user.Id != tt.userId {
+				t.Errorf("got user %d, want %d", user.Id, tt.userId)
+			}
+			if user.Email != tt.wantEmail {
+				t.Errorf("got email %q, want %q", user.Email, tt.wantEmail)
+			}
+			if (user.Name != nil) != tt.wantName {
+				t.Errorf("got name %v, want one: %v", user.Name, tt.wantName)
+			}
+			if user.Role != "" {
+				t.Errorf("got role %q, want none", user.Role)
+			}
+		})
+	}
+}
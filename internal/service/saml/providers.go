@@ -0,0 +1,52 @@
+package saml
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"net/url"
+	"os"
+)
+
+type providersFile struct {
+	Providers []*model.SAMLProvider `json:"providers"`
+}
+
+// LoadProviders reads the SAML identity providers from a JSON file. An
+// empty path yields no providers, which disables SAML login.
+func LoadProviders(path string) ([]*model.SAMLProvider, error) {
+	const op = "saml.LoadProviders"
+
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var file providersFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[string]bool, len(file.Providers))
+	for _, p := range file.Providers {
+		if p.Name == "" || p.EntityId == "" || p.ACSURL == "" {
+			return nil, fmt.Errorf("%s: provider %q: name, entity_id and acs_url are required", op, p.Name)
+		}
+		if u, err := url.Parse(p.ACSURL); err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("%s: provider %q: acs_url must be an absolute URL", op, p.Name)
+		}
+		if (p.IdPMetadataURL == "") == (p.IdPMetadataFile == "") {
+			return nil, fmt.Errorf("%s: provider %q: exactly one of idp_metadata_url and idp_metadata_file is required", op, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: duplicate provider %q", op, p.Name)
+		}
+		seen[p.Name] = true
+	}
+
+	return file.Providers, nil
+}
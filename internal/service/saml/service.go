@@ -0,0 +1,513 @@
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/crewjam/saml"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	loginStateRepoModel "github.com/nogavadu/auth-service/internal/repository/loginstate/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	authService "github.com/nogavadu/auth-service/internal/service/auth"
+	"github.com/nogavadu/platform_common/pkg/db"
+	dsig "github.com/russellhaering/goxmldsig"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	stateExpTime = 10 * time.Minute
+
+	metadataFetchTimeout = 10 * time.Second
+	metadataMaxSize      = 1 << 20
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrInvalidState     = errors.New("invalid or expired relay state")
+	ErrInvalidResponse  = errors.New("invalid saml response")
+	ErrEmailNotTrusted  = errors.New("identity provider is not trusted to log into existing users")
+	ErrProviderFailure  = errors.New("identity provider is unavailable")
+	ErrInternal         = errors.New("internal error")
+	errNoIdPDescriptors = errors.New("metadata has no IDPSSODescriptor")
+)
+
+type samlService struct {
+	log *slog.Logger
+
+	providers map[string]*provider
+
+	authService service.AuthService
+
+	userRepo       repository.UserRepository
+	roleRepo       repository.RoleRepository
+	identityRepo   repository.IdentityRepository
+	loginStateRepo repository.LoginStateRepository
+	txManager      db.TxManager
+}
+
+// provider loads the IdP metadata on first login, so the service starts
+// even when an IdP is temporarily unreachable. The SP metadata is available
+// right away.
+type provider struct {
+	cfg *model.SAMLProvider
+
+	mu sync.Mutex
+	sp *saml.ServiceProvider
+}
+
+// assertedUser is what a verified assertion says about the user.
+type assertedUser struct {
+	subject string
+	email   string
+	name    string
+	roles   []string
+}
+
+func New(
+	log *slog.Logger,
+	providers []*model.SAMLProvider,
+	certificate *x509.Certificate,
+	key *rsa.PrivateKey,
+	authService service.AuthService,
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	identityRepo repository.IdentityRepository,
+	loginStateRepo repository.LoginStateRepository,
+	txManager db.TxManager,
+) service.SAMLService {
+	s := &samlService{
+		log:            log,
+		providers:      make(map[string]*provider, len(providers)),
+		authService:    authService,
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		identityRepo:   identityRepo,
+		loginStateRepo: loginStateRepo,
+		txManager:      txManager,
+	}
+	for _, p := range providers {
+		// LoadProviders has already rejected malformed URLs.
+		acsURL, _ := url.Parse(p.ACSURL)
+
+		sp := &saml.ServiceProvider{
+			EntityID:          p.EntityId,
+			Key:               key,
+			Certificate:       certificate,
+			AcsURL:            *acsURL,
+			AuthnNameIDFormat: saml.PersistentNameIDFormat,
+		}
+		if p.SignRequests {
+			if key == nil {
+				log.Warn("saml provider wants signed requests but no SP key is configured", slog.String("provider", p.Name))
+			} else {
+				sp.SignatureMethod = dsig.RSASHA256SignatureMethod
+			}
+		}
+
+		s.providers[p.Name] = &provider{cfg: p, sp: sp}
+	}
+
+	return s
+}
+
+func (s *samlService) Metadata(providerName string) ([]byte, error) {
+	const op = "samlService.Metadata"
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	metadata := p.sp.Metadata()
+	// Responses are only accepted through CompleteLogin, which gets them
+	// from the HTTP-POST binding; drop the artifact endpoint.
+	for i := range metadata.SPSSODescriptors {
+		descriptor := &metadata.SPSSODescriptors[i]
+		descriptor.AssertionConsumerServices = descriptor.AssertionConsumerServices[:1]
+	}
+
+	data, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		s.log.Error("failed to marshal metadata", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func (s *samlService) BeginLogin(ctx context.Context, providerName string) (*model.SAMLLoginStart, error) {
+	const op = "samlService.BeginLogin"
+	log := s.log.With(slog.String("op", op), slog.String("provider", providerName))
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	sp, err := p.serviceProvider(ctx)
+	if err != nil {
+		log.Error("failed to load idp metadata", slog.String("error", err.Error()))
+		return nil, ErrProviderFailure
+	}
+
+	ssoURL := sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if ssoURL == "" {
+		log.Error("idp has no HTTP-Redirect single sign-on endpoint")
+		return nil, ErrProviderFailure
+	}
+
+	req, err := sp.MakeAuthenticationRequest(ssoURL, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		log.Error("failed to make authn request", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	relayState, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate relay state", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	redirectURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		log.Error("failed to encode authn request", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	// The request ID plays the part of the OIDC nonce: the response must be
+	// InResponseTo it. SAML has no PKCE, so there is no code verifier.
+	err = s.loginStateRepo.Create(ctx, &loginStateRepoModel.LoginState{
+		StateHash: hashToken(relayState),
+		Provider:  providerName,
+		Nonce:     req.ID,
+		ExpiresAt: time.Now().Add(stateExpTime),
+	})
+	if err != nil {
+		log.Error("failed to save login state", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return &model.SAMLLoginStart{
+		RedirectURL: redirectURL.String(),
+		RelayState:  relayState,
+	}, nil
+}
+
+func (s *samlService) CompleteLogin(ctx context.Context, providerName string, relayState string, samlResponse string) (string, error) {
+	const op = "samlService.CompleteLogin"
+	log := s.log.With(slog.String("op", op), slog.String("provider", providerName))
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	loginState, err := s.loginStateRepo.Consume(ctx, hashToken(relayState))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrInvalidState
+		}
+
+		log.Error("failed to consume login state", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+	if loginState.Provider != providerName || time.Now().After(loginState.ExpiresAt) {
+		return "", ErrInvalidState
+	}
+
+	sp, err := p.serviceProvider(ctx)
+	if err != nil {
+		log.Error("failed to load idp metadata", slog.String("error", err.Error()))
+		return "", ErrProviderFailure
+	}
+
+	responseXML, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return "", ErrInvalidResponse
+	}
+
+	// Checks the signature, issuer, audience, recipient, validity window
+	// and that the response answers our request.
+	assertion, err := sp.ParseXMLResponse(responseXML, []string{loginState.Nonce})
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		log.Warn("rejected saml response", slog.String("error", err.Error()))
+		return "", ErrInvalidResponse
+	}
+
+	user := p.assertedUser(assertion)
+	if user.subject == "" || user.email == "" {
+		log.Warn("saml assertion has no subject or email")
+		return "", ErrInvalidResponse
+	}
+
+	userId, err := s.linkUser(ctx, p.cfg, user)
+	if err != nil {
+		if errors.Is(err, ErrEmailNotTrusted) {
+			return "", ErrEmailNotTrusted
+		}
+
+		log.Error("failed to link user", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+
+	refreshToken, err := s.authService.IssueRefreshToken(ctx, userId)
+	if err != nil {
+		if errors.Is(err, authService.ErrAccountDeactivated) || errors.Is(err, authService.ErrUserSuspended) {
+			return "", err
+		}
+
+		return "", ErrInternal
+	}
+
+	return refreshToken, nil
+}
+
+// linkUser returns the local user behind the asserted identity, creating it
+// on first login, and keeps its role in line with the mapped attributes.
+func (s *samlService) linkUser(ctx context.Context, cfg *model.SAMLProvider, asserted *assertedUser) (int, error) {
+	const op = "samlService.linkUser"
+
+	var userId int
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		roleId, errTx := s.roleId(ctx, cfg, asserted.roles)
+		if errTx != nil {
+			return errTx
+		}
+
+		identity, errTx := s.identityRepo.Get(ctx, cfg.Name, asserted.subject)
+		switch {
+		case errTx == nil:
+			userId = identity.UserId
+		case errors.Is(errTx, repository.ErrNotFound):
+			if userId, errTx = s.findOrCreateUser(ctx, cfg, asserted); errTx != nil {
+				return errTx
+			}
+
+			errTx = s.identityRepo.Create(ctx, &identityRepoModel.Identity{
+				Provider: cfg.Name,
+				Subject:  asserted.subject,
+				UserId:   userId,
+				Email:    &asserted.email,
+			})
+			if errTx != nil {
+				return errTx
+			}
+		default:
+			return errTx
+		}
+
+		if roleId != nil {
+			return s.userRepo.Update(ctx, userId, &userRepoModel.UserUpdateInput{RoleId: roleId})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userId, nil
+}
+
+func (s *samlService) findOrCreateUser(ctx context.Context, cfg *model.SAMLProvider, asserted *assertedUser) (int, error) {
+	user, err := s.userRepo.GetByEmail(ctx, asserted.email)
+	switch {
+	case err == nil:
+		if !cfg.TrustEmail {
+			return 0, ErrEmailNotTrusted
+		}
+
+		return user.Id, nil
+	case errors.Is(err, repository.ErrNotFound):
+		info := &userRepoModel.UserInfo{
+			Email: asserted.email,
+		}
+		if asserted.name != "" {
+			info.Name = &asserted.name
+		}
+		// A provider trusted to assert emails vouches for this one.
+		if cfg.TrustEmail {
+			now := time.Now()
+			info.EmailVerifiedAt = &now
+		}
+
+		return s.userRepo.Create(ctx, info)
+	default:
+		return 0, err
+	}
+}
+
+// roleId maps the asserted role values to the highest-level configured role,
+// or to the default role when none match. nil leaves the role unchanged.
+func (s *samlService) roleId(ctx context.Context, cfg *model.SAMLProvider, values []string) (*int, error) {
+	var best *int
+	level := -1
+	for _, value := range values {
+		name, ok := cfg.RoleMapping[value]
+		if !ok {
+			continue
+		}
+
+		role, err := s.roleRepo.GetByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				s.log.Warn("saml role is mapped to an unknown role", slog.String("value", value), slog.String("role", name))
+				continue
+			}
+
+			return nil, err
+		}
+		if role.Level > level {
+			id := int(role.ID)
+			best, level = &id, role.Level
+		}
+	}
+	if best != nil || cfg.DefaultRole == "" {
+		return best, nil
+	}
+
+	role, err := s.roleRepo.GetByName(ctx, cfg.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+	id := int(role.ID)
+
+	return &id, nil
+}
+
+func (p *provider) assertedUser(assertion *saml.Assertion) *assertedUser {
+	user := &assertedUser{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		user.subject = assertion.Subject.NameID.Value
+		if assertion.Subject.NameID.Format == string(saml.EmailAddressNameIDFormat) {
+			user.email = assertion.Subject.NameID.Value
+		}
+	}
+
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			switch {
+			case p.cfg.EmailAttribute != "" && matches(attr, p.cfg.EmailAttribute) && len(attr.Values) > 0:
+				user.email = attr.Values[0].Value
+			case p.cfg.NameAttribute != "" && matches(attr, p.cfg.NameAttribute) && len(attr.Values) > 0:
+				user.name = attr.Values[0].Value
+			case p.cfg.RoleAttribute != "" && matches(attr, p.cfg.RoleAttribute):
+				for _, v := range attr.Values {
+					user.roles = append(user.roles, v.Value)
+				}
+			}
+		}
+	}
+
+	return user
+}
+
+func matches(attr saml.Attribute, name string) bool {
+	return attr.Name == name || attr.FriendlyName == name
+}
+
+func (p *provider) serviceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sp.IDPMetadata != nil {
+		return p.sp, nil
+	}
+
+	data, err := p.readMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := parseMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	p.sp.IDPMetadata = descriptor
+
+	return p.sp, nil
+}
+
+func (p *provider) readMetadata(ctx context.Context) ([]byte, error) {
+	if p.cfg.IdPMetadataFile != "" {
+		return os.ReadFile(p.cfg.IdPMetadataFile)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.IdPMetadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch metadata: %s", res.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, metadataMaxSize))
+}
+
+// parseMetadata accepts a single EntityDescriptor or an EntitiesDescriptor,
+// in which case the first IdP in it is used.
+func parseMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	var entity saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &entity); err == nil {
+		if len(entity.IDPSSODescriptors) == 0 {
+			return nil, errNoIdPDescriptors
+		}
+
+		return &entity, nil
+	}
+
+	var entities saml.EntitiesDescriptor
+	if err := xml.Unmarshal(data, &entities); err != nil {
+		return nil, err
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+
+	return nil, errNoIdPDescriptors
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,496 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	identityRepoModel "github.com/nogavadu/auth-service/internal/repository/identity/model"
+	loginStateRepoModel "github.com/nogavadu/auth-service/internal/repository/loginstate/model"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/platform_common/pkg/db"
+	dsig "github.com/russellhaering/goxmldsig"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	idpMetadataURL = "https://idp.example.com/metadata"
+	idpSSOURL      = "https://idp.example.com/sso"
+)
+
+// newIdP returns an identity provider with a fresh key and self-signed
+// certificate. Every IdP made by it has the same entity id, so one can sign
+// in place of another.
+func newIdP(t *testing.T) *saml.IdentityProvider {
+	t.Helper()
+
+	key, cert := newKeyPair(t, "idp.example.com")
+	metadataURL, _ := url.Parse(idpMetadataURL)
+	ssoURL, _ := url.Parse(idpSSOURL)
+
+	return &saml.IdentityProvider{
+		Key:             key,
+		Certificate:     cert,
+		MetadataURL:     *metadataURL,
+		SSOURL:          *ssoURL,
+		SignatureMethod: dsig.RSASHA256SignatureMethod,
+	}
+}
+
+func newKeyPair(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, cert
+}
+
+type txManager struct{}
+
+func (txManager) ReadCommitted(ctx context.Context, f db.Handler) error {
+	return f(ctx)
+}
+
+type userRepo struct {
+	repository.UserRepository
+	users []*userRepoModel.User
+}
+
+func (r *userRepo) Create(_ context.Context, info *userRepoModel.UserInfo) (int, error) {
+	r.users = append(r.users, &userRepoModel.User{Id: len(r.users) + 1, UserInfo: *info})
+	return len(r.users), nil
+}
+
+func (r *userRepo) GetByEmail(_ context.Context, email string) (*userRepoModel.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r *userRepo) Update(_ context.Context, id int, input *userRepoModel.UserUpdateInput) error {
+	if input.RoleId != nil {
+		r.users[id-1].RoleId = *input.RoleId
+	}
+
+	return nil
+}
+
+type roleRepo struct {
+	repository.RoleRepository
+}
+
+func (r *roleRepo) GetByName(_ context.Context, name string) (*roleRepoModel.Role, error) {
+	for _, role := range []*roleRepoModel.Role{
+		{ID: 1, Name: "user", Level: 1},
+		{ID: 2, Name: "moderator", Level: 50},
+		{ID: 3, Name: "admin", Level: 100},
+	} {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+type identityRepo struct {
+	repository.IdentityRepository
+	identities []*identityRepoModel.Identity
+}
+
+func (r *identityRepo) Create(_ context.Context, identity *identityRepoModel.Identity) error {
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *identityRepo) Get(_ context.Context, provider string, subject string) (*identityRepoModel.Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+type loginStateRepo struct {
+	states map[string]*loginStateRepoModel.LoginState
+}
+
+func (r *loginStateRepo) Create(_ context.Context, state *loginStateRepoModel.LoginState) error {
+	r.states[state.StateHash] = state
+	return nil
+}
+
+func (r *loginStateRepo) Consume(_ context.Context, stateHash string) (*loginStateRepoModel.LoginState, error) {
+	state, ok := r.states[stateHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(r.states, stateHash)
+
+	return state, nil
+}
+
+// authServ issues "refresh:<user id>" as the refresh token.
+type authServ struct {
+	service.AuthService
+}
+
+func (s *authServ) IssueRefreshToken(_ context.Context, userId int) (string, error) {
+	return "refresh:" + strconv.Itoa(userId), nil
+}
+
+type testEnv struct {
+	idp          *saml.IdentityProvider
+	cfg          *model.SAMLProvider
+	serv         service.SAMLService
+	userRepo     *userRepo
+	identityRepo *identityRepo
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		idp: newIdP(t),
+		cfg: &model.SAMLProvider{
+			Name:           "corp",
+			EntityId:       "https://auth.example.com/saml/corp",
+			ACSURL:         "https://auth.example.com/saml/corp/acs",
+			EmailAttribute: "eduPersonPrincipalName",
+			NameAttribute:  "cn",
+			RoleAttribute:  "eduPersonAffiliation",
+			RoleMapping:    map[string]string{"staff": "moderator", "admins": "admin"},
+			DefaultRole:    "user",
+		},
+		userRepo:     &userRepo{},
+		identityRepo: &identityRepo{},
+	}
+
+	metadata, err := xml.Marshal(env.idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.cfg.IdPMetadataFile = filepath.Join(t.TempDir(), "idp.xml")
+	if err = os.WriteFile(env.cfg.IdPMetadataFile, metadata, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, cert := newKeyPair(t, "auth.example.com")
+	env.serv = New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		[]*model.SAMLProvider{env.cfg},
+		cert,
+		key,
+		&authServ{},
+		env.userRepo,
+		&roleRepo{},
+		env.identityRepo,
+		&loginStateRepo{states: map[string]*loginStateRepoModel.LoginState{}},
+		txManager{},
+	)
+
+	return env
+}
+
+// begin starts a login and decodes the AuthnRequest from the redirect URL,
+// as the IdP would.
+func (env *testEnv) begin(t *testing.T) (string, *saml.AuthnRequest) {
+	t.Helper()
+
+	start, err := env.serv.BeginLogin(context.Background(), "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redirectURL, err := url.Parse(start.RedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := redirectURL.Query()
+	if redirectURL.Host != "idp.example.com" || query.Get("RelayState") != start.RelayState {
+		t.Fatalf("unexpected redirect url %s", start.RedirectURL)
+	}
+
+	deflated, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestXML, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request saml.AuthnRequest
+	if err = xml.Unmarshal(requestXML, &request); err != nil {
+		t.Fatal(err)
+	}
+
+	return start.RelayState, &request
+}
+
+// respond has idp answer request for the user in session, after tamper has
+// had a chance to alter what gets signed.
+func (env *testEnv) respond(t *testing.T, idp *saml.IdentityProvider, request *saml.AuthnRequest, session *saml.Session, tamper func(*saml.IdpAuthnRequest)) string {
+	t.Helper()
+
+	data, err := env.serv.Metadata("corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spMetadata saml.EntityDescriptor
+	if err = xml.Unmarshal(data, &spMetadata); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &saml.IdpAuthnRequest{
+		IDP:                     idp,
+		HTTPRequest:             httptest.NewRequest(http.MethodPost, idpSSOURL, nil),
+		Request:                 *request,
+		ServiceProviderMetadata: &spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0],
+		Now:                     time.Now(),
+	}
+	if err = (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(req)
+	}
+	if err = req.MakeResponse(); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	responseXML, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(responseXML)
+}
+
+func newSession() *saml.Session {
+	return &saml.Session{
+		NameID:         "external-1",
+		NameIDFormat:   string(saml.PersistentNameIDFormat),
+		UserEmail:      "jane@example.com",
+		UserCommonName: "Jane",
+		CreateTime:     time.Now(),
+	}
+}
+
+func TestCompleteLogin(t *testing.T) {
+	rogue := newIdP(t)
+
+	tests := []struct {
+		name    string
+		rogue   bool
+		session func(*saml.Session)
+		tamper  func(*saml.IdpAuthnRequest)
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "bad signature", rogue: true, wantErr: ErrInvalidResponse},
+		{
+			name: "wrong audience",
+			tamper: func(req *saml.IdpAuthnRequest) {
+				req.Assertion.Conditions.AudienceRestrictions[0].Audience.Value = "https://other.example.com"
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "wrong InResponseTo",
+			tamper: func(req *saml.IdpAuthnRequest) {
+				req.Request.ID = "id-another-request"
+				req.Assertion.Subject.SubjectConfirmations[0].SubjectConfirmationData.InResponseTo = req.Request.ID
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "wrong recipient",
+			tamper: func(req *saml.IdpAuthnRequest) {
+				req.ACSEndpoint = &saml.IndexedEndpoint{Binding: saml.HTTPPostBinding, Location: "https://other.example.com/acs"}
+				req.Assertion.Subject.SubjectConfirmations[0].SubjectConfirmationData.Recipient = req.ACSEndpoint.Location
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "expired",
+			tamper: func(req *saml.IdpAuthnRequest) {
+				req.Assertion.Conditions.NotOnOrAfter = time.Now().Add(-time.Hour)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{name: "no email", session: func(s *saml.Session) { s.UserEmail = "" }, wantErr: ErrInvalidResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			idp := env.idp
+			if tt.rogue {
+				idp = rogue
+			}
+			session := newSession()
+			if tt.session != nil {
+				tt.session(session)
+			}
+
+			relayState, request := env.begin(t)
+			response := env.respond(t, idp, request, session, tt.tamper)
+
+			refreshToken, err := env.serv.CompleteLogin(context.Background(), "corp", relayState, response)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(env.userRepo.users) != 0 || len(env.identityRepo.identities) != 0 {
+					t.Error("a rejected login provisioned a user")
+				}
+				return
+			}
+
+			if refreshToken != "refresh:1" {
+				t.Errorf("got refresh token %q, want %q", refreshToken, "refresh:1")
+			}
+		})
+	}
+}
+
+func TestCompleteLoginRejectsReusedRelayState(t *testing.T) {
+	env := newTestEnv(t)
+
+	relayState, request := env.begin(t)
+	response := env.respond(t, env.idp, request, newSession(), nil)
+	if _, err := env.serv.CompleteLogin(context.Background(), "corp", relayState, response); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.serv.CompleteLogin(context.Background(), "corp", relayState, response); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestCompleteLoginProvisioning(t *testing.T) {
+	login := func(t *testing.T, env *testEnv, session *saml.Session) (string, error) {
+		t.Helper()
+
+		relayState, request := env.begin(t)
+		return env.serv.CompleteLogin(context.Background(), "corp", relayState, env.respond(t, env.idp, request, session, nil))
+	}
+
+	t.Run("creates a user", func(t *testing.T) {
+		env := newTestEnv(t)
+
+		session := newSession()
+		session.Groups = []string{"staff", "admins", "contractors"}
+		if _, err := login(t, env, session); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(env.userRepo.users) != 1 {
+			t.Fatalf("got %d users, want 1", len(env.userRepo.users))
+		}
+		user := env.userRepo.users[0]
+		if user.Email != "jane@example.com" || user.Name == nil || *user.Name != "Jane" || user.PassHash != "" {
+			t.Errorf("unexpected user %+v", user.UserInfo)
+		}
+		if user.RoleId != 3 {
+			t.Errorf("got role %d, want the highest mapped role 3", user.RoleId)
+		}
+		if len(env.identityRepo.identities) != 1 || env.identityRepo.identities[0].Subject != "external-1" {
+			t.Errorf("identity is not linked to the new user")
+		}
+
+		// The next login finds the identity, even under a new email, and
+		// falls back to the default role.
+		session = newSession()
+		session.UserEmail = "jane.doe@example.com"
+		refreshToken, err := login(t, env, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refreshToken != "refresh:1" || len(env.userRepo.users) != 1 || len(env.identityRepo.identities) != 1 {
+			t.Errorf("returning user was provisioned again")
+		}
+		if env.userRepo.users[0].RoleId != 1 {
+			t.Errorf("got role %d, want the default role 1", env.userRepo.users[0].RoleId)
+		}
+	})
+
+	t.Run("refuses an existing email", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "jane@example.com", PassHash: "hash"}},
+		}
+
+		if _, err := login(t, env, newSession()); !errors.Is(err, ErrEmailNotTrusted) {
+			t.Fatalf("got error %v, want %v", err, ErrEmailNotTrusted)
+		}
+		if len(env.identityRepo.identities) != 0 {
+			t.Error("identity was linked to an untrusted email")
+		}
+	})
+
+	t.Run("links an existing email when trusted", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.cfg.TrustEmail = true
+		env.userRepo.users = []*userRepoModel.User{
+			{Id: 1, UserInfo: userRepoModel.UserInfo{Email: "john@example.com"}},
+			{Id: 2, UserInfo: userRepoModel.UserInfo{Email: "jane@example.com", PassHash: "hash"}},
+		}
+
+		refreshToken, err := login(t, env, newSession())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refreshToken != "refresh:2" || len(env.userRepo.users) != 2 {
+			t.Errorf("got refresh token %q with %d users, want the existing user 2", refreshToken, len(env.userRepo.users))
+		}
+	})
+}
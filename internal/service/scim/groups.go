@@ -0,0 +1,320 @@
+package scim
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"strings"
+)
+
+// Groups are the roles within the client's reach, except the default one.
+// A user holds a single role, so adding them to a group takes them out of
+// their previous one, and removing them puts them back in the default role.
+
+// CreateGroup creates a role at level 0 without any permissions; they are
+// granted by an admin.
+func (s *scimService) CreateGroup(ctx context.Context, client *model.SCIMClient, name string, members []int) (*model.SCIMGroup, error) {
+	const op = "scimService.CreateGroup"
+
+	var group *model.SCIMGroup
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		id, errTx := s.roleRepo.Create(ctx, name, 0)
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrAlreadyExists) {
+				return ErrConflict
+			}
+
+			return errTx
+		}
+
+		for _, userId := range members {
+			if errTx = s.addMember(ctx, client, id, userId); errTx != nil {
+				return errTx
+			}
+		}
+
+		group, errTx = s.getGroup(ctx, client, id, true)
+		return errTx
+	})
+	if err != nil {
+		return nil, s.internalError(op, "failed to create group", err)
+	}
+
+	return group, nil
+}
+
+func (s *scimService) GetGroup(ctx context.Context, client *model.SCIMClient, id int, withMembers bool) (*model.SCIMGroup, error) {
+	const op = "scimService.GetGroup"
+
+	group, err := s.getGroup(ctx, client, id, withMembers)
+	if err != nil {
+		return nil, s.internalError(op, "failed to get group", err)
+	}
+
+	return group, nil
+}
+
+func (s *scimService) ListGroups(ctx context.Context, client *model.SCIMClient, filter *model.SCIMGroupFilter) (*model.SCIMGroupPage, error) {
+	const op = "scimService.ListGroups"
+
+	offset, limit := page(filter.StartIndex, filter.Count)
+	excludeId := defaultRoleId
+	repoFilter := &roleRepoModel.ListFilter{
+		Name:      filter.Name,
+		MaxLevel:  &client.MaxRoleLevel,
+		ExcludeId: &excludeId,
+		Offset:    offset,
+		Limit:     limit,
+	}
+
+	if filter.Id != nil {
+		group, err := s.getGroup(ctx, client, *filter.Id, filter.WithMembers)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return &model.SCIMGroupPage{}, nil
+			}
+
+			return nil, s.internalError(op, "failed to get group", err)
+		}
+		if filter.Name != "" && !strings.EqualFold(group.Name, filter.Name) {
+			return &model.SCIMGroupPage{}, nil
+		}
+
+		res := &model.SCIMGroupPage{Total: 1}
+		if offset == 0 && limit > 0 {
+			res.Groups = []*model.SCIMGroup{group}
+		}
+		return res, nil
+	}
+
+	total, err := s.roleRepo.Count(ctx, repoFilter)
+	if err != nil {
+		return nil, s.internalError(op, "failed to count groups", err)
+	}
+
+	res := &model.SCIMGroupPage{
+		Total: total,
+	}
+	if limit == 0 {
+		return res, nil
+	}
+
+	roles, err := s.roleRepo.List(ctx, repoFilter)
+	if err != nil {
+		return nil, s.internalError(op, "failed to list groups", err)
+	}
+
+	for _, role := range roles {
+		group := &model.SCIMGroup{
+			Id:   int(role.ID),
+			Name: role.Name,
+		}
+		if filter.WithMembers {
+			if group.Members, err = s.listMembers(ctx, group.Id); err != nil {
+				return nil, s.internalError(op, "failed to list members", err)
+			}
+		}
+
+		res.Groups = append(res.Groups, group)
+	}
+
+	return res, nil
+}
+
+// PatchGroup applies the patch in one transaction. The group is returned
+// without its members.
+func (s *scimService) PatchGroup(ctx context.Context, client *model.SCIMClient, id int, patch *model.SCIMGroupPatch) (*model.SCIMGroup, error) {
+	const op = "scimService.PatchGroup"
+
+	var group *model.SCIMGroup
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if _, errTx := s.getGroup(ctx, client, id, false); errTx != nil {
+			return errTx
+		}
+
+		if patch.Name != nil {
+			if errTx := s.roleRepo.Rename(ctx, id, *patch.Name); errTx != nil {
+				if errors.Is(errTx, repository.ErrAlreadyExists) {
+					return ErrConflict
+				}
+
+				return errTx
+			}
+		}
+
+		for _, change := range patch.Members {
+			if errTx := s.changeMembers(ctx, client, id, change); errTx != nil {
+				return errTx
+			}
+		}
+
+		var errTx error
+		group, errTx = s.getGroup(ctx, client, id, false)
+		return errTx
+	})
+	if err != nil {
+		return nil, s.internalError(op, "failed to patch group", err)
+	}
+
+	return group, nil
+}
+
+// DeleteGroup deletes the role; its users fall back to the default role.
+func (s *scimService) DeleteGroup(ctx context.Context, client *model.SCIMClient, id int) error {
+	const op = "scimService.DeleteGroup"
+
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if _, errTx := s.getGroup(ctx, client, id, false); errTx != nil {
+			return errTx
+		}
+
+		errTx := s.roleRepo.Delete(ctx, id)
+		if errors.Is(errTx, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return errTx
+	})
+	if err != nil {
+		return s.internalError(op, "failed to delete group", err)
+	}
+
+	return nil
+}
+
+func (s *scimService) getGroup(ctx context.Context, client *model.SCIMClient, id int, withMembers bool) (*model.SCIMGroup, error) {
+	if id == defaultRoleId {
+		return nil, ErrNotFound
+	}
+
+	role, err := s.visibleRole(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &model.SCIMGroup{
+		Id:   int(role.ID),
+		Name: role.Name,
+	}
+	if withMembers {
+		if group.Members, err = s.listMembers(ctx, group.Id); err != nil {
+			return nil, err
+		}
+	}
+
+	return group, nil
+}
+
+func (s *scimService) listMembers(ctx context.Context, roleId int) ([]*model.SCIMMember, error) {
+	members := []*model.SCIMMember{}
+	for offset := uint64(0); ; offset += maxPageSize {
+		users, err := s.userRepo.List(ctx, &userRepoModel.ListFilter{
+			RoleId: &roleId,
+			SortBy: userRepoModel.SortById,
+			Offset: offset,
+			Limit:  maxPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range users {
+			members = append(members, &model.SCIMMember{
+				UserId: user.Id,
+				Email:  user.Email,
+			})
+		}
+
+		if len(users) < maxPageSize {
+			return members, nil
+		}
+	}
+}
+
+func (s *scimService) changeMembers(ctx context.Context, client *model.SCIMClient, roleId int, change *model.SCIMMembersChange) error {
+	switch change.Op {
+	case model.SCIMMembersAdd:
+		for _, userId := range change.UserIds {
+			if err := s.addMember(ctx, client, roleId, userId); err != nil {
+				return err
+			}
+		}
+	case model.SCIMMembersRemove:
+		if change.UserIds == nil {
+			return s.removeAllMembers(ctx, roleId)
+		}
+
+		for _, userId := range change.UserIds {
+			if err := s.removeMember(ctx, roleId, userId); err != nil {
+				return err
+			}
+		}
+	case model.SCIMMembersReplace:
+		if err := s.removeAllMembers(ctx, roleId); err != nil {
+			return err
+		}
+
+		for _, userId := range change.UserIds {
+			if err := s.addMember(ctx, client, roleId, userId); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrInvalidValue
+	}
+
+	return nil
+}
+
+func (s *scimService) addMember(ctx context.Context, client *model.SCIMClient, roleId int, userId int) error {
+	if _, err := s.getUser(ctx, client, userId); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidValue
+		}
+
+		return err
+	}
+
+	return s.userRepo.Update(ctx, userId, &userRepoModel.UserUpdateInput{
+		RoleId: &roleId,
+	})
+}
+
+// removeMember moves the user back to the default role. Users who are not
+// in the group are left alone.
+func (s *scimService) removeMember(ctx context.Context, roleId int, userId int) error {
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+	if user.RoleId != roleId || user.DeletedAt != nil {
+		return nil
+	}
+
+	defaultRole := defaultRoleId
+	return s.userRepo.Update(ctx, userId, &userRepoModel.UserUpdateInput{
+		RoleId: &defaultRole,
+	})
+}
+
+func (s *scimService) removeAllMembers(ctx context.Context, roleId int) error {
+	members, err := s.listMembers(ctx, roleId)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if err = s.removeMember(ctx, roleId, member.UserId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
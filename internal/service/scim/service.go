@@ -0,0 +1,156 @@
+package scim
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// defaultRoleId is the role users.role defaults to. It is not exposed as a
+// group: being in it means being in no group.
+const defaultRoleId = 1
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+var (
+	ErrUnauthorized = errors.New("invalid SCIM token")
+	ErrNotFound     = errors.New("resource not found")
+	ErrConflict     = errors.New("resource already exists")
+	ErrInvalidValue = errors.New("invalid value")
+	ErrInternal     = errors.New("internal error")
+)
+
+type scimService struct {
+	log *slog.Logger
+
+	clients []*model.SCIMClient
+
+	userRepo  repository.UserRepository
+	roleRepo  repository.RoleRepository
+	txManager db.TxManager
+}
+
+func New(
+	log *slog.Logger,
+	clients []*model.SCIMClient,
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	txManager db.TxManager,
+) service.SCIMService {
+	return &scimService{
+		log:       log,
+		clients:   clients,
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		txManager: txManager,
+	}
+}
+
+// LoadClients reads the SCIM client registry. A token hash can be made with
+// `printf %s "$TOKEN" | sha256sum`.
+func LoadClients(path string) ([]*model.SCIMClient, error) {
+	const op = "scim.LoadClients"
+
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var registry model.SCIMClients
+	if err = json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[string]bool, len(registry.Clients))
+	for _, client := range registry.Clients {
+		if client.Name == "" {
+			return nil, fmt.Errorf("%s: client without a name", op)
+		}
+		if seen[client.Name] {
+			return nil, fmt.Errorf("%s: duplicate client %q", op, client.Name)
+		}
+		seen[client.Name] = true
+
+		client.TokenSHA256 = strings.ToLower(client.TokenSHA256)
+		if hash, err := hex.DecodeString(client.TokenSHA256); err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("%s: client %q: token_sha256 must be a hex SHA-256", op, client.Name)
+		}
+	}
+
+	return registry.Clients, nil
+}
+
+func (s *scimService) Authenticate(token string) (*model.SCIMClient, error) {
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+
+	for _, client := range s.clients {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(client.TokenSHA256)) == 1 {
+			return client, nil
+		}
+	}
+
+	return nil, ErrUnauthorized
+}
+
+// visibleRole returns the role if it is within the client's reach.
+func (s *scimService) visibleRole(ctx context.Context, client *model.SCIMClient, roleId int) (*roleRepoModel.Role, error) {
+	role, err := s.roleRepo.GetById(ctx, roleId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+	if role.Level > client.MaxRoleLevel {
+		return nil, ErrNotFound
+	}
+
+	return role, nil
+}
+
+// page turns a 1-based SCIM start index and count into an offset and limit.
+func page(startIndex int, count *int) (uint64, uint64) {
+	offset := uint64(0)
+	if startIndex > 1 {
+		offset = uint64(startIndex - 1)
+	}
+
+	limit := defaultPageSize
+	if count != nil {
+		limit = min(max(*count, 0), maxPageSize)
+	}
+
+	return offset, uint64(limit)
+}
+
+// internalError logs err and hides it behind ErrInternal, unless it is one
+// of the errors of the service.
+func (s *scimService) internalError(op string, msg string, err error) error {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) || errors.Is(err, ErrInvalidValue) {
+		return err
+	}
+
+	s.log.Error(msg, slog.String("op", op), slog.String("error", err.Error()))
+	return ErrInternal
+}
@@ -0,0 +1,297 @@
+package scim
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// CreateUser provisions a user in the default role. Without a password the
+// user can only log in through other authenticators, such as SSO.
+func (s *scimService) CreateUser(ctx context.Context, client *model.SCIMClient, input *model.SCIMUserInput) (*model.SCIMUser, error) {
+	const op = "scimService.CreateUser"
+
+	passHash, err := hashPassword(input.Password)
+	if err != nil {
+		return nil, s.internalError(op, "failed to hash password", err)
+	}
+
+	var user *model.SCIMUser
+	err = s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		id, errTx := s.userRepo.Create(ctx, &userRepoModel.UserInfo{
+			Name:     input.Name,
+			Email:    input.Email,
+			PassHash: passHash,
+		})
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrAlreadyExists) {
+				return ErrConflict
+			}
+
+			return errTx
+		}
+
+		if !input.Active {
+			if errTx = s.userRepo.SetDeactivated(ctx, id, true); errTx != nil {
+				return errTx
+			}
+		}
+
+		user, errTx = s.getUser(ctx, client, id)
+		return errTx
+	})
+	if err != nil {
+		return nil, s.internalError(op, "failed to create user", err)
+	}
+
+	return user, nil
+}
+
+func (s *scimService) GetUser(ctx context.Context, client *model.SCIMClient, id int) (*model.SCIMUser, error) {
+	const op = "scimService.GetUser"
+
+	user, err := s.getUser(ctx, client, id)
+	if err != nil {
+		return nil, s.internalError(op, "failed to get user", err)
+	}
+
+	return user, nil
+}
+
+func (s *scimService) ListUsers(ctx context.Context, client *model.SCIMClient, filter *model.SCIMUserFilter) (*model.SCIMUserPage, error) {
+	const op = "scimService.ListUsers"
+
+	offset, limit := page(filter.StartIndex, filter.Count)
+	repoFilter := &userRepoModel.ListFilter{
+		EmailIs:      filter.Email,
+		MaxRoleLevel: &client.MaxRoleLevel,
+		SortBy:       userRepoModel.SortById,
+		Offset:       offset,
+		Limit:        limit,
+	}
+	if filter.Active != nil {
+		repoFilter.Status = userRepoModel.StatusDeactivated
+		if *filter.Active {
+			repoFilter.Status = userRepoModel.StatusActive
+		}
+	}
+
+	// The id filter is served by a lookup, so that it shares the visibility
+	// rules of GetUser.
+	if filter.Id != nil {
+		user, err := s.getUser(ctx, client, *filter.Id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return &model.SCIMUserPage{}, nil
+			}
+
+			return nil, s.internalError(op, "failed to get user", err)
+		}
+		if !matchesUser(user, filter) {
+			return &model.SCIMUserPage{}, nil
+		}
+
+		res := &model.SCIMUserPage{Total: 1}
+		if offset == 0 && limit > 0 {
+			res.Users = []*model.SCIMUser{user}
+		}
+		return res, nil
+	}
+
+	total, err := s.userRepo.Count(ctx, repoFilter)
+	if err != nil {
+		return nil, s.internalError(op, "failed to count users", err)
+	}
+
+	res := &model.SCIMUserPage{
+		Total: total,
+	}
+	if limit == 0 {
+		return res, nil
+	}
+
+	users, err := s.userRepo.List(ctx, repoFilter)
+	if err != nil {
+		return nil, s.internalError(op, "failed to list users", err)
+	}
+
+	roles := map[int]*roleRepoModel.Role{}
+	for _, user := range users {
+		role, ok := roles[user.RoleId]
+		if !ok {
+			role, err = s.roleRepo.GetById(ctx, user.RoleId)
+			if err != nil {
+				return nil, s.internalError(op, "failed to get role", err)
+			}
+			roles[user.RoleId] = role
+		}
+
+		res.Users = append(res.Users, scimUserFromRepo(user, role))
+	}
+
+	return res, nil
+}
+
+// PatchUser applies the patch in one transaction. Setting active to false
+// deactivates the user, which invalidates their tokens; true reactivates
+// them.
+func (s *scimService) PatchUser(ctx context.Context, client *model.SCIMClient, id int, patch *model.SCIMUserPatch) (*model.SCIMUser, error) {
+	const op = "scimService.PatchUser"
+
+	passHash, err := hashPassword(patch.Password)
+	if err != nil {
+		return nil, s.internalError(op, "failed to hash password", err)
+	}
+
+	var user *model.SCIMUser
+	err = s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if _, errTx := s.getUser(ctx, client, id); errTx != nil {
+			return errTx
+		}
+
+		input := &userRepoModel.UserUpdateInput{
+			Name:      patch.Name,
+			Email:     patch.Email,
+			ClearName: patch.ClearName,
+		}
+		if patch.Password != nil {
+			input.Password = &passHash
+		}
+
+		if errTx := s.userRepo.Update(ctx, id, input); errTx != nil {
+			switch {
+			case errors.Is(errTx, repository.ErrAlreadyExists):
+				return ErrConflict
+			case errors.Is(errTx, repository.ErrNotFound):
+				return ErrNotFound
+			}
+
+			return errTx
+		}
+
+		if patch.Active != nil {
+			if errTx := s.userRepo.SetDeactivated(ctx, id, !*patch.Active); errTx != nil {
+				return errTx
+			}
+		}
+
+		var errTx error
+		user, errTx = s.getUser(ctx, client, id)
+		return errTx
+	})
+	if err != nil {
+		return nil, s.internalError(op, "failed to patch user", err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser soft-deletes the user; they are purged after the grace period
+// like users deleted through the API.
+func (s *scimService) DeleteUser(ctx context.Context, client *model.SCIMClient, id int) error {
+	const op = "scimService.DeleteUser"
+
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if _, errTx := s.getUser(ctx, client, id); errTx != nil {
+			return errTx
+		}
+
+		errTx := s.userRepo.Delete(ctx, id)
+		if errors.Is(errTx, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return errTx
+	})
+	if err != nil {
+		return s.internalError(op, "failed to delete user", err)
+	}
+
+	return nil
+}
+
+// getUser returns the user unless they are deleted or out of the client's
+// reach.
+func (s *scimService) getUser(ctx context.Context, client *model.SCIMClient, id int) (*model.SCIMUser, error) {
+	user, err := s.userRepo.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	role, err := s.visibleRole(ctx, client, user.RoleId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scimUserFromRepo(user, role), nil
+}
+
+func matchesUser(user *model.SCIMUser, filter *model.SCIMUserFilter) bool {
+	if filter.Email != "" && !strings.EqualFold(user.Email, filter.Email) {
+		return false
+	}
+	if filter.Active != nil && *filter.Active != (user.Status == model.UserStatusActive) {
+		return false
+	}
+
+	return true
+}
+
+func scimUserFromRepo(user *userRepoModel.User, role *roleRepoModel.Role) *model.SCIMUser {
+	status := model.UserStatusActive
+	if user.DeactivatedAt != nil {
+		status = model.UserStatusDeactivated
+	}
+
+	res := &model.SCIMUser{
+		User: model.User{
+			Id: user.Id,
+			UserInfo: model.UserInfo{
+				Name:   user.Name,
+				Email:  user.Email,
+				Avatar: user.Avatar,
+				Role:   role.Name,
+			},
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			LastLoginAt:   user.LastLoginAt,
+			Status:        status,
+			DeactivatedAt: user.DeactivatedAt,
+		},
+	}
+	if int(role.ID) != defaultRoleId {
+		res.Group = &model.SCIMGroupRef{
+			Id:   int(role.ID),
+			Name: role.Name,
+		}
+	}
+
+	return res
+}
+
+// hashPassword returns the bcrypt hash of password, or an empty hash, which
+// matches no password, when there is none.
+func hashPassword(password *string) (string, error) {
+	if password == nil {
+		return "", nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
@@ -3,22 +3,105 @@ package service
 import (
 	"context"
 	"github.com/nogavadu/auth-service/internal/domain/model"
+	"io"
+	"time"
 )
 
 type AuthService interface {
 	Register(ctx context.Context, userInfo *model.UserInfo, password string) (int, error)
 	Login(ctx context.Context, email string, password string) (string, error)
+	Authenticate(ctx context.Context, email string, password string) (int, error)
+	CheckSession(ctx context.Context, userId int, authTime time.Time) error
+	IssueRefreshToken(ctx context.Context, userId int) (string, error)
 	GetRefreshToken(ctx context.Context, refreshToken string) (string, error)
 	GetAccessToken(ctx context.Context, refreshToken string) (string, error)
 	IsUser(ctx context.Context, userId int, refreshToken string) error
 }
 
 type AccessService interface {
-	Check(ctx context.Context, accessToken string, requiredLvl int) error
+	Check(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error)
+	CheckV2(ctx context.Context, accessToken string, requiredLvl int) (*model.Principal, error)
+	BatchCheck(ctx context.Context, accessToken string, items []*model.AccessItem) ([]*model.AccessDecision, error)
 }
 
 type UserService interface {
 	GetById(ctx context.Context, id int) (*model.User, error)
-	Update(ctx context.Context, id int, input *model.UserUpdateInput) error
-	Delete(ctx context.Context, id int) error
+	List(ctx context.Context, filter *model.UserFilter) (*model.UserPage, error)
+	Update(ctx context.Context, principal *model.Principal, id int, input *model.UserUpdateInput) (*model.User, error)
+	Deactivate(ctx context.Context, moderator *model.Principal, id int) error
+	Reactivate(ctx context.Context, moderator *model.Principal, id int) error
+	Delete(ctx context.Context, principal *model.Principal, id int) error
+	Restore(ctx context.Context, moderator *model.Principal, id int) error
+	PurgeDeleted(ctx context.Context) (int, error)
+	Suspend(ctx context.Context, moderator *model.Principal, userId int, reason string, until *time.Time) (*model.Suspension, error)
+	Unsuspend(ctx context.Context, moderator *model.Principal, userId int) error
+	ListSuspensions(ctx context.Context, userId int) ([]*model.Suspension, error)
+	GetAttributes(ctx context.Context, userId int, keys []string, privileged bool) (map[string]interface{}, error)
+	UpdateAttributes(ctx context.Context, userId int, values map[string]interface{}, mask []string, privileged bool) (map[string]interface{}, error)
+	RequestEmailChange(ctx context.Context, userId int, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) (*model.User, error)
+	UploadAvatar(ctx context.Context, userId int, r io.Reader) (*model.Avatar, error)
+}
+
+type SCIMService interface {
+	Authenticate(token string) (*model.SCIMClient, error)
+	CreateUser(ctx context.Context, client *model.SCIMClient, input *model.SCIMUserInput) (*model.SCIMUser, error)
+	GetUser(ctx context.Context, client *model.SCIMClient, id int) (*model.SCIMUser, error)
+	ListUsers(ctx context.Context, client *model.SCIMClient, filter *model.SCIMUserFilter) (*model.SCIMUserPage, error)
+	PatchUser(ctx context.Context, client *model.SCIMClient, id int, patch *model.SCIMUserPatch) (*model.SCIMUser, error)
+	DeleteUser(ctx context.Context, client *model.SCIMClient, id int) error
+	CreateGroup(ctx context.Context, client *model.SCIMClient, name string, members []int) (*model.SCIMGroup, error)
+	GetGroup(ctx context.Context, client *model.SCIMClient, id int, withMembers bool) (*model.SCIMGroup, error)
+	ListGroups(ctx context.Context, client *model.SCIMClient, filter *model.SCIMGroupFilter) (*model.SCIMGroupPage, error)
+	PatchGroup(ctx context.Context, client *model.SCIMClient, id int, patch *model.SCIMGroupPatch) (*model.SCIMGroup, error)
+	DeleteGroup(ctx context.Context, client *model.SCIMClient, id int) error
+}
+
+type OIDCService interface {
+	ValidateAuthorizeRequest(ctx context.Context, req *model.AuthorizeRequest) error
+	Authorize(ctx context.Context, req *model.AuthorizeRequest, email string, password string) (string, error)
+	Token(ctx context.Context, req *model.TokenRequest) (*model.TokenSet, error)
+	UserInfo(ctx context.Context, accessToken string) (*model.User, error)
+	DeviceAuthorization(ctx context.Context, clientId string, clientSecret string, scope string) (*model.DeviceAuthorization, error)
+	GetDeviceRequest(ctx context.Context, userCode string) (*model.DeviceRequest, error)
+	AuthorizeDevice(ctx context.Context, userCode string, email string, password string, approve bool) error
+	PurgeDeviceCodes(ctx context.Context) (int, error)
+}
+
+type ServiceAccountService interface {
+	Create(ctx context.Context, creator *model.Principal, info *model.ServiceAccountInfo) (*model.ServiceAccount, string, error)
+	RotateSecret(ctx context.Context, id int) (string, error)
+	Disable(ctx context.Context, id int) error
+	Token(ctx context.Context, clientId string, clientSecret string, scope string) (*model.TokenSet, error)
+}
+
+type APIKeyService interface {
+	Create(ctx context.Context, owner *model.Principal, name string, scopes []string, expiresAt *time.Time) (*model.APIKey, string, error)
+	List(ctx context.Context, userId int) ([]*model.APIKey, error)
+	Revoke(ctx context.Context, userId int, id int) error
+}
+
+type FederationService interface {
+	BeginExternalLogin(ctx context.Context, provider string) (*model.ExternalLoginStart, error)
+	CompleteExternalLogin(ctx context.Context, provider string, state string, code string) (string, error)
+	VerifyExternalLogin(ctx context.Context, provider string, state string, code string) (*model.ExternalIdentity, error)
+}
+
+type SAMLService interface {
+	Metadata(provider string) ([]byte, error)
+	BeginLogin(ctx context.Context, provider string) (*model.SAMLLoginStart, error)
+	CompleteLogin(ctx context.Context, provider string, relayState string, samlResponse string) (string, error)
+}
+
+type IdentityService interface {
+	List(ctx context.Context, userId int) ([]*model.Identity, error)
+	Link(ctx context.Context, principal *model.Principal, password string, provider string, state string, code string) (*model.Identity, error)
+	Unlink(ctx context.Context, userId int, provider string, subject string) error
+}
+
+// Authenticator checks a login and password against one backend and returns
+// the id of the matching local user.
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, email string, password string) (int, error)
 }
@@ -0,0 +1,269 @@
+package serviceaccount
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	serviceAccountRepoModel "github.com/nogavadu/auth-service/internal/repository/serviceaccount/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/utils"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const clientIdPrefix = "sa_"
+
+var (
+	ErrNotFound           = errors.New("service account not found")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrForbidden          = errors.New("role outranks the caller")
+	ErrInvalidCredentials = errors.New("invalid client credentials")
+	ErrInvalidScope       = errors.New("invalid scope")
+	ErrInternal           = errors.New("internal error")
+)
+
+type serviceAccountService struct {
+	log *slog.Logger
+
+	accessTokenSecret  string
+	accessTokenExpTime time.Duration
+	accessTokenKey     *rsa.PrivateKey
+
+	serviceAccountRepo repository.ServiceAccountRepository
+	roleRepo           repository.RoleRepository
+	txManager          db.TxManager
+}
+
+func New(
+	log *slog.Logger,
+	accessTokenSecret string,
+	accessTokenExp time.Duration,
+	accessTokenKey *rsa.PrivateKey,
+	serviceAccountRepo repository.ServiceAccountRepository,
+	roleRepo repository.RoleRepository,
+	txManager db.TxManager,
+) service.ServiceAccountService {
+	return &serviceAccountService{
+		log:                log,
+		accessTokenSecret:  accessTokenSecret,
+		accessTokenExpTime: accessTokenExp,
+		accessTokenKey:     accessTokenKey,
+		serviceAccountRepo: serviceAccountRepo,
+		roleRepo:           roleRepo,
+		txManager:          txManager,
+	}
+}
+
+// Create registers a service account on behalf of creator, whose own role
+// bounds the role the account may be given.
+func (s *serviceAccountService) Create(ctx context.Context, creator *model.Principal, info *model.ServiceAccountInfo) (*model.ServiceAccount, string, error) {
+	const op = "serviceAccountService.Create"
+	log := s.log.With(slog.String("op", op))
+
+	clientId, err := randomHex(16)
+	if err != nil {
+		log.Error("failed to generate client id", slog.String("error", err.Error()))
+		return nil, "", ErrInternal
+	}
+	clientId = clientIdPrefix + clientId
+
+	secret, secretHash, err := newSecret()
+	if err != nil {
+		log.Error("failed to generate client secret", slog.String("error", err.Error()))
+		return nil, "", ErrInternal
+	}
+
+	var account *model.ServiceAccount
+	err = s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		var errTx error
+		defer func() {
+			if errTx != nil {
+				log.Error("failed to create service account", slog.String("error", errTx.Error()))
+			}
+		}()
+
+		role, errTx := s.roleRepo.GetByName(ctx, info.Role)
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidRole
+			}
+
+			return ErrInternal
+		}
+		if role.Level > creator.RoleLevel {
+			return ErrForbidden
+		}
+
+		id, errTx := s.serviceAccountRepo.Create(ctx, &serviceAccountRepoModel.ServiceAccountInfo{
+			ClientId:         clientId,
+			ClientSecretHash: secretHash,
+			Name:             info.Name,
+			RoleId:           int(role.ID),
+			Scopes:           info.Scopes,
+		})
+		if errTx != nil {
+			return ErrInternal
+		}
+
+		repoAccount, errTx := s.serviceAccountRepo.GetById(ctx, id)
+		if errTx != nil {
+			return ErrInternal
+		}
+
+		account = toModel(repoAccount, role.Name)
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidRole) || errors.Is(err, ErrForbidden) {
+			return nil, "", err
+		}
+
+		return nil, "", ErrInternal
+	}
+
+	return account, secret, nil
+}
+
+func (s *serviceAccountService) RotateSecret(ctx context.Context, id int) (string, error) {
+	const op = "serviceAccountService.RotateSecret"
+	log := s.log.With(slog.String("op", op))
+
+	secret, secretHash, err := newSecret()
+	if err != nil {
+		log.Error("failed to generate client secret", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+
+	if err = s.serviceAccountRepo.UpdateSecret(ctx, id, secretHash); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrNotFound
+		}
+
+		log.Error("failed to update client secret", slog.String("error", err.Error()))
+		return "", ErrInternal
+	}
+
+	return secret, nil
+}
+
+func (s *serviceAccountService) Disable(ctx context.Context, id int) error {
+	const op = "serviceAccountService.Disable"
+	log := s.log.With(slog.String("op", op))
+
+	if err := s.serviceAccountRepo.Disable(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		log.Error("failed to disable service account", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (s *serviceAccountService) Token(ctx context.Context, clientId string, clientSecret string, scope string) (*model.TokenSet, error) {
+	const op = "serviceAccountService.Token"
+	log := s.log.With(slog.String("op", op))
+
+	repoAccount, err := s.serviceAccountRepo.GetByClientId(ctx, clientId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+
+		log.Error("failed to get service account", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if repoAccount.DisabledAt != nil || !utils.VerifyPassword(repoAccount.ClientSecretHash, clientSecret) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// An empty scope grants everything the account is allowed; otherwise each
+	// requested scope must be one of the allowed ones.
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = repoAccount.Scopes
+	}
+	for _, sc := range scopes {
+		if !slices.Contains(repoAccount.Scopes, sc) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	role, err := s.roleRepo.GetById(ctx, repoAccount.RoleId)
+	if err != nil {
+		log.Error("failed to get role", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	account := toModel(repoAccount, role.Name)
+	grantedScope := strings.Join(scopes, " ")
+
+	var accessToken string
+	if s.accessTokenKey != nil {
+		accessToken, err = utils.GenerateServiceAccountRSAToken(account, grantedScope, s.accessTokenKey, s.accessTokenExpTime)
+	} else {
+		accessToken, err = utils.GenerateServiceAccountToken(account, grantedScope, s.accessTokenSecret, s.accessTokenExpTime)
+	}
+	if err != nil {
+		log.Error("failed to generate jwt token", slog.String("err", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return &model.TokenSet{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   s.accessTokenExpTime,
+		Scope:       grantedScope,
+	}, nil
+}
+
+func toModel(account *serviceAccountRepoModel.ServiceAccount, role string) *model.ServiceAccount {
+	return &model.ServiceAccount{
+		Id:         account.Id,
+		ClientId:   account.ClientId,
+		CreatedAt:  account.CreatedAt,
+		DisabledAt: account.DisabledAt,
+		ServiceAccountInfo: model.ServiceAccountInfo{
+			Name:   account.Name,
+			Role:   role,
+			Scopes: account.Scopes,
+		},
+	}
+}
+
+func newSecret() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, string(hash), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,193 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+)
+
+var attributeKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// LoadAttributeSchema reads the registered user attributes from a JSON file.
+// An empty path yields an empty schema, which allows no attributes.
+func LoadAttributeSchema(path string) (*model.AttributeSchema, error) {
+	const op = "user.LoadAttributeSchema"
+
+	if path == "" {
+		return &model.AttributeSchema{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var schema model.AttributeSchema
+	if err = json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[string]bool, len(schema.Attributes))
+	for _, def := range schema.Attributes {
+		if !attributeKeyRe.MatchString(def.Key) {
+			return nil, fmt.Errorf("%s: attribute %q: key must be lower snake case", op, def.Key)
+		}
+		switch def.Type {
+		case model.AttributeTypeString, model.AttributeTypeNumber, model.AttributeTypeInteger,
+			model.AttributeTypeBoolean, model.AttributeTypeStringList:
+		default:
+			return nil, fmt.Errorf("%s: attribute %q: unknown type %q", op, def.Key, def.Type)
+		}
+		switch def.Visibility {
+		case model.AttributeVisibilityPublic, model.AttributeVisibilityAdmin:
+		default:
+			return nil, fmt.Errorf("%s: attribute %q: unknown visibility %q", op, def.Key, def.Visibility)
+		}
+		if seen[def.Key] {
+			return nil, fmt.Errorf("%s: duplicate attribute %q", op, def.Key)
+		}
+		seen[def.Key] = true
+	}
+
+	return &schema, nil
+}
+
+// GetAttributes returns the user's attributes named by keys, or all of them
+// when keys is empty. Unless privileged, only public attributes are visible.
+func (s *userService) GetAttributes(ctx context.Context, userId int, keys []string, privileged bool) (map[string]interface{}, error) {
+	const op = "userService.GetAttributes"
+	log := s.log.With(slog.String("op", op))
+
+	if err := s.checkAttributeKeys(keys, privileged); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	return s.visibleAttributes(user.Attributes, keys, privileged), nil
+}
+
+// UpdateAttributes sets the attributes named by mask to their values, and
+// removes those that have no value. With an empty mask every given value is
+// set and nothing is removed. Values outside the mask are ignored.
+func (s *userService) UpdateAttributes(ctx context.Context, userId int, values map[string]interface{}, mask []string, privileged bool) (map[string]interface{}, error) {
+	const op = "userService.UpdateAttributes"
+	log := s.log.With(slog.String("op", op))
+
+	if len(mask) == 0 {
+		for key := range values {
+			mask = append(mask, key)
+		}
+	}
+	if err := s.checkAttributeKeys(mask, privileged); err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]interface{}, len(mask))
+	remove := make([]string, 0, len(mask))
+	for _, key := range mask {
+		value, ok := values[key]
+		if !ok || value == nil {
+			remove = append(remove, key)
+			continue
+		}
+
+		if attributeType := s.attributeSchema.Get(key).Type; !validAttributeValue(attributeType, value) {
+			return nil, fmt.Errorf("%w: %s must be of type %s", ErrInvalidAttribute, key, attributeType)
+		}
+		set[key] = value
+	}
+
+	if err := s.userRepo.UpdateAttributes(ctx, userId, set, remove); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to update attributes", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return s.GetAttributes(ctx, userId, nil, privileged)
+}
+
+func (s *userService) checkAttributeKeys(keys []string, privileged bool) error {
+	for _, key := range keys {
+		def := s.attributeSchema.Get(key)
+		if def == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownAttribute, key)
+		}
+		if !privileged && def.Visibility != model.AttributeVisibilityPublic {
+			return fmt.Errorf("%w: %s", ErrAttributeForbidden, key)
+		}
+	}
+
+	return nil
+}
+
+// visibleAttributes drops the stored attributes that are no longer
+// registered or that the caller may not see.
+func (s *userService) visibleAttributes(attributes map[string]interface{}, keys []string, privileged bool) map[string]interface{} {
+	res := make(map[string]interface{}, len(attributes))
+	for _, def := range s.attributeSchema.Attributes {
+		if !privileged && def.Visibility != model.AttributeVisibilityPublic {
+			continue
+		}
+		if len(keys) > 0 && !slices.Contains(keys, def.Key) {
+			continue
+		}
+		if value, ok := attributes[def.Key]; ok {
+			res[def.Key] = value
+		}
+	}
+
+	return res
+}
+
+func validAttributeValue(attributeType string, value interface{}) bool {
+	switch attributeType {
+	case model.AttributeTypeString:
+		_, ok := value.(string)
+		return ok
+	case model.AttributeTypeNumber:
+		_, ok := value.(float64)
+		return ok
+	case model.AttributeTypeInteger:
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n) && math.Abs(n) <= 1<<53
+	case model.AttributeTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case model.AttributeTypeStringList:
+		list, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range list {
+			if _, ok = item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,208 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func (r *userRepo) UpdateAttributes(_ context.Context, id int, set map[string]interface{}, remove []string) error {
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	if user.Attributes == nil {
+		user.Attributes = make(map[string]interface{}, len(set))
+	}
+	maps.Copy(user.Attributes, set)
+	for _, key := range remove {
+		delete(user.Attributes, key)
+	}
+
+	return nil
+}
+
+var attributeSchema = &model.AttributeSchema{
+	Attributes: []*model.AttributeDefinition{
+		{Key: "department", Type: model.AttributeTypeString, Visibility: model.AttributeVisibilityPublic},
+		{Key: "height", Type: model.AttributeTypeNumber, Visibility: model.AttributeVisibilityPublic},
+		{Key: "floor", Type: model.AttributeTypeInteger, Visibility: model.AttributeVisibilityPublic},
+		{Key: "newsletter", Type: model.AttributeTypeBoolean, Visibility: model.AttributeVisibilityPublic},
+		{Key: "teams", Type: model.AttributeTypeStringList, Visibility: model.AttributeVisibilityPublic},
+		{Key: "clearance", Type: model.AttributeTypeString, Visibility: model.AttributeVisibilityAdmin},
+	},
+}
+
+// newAttributesService knows user 1, whose stored attributes include one
+// that is no longer registered, and user 2, who is deleted.
+func newAttributesService() *userService {
+	deletedAt := time.Now()
+
+	return &userService{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		userRepo: &userRepo{users: map[int]*userRepoModel.User{
+			1: {Id: 1, Attributes: map[string]interface{}{
+				"department": "sales",
+				"clearance":  "secret",
+				"shoe_size":  float64(42),
+			}},
+			2: {Id: 2, DeletedAt: &deletedAt},
+		}},
+		attributeSchema: attributeSchema,
+	}
+}
+
+func TestGetAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		userId     int
+		keys       []string
+		privileged bool
+		want       map[string]interface{}
+		wantErr    error
+	}{
+		{name: "public", userId: 1, want: map[string]interface{}{"department": "sales"}},
+		{name: "privileged", userId: 1, privileged: true, want: map[string]interface{}{"department": "sales", "clearance": "secret"}},
+		{name: "by key", userId: 1, keys: []string{"clearance"}, privileged: true, want: map[string]interface{}{"clearance": "secret"}},
+		{name: "admin key unprivileged", userId: 1, keys: []string{"clearance"}, wantErr: ErrAttributeForbidden},
+		{name: "unregistered key", userId: 1, keys: []string{"shoe_size"}, privileged: true, wantErr: ErrUnknownAttribute},
+		{name: "deleted user", userId: 2, wantErr: ErrNotFound},
+		{name: "unknown user", userId: 3, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAttributesService()
+
+			attributes, err := s.GetAttributes(context.Background(), tt.userId, tt.keys, tt.privileged)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !maps.Equal(attributes, tt.want) {
+				t.Errorf("got attributes %v, want %v", attributes, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]interface{}
+		mask       []string
+		privileged bool
+		// want is every attribute of the user afterwards, as an admin sees
+		// them.
+		want    map[string]interface{}
+		wantErr error
+	}{
+		{
+			name:   "set",
+			values: map[string]interface{}{"department": "support", "floor": float64(3), "newsletter": true},
+			want:   map[string]interface{}{"department": "support", "floor": float64(3), "newsletter": true, "clearance": "secret"},
+		},
+		{
+			name:   "mask picks values",
+			values: map[string]interface{}{"department": "support", "height": 1.8},
+			mask:   []string{"height"},
+			want:   map[string]interface{}{"department": "sales", "height": 1.8, "clearance": "secret"},
+		},
+		{
+			name: "masked without a value",
+			mask: []string{"department"},
+			want: map[string]interface{}{"clearance": "secret"},
+		},
+		{
+			name:   "null value",
+			values: map[string]interface{}{"department": nil},
+			want:   map[string]interface{}{"clearance": "secret"},
+		},
+		{
+			name:       "admin attribute",
+			values:     map[string]interface{}{"clearance": "top secret"},
+			privileged: true,
+			want:       map[string]interface{}{"department": "sales", "clearance": "top secret"},
+		},
+		{name: "admin attribute unprivileged", values: map[string]interface{}{"clearance": "top secret"}, wantErr: ErrAttributeForbidden},
+		{name: "unregistered", values: map[string]interface{}{"shoe_size": float64(43)}, wantErr: ErrUnknownAttribute},
+		{name: "string as number", values: map[string]interface{}{"height": "tall"}, wantErr: ErrInvalidAttribute},
+		{name: "fraction as integer", values: map[string]interface{}{"floor": 2.5}, wantErr: ErrInvalidAttribute},
+		{name: "integer too large", values: map[string]interface{}{"floor": float64(1<<53 + 2)}, wantErr: ErrInvalidAttribute},
+		{name: "number as boolean", values: map[string]interface{}{"newsletter": float64(1)}, wantErr: ErrInvalidAttribute},
+		{name: "list of numbers", values: map[string]interface{}{"teams": []interface{}{"core", float64(1)}}, wantErr: ErrInvalidAttribute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAttributesService()
+
+			_, err := s.UpdateAttributes(context.Background(), 1, tt.values, tt.mask, tt.privileged)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			attributes, err := s.GetAttributes(context.Background(), 1, nil, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if tt.wantErr != nil {
+				want = map[string]interface{}{"department": "sales", "clearance": "secret"}
+			}
+			if !maps.Equal(attributes, want) {
+				t.Errorf("got attributes %v, want %v", attributes, want)
+			}
+		})
+	}
+}
+
+func TestLoadAttributeSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr bool
+	}{
+		{name: "valid", schema: `{"attributes": [{"key": "department", "type": "string", "visibility": "public", "in_claims": true}]}`},
+		{name: "key not snake case", schema: `{"attributes": [{"key": "Department", "type": "string", "visibility": "public"}]}`, wantErr: true},
+		{name: "unknown type", schema: `{"attributes": [{"key": "department", "type": "date", "visibility": "public"}]}`, wantErr: true},
+		{name: "unknown visibility", schema: `{"attributes": [{"key": "department", "type": "string", "visibility": "private"}]}`, wantErr: true},
+		{
+			name: "duplicate",
+			schema: `{"attributes": [
+				{"key": "department", "type": "string", "visibility": "public"},
+				{"key": "department", "type": "string", "visibility": "admin"}
+			]}`,
+			wantErr: true,
+		},
+		{name: "not json", schema: `attributes:`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "attributes.json")
+			if err := os.WriteFile(path, []byte(tt.schema), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			schema, err := LoadAttributeSchema(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && schema.Get("department") == nil {
+				t.Errorf("got schema %+v", schema)
+			}
+		})
+	}
+
+	schema, err := LoadAttributeSchema("")
+	if err != nil || len(schema.Attributes) != 0 {
+		t.Errorf("got schema %+v, error %v without a path, want an empty schema", schema, err)
+	}
+}
@@ -0,0 +1,118 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	"io"
+	"log/slog"
+	"strconv"
+)
+
+// UploadAvatar replaces the user's avatar with the image read from r, and
+// deletes the previous one. The image is stored in all of avatarSizes, and
+// the user's avatar becomes the URL of the largest variant. Errors reading r
+// are returned unchanged.
+func (s *userService) UploadAvatar(ctx context.Context, userId int, r io.Reader) (*model.Avatar, error) {
+	const op = "userService.UploadAvatar"
+	log := s.log.With(slog.String("op", op))
+
+	data, err := io.ReadAll(io.LimitReader(r, s.avatarMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.avatarMaxSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	variants, contentType, err := renderAvatar(data)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedImage) || errors.Is(err, ErrInvalidImage) || errors.Is(err, ErrAvatarTooLarge) {
+			return nil, err
+		}
+
+		log.Error("failed to render avatar", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	// Every upload gets fresh keys, so that the blobs can be cached forever.
+	version, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate avatar key", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+	key := fmt.Sprintf("%d/%s", userId, version)
+
+	for i, size := range avatarSizes {
+		if err = s.avatarStorage.Put(ctx, avatarVariantKey(key, size), contentType, variants[i]); err != nil {
+			log.Error("failed to store avatar", slog.String("error", err.Error()))
+			s.deleteAvatarBlobs(ctx, key)
+			return nil, ErrInternal
+		}
+	}
+
+	avatar := s.avatarFromKey(key)
+	previousKey, err := s.userRepo.SetAvatar(ctx, userId, &avatar.URL, &key)
+	if err != nil {
+		s.deleteAvatarBlobs(ctx, key)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to set avatar", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if previousKey != nil {
+		s.deleteAvatarBlobs(ctx, *previousKey)
+	}
+
+	return avatar, nil
+}
+
+func (s *userService) avatarFromKey(key string) *model.Avatar {
+	avatar := &model.Avatar{
+		URL:      s.avatarStorage.URL(avatarVariantKey(key, avatarSizes[0])),
+		Variants: make([]*model.AvatarVariant, 0, len(avatarSizes)),
+	}
+	for _, size := range avatarSizes {
+		avatar.Variants = append(avatar.Variants, &model.AvatarVariant{
+			Size: size,
+			URL:  s.avatarStorage.URL(avatarVariantKey(key, size)),
+		})
+	}
+
+	return avatar
+}
+
+// deleteAvatarBlobs deletes the variants of an avatar. Failures are only
+// logged: the avatar is no longer referenced, so at worst the blobs leak.
+func (s *userService) deleteAvatarBlobs(ctx context.Context, key string) {
+	for _, size := range avatarSizes {
+		if err := s.avatarStorage.Delete(ctx, avatarVariantKey(key, size)); err != nil {
+			s.log.Warn("failed to delete avatar",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func avatarVariantKey(key string, size int) string {
+	return key + "/" + strconv.Itoa(size)
+}
@@ -0,0 +1,188 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/repository"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"image"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blobStorage keeps blobs in memory and fails every Put after the first
+// failAfter ones, when that is set.
+type blobStorage struct {
+	blobs     map[string][]byte
+	failAfter int
+	puts      int
+}
+
+func (s *blobStorage) Put(_ context.Context, key string, _ string, data []byte) error {
+	s.puts++
+	if s.failAfter > 0 && s.puts > s.failAfter {
+		return errors.New("bucket unavailable")
+	}
+	s.blobs[key] = data
+
+	return nil
+}
+
+func (s *blobStorage) Delete(_ context.Context, key string) error {
+	delete(s.blobs, key)
+	return nil
+}
+
+func (s *blobStorage) URL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+// avatarRepo tracks the avatar key of each user it gets.
+type avatarRepo struct {
+	userRepo
+	avatarKeys map[int]string
+	err        error
+}
+
+func (r *avatarRepo) SetAvatar(_ context.Context, id int, _ *string, avatarKey *string) (*string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if _, ok := r.users[id]; !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	previous, ok := r.avatarKeys[id]
+	r.avatarKeys[id] = *avatarKey
+	if !ok {
+		return nil, nil
+	}
+
+	return &previous, nil
+}
+
+type avatarEnv struct {
+	serv    *userService
+	repo    *avatarRepo
+	storage *blobStorage
+}
+
+// newAvatarEnv knows user 1, whose avatar is stored under 1/old, and user 2,
+// who is deleted.
+func newAvatarEnv() *avatarEnv {
+	deletedAt := time.Now()
+	repo := &avatarRepo{
+		userRepo: userRepo{users: map[int]*userRepoModel.User{
+			1: {Id: 1},
+			2: {Id: 2, DeletedAt: &deletedAt},
+		}},
+		avatarKeys: map[int]string{1: "1/old"},
+	}
+	storage := &blobStorage{blobs: map[string][]byte{}}
+	for _, size := range avatarSizes {
+		storage.blobs[avatarVariantKey("1/old", size)] = []byte("old")
+	}
+
+	return &avatarEnv{
+		serv: &userService{
+			log:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+			userRepo:      repo,
+			avatarMaxSize: 1 << 20,
+			avatarStorage: storage,
+		},
+		repo:    repo,
+		storage: storage,
+	}
+}
+
+func (e *avatarEnv) blobKeys() []string {
+	return slices.Sorted(maps.Keys(e.storage.blobs))
+}
+
+func TestUploadAvatar(t *testing.T) {
+	env := newAvatarEnv()
+	upload := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+
+	avatar, err := env.serv.UploadAvatar(context.Background(), 1, bytes.NewReader(upload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := env.repo.avatarKeys[1]
+	if key == "1/old" || !strings.HasPrefix(key, "1/") {
+		t.Fatalf("got avatar key %q", key)
+	}
+	var want []string
+	for _, size := range avatarSizes {
+		want = append(want, avatarVariantKey(key, size))
+	}
+	slices.Sort(want)
+	if got := env.blobKeys(); !slices.Equal(got, want) {
+		t.Errorf("got blobs %v, want only the new ones %v", got, want)
+	}
+
+	if avatar.URL != env.storage.URL(avatarVariantKey(key, avatarSizes[0])) {
+		t.Errorf("got url %q", avatar.URL)
+	}
+	if len(avatar.Variants) != len(avatarSizes) {
+		t.Fatalf("got %d variants, want %d", len(avatar.Variants), len(avatarSizes))
+	}
+	for i, variant := range avatar.Variants {
+		if variant.Size != avatarSizes[i] || variant.URL != env.storage.URL(avatarVariantKey(key, variant.Size)) {
+			t.Errorf("got variant %+v", variant)
+		}
+	}
+
+	// The next upload gets another key.
+	if _, err = env.serv.UploadAvatar(context.Background(), 1, bytes.NewReader(upload)); err != nil {
+		t.Fatal(err)
+	}
+	if env.repo.avatarKeys[1] == key {
+		t.Error("the key was reused")
+	}
+}
+
+func TestUploadAvatarFailures(t *testing.T) {
+	upload := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	oldBlobs := newAvatarEnv().blobKeys()
+
+	tests := []struct {
+		name    string
+		userId  int
+		data    []byte
+		prepare func(env *avatarEnv)
+		wantErr error
+	}{
+		{name: "too large", userId: 1, data: upload, prepare: func(env *avatarEnv) { env.serv.avatarMaxSize = int64(len(upload) - 1) }, wantErr: ErrAvatarTooLarge},
+		{name: "not an image", userId: 1, data: []byte("hello"), wantErr: ErrUnsupportedImage},
+		{name: "deleted user", userId: 2, data: upload, wantErr: ErrNotFound},
+		{name: "unknown user", userId: 3, data: upload, wantErr: ErrNotFound},
+		// The variants stored before the failure are deleted again.
+		{name: "storage failed", userId: 1, data: upload, prepare: func(env *avatarEnv) { env.storage.failAfter = 1 }, wantErr: ErrInternal},
+		{name: "repository failed", userId: 1, data: upload, prepare: func(env *avatarEnv) { env.repo.err = errors.New("connection reset") }, wantErr: ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newAvatarEnv()
+			if tt.prepare != nil {
+				tt.prepare(env)
+			}
+
+			if _, err := env.serv.UploadAvatar(context.Background(), tt.userId, bytes.NewReader(tt.data)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if env.repo.avatarKeys[1] != "1/old" {
+				t.Errorf("avatar key changed to %q", env.repo.avatarKeys[1])
+			}
+			if got := env.blobKeys(); !slices.Equal(got, oldBlobs) {
+				t.Errorf("got blobs %v, want the old ones %v", got, oldBlobs)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	emailChangeRepoModel "github.com/nogavadu/auth-service/internal/repository/emailchange/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	emailChangeConfirmMessage = "email_change_confirm"
+	emailChangeNoticeMessage  = "email_change_notice"
+)
+
+// emailChangeMessage is published to the email change topic; the mailer
+// renders it into the email sent to Email.
+type emailChangeMessage struct {
+	Type      string    `json:"type"`
+	UserId    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	NewEmail  string    `json:"new_email"`
+	Token     string    `json:"token,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RequestEmailChange starts changing the user's email to newEmail. A
+// confirmation with a one-time token is sent to the new address and a notice
+// to the current one; the email stays unchanged until ConfirmEmailChange.
+// Requesting again invalidates any earlier pending change.
+func (s *userService) RequestEmailChange(ctx context.Context, userId int, newEmail string) error {
+	const op = "userService.RequestEmailChange"
+	log := s.log.With(slog.String("op", op))
+
+	token, err := randomToken()
+	if err != nil {
+		log.Error("failed to generate token", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+	expiresAt := time.Now().Add(s.emailChangeTTL)
+
+	err = s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		user, errTx := s.userRepo.GetById(ctx, userId)
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrNotFound
+			}
+
+			return errTx
+		}
+		if user.DeletedAt != nil {
+			return ErrNotFound
+		}
+		if strings.EqualFold(user.Email, newEmail) {
+			return ErrSameEmail
+		}
+
+		_, errTx = s.userRepo.GetByEmail(ctx, newEmail)
+		if errTx == nil {
+			return ErrEmailTaken
+		}
+		if !errors.Is(errTx, repository.ErrNotFound) {
+			return errTx
+		}
+
+		if errTx = s.emailChangeRepo.CancelPending(ctx, userId); errTx != nil {
+			return errTx
+		}
+
+		errTx = s.emailChangeRepo.Create(ctx, &emailChangeRepoModel.EmailChange{
+			TokenHash: hashToken(token),
+			UserId:    userId,
+			OldEmail:  user.Email,
+			NewEmail:  newEmail,
+			ExpiresAt: expiresAt,
+		})
+		if errTx != nil {
+			return errTx
+		}
+
+		// Sent last, so that a failed send rolls the change back.
+		return s.sendEmailChangeMessages(userId, user.Email, newEmail, token, expiresAt)
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrSameEmail) || errors.Is(err, ErrEmailTaken) {
+			return err
+		}
+
+		log.Error("failed to request email change", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange applies the change the token was issued for. The token
+// is invalid once used, expired, superseded by a newer request, or when the
+// user's email has been changed by other means since it was issued. If the
+// new address has been taken in the meantime ErrEmailTaken is returned and
+// the token stays usable until it expires.
+func (s *userService) ConfirmEmailChange(ctx context.Context, token string) (*model.User, error) {
+	const op = "userService.ConfirmEmailChange"
+	log := s.log.With(slog.String("op", op))
+
+	var userId int
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		change, errTx := s.emailChangeRepo.Consume(ctx, hashToken(token))
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidEmailChangeToken
+			}
+
+			return errTx
+		}
+		if time.Now().After(change.ExpiresAt) {
+			return ErrInvalidEmailChangeToken
+		}
+		userId = change.UserId
+
+		user, errTx := s.userRepo.GetById(ctx, change.UserId)
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrInvalidEmailChangeToken
+			}
+
+			return errTx
+		}
+		if user.Disabled() || user.Email != change.OldEmail {
+			return ErrInvalidEmailChangeToken
+		}
+
+		// Following the link proves the new address is the user's.
+		errTx = s.userRepo.Update(ctx, change.UserId, &userRepoModel.UserUpdateInput{
+			Email:         &change.NewEmail,
+			EmailVerified: true,
+		})
+		if errTx != nil {
+			switch {
+			case errors.Is(errTx, repository.ErrAlreadyExists):
+				return ErrEmailTaken
+			case errors.Is(errTx, repository.ErrNotFound):
+				return ErrInvalidEmailChangeToken
+			}
+
+			return errTx
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidEmailChangeToken) || errors.Is(err, ErrEmailTaken) {
+			return nil, err
+		}
+
+		log.Error("failed to confirm email change", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	user, err := s.GetById(ctx, userId)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	return user, nil
+}
+
+func (s *userService) sendEmailChangeMessages(userId int, oldEmail string, newEmail string, token string, expiresAt time.Time) error {
+	messages := []*emailChangeMessage{
+		{
+			Type:      emailChangeConfirmMessage,
+			UserId:    userId,
+			Email:     newEmail,
+			NewEmail:  newEmail,
+			Token:     token,
+			ExpiresAt: expiresAt,
+		},
+		{
+			Type:      emailChangeNoticeMessage,
+			UserId:    userId,
+			Email:     oldEmail,
+			NewEmail:  newEmail,
+			ExpiresAt: expiresAt,
+		},
+	}
+
+	producerMessages := make([]*sarama.ProducerMessage, 0, len(messages))
+	for _, message := range messages {
+		value, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+
+		producerMessages = append(producerMessages, &sarama.ProducerMessage{
+			Topic:     s.emailChangeTopic,
+			Key:       sarama.StringEncoder(strconv.Itoa(userId)),
+			Value:     sarama.ByteEncoder(value),
+			Timestamp: time.Now(),
+		})
+	}
+
+	return s.emailProducer.SendMessages(producerMessages)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
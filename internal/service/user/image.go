@@ -0,0 +1,247 @@
+package user
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// avatarSizes are the variants generated for an avatar, largest first.
+var avatarSizes = []int{512, 128, 64}
+
+const (
+	// Guards against decompression bombs, which are small files that
+	// decode to huge images.
+	maxAvatarDimension = 10000
+	maxAvatarPixels    = 40_000_000
+
+	avatarJPEGQuality = 90
+)
+
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// renderAvatar decodes the uploaded image and encodes a square variant of it
+// for each of avatarSizes. Re-encoding drops any metadata of the upload,
+// such as EXIF location data; the EXIF orientation is applied beforehand.
+// Variants are JPEGs, or PNGs when the image is transparent.
+func renderAvatar(data []byte) ([][]byte, string, error) {
+	if !avatarContentTypes[http.DetectContentType(data)] {
+		return nil, "", ErrUnsupportedImage
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return nil, "", ErrInvalidImage
+	}
+	if cfg.Width > maxAvatarDimension || cfg.Height > maxAvatarDimension || cfg.Width*cfg.Height > maxAvatarPixels {
+		return nil, "", ErrAvatarTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+
+	largest := resizeSquare(src, avatarSizes[0])
+	if format == "jpeg" {
+		largest = orient(largest, jpegOrientation(data))
+	}
+
+	contentType := "image/png"
+	if largest.Opaque() {
+		contentType = "image/jpeg"
+	}
+
+	variants := make([][]byte, 0, len(avatarSizes))
+	for _, size := range avatarSizes {
+		img := largest
+		if size != avatarSizes[0] {
+			img = resizeSquare(largest, size)
+		}
+
+		var buf bytes.Buffer
+		if contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: avatarJPEGQuality})
+		} else {
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		variants = append(variants, buf.Bytes())
+	}
+
+	return variants, contentType, nil
+}
+
+// resizeSquare crops the centered square out of src and scales it to size by
+// size pixels. Each pixel is the average of the source pixels it covers.
+func resizeSquare(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0, sy1 := span(y0, side, size, y)
+		for x := 0; x < size; x++ {
+			sx0, sx1 := span(x0, side, size, x)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					bl += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
+
+// span is the range of source pixels covered by destination pixel i. When
+// upscaling it is a single pixel.
+func span(offset int, side int, size int, i int) (int, int) {
+	start := offset + i*side/size
+	end := offset + (i+1)*side/size
+	if end <= start {
+		end = start + 1
+	}
+
+	return start, end
+}
+
+// orient transforms the square img so that it displays upright, given its
+// EXIF orientation.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	n := img.Bounds().Dx()
+	dst := image.NewRGBA(img.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = n-1-x, y
+			case 3:
+				sx, sy = n-1-x, n-1-y
+			case 4:
+				sx, sy = x, n-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, n-1-x
+			case 7:
+				sx, sy = n-1-y, n-1-x
+			case 8:
+				sx, sy = n-1-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, 1 (upright) when it
+// has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte.
+			i++
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// Image data starts, there are no more metadata segments.
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	offset := uint64(order.Uint32(tiff[4:]))
+	if offset+2 > uint64(len(tiff)) {
+		return 1
+	}
+
+	entries := uint64(order.Uint16(tiff[offset:]))
+	for k := uint64(0); k < entries; k++ {
+		entry := offset + 2 + k*12
+		if entry+12 > uint64(len(tiff)) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
@@ -0,0 +1,343 @@
+package user
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 0xFF, A: 0xFF}
+	green = color.RGBA{G: 0xFF, A: 0xFF}
+	blue  = color.RGBA{B: 0xFF, A: 0xFF}
+	white = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	black = color.RGBA{A: 0xFF}
+)
+
+// quadrants paints a side by side square in four colours, top left to
+// bottom right, at the given offset.
+func quadrants(dst draw.Image, offset image.Point, side int, colors [4]color.RGBA) {
+	half := side / 2
+	for i, c := range colors {
+		corner := offset.Add(image.Pt(i%2*half, i/2*half))
+		draw.Draw(dst, image.Rectangle{Min: corner, Max: corner.Add(image.Pt(half, half))}, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+}
+
+func TestResizeSquare(t *testing.T) {
+	// A landscape image whose outer columns are cropped away.
+	landscape := image.NewRGBA(image.Rect(0, 0, 6, 4))
+	draw.Draw(landscape, landscape.Bounds(), image.NewUniform(black), image.Point{}, draw.Src)
+	quadrants(landscape, image.Pt(1, 0), 4, [4]color.RGBA{red, green, blue, white})
+
+	// A portrait image, not at the origin, whose top and bottom rows are
+	// cropped away.
+	portrait := image.NewRGBA(image.Rect(10, 10, 14, 16))
+	draw.Draw(portrait, portrait.Bounds(), image.NewUniform(black), image.Point{}, draw.Src)
+	quadrants(portrait, image.Pt(10, 11), 4, [4]color.RGBA{red, green, blue, white})
+
+	checkerboard := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	quadrants(checkerboard, image.Point{}, 2, [4]color.RGBA{black, white, white, black})
+
+	dot := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	dot.SetRGBA(0, 0, red)
+
+	tests := []struct {
+		name string
+		src  image.Image
+		size int
+		want []color.RGBA
+	}{
+		{name: "landscape", src: landscape, size: 2, want: []color.RGBA{red, green, blue, white}},
+		{name: "portrait", src: portrait, size: 2, want: []color.RGBA{red, green, blue, white}},
+		{name: "averaged", src: checkerboard, size: 1, want: []color.RGBA{{R: 0x7F, G: 0x7F, B: 0x7F, A: 0xFF}}},
+		{name: "upscaled", src: dot, size: 3, want: []color.RGBA{red, red, red, red, red, red, red, red, red}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := resizeSquare(tt.src, tt.size)
+
+			if got := dst.Bounds(); got != image.Rect(0, 0, tt.size, tt.size) {
+				t.Fatalf("got bounds %v, want %dx%d", got, tt.size, tt.size)
+			}
+			for i, want := range tt.want {
+				if got := dst.RGBAAt(i%tt.size, i/tt.size); got != want {
+					t.Errorf("pixel %d: got %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// The stored pixels a b / c d, as they display under each orientation.
+	a, b, c, d := red, green, blue, white
+	tests := []struct {
+		orientation int
+		want        [4]color.RGBA
+	}{
+		{orientation: 0, want: [4]color.RGBA{a, b, c, d}},
+		{orientation: 1, want: [4]color.RGBA{a, b, c, d}},
+		{orientation: 2, want: [4]color.RGBA{b, a, d, c}},
+		{orientation: 3, want: [4]color.RGBA{d, c, b, a}},
+		{orientation: 4, want: [4]color.RGBA{c, d, a, b}},
+		{orientation: 5, want: [4]color.RGBA{a, c, b, d}},
+		{orientation: 6, want: [4]color.RGBA{c, a, d, b}},
+		{orientation: 7, want: [4]color.RGBA{d, b, c, a}},
+		{orientation: 8, want: [4]color.RGBA{b, d, a, c}},
+		{orientation: 9, want: [4]color.RGBA{a, b, c, d}},
+	}
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		quadrants(img, image.Point{}, 2, [4]color.RGBA{a, b, c, d})
+
+		dst := orient(img, tt.orientation)
+
+		for i, want := range tt.want {
+			if got := dst.RGBAAt(i%2, i/2); got != want {
+				t.Errorf("orientation %d, pixel %d: got %v, want %v", tt.orientation, i, got, want)
+			}
+		}
+	}
+}
+
+// tiffHeader is an EXIF TIFF structure whose only IFD has an image width and
+// the orientation.
+func tiffHeader(order binary.AppendByteOrder, orientation int) []byte {
+	b := []byte("MM")
+	if order.String() == binary.LittleEndian.String() {
+		b = []byte("II")
+	}
+	b = order.AppendUint16(b, 42)
+	b = order.AppendUint32(b, 8)
+
+	b = order.AppendUint16(b, 2)
+	for _, tag := range [][2]int{{0x0100, 64}, {0x0112, orientation}} {
+		b = order.AppendUint16(b, uint16(tag[0]))
+		b = order.AppendUint16(b, 3) // SHORT
+		b = order.AppendUint32(b, 1)
+		b = order.AppendUint16(b, uint16(tag[1]))
+		b = order.AppendUint16(b, 0)
+	}
+
+	return order.AppendUint32(b, 0)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	b := binary.BigEndian.AppendUint16([]byte{0xFF, marker}, uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// jpegOf puts the segments between the start and end of image markers.
+func jpegOf(segments ...[]byte) []byte {
+	b := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+
+	return append(b, 0xFF, 0xD9)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		for _, order := range []binary.AppendByteOrder{binary.LittleEndian, binary.BigEndian} {
+			if got := jpegOrientation(jpegOf(exifSegment(tiffHeader(order, orientation)))); got != orientation {
+				t.Errorf("%v: got orientation %d, want %d", order, got, orientation)
+			}
+		}
+	}
+
+	app0 := segment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	exif := exifSegment(tiffHeader(binary.BigEndian, 6))
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "not a jpeg", data: []byte("GIF89a"), want: 1},
+		{name: "no exif", data: jpegOf(app0), want: 1},
+		{name: "behind another segment", data: jpegOf(app0, exif), want: 6},
+		{name: "behind fill bytes", data: jpegOf([]byte{0xFF, 0xFF}, exif), want: 6},
+		{name: "behind the image data", data: jpegOf(segment(0xDA, nil), exif), want: 1},
+		{name: "xmp", data: jpegOf(segment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))), want: 1},
+		{name: "truncated segment", data: jpegOf(exif)[:len(exif)-4], want: 1},
+		{name: "length too short", data: jpegOf([]byte{0xFF, 0xE1, 0x00, 0x01}, exif), want: 1},
+		{name: "no marker", data: jpegOf([]byte{0x00}, exif), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("got orientation %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExifOrientationMalformed(t *testing.T) {
+	valid := tiffHeader(binary.LittleEndian, 6)
+	// with changes the 16 or 32 bit numbers at the given offsets.
+	with := func(changes map[int]uint32) []byte {
+		b := bytes.Clone(valid)
+		for at, n := range changes {
+			if at == 4 {
+				binary.LittleEndian.PutUint32(b[at:], n)
+			} else {
+				binary.LittleEndian.PutUint16(b[at:], uint16(n))
+			}
+		}
+		return b
+	}
+	const (
+		magic          = 2
+		ifdOffset      = 4
+		entries        = 8
+		orientationTag = 8 + 2 + 12
+	)
+
+	tests := []struct {
+		name string
+		tiff []byte
+	}{
+		{name: "too short", tiff: valid[:6]},
+		{name: "unknown byte order", tiff: append([]byte("XX"), valid[2:]...)},
+		{name: "wrong magic", tiff: with(map[int]uint32{magic: 43})},
+		{name: "ifd out of range", tiff: with(map[int]uint32{ifdOffset: 1000})},
+		{name: "ifd offset overflowing", tiff: with(map[int]uint32{ifdOffset: 0xFFFFFFFF})},
+		{name: "entries out of range", tiff: with(map[int]uint32{entries: 1000, orientationTag: 0x0101})},
+		{name: "truncated entry", tiff: valid[:orientationTag+6]},
+		{name: "no orientation", tiff: with(map[int]uint32{entries: 1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != 1 {
+				t.Errorf("got orientation %d, want 1", got)
+			}
+		})
+	}
+}
+
+// pngHeader is a PNG that ends after declaring its dimensions.
+func pngHeader(width uint32, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0) // 8-bit RGB
+
+	b := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(ihdr)-4))
+	b = append(b, ihdr...)
+
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRenderAvatar(t *testing.T) {
+	opaque := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	draw.Draw(opaque, opaque.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	transparent := image.NewNRGBA(image.Rect(0, 0, 30, 40))
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantContentType string
+		wantErr         error
+	}{
+		{name: "opaque", data: encodePNG(t, opaque), wantContentType: "image/jpeg"},
+		{name: "transparent", data: encodePNG(t, transparent), wantContentType: "image/png"},
+		{name: "not an image", data: []byte("<svg xmlns='http://www.w3.org/2000/svg'/>"), wantErr: ErrUnsupportedImage},
+		{name: "corrupt header", data: append([]byte("\x89PNG\r\n\x1a\n"), "garbage"...), wantErr: ErrInvalidImage},
+		{name: "no pixels", data: pngHeader(0, 10), wantErr: ErrInvalidImage},
+		{name: "too wide", data: pngHeader(maxAvatarDimension+1, 1), wantErr: ErrAvatarTooLarge},
+		{name: "too tall", data: pngHeader(1, maxAvatarDimension+1), wantErr: ErrAvatarTooLarge},
+		{name: "too many pixels", data: pngHeader(6400, 6400), wantErr: ErrAvatarTooLarge},
+		// Within the limits, so it gets decoded, which fails without image
+		// data.
+		{name: "just within the limits", data: pngHeader(6000, 6000), wantErr: ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants, contentType, err := renderAvatar(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if contentType != tt.wantContentType {
+				t.Errorf("got content type %q, want %q", contentType, tt.wantContentType)
+			}
+			if len(variants) != len(avatarSizes) {
+				t.Fatalf("got %d variants, want %d", len(variants), len(avatarSizes))
+			}
+			for i, size := range avatarSizes {
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(variants[i]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if "image/"+format != tt.wantContentType || cfg.Width != size || cfg.Height != size {
+					t.Errorf("variant %d: got a %dx%d %s, want a %dx%d %s", i, cfg.Width, cfg.Height, format, size, size, tt.wantContentType)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderAvatarAppliesOrientation(t *testing.T) {
+	// Only the bottom left quadrant is red. Rotated by 90° clockwise, as
+	// orientation 6 asks, it displays at the top left.
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	quadrants(img, image.Point{}, 64, [4]color.RGBA{blue, blue, red, blue})
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	data := append(bytes.Clone(encoded[:2]), exifSegment(tiffHeader(binary.BigEndian, 6))...)
+	data = append(data, encoded[2:]...)
+
+	variants, _, err := renderAvatar(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	avatar, err := jpeg.Decode(bytes.NewReader(variants[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quarter := avatarSizes[0] / 4
+	for _, p := range []struct {
+		at      image.Point
+		wantRed bool
+	}{
+		{at: image.Pt(quarter, quarter), wantRed: true},
+		{at: image.Pt(3*quarter, quarter)},
+		{at: image.Pt(quarter, 3*quarter)},
+		{at: image.Pt(3*quarter, 3*quarter)},
+	} {
+		r, _, b, _ := avatar.At(p.at.X, p.at.Y).RGBA()
+		if isRed := r > b; isRed != p.wantRed {
+			t.Errorf("pixel at %v: got red %v, want red %v", p.at, isRed, p.wantRed)
+		}
+	}
+}
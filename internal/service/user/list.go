@@ -0,0 +1,219 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var sortFields = map[string]string{
+	model.UserSortById:        userRepoModel.SortById,
+	model.UserSortByEmail:     userRepoModel.SortByEmail,
+	model.UserSortByName:      userRepoModel.SortByName,
+	model.UserSortByCreatedAt: userRepoModel.SortByCreatedAt,
+}
+
+var statuses = map[string]string{
+	"":                          "",
+	model.UserStatusActive:      userRepoModel.StatusActive,
+	model.UserStatusDeactivated: userRepoModel.StatusDeactivated,
+	model.UserStatusDeleted:     userRepoModel.StatusDeleted,
+}
+
+// pageToken is the position of the last user on a page. It carries the sort
+// and a hash of the filters it was made for, so it cannot be replayed
+// against different ones.
+type pageToken struct {
+	SortBy  string `json:"s"`
+	Desc    bool   `json:"d"`
+	Filters string `json:"f"`
+	Key     string `json:"k"`
+	Id      int    `json:"i"`
+}
+
+func (s *userService) List(ctx context.Context, filter *model.UserFilter) (*model.UserPage, error) {
+	const op = "userService.List"
+	log := s.log.With(slog.String("op", op))
+
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = model.UserSortById
+	}
+	repoSortBy, ok := sortFields[sortBy]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	repoStatus, ok := statuses[filter.Status]
+	if !ok {
+		return nil, ErrInvalidStatus
+	}
+
+	pageSize := filter.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	repoFilter := &userRepoModel.ListFilter{
+		Email:           filter.Email,
+		Name:            filter.Name,
+		CreatedAfter:    filter.CreatedAfter,
+		CreatedBefore:   filter.CreatedBefore,
+		LastLoginAfter:  filter.LastLoginAfter,
+		LastLoginBefore: filter.LastLoginBefore,
+		Status:          repoStatus,
+		Suspended:       filter.Suspended,
+		Verified:        filter.Verified,
+		SortBy:          repoSortBy,
+		Desc:            filter.Desc,
+		// One extra row tells whether there is a next page.
+		Limit: uint64(pageSize + 1),
+	}
+
+	filters, err := filtersHash(filter)
+	if err != nil {
+		log.Error("failed to hash filters", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	if filter.PageToken != "" {
+		token, err := decodePageToken(filter.PageToken)
+		if err != nil || token.SortBy != sortBy || token.Desc != filter.Desc || token.Filters != filters {
+			return nil, ErrInvalidPageToken
+		}
+
+		var key interface{} = token.Key
+		if sortBy == model.UserSortByCreatedAt {
+			if key, err = time.Parse(time.RFC3339Nano, token.Key); err != nil {
+				return nil, ErrInvalidPageToken
+			}
+		}
+		repoFilter.After = &userRepoModel.ListCursor{Key: key, Id: token.Id}
+	}
+
+	if filter.Role != "" {
+		role, err := s.roleRepo.GetByName(ctx, filter.Role)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrUnknownRole
+			}
+
+			log.Error("failed to get role", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+		roleId := int(role.ID)
+		repoFilter.RoleId = &roleId
+	}
+
+	users, err := s.userRepo.List(ctx, repoFilter)
+	if err != nil {
+		log.Error("failed to list users", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	page := &model.UserPage{
+		Users: make([]*model.User, 0, min(len(users), pageSize)),
+	}
+	if len(users) > pageSize {
+		users = users[:pageSize]
+
+		page.NextPageToken, err = encodePageToken(&pageToken{
+			SortBy:  sortBy,
+			Desc:    filter.Desc,
+			Filters: filters,
+			Key:     sortKey(users[len(users)-1], sortBy),
+			Id:      users[len(users)-1].Id,
+		})
+		if err != nil {
+			log.Error("failed to encode page token", slog.String("error", err.Error()))
+			return nil, ErrInternal
+		}
+	}
+
+	roles := make(map[int]string)
+	for _, user := range users {
+		role, ok := roles[user.RoleId]
+		if !ok {
+			repoRole, err := s.roleRepo.GetById(ctx, user.RoleId)
+			if err != nil {
+				log.Error("failed to get role", slog.String("error", err.Error()))
+				return nil, ErrInternal
+			}
+			role = repoRole.Name
+			roles[user.RoleId] = role
+		}
+
+		page.Users = append(page.Users, userFromRepo(user, role))
+	}
+
+	return page, nil
+}
+
+func sortKey(user *userRepoModel.User, sortBy string) string {
+	switch sortBy {
+	case model.UserSortByEmail:
+		return user.Email
+	case model.UserSortByName:
+		if user.Name != nil {
+			return *user.Name
+		}
+		return ""
+	case model.UserSortByCreatedAt:
+		return user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return strconv.Itoa(user.Id)
+	}
+}
+
+// filtersHash identifies the filters of a listing, leaving out the sort and
+// paging fields.
+func filtersHash(filter *model.UserFilter) (string, error) {
+	filters := *filter
+	filters.SortBy, filters.Desc, filters.PageSize, filters.PageToken = "", false, 0, ""
+
+	data, err := json.Marshal(&filters)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+func encodePageToken(token *pageToken) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(raw string) (*pageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decode page token: %w", err)
+	}
+
+	var token pageToken
+	if err = json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("decode page token: %w", err)
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,170 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// userRepo lists users 1 to 5 by id, ignoring every filter but remembering
+// the last cursor, purges the purgeable ones and gets the ones in users.
+type userRepo struct {
+	repository.UserRepository
+	purgeable []*userRepoModel.PurgedUser
+	users     map[int]*userRepoModel.User
+	after     *userRepoModel.ListCursor
+}
+
+// createdAt is when the listed user of the id signed up. It has
+// nanoseconds and is not in UTC, so page tokens must keep both.
+func createdAt(id int) time.Time {
+	return time.Date(2026, 1, id, 12, 0, 0, 123456789, time.FixedZone("UTC+3", 3*60*60))
+}
+
+func (r *userRepo) GetById(_ context.Context, id int) (*userRepoModel.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (r *userRepo) List(_ context.Context, filter *userRepoModel.ListFilter) ([]*userRepoModel.User, error) {
+	r.after = filter.After
+
+	first := 1
+	if filter.After != nil {
+		first = filter.After.Id + 1
+	}
+
+	var users []*userRepoModel.User
+	for id := first; id <= 5 && uint64(len(users)) < filter.Limit; id++ {
+		users = append(users, &userRepoModel.User{Id: id, UserInfo: userRepoModel.UserInfo{RoleId: 1}, CreatedAt: createdAt(id)})
+	}
+
+	return users, nil
+}
+
+// roleRepo knows the roles user, moderator and admin, of ids 1 to 3.
+type roleRepo struct {
+	repository.RoleRepository
+}
+
+var roles = []*roleRepoModel.Role{
+	{ID: 1, Name: "user", Level: 1},
+	{ID: 2, Name: "moderator", Level: 50},
+	{ID: 3, Name: "admin", Level: 100},
+}
+
+func (r *roleRepo) GetById(_ context.Context, id int) (*roleRepoModel.Role, error) {
+	if id < 1 || id > len(roles) {
+		return nil, repository.ErrNotFound
+	}
+
+	return roles[id-1], nil
+}
+
+func TestListPageToken(t *testing.T) {
+	suspended, notSuspended := true, false
+
+	tests := []struct {
+		name    string
+		next    func(filter *model.UserFilter)
+		wantErr error
+	}{
+		{name: "same filters"},
+		{name: "other page size", next: func(f *model.UserFilter) { f.PageSize = 3 }},
+		{name: "other email", next: func(f *model.UserFilter) { f.Email = "john" }, wantErr: ErrInvalidPageToken},
+		{name: "other status", next: func(f *model.UserFilter) { f.Status = model.UserStatusDeleted }, wantErr: ErrInvalidPageToken},
+		{name: "other suspension", next: func(f *model.UserFilter) { f.Suspended = &notSuspended }, wantErr: ErrInvalidPageToken},
+		{name: "suspension dropped", next: func(f *model.UserFilter) { f.Suspended = nil }, wantErr: ErrInvalidPageToken},
+		{name: "other verification", next: func(f *model.UserFilter) { f.Verified = &suspended }, wantErr: ErrInvalidPageToken},
+		{name: "other sort", next: func(f *model.UserFilter) { f.SortBy = model.UserSortByEmail }, wantErr: ErrInvalidPageToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &userService{
+				log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+				userRepo: &userRepo{},
+				roleRepo: &roleRepo{},
+			}
+			ctx := context.Background()
+
+			filter := &model.UserFilter{Email: "jane", Suspended: &suspended, PageSize: 2}
+			page, err := s.List(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.NextPageToken == "" {
+				t.Fatal("no next page token")
+			}
+
+			next := *filter
+			next.PageToken = page.NextPageToken
+			if tt.next != nil {
+				tt.next(&next)
+			}
+
+			page, err = s.List(ctx, &next)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && page.Users[0].Id != 3 {
+				t.Errorf("next page starts at user %d, want 3", page.Users[0].Id)
+			}
+		})
+	}
+}
+
+func TestListByCreatedAt(t *testing.T) {
+	users := &userRepo{}
+	s := &userService{
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		userRepo: users,
+		roleRepo: &roleRepo{},
+	}
+	ctx := context.Background()
+
+	filter := &model.UserFilter{SortBy: model.UserSortByCreatedAt, PageSize: 2}
+	page, err := s.List(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Users[1].CreatedAt.Equal(createdAt(2)) {
+		t.Errorf("got created at %v, want %v", page.Users[1].CreatedAt, createdAt(2))
+	}
+
+	next := *filter
+	next.PageToken = page.NextPageToken
+	if _, err = s.List(ctx, &next); err != nil {
+		t.Fatal(err)
+	}
+	key, ok := users.after.Key.(time.Time)
+	if !ok {
+		t.Fatalf("got cursor key %T, want time.Time", users.after.Key)
+	}
+	if !key.Equal(createdAt(2)) || users.after.Id != 2 {
+		t.Errorf("got cursor %v/%d, want %v/2", key, users.after.Id, createdAt(2))
+	}
+
+	filters, err := filtersHash(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := encodePageToken(&pageToken{SortBy: model.UserSortByCreatedAt, Filters: filters, Key: "yesterday", Id: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.PageToken = forged
+	if _, err = s.List(ctx, &next); !errors.Is(err, ErrInvalidPageToken) {
+		t.Fatalf("got error %v for a key that is no time, want %v", err, ErrInvalidPageToken)
+	}
+}
@@ -0,0 +1,80 @@
+package user
+
+import (
+	"context"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/auth-service/internal/service"
+	"log/slog"
+	"time"
+)
+
+const purgeBatchSize = 100
+
+// PurgeDeleted erases the personal data of users deleted longer than the
+// grace period ago, together with their linked identities, email changes and
+// avatars, and returns how many users it purged.
+func (s *userService) PurgeDeleted(ctx context.Context) (int, error) {
+	const op = "userService.PurgeDeleted"
+	log := s.log.With(slog.String("op", op))
+
+	deletedBefore := time.Now().Add(-s.purgeGracePeriod)
+
+	purged := 0
+	for {
+		var users []*userRepoModel.PurgedUser
+		err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+			var errTx error
+			users, errTx = s.userRepo.Purge(ctx, deletedBefore, purgeBatchSize)
+			if errTx != nil || len(users) == 0 {
+				return errTx
+			}
+
+			ids := make([]int, 0, len(users))
+			for _, user := range users {
+				ids = append(ids, user.Id)
+			}
+
+			if errTx = s.identityRepo.DeleteByUserIds(ctx, ids); errTx != nil {
+				return errTx
+			}
+
+			return s.emailChangeRepo.DeleteByUserIds(ctx, ids)
+		})
+		if err != nil {
+			log.Error("failed to purge users", slog.String("error", err.Error()))
+			return purged, ErrInternal
+		}
+
+		for _, user := range users {
+			if user.AvatarKey != nil {
+				s.deleteAvatarBlobs(ctx, *user.AvatarKey)
+			}
+		}
+
+		purged += len(users)
+		if len(users) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// RunPurger calls PurgeDeleted every interval until ctx is done.
+func RunPurger(ctx context.Context, log *slog.Logger, userService service.UserService, interval time.Duration) {
+	log = log.With(slog.String("op", "user.RunPurger"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := userService.PurgeDeleted(ctx)
+		if err == nil && purged > 0 {
+			log.Info("purged deleted users", slog.Int("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/repository"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"github.com/nogavadu/platform_common/pkg/db"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+// txManager tells the repositories whether they run in a transaction.
+type txManager struct {
+	inTx *bool
+}
+
+func (m txManager) ReadCommitted(ctx context.Context, f db.Handler) error {
+	*m.inTx = true
+	defer func() { *m.inTx = false }()
+
+	return f(ctx)
+}
+
+func (r *userRepo) Purge(_ context.Context, _ time.Time, limit uint64) ([]*userRepoModel.PurgedUser, error) {
+	n := min(len(r.purgeable), int(limit))
+	users := r.purgeable[:n]
+	r.purgeable = r.purgeable[n:]
+
+	return users, nil
+}
+
+// userIdsRepo records the user ids it deleted rows of, and whether it did
+// so in a transaction.
+type userIdsRepo struct {
+	inTx    *bool
+	err     error
+	deleted []int
+}
+
+func (r *userIdsRepo) DeleteByUserIds(_ context.Context, userIds []int) error {
+	if !*r.inTx {
+		return errors.New("outside a transaction")
+	}
+	if r.err != nil {
+		return r.err
+	}
+	r.deleted = append(r.deleted, userIds...)
+
+	return nil
+}
+
+type identityRepo struct {
+	repository.IdentityRepository
+	ids *userIdsRepo
+}
+
+func (r *identityRepo) DeleteByUserIds(ctx context.Context, userIds []int) error {
+	return r.ids.DeleteByUserIds(ctx, userIds)
+}
+
+type emailChangeRepo struct {
+	repository.EmailChangeRepository
+	ids *userIdsRepo
+}
+
+func (r *emailChangeRepo) DeleteByUserIds(ctx context.Context, userIds []int) error {
+	return r.ids.DeleteByUserIds(ctx, userIds)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	tests := []struct {
+		name           string
+		emailChangeErr error
+		wantErr        error
+		wantPurged     int
+	}{
+		{name: "purged", wantPurged: 2},
+		{name: "email changes not deleted", emailChangeErr: errors.New("connection reset"), wantErr: ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTx := false
+			identities := &userIdsRepo{inTx: &inTx}
+			emailChanges := &userIdsRepo{inTx: &inTx, err: tt.emailChangeErr}
+			s := &userService{
+				log:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+				userRepo:        &userRepo{purgeable: []*userRepoModel.PurgedUser{{Id: 3}, {Id: 5}}},
+				identityRepo:    &identityRepo{ids: identities},
+				emailChangeRepo: &emailChangeRepo{ids: emailChanges},
+				txManager:       txManager{inTx: &inTx},
+			}
+
+			purged, err := s.PurgeDeleted(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if purged != tt.wantPurged {
+				t.Errorf("purged %d users, want %d", purged, tt.wantPurged)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			for name, repo := range map[string]*userIdsRepo{"identities": identities, "email changes": emailChanges} {
+				if !slices.Equal(repo.deleted, []int{3, 5}) {
+					t.Errorf("deleted %s of users %v, want [3 5]", name, repo.deleted)
+				}
+			}
+		})
+	}
+}
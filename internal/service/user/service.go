@@ -2,34 +2,94 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/IBM/sarama"
 	"github.com/nogavadu/auth-service/internal/domain/model"
 	"github.com/nogavadu/auth-service/internal/repository"
 	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
 	"github.com/nogavadu/auth-service/internal/service"
+	"github.com/nogavadu/auth-service/internal/storage"
 	"github.com/nogavadu/platform_common/pkg/db"
 	"log/slog"
+	"time"
+)
+
+var (
+	ErrInvalidPageToken        = errors.New("invalid page token")
+	ErrInvalidSort             = errors.New("invalid sort")
+	ErrUnknownRole             = errors.New("unknown role")
+	ErrEmailTaken              = errors.New("email is already taken")
+	ErrInvalidStatus           = errors.New("invalid status")
+	ErrNotFound                = errors.New("user not found")
+	ErrNotDeleted              = errors.New("user is not deleted")
+	ErrPurged                  = errors.New("user is already purged")
+	ErrInvalidUntil            = errors.New("until must be in the future")
+	ErrNotSuspended            = errors.New("user is not suspended")
+	ErrForbidden               = errors.New("user outranks the moderator")
+	ErrRoleForbidden           = errors.New("role is not below the caller's")
+	ErrUnknownAttribute        = errors.New("unknown attribute")
+	ErrAttributeForbidden      = errors.New("attribute is admin-only")
+	ErrInvalidAttribute        = errors.New("invalid attribute value")
+	ErrSameEmail               = errors.New("new email is the current one")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+	ErrAvatarTooLarge          = errors.New("avatar is too large")
+	ErrUnsupportedImage        = errors.New("avatar must be a JPEG, PNG or GIF image")
+	ErrInvalidImage            = errors.New("invalid image")
+	ErrInternal                = errors.New("internal error")
 )
 
 type userService struct {
 	log *slog.Logger
 
-	userRepo  repository.UserRepository
-	roleRepo  repository.RoleRepository
-	txManager db.TxManager
+	purgeGracePeriod time.Duration
+	attributeSchema  *model.AttributeSchema
+	emailChangeTopic string
+	emailChangeTTL   time.Duration
+	avatarMaxSize    int64
+
+	userRepo        repository.UserRepository
+	roleRepo        repository.RoleRepository
+	identityRepo    repository.IdentityRepository
+	suspensionRepo  repository.SuspensionRepository
+	emailChangeRepo repository.EmailChangeRepository
+	txManager       db.TxManager
+
+	emailProducer sarama.SyncProducer
+	avatarStorage storage.BlobStorage
 }
 
 func New(
 	log *slog.Logger,
+	purgeGracePeriod time.Duration,
+	attributeSchema *model.AttributeSchema,
+	emailChangeTopic string,
+	emailChangeTTL time.Duration,
+	avatarMaxSize int64,
 	userRepo repository.UserRepository,
 	roleRepo repository.RoleRepository,
+	identityRepo repository.IdentityRepository,
+	suspensionRepo repository.SuspensionRepository,
+	emailChangeRepo repository.EmailChangeRepository,
 	txManager db.TxManager,
+	emailProducer sarama.SyncProducer,
+	avatarStorage storage.BlobStorage,
 ) service.UserService {
 	return &userService{
-		log:       log,
-		userRepo:  userRepo,
-		roleRepo:  roleRepo,
-		txManager: txManager,
+		log:              log,
+		purgeGracePeriod: purgeGracePeriod,
+		attributeSchema:  attributeSchema,
+		emailChangeTopic: emailChangeTopic,
+		emailChangeTTL:   emailChangeTTL,
+		avatarMaxSize:    avatarMaxSize,
+		userRepo:         userRepo,
+		roleRepo:         roleRepo,
+		identityRepo:     identityRepo,
+		suspensionRepo:   suspensionRepo,
+		emailChangeRepo:  emailChangeRepo,
+		txManager:        txManager,
+		emailProducer:    emailProducer,
+		avatarStorage:    avatarStorage,
 	}
 }
 
@@ -65,61 +125,242 @@ func (s *userService) GetById(ctx context.Context, id int) (*model.User, error)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &model.User{
-		Id: user.Id,
-		UserInfo: model.UserInfo{
-			Name:   user.Name,
-			Email:  user.Email,
-			Avatar: user.Avatar,
-			Role:   role,
-		},
-	}, nil
+	return userFromRepo(user, role), nil
 }
 
-func (s *userService) Update(ctx context.Context, id int, input *model.UserUpdateInput) error {
+// Update edits the user. Users may edit their own profile; anyone else's
+// takes outranking them, and a role can only be given by someone above it.
+func (s *userService) Update(ctx context.Context, principal *model.Principal, id int, input *model.UserUpdateInput) (*model.User, error) {
 	const op = "userService.Update"
 	log := s.log.With(slog.String("op", op))
 
+	var previousAvatarKey *string
 	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
 		var errTx error
 		defer func() {
 			if errTx != nil {
-				log.Error("failed to get user by id", slog.String("error", errTx.Error()))
+				log.Error("failed to update user", slog.String("error", errTx.Error()))
 			}
 		}()
 
+		if err := s.checkSelfOrOutranks(ctx, principal, id); err != nil {
+			if errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) {
+				return err
+			}
+
+			errTx = err
+			return errTx
+		}
+
 		var roleId *int
 		if input.Role != nil {
-			role, errTx := s.roleRepo.GetByName(ctx, *input.Role)
-			if errTx != nil {
+			role, err := s.roleRepo.GetByName(ctx, *input.Role)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return ErrUnknownRole
+				}
+
+				errTx = err
 				return errTx
 			}
+			if role.Level >= principal.RoleLevel {
+				return ErrRoleForbidden
+			}
 			intRole := int(role.ID)
 			roleId = &intRole
 		}
 
-		if errTx = s.userRepo.Update(ctx, id, &userRepoModel.UserUpdateInput{
-			Name:     input.Name,
-			Email:    input.Email,
-			Password: input.Password,
-			Avatar:   input.Avatar,
-			RoleId:   roleId,
-		}); errTx != nil {
+		// The avatar goes through SetAvatar, which drops the key of an
+		// uploaded one so that its blobs can be deleted.
+		if input.Avatar != nil || input.ClearAvatar {
+			var err error
+			previousAvatarKey, err = s.userRepo.SetAvatar(ctx, id, input.Avatar, nil)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return ErrNotFound
+				}
+
+				errTx = err
+				return errTx
+			}
+		}
+
+		err := s.userRepo.Update(ctx, id, &userRepoModel.UserUpdateInput{
+			Name:      input.Name,
+			Email:     input.Email,
+			Password:  input.Password,
+			RoleId:    roleId,
+			ClearName: input.ClearName,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				return ErrNotFound
+			case errors.Is(err, repository.ErrAlreadyExists):
+				return ErrEmailTaken
+			}
+
+			errTx = err
 			return errTx
 		}
 
 		return nil
 	})
+	if err != nil {
+		if errors.Is(err, ErrUnknownRole) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrEmailTaken) ||
+			errors.Is(err, ErrForbidden) || errors.Is(err, ErrRoleForbidden) {
+			return nil, err
+		}
+
+		return nil, ErrInternal
+	}
+
+	if previousAvatarKey != nil {
+		s.deleteAvatarBlobs(ctx, *previousAvatarKey)
+	}
+
+	user, err := s.GetById(ctx, id)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	return user, nil
+}
+
+// Deactivate, Reactivate and Restore take a moderator who outranks the
+// user, as Suspend does.
+func (s *userService) Deactivate(ctx context.Context, moderator *model.Principal, id int) error {
+	const op = "userService.Deactivate"
 
-	return err
+	return s.setDeactivated(ctx, op, moderator, id, true)
 }
 
-func (s *userService) Delete(ctx context.Context, id int) error {
+func (s *userService) Reactivate(ctx context.Context, moderator *model.Principal, id int) error {
+	const op = "userService.Reactivate"
+
+	return s.setDeactivated(ctx, op, moderator, id, false)
+}
+
+func (s *userService) setDeactivated(ctx context.Context, op string, moderator *model.Principal, id int, deactivated bool) error {
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if errTx := s.checkOutranks(ctx, moderator, id); errTx != nil {
+			return errTx
+		}
+
+		return s.userRepo.SetDeactivated(ctx, id, deactivated)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound), errors.Is(err, ErrForbidden):
+			return err
+		case errors.Is(err, repository.ErrNotFound):
+			return ErrNotFound
+		}
+
+		s.log.Error("failed to update user", slog.String("op", op), slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// Delete only marks the user as deleted; their personal data is erased by
+// PurgeDeleted once the grace period is over. Users may delete their own
+// account; anyone else's takes outranking them.
+func (s *userService) Delete(ctx context.Context, principal *model.Principal, id int) error {
 	const op = "userService.Delete"
 
-	if err := s.userRepo.Delete(ctx, id); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if errTx := s.checkSelfOrOutranks(ctx, principal, id); errTx != nil {
+			return errTx
+		}
+
+		return s.userRepo.Delete(ctx, id)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound), errors.Is(err, ErrForbidden):
+			return err
+		case errors.Is(err, repository.ErrNotFound):
+			return ErrNotFound
+		}
+
+		s.log.Error("failed to delete user", slog.String("op", op), slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (s *userService) Restore(ctx context.Context, moderator *model.Principal, id int) error {
+	const op = "userService.Restore"
+	log := s.log.With(slog.String("op", op))
+
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		user, errTx := s.userRepo.GetById(ctx, id)
+		if errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrNotFound
+			}
+
+			return errTx
+		}
+		if errTx = s.outranks(ctx, moderator, user); errTx != nil {
+			return errTx
+		}
+		if user.DeletedAt == nil {
+			return ErrNotDeleted
+		}
+		if user.PurgedAt != nil {
+			return ErrPurged
+		}
+
+		// The purger may have got to the user since it was read.
+		if errTx = s.userRepo.Restore(ctx, id); errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrPurged
+			}
+
+			return errTx
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotDeleted) || errors.Is(err, ErrPurged) {
+			return err
+		}
+
+		log.Error("failed to restore user", slog.String("error", err.Error()))
+		return ErrInternal
 	}
 
 	return nil
 }
+
+func userFromRepo(user *userRepoModel.User, role string) *model.User {
+	status := model.UserStatusActive
+	switch {
+	case user.DeletedAt != nil:
+		status = model.UserStatusDeleted
+	case user.DeactivatedAt != nil:
+		status = model.UserStatusDeactivated
+	}
+
+	return &model.User{
+		Id: user.Id,
+		UserInfo: model.UserInfo{
+			Name:   user.Name,
+			Email:  user.Email,
+			Avatar: user.Avatar,
+			Role:   role,
+		},
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		LastLoginAt:   user.LastLoginAt,
+		Status:        status,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		DeactivatedAt: user.DeactivatedAt,
+		DeletedAt:     user.DeletedAt,
+	}
+}
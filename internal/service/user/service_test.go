@@ -0,0 +1,145 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"testing"
+	"time"
+)
+
+func (r *userRepo) SetDeactivated(_ context.Context, _ int, _ bool) error {
+	return nil
+}
+
+func (r *userRepo) Delete(_ context.Context, _ int) error {
+	return nil
+}
+
+func (r *userRepo) Restore(_ context.Context, _ int) error {
+	return nil
+}
+
+func (r *userRepo) Update(_ context.Context, _ int, _ *userRepoModel.UserUpdateInput) error {
+	return nil
+}
+
+func (r *roleRepo) GetByName(_ context.Context, name string) (*roleRepoModel.Role, error) {
+	for _, role := range roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func TestModerationOutranks(t *testing.T) {
+	actions := map[string]func(s *userService, principal *model.Principal, id int) error{
+		"deactivate": func(s *userService, principal *model.Principal, id int) error {
+			return s.Deactivate(context.Background(), principal, id)
+		},
+		"reactivate": func(s *userService, principal *model.Principal, id int) error {
+			return s.Reactivate(context.Background(), principal, id)
+		},
+		"delete": func(s *userService, principal *model.Principal, id int) error {
+			return s.Delete(context.Background(), principal, id)
+		},
+		"restore": func(s *userService, principal *model.Principal, id int) error {
+			return s.Restore(context.Background(), principal, id)
+		},
+	}
+
+	// Users may delete themselves, so the moderator is not one of the users.
+	peer := &model.Principal{UserId: 9, Role: "moderator", RoleLevel: 50}
+
+	tests := []struct {
+		name      string
+		moderator *model.Principal
+		userId    int
+		wantErr   error
+	}{
+		{name: "user by moderator", moderator: peer, userId: 1},
+		{name: "moderator by moderator", moderator: peer, userId: 2, wantErr: ErrForbidden},
+		{name: "admin by moderator", moderator: peer, userId: 3, wantErr: ErrForbidden},
+		{name: "user by service account", moderator: serviceAccount, userId: 1},
+		{name: "admin by service account", moderator: serviceAccount, userId: 3, wantErr: ErrForbidden},
+		{name: "unknown user", moderator: peer, userId: 4, wantErr: ErrNotFound},
+	}
+	for action, do := range actions {
+		for _, tt := range tests {
+			t.Run(action+" "+tt.name, func(t *testing.T) {
+				users := rankedUsers()
+				// Only deleted users can be restored.
+				if action == "restore" {
+					deletedAt := time.Now()
+					for _, user := range users {
+						user.DeletedAt = &deletedAt
+					}
+				}
+				s := newModerationService(users)
+
+				if err := do(s, tt.moderator, tt.userId); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteSelf(t *testing.T) {
+	admin := &model.Principal{UserId: 3, Role: "admin", RoleLevel: 100}
+
+	tests := []struct {
+		name      string
+		principal *model.Principal
+		userId    int
+		wantErr   error
+	}{
+		{name: "moderator", principal: moderator, userId: 2},
+		{name: "admin", principal: admin, userId: 3},
+		{name: "admin by moderator", principal: moderator, userId: 3, wantErr: ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newModerationService(rankedUsers())
+
+			if err := s.Delete(context.Background(), tt.principal, tt.userId); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	admin := &model.Principal{UserId: 3, Role: "admin", RoleLevel: 100}
+
+	tests := []struct {
+		name      string
+		principal *model.Principal
+		userId    int
+		role      string
+		wantErr   error
+	}{
+		{name: "user made moderator by admin", principal: admin, userId: 1, role: "moderator"},
+		{name: "moderator made user by admin", principal: admin, userId: 2, role: "user"},
+		{name: "user made admin by admin", principal: admin, userId: 1, role: "admin", wantErr: ErrRoleForbidden},
+		{name: "user made moderator by moderator", principal: moderator, userId: 1, role: "moderator", wantErr: ErrRoleForbidden},
+		{name: "admin made user by moderator", principal: moderator, userId: 3, role: "user", wantErr: ErrForbidden},
+		{name: "admin steps down", principal: admin, userId: 3, role: "moderator"},
+		{name: "unknown role", principal: admin, userId: 1, role: "owner", wantErr: ErrUnknownRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newModerationService(rankedUsers())
+
+			_, err := s.Update(context.Background(), tt.principal, tt.userId, &model.UserUpdateInput{Role: &tt.role})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
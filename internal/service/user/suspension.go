@@ -0,0 +1,186 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"log/slog"
+	"time"
+)
+
+// Suspend suspends the user until the given time, or indefinitely when it is
+// nil, replacing any suspension already in force. All of the user's sessions
+// are revoked, so they stay logged out after the suspension ends.
+func (s *userService) Suspend(ctx context.Context, moderator *model.Principal, userId int, reason string, until *time.Time) (*model.Suspension, error) {
+	const op = "userService.Suspend"
+	log := s.log.With(slog.String("op", op))
+
+	if until != nil && !until.After(time.Now()) {
+		return nil, ErrInvalidUntil
+	}
+
+	var suspension *suspensionRepoModel.Suspension
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if errTx := s.checkOutranks(ctx, moderator, userId); errTx != nil {
+			return errTx
+		}
+
+		errTx := s.suspensionRepo.Lift(ctx, userId, moderatorId(moderator))
+		if errTx != nil && !errors.Is(errTx, repository.ErrNotFound) {
+			return errTx
+		}
+
+		id, errTx := s.suspensionRepo.Create(ctx, &suspensionRepoModel.SuspensionInfo{
+			UserId:      userId,
+			Reason:      reason,
+			SuspendedBy: moderatorId(moderator),
+			Until:       until,
+		})
+		if errTx != nil {
+			return errTx
+		}
+
+		if errTx = s.userRepo.RevokeSessions(ctx, userId); errTx != nil {
+			return errTx
+		}
+
+		suspension, errTx = s.suspensionRepo.GetById(ctx, id)
+		return errTx
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) {
+			return nil, err
+		}
+
+		log.Error("failed to suspend user", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	return suspensionToModel(suspension), nil
+}
+
+// Unsuspend lifts the user's suspension. Sessions revoked by it stay revoked.
+func (s *userService) Unsuspend(ctx context.Context, moderator *model.Principal, userId int) error {
+	const op = "userService.Unsuspend"
+	log := s.log.With(slog.String("op", op))
+
+	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if errTx := s.checkOutranks(ctx, moderator, userId); errTx != nil {
+			return errTx
+		}
+
+		if errTx := s.suspensionRepo.Lift(ctx, userId, moderatorId(moderator)); errTx != nil {
+			if errors.Is(errTx, repository.ErrNotFound) {
+				return ErrNotSuspended
+			}
+
+			return errTx
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotSuspended) {
+			return err
+		}
+
+		log.Error("failed to unsuspend user", slog.String("error", err.Error()))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// ListSuspensions returns the user's suspension history, newest first.
+func (s *userService) ListSuspensions(ctx context.Context, userId int) ([]*model.Suspension, error) {
+	const op = "userService.ListSuspensions"
+	log := s.log.With(slog.String("op", op))
+
+	if _, err := s.userRepo.GetById(ctx, userId); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	suspensions, err := s.suspensionRepo.ListByUserId(ctx, userId)
+	if err != nil {
+		log.Error("failed to list suspensions", slog.String("error", err.Error()))
+		return nil, ErrInternal
+	}
+
+	res := make([]*model.Suspension, 0, len(suspensions))
+	for _, suspension := range suspensions {
+		res = append(res, suspensionToModel(suspension))
+	}
+
+	return res, nil
+}
+
+// checkOutranks makes sure the user exists and that the moderator's role,
+// a service account's included, is above theirs, so moderators cannot
+// suspend, deactivate or delete each other or admins.
+func (s *userService) checkOutranks(ctx context.Context, moderator *model.Principal, userId int) error {
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+	if user.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	return s.outranks(ctx, moderator, user)
+}
+
+// outranks is checkOutranks for a user already read, deleted or not.
+func (s *userService) outranks(ctx context.Context, moderator *model.Principal, user *userRepoModel.User) error {
+	role, err := s.roleRepo.GetById(ctx, user.RoleId)
+	if err != nil {
+		return err
+	}
+	if role.Level >= moderator.RoleLevel {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// checkSelfOrOutranks is checkOutranks for what users may also do to their
+// own account, whatever their rank.
+func (s *userService) checkSelfOrOutranks(ctx context.Context, principal *model.Principal, userId int) error {
+	if principal.ClientId == "" && principal.UserId == userId {
+		return nil
+	}
+
+	return s.checkOutranks(ctx, principal, userId)
+}
+
+func moderatorId(moderator *model.Principal) *int {
+	if moderator.ClientId != "" {
+		return nil
+	}
+
+	return &moderator.UserId
+}
+
+func suspensionToModel(suspension *suspensionRepoModel.Suspension) *model.Suspension {
+	return &model.Suspension{
+		Id:          suspension.Id,
+		UserId:      suspension.UserId,
+		Reason:      suspension.Reason,
+		SuspendedBy: suspension.SuspendedBy,
+		CreatedAt:   suspension.CreatedAt,
+		Until:       suspension.Until,
+		LiftedAt:    suspension.LiftedAt,
+		LiftedBy:    suspension.LiftedBy,
+	}
+}
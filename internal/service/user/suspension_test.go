@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func (r *userRepo) RevokeSessions(_ context.Context, _ int) error {
+	return nil
+}
+
+// suspensionRepo has no suspension in force and creates every one with id 1.
+type suspensionRepo struct {
+	repository.SuspensionRepository
+}
+
+func (r *suspensionRepo) Lift(_ context.Context, _ int, _ *int) error {
+	return repository.ErrNotFound
+}
+
+func (r *suspensionRepo) Create(_ context.Context, _ *suspensionRepoModel.SuspensionInfo) (int, error) {
+	return 1, nil
+}
+
+func (r *suspensionRepo) GetById(_ context.Context, id int) (*suspensionRepoModel.Suspension, error) {
+	return &suspensionRepoModel.Suspension{Id: id}, nil
+}
+
+// rankedUsers are a user, a moderator and an admin, whose ids are those of
+// their roles.
+func rankedUsers() map[int]*userRepoModel.User {
+	users := make(map[int]*userRepoModel.User, len(roles))
+	for _, role := range roles {
+		users[int(role.ID)] = &userRepoModel.User{Id: int(role.ID), UserInfo: userRepoModel.UserInfo{RoleId: int(role.ID)}}
+	}
+
+	return users
+}
+
+func newModerationService(users map[int]*userRepoModel.User) *userService {
+	return &userService{
+		log:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		userRepo:       &userRepo{users: users},
+		roleRepo:       &roleRepo{},
+		suspensionRepo: &suspensionRepo{},
+		txManager:      txManager{inTx: new(bool)},
+	}
+}
+
+var (
+	moderator      = &model.Principal{UserId: 2, Role: "moderator", RoleLevel: 50}
+	serviceAccount = &model.Principal{ClientId: "moderation-bot", Role: "moderator", RoleLevel: 50}
+)
+
+func TestSuspendOutranks(t *testing.T) {
+	tests := []struct {
+		name      string
+		moderator *model.Principal
+		userId    int
+		wantErr   error
+	}{
+		{name: "user by moderator", moderator: moderator, userId: 1},
+		{name: "moderator by moderator", moderator: moderator, userId: 2, wantErr: ErrForbidden},
+		{name: "admin by moderator", moderator: moderator, userId: 3, wantErr: ErrForbidden},
+		{name: "user by service account", moderator: serviceAccount, userId: 1},
+		{name: "admin by service account", moderator: serviceAccount, userId: 3, wantErr: ErrForbidden},
+		{name: "unknown user", moderator: moderator, userId: 4, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newModerationService(rankedUsers())
+
+			_, err := s.Suspend(context.Background(), tt.moderator, tt.userId, "spam", nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "context"
+
+// BlobStorage stores publicly readable blobs, such as avatars, under keys
+// chosen by the caller.
+type BlobStorage interface {
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where the blob is served from.
+	URL(key string) string
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "google.golang.org/protobuf/types/known/wrapperspb"
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"time"
+)
 
 func ProtoStringToPtrString(s *wrapperspb.StringValue) *string {
 	if s == nil {
@@ -25,3 +29,35 @@ func ProtoInt32ToPtrInt(s *wrapperspb.Int32Value) *int {
 	val := int(s.GetValue())
 	return &val
 }
+
+func IntPtrToProtoInt64(ptr *int) *wrapperspb.Int64Value {
+	if ptr == nil {
+		return nil
+	}
+
+	return wrapperspb.Int64(int64(*ptr))
+}
+
+func ProtoBoolValueToPtrBool(b *wrapperspb.BoolValue) *bool {
+	if b == nil {
+		return nil
+	}
+
+	val := b.GetValue()
+	return &val
+}
+
+func ProtoTimestampToPtrTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func TimePtrToProtoTimestamp(ptr *time.Time) *timestamppb.Timestamp {
+	if ptr == nil {
+		return nil
+	}
+	return timestamppb.New(*ptr)
+}
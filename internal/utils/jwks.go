@@ -0,0 +1,36 @@
+package utils
+
+import "crypto/rsa"
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func PublicJWKSet(keys ...*rsa.PublicKey) *JWKSet {
+	set := &JWKSet{
+		Keys: make([]JWK, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		n, e := jwkRSAParams(key)
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: KeyID(key),
+			N:   n,
+			E:   e,
+		})
+	}
+
+	return set
+}
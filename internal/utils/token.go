@@ -1,28 +1,155 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/nogavadu/auth-service/internal/domain/model"
+	"math/big"
 	"time"
 )
 
-func GenerateToken(user *model.User, secretKey string, dur time.Duration) (string, error) {
+const (
+	// AccessTokenType is the "typ" header of access tokens (RFC 9068).
+	AccessTokenType = "at+jwt"
+	// IdTokenType is the "typ" header of ID tokens.
+	IdTokenType = "JWT"
+
+	// Audience is the "aud" of first-party tokens. Tokens issued to OpenID
+	// clients name the client instead.
+	Audience = "auth-service"
+)
+
+// GenerateToken issues an HS256 access token.
+func GenerateToken(user *model.User, sessionId string, authTime time.Time, secretKey string, dur time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, userClaims(user, sessionId, authTime, dur))
+	token.Header["typ"] = AccessTokenType
+
+	return token.SignedString([]byte(secretKey))
+}
+
+// GenerateRefreshToken issues an HS256 refresh token. Refresh tokens keep
+// the default "typ", so they never pass for access tokens.
+func GenerateRefreshToken(user *model.User, sessionId string, authTime time.Time, secretKey string, dur time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, userClaims(user, sessionId, authTime, dur))
+
+	return token.SignedString([]byte(secretKey))
+}
+
+// GenerateRSAToken issues an RS256 access token.
+func GenerateRSAToken(user *model.User, sessionId string, authTime time.Time, key *rsa.PrivateKey, dur time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, userClaims(user, sessionId, authTime, dur))
+	token.Header["typ"] = AccessTokenType
+	token.Header["kid"] = KeyID(&key.PublicKey)
+
+	return token.SignedString(key)
+}
+
+func userClaims(user *model.User, sessionId string, authTime time.Time, dur time.Duration) *model.UserClaims {
 	claims := &model.UserClaims{
 		StandardClaims: jwt.StandardClaims{
+			Audience:  Audience,
 			ExpiresAt: time.Now().Add(dur).Unix(),
 		},
-		Id:    user.Id,
-		Email: user.Email,
-		Role:  user.Role,
+		Id:         user.Id,
+		Email:      user.Email,
+		Role:       user.Role,
+		SessionId:  sessionId,
+		Attributes: user.Attributes,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = authTime.Unix()
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return claims
+}
+
+func GenerateServiceAccountToken(account *model.ServiceAccount, scope string, secretKey string, dur time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, serviceAccountClaims(account, scope, dur))
+	token.Header["typ"] = AccessTokenType
 
 	return token.SignedString([]byte(secretKey))
 }
 
+func GenerateServiceAccountRSAToken(account *model.ServiceAccount, scope string, key *rsa.PrivateKey, dur time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, serviceAccountClaims(account, scope, dur))
+	token.Header["typ"] = AccessTokenType
+	token.Header["kid"] = KeyID(&key.PublicKey)
+
+	return token.SignedString(key)
+}
+
+func serviceAccountClaims(account *model.ServiceAccount, scope string, dur time.Duration) *model.UserClaims {
+	return &model.UserClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  Audience,
+			ExpiresAt: time.Now().Add(dur).Unix(),
+			Subject:   account.ClientId,
+		},
+		Role:     account.Role,
+		ClientId: account.ClientId,
+		Scope:    scope,
+	}
+}
+
+// VerifyToken verifies an HS256 access token.
 func VerifyToken(tokenStr, secretKey string) (*model.UserClaims, error) {
+	token, err := parseHMACToken(tokenStr, secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return accessTokenClaims(token)
+}
+
+// VerifyRefreshToken verifies an HS256 refresh token.
+func VerifyRefreshToken(tokenStr, secretKey string) (*model.UserClaims, error) {
+	token, err := parseHMACToken(tokenStr, secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*model.UserClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	if token.Header["typ"] == AccessTokenType {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	// Refresh tokens issued before tokens named their audience have none.
+	if !claims.VerifyAudience(Audience, false) {
+		return nil, fmt.Errorf("invalid token audience")
+	}
+
+	return claims, nil
+}
+
+// VerifyRSAToken verifies an RS256 access token.
+func VerifyRSAToken(tokenStr string, key *rsa.PublicKey) (*model.UserClaims, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenStr,
+		&model.UserClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			_, ok := token.Method.(*jwt.SigningMethodRSA)
+			if !ok {
+				return nil, fmt.Errorf("enexpected token signing method")
+			}
+
+			return key, nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	return accessTokenClaims(token)
+}
+
+func parseHMACToken(tokenStr, secretKey string) (*jwt.Token, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenStr,
 		&model.UserClaims{},
@@ -39,10 +166,47 @@ func VerifyToken(tokenStr, secretKey string) (*model.UserClaims, error) {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
+	return token, nil
+}
+
+// accessTokenClaims refuses tokens other than first-party access tokens,
+// such as ID tokens and the access tokens of OpenID clients, which are
+// signed with the same key.
+func accessTokenClaims(token *jwt.Token) (*model.UserClaims, error) {
 	claims, ok := token.Claims.(*model.UserClaims)
 	if !ok {
 		return nil, fmt.Errorf("invalid token claims")
 	}
+	if token.Header["typ"] != AccessTokenType {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	if !claims.VerifyAudience(Audience, true) {
+		return nil, fmt.Errorf("invalid token audience")
+	}
 
 	return claims, nil
 }
+
+func NewSessionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// KeyID returns the RFC 7638 thumbprint of the key, used as the "kid" header and in the JWKS.
+func KeyID(key *rsa.PublicKey) string {
+	n, e := jwkRSAParams(key)
+	thumbprint := sha256.Sum256([]byte(fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, e, n)))
+
+	return base64.RawURLEncoding.EncodeToString(thumbprint[:])
+}
+
+func jwkRSAParams(key *rsa.PublicKey) (string, string) {
+	n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+
+	return n, e
+}
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id    INT     NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission VARCHAR NOT NULL,
+    resource   VARCHAR NOT NULL DEFAULT '*',
+    PRIMARY KEY (role_id, permission, resource)
+);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'users:read'
+FROM roles
+WHERE name = 'moderator';
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, '*'
+FROM roles
+WHERE name IN ('admin', 'creator');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS role_permissions;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS clients
+(
+    id                 SERIAL PRIMARY KEY,
+    client_id          VARCHAR UNIQUE NOT NULL,
+    client_secret_hash VARCHAR,
+    name               VARCHAR        NOT NULL,
+    redirect_uris      VARCHAR[]      NOT NULL DEFAULT '{}',
+    created_at         TIMESTAMPTZ    NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS authorization_codes
+(
+    code_hash             VARCHAR PRIMARY KEY,
+    client_id             VARCHAR     NOT NULL REFERENCES clients (client_id) ON DELETE CASCADE,
+    user_id               INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri          VARCHAR     NOT NULL,
+    scope                 VARCHAR     NOT NULL,
+    nonce                 VARCHAR,
+    code_challenge        VARCHAR,
+    code_challenge_method VARCHAR,
+    expires_at            TIMESTAMPTZ NOT NULL,
+    used_at               TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS authorization_codes;
+DROP TABLE IF EXISTS clients;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS service_accounts
+(
+    id                 SERIAL PRIMARY KEY,
+    client_id          VARCHAR UNIQUE NOT NULL,
+    client_secret_hash VARCHAR        NOT NULL,
+    name               VARCHAR        NOT NULL,
+    role_id            INT            NOT NULL DEFAULT 1 REFERENCES roles (id) ON DELETE SET DEFAULT,
+    scopes             VARCHAR[]      NOT NULL DEFAULT '{}',
+    created_at         TIMESTAMPTZ    NOT NULL DEFAULT now(),
+    disabled_at        TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS service_accounts;
+-- +goose StatementEnd
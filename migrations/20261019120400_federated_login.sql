@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_identities
+(
+    provider   VARCHAR     NOT NULL,
+    subject    VARCHAR     NOT NULL,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS external_login_states
+(
+    state_hash    VARCHAR PRIMARY KEY,
+    provider      VARCHAR     NOT NULL,
+    nonce         VARCHAR     NOT NULL,
+    code_verifier VARCHAR     NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    used_at       TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS external_login_states;
+DROP TABLE IF EXISTS user_identities;
+-- +goose StatementEnd
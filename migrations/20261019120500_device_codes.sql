@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS device_codes
+(
+    device_code_hash VARCHAR PRIMARY KEY,
+    user_code        VARCHAR UNIQUE NOT NULL,
+    client_id        VARCHAR        NOT NULL REFERENCES clients (client_id) ON DELETE CASCADE,
+    scope            VARCHAR        NOT NULL,
+    status           VARCHAR        NOT NULL DEFAULT 'pending',
+    user_id          INT REFERENCES users (id) ON DELETE CASCADE,
+    interval_seconds INT            NOT NULL,
+    expires_at       TIMESTAMPTZ    NOT NULL,
+    last_polled_at   TIMESTAMPTZ,
+    used_at          TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS device_codes;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_at     TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS purged_at      TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS users_purge_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_purge_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS purged_at,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS deactivated_at;
+-- +goose StatementEnd
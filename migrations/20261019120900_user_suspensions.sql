@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_suspensions
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason       VARCHAR     NOT NULL,
+    suspended_by INT REFERENCES users (id) ON DELETE SET NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    until        TIMESTAMPTZ,
+    lifted_at    TIMESTAMPTZ,
+    lifted_by    INT REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_suspensions_user_id_idx ON user_suspensions (user_id, created_at);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMPTZ;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'users:suspend'
+FROM roles
+WHERE name = 'moderator'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM role_permissions
+WHERE permission = 'users:suspend';
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS sessions_revoked_at;
+
+DROP TABLE IF EXISTS user_suspensions;
+-- +goose StatementEnd
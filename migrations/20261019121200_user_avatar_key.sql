@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- avatar_key is set when the avatar was uploaded, and locates its blobs.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS avatar_key VARCHAR;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS avatar_key;
+-- +goose StatementEnd
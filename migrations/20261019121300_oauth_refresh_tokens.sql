@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Each refresh token issued through the OAuth token endpoint is bound to the
+-- client and scope it was granted for. The row is replaced on every rotation.
+CREATE TABLE IF NOT EXISTS oauth_refresh_tokens
+(
+    token_hash VARCHAR PRIMARY KEY,
+    client_id  VARCHAR     NOT NULL REFERENCES clients (client_id) ON DELETE CASCADE,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    scope      VARCHAR     NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oauth_refresh_tokens;
+-- +goose StatementEnd
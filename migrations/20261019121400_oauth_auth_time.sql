@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tokens issued to clients carry the time the user logged in, so codes keep
+-- it until they are exchanged and refresh tokens across rotations. Client
+-- refresh tokens used to be first-party ones; they are dropped rather than
+-- migrated, and the clients have the user log in again.
+DELETE FROM oauth_refresh_tokens;
+ALTER TABLE oauth_refresh_tokens
+    ADD COLUMN auth_time  TIMESTAMPTZ NOT NULL,
+    ADD COLUMN expires_at TIMESTAMPTZ NOT NULL;
+
+ALTER TABLE authorization_codes
+    ADD COLUMN auth_time TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE authorization_codes
+    ALTER COLUMN auth_time DROP DEFAULT;
+
+ALTER TABLE device_codes
+    ADD COLUMN auth_time TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE device_codes
+    DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE authorization_codes
+    DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE oauth_refresh_tokens
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS auth_time;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- email_verified_at is set when the user proved they own their email, and
+-- cleared whenever the email is changed without such proof.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_verified_at;
+-- +goose StatementEnd
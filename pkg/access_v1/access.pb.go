@@ -7,9 +7,11 @@
 package access_v1
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -66,15 +68,510 @@ func (x *CheckRequest) GetRequiredLvl() uint32 {
 	return 0
 }
 
+type Permission struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Permission    string                 `protobuf:"bytes,1,opt,name=permission,proto3" json:"permission,omitempty"`
+	Resource      string                 `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Permission) Reset() {
+	*x = Permission{}
+	mi := &file_access_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Permission) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Permission) ProtoMessage() {}
+
+func (x *Permission) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Permission.ProtoReflect.Descriptor instead.
+func (*Permission) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Permission) GetPermission() string {
+	if x != nil {
+		return x.Permission
+	}
+	return ""
+}
+
+func (x *Permission) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+type Principal struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	UserId      int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Email       string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Roles       []string               `protobuf:"bytes,3,rep,name=roles,proto3" json:"roles,omitempty"`
+	RoleLevel   uint32                 `protobuf:"varint,4,opt,name=role_level,json=roleLevel,proto3" json:"role_level,omitempty"`
+	Permissions []*Permission          `protobuf:"bytes,5,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	SessionId   string                 `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	ExpiresAt   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	ClientId    string                 `protobuf:"bytes,8,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Scopes      []string               `protobuf:"bytes,9,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	// api_key_id is set when the caller authenticated with an API key.
+	ApiKeyId int64 `protobuf:"varint,10,opt,name=api_key_id,json=apiKeyId,proto3" json:"api_key_id,omitempty"`
+	// auth_time is when the user last entered credentials, if known.
+	AuthTime      *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=auth_time,json=authTime,proto3" json:"auth_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Principal) Reset() {
+	*x = Principal{}
+	mi := &file_access_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Principal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Principal) ProtoMessage() {}
+
+func (x *Principal) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Principal.ProtoReflect.Descriptor instead.
+func (*Principal) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Principal) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Principal) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Principal) GetRoles() []string {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
+func (x *Principal) GetRoleLevel() uint32 {
+	if x != nil {
+		return x.RoleLevel
+	}
+	return 0
+}
+
+func (x *Principal) GetPermissions() []*Permission {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *Principal) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Principal) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Principal) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *Principal) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *Principal) GetApiKeyId() int64 {
+	if x != nil {
+		return x.ApiKeyId
+	}
+	return 0
+}
+
+func (x *Principal) GetAuthTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AuthTime
+	}
+	return nil
+}
+
+type CheckResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Principal     *Principal             `protobuf:"bytes,1,opt,name=principal,proto3" json:"principal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckResponse) Reset() {
+	*x = CheckResponse{}
+	mi := &file_access_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckResponse) ProtoMessage() {}
+
+func (x *CheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckResponse.ProtoReflect.Descriptor instead.
+func (*CheckResponse) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CheckResponse) GetPrincipal() *Principal {
+	if x != nil {
+		return x.Principal
+	}
+	return nil
+}
+
+type AccessItem struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Requirement:
+	//
+	//	*AccessItem_RequiredLvl
+	//	*AccessItem_Permission
+	Requirement   isAccessItem_Requirement `protobuf_oneof:"requirement"`
+	Resource      string                   `protobuf:"bytes,3,opt,name=resource,proto3" json:"resource,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccessItem) Reset() {
+	*x = AccessItem{}
+	mi := &file_access_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccessItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccessItem) ProtoMessage() {}
+
+func (x *AccessItem) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccessItem.ProtoReflect.Descriptor instead.
+func (*AccessItem) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AccessItem) GetRequirement() isAccessItem_Requirement {
+	if x != nil {
+		return x.Requirement
+	}
+	return nil
+}
+
+func (x *AccessItem) GetRequiredLvl() uint32 {
+	if x != nil {
+		if x, ok := x.Requirement.(*AccessItem_RequiredLvl); ok {
+			return x.RequiredLvl
+		}
+	}
+	return 0
+}
+
+func (x *AccessItem) GetPermission() string {
+	if x != nil {
+		if x, ok := x.Requirement.(*AccessItem_Permission); ok {
+			return x.Permission
+		}
+	}
+	return ""
+}
+
+func (x *AccessItem) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+type isAccessItem_Requirement interface {
+	isAccessItem_Requirement()
+}
+
+type AccessItem_RequiredLvl struct {
+	RequiredLvl uint32 `protobuf:"varint,1,opt,name=required_lvl,json=requiredLvl,proto3,oneof"`
+}
+
+type AccessItem_Permission struct {
+	Permission string `protobuf:"bytes,2,opt,name=permission,proto3,oneof"`
+}
+
+func (*AccessItem_RequiredLvl) isAccessItem_Requirement() {}
+
+func (*AccessItem_Permission) isAccessItem_Requirement() {}
+
+type BatchCheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*AccessItem          `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchCheckRequest) Reset() {
+	*x = BatchCheckRequest{}
+	mi := &file_access_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchCheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCheckRequest) ProtoMessage() {}
+
+func (x *BatchCheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCheckRequest.ProtoReflect.Descriptor instead.
+func (*BatchCheckRequest) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchCheckRequest) GetItems() []*AccessItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type AccessDecision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Allowed       bool                   `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccessDecision) Reset() {
+	*x = AccessDecision{}
+	mi := &file_access_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccessDecision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccessDecision) ProtoMessage() {}
+
+func (x *AccessDecision) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccessDecision.ProtoReflect.Descriptor instead.
+func (*AccessDecision) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AccessDecision) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+func (x *AccessDecision) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type BatchCheckResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Decisions     []*AccessDecision      `protobuf:"bytes,1,rep,name=decisions,proto3" json:"decisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchCheckResponse) Reset() {
+	*x = BatchCheckResponse{}
+	mi := &file_access_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchCheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCheckResponse) ProtoMessage() {}
+
+func (x *BatchCheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_access_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCheckResponse.ProtoReflect.Descriptor instead.
+func (*BatchCheckResponse) Descriptor() ([]byte, []int) {
+	return file_access_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BatchCheckResponse) GetDecisions() []*AccessDecision {
+	if x != nil {
+		return x.Decisions
+	}
+	return nil
+}
+
 var File_access_proto protoreflect.FileDescriptor
 
 const file_access_proto_rawDesc = "" +
 	"\n" +
-	"\faccess.proto\x12\taccess_v1\x1a\x1bgoogle/protobuf/empty.proto\"1\n" +
+	"\faccess.proto\x12\taccess_v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"1\n" +
 	"\fCheckRequest\x12!\n" +
-	"\frequired_lvl\x18\x01 \x01(\rR\vrequiredLvl2D\n" +
-	"\bAccessV1\x128\n" +
-	"\x05Check\x12\x17.access_v1.CheckRequest\x1a\x16.google.protobuf.EmptyB-Z+github.com/nogavadu/pkg/access_v1;access_v1b\x06proto3"
+	"\frequired_lvl\x18\x01 \x01(\rR\vrequiredLvl\"H\n" +
+	"\n" +
+	"Permission\x12\x1e\n" +
+	"\n" +
+	"permission\x18\x01 \x01(\tR\n" +
+	"permission\x12\x1a\n" +
+	"\bresource\x18\x02 \x01(\tR\bresource\"\x8e\x03\n" +
+	"\tPrincipal\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x14\n" +
+	"\x05roles\x18\x03 \x03(\tR\x05roles\x12\x1d\n" +
+	"\n" +
+	"role_level\x18\x04 \x01(\rR\troleLevel\x127\n" +
+	"\vpermissions\x18\x05 \x03(\v2\x15.access_v1.PermissionR\vpermissions\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\tR\tsessionId\x129\n" +
+	"\n" +
+	"expires_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x1b\n" +
+	"\tclient_id\x18\b \x01(\tR\bclientId\x12\x16\n" +
+	"\x06scopes\x18\t \x03(\tR\x06scopes\x12\x1c\n" +
+	"\n" +
+	"api_key_id\x18\n" +
+	" \x01(\x03R\bapiKeyId\x127\n" +
+	"\tauth_time\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\bauthTime\"C\n" +
+	"\rCheckResponse\x122\n" +
+	"\tprincipal\x18\x01 \x01(\v2\x14.access_v1.PrincipalR\tprincipal\"~\n" +
+	"\n" +
+	"AccessItem\x12#\n" +
+	"\frequired_lvl\x18\x01 \x01(\rH\x00R\vrequiredLvl\x12 \n" +
+	"\n" +
+	"permission\x18\x02 \x01(\tH\x00R\n" +
+	"permission\x12\x1a\n" +
+	"\bresource\x18\x03 \x01(\tR\bresourceB\r\n" +
+	"\vrequirement\"@\n" +
+	"\x11BatchCheckRequest\x12+\n" +
+	"\x05items\x18\x01 \x03(\v2\x15.access_v1.AccessItemR\x05items\"B\n" +
+	"\x0eAccessDecision\x12\x18\n" +
+	"\aallowed\x18\x01 \x01(\bR\aallowed\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"M\n" +
+	"\x12BatchCheckResponse\x127\n" +
+	"\tdecisions\x18\x01 \x03(\v2\x19.access_v1.AccessDecisionR\tdecisions2\xaa\x02\n" +
+	"\bAccessV1\x12U\n" +
+	"\x05Check\x12\x17.access_v1.CheckRequest\x1a\x16.google.protobuf.Empty\"\x1b\x82\xd3\xe4\x93\x02\x15:\x01*\"\x10/v1/access/check\x12Y\n" +
+	"\aCheckV2\x12\x17.access_v1.CheckRequest\x1a\x18.access_v1.CheckResponse\"\x1b\x82\xd3\xe4\x93\x02\x15:\x01*\"\x10/v2/access/check\x12l\n" +
+	"\n" +
+	"BatchCheck\x12\x1c.access_v1.BatchCheckRequest\x1a\x1d.access_v1.BatchCheckResponse\"!\x82\xd3\xe4\x93\x02\x1b:\x01*\"\x16/v1/access/batch-checkB-Z+github.com/nogavadu/pkg/access_v1;access_v1b\x06proto3"
 
 var (
 	file_access_proto_rawDescOnce sync.Once
@@ -88,19 +585,37 @@ func file_access_proto_rawDescGZIP() []byte {
 	return file_access_proto_rawDescData
 }
 
-var file_access_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_access_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_access_proto_goTypes = []any{
-	(*CheckRequest)(nil),  // 0: access_v1.CheckRequest
-	(*emptypb.Empty)(nil), // 1: google.protobuf.Empty
+	(*CheckRequest)(nil),          // 0: access_v1.CheckRequest
+	(*Permission)(nil),            // 1: access_v1.Permission
+	(*Principal)(nil),             // 2: access_v1.Principal
+	(*CheckResponse)(nil),         // 3: access_v1.CheckResponse
+	(*AccessItem)(nil),            // 4: access_v1.AccessItem
+	(*BatchCheckRequest)(nil),     // 5: access_v1.BatchCheckRequest
+	(*AccessDecision)(nil),        // 6: access_v1.AccessDecision
+	(*BatchCheckResponse)(nil),    // 7: access_v1.BatchCheckResponse
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),         // 9: google.protobuf.Empty
 }
 var file_access_proto_depIdxs = []int32{
-	0, // 0: access_v1.AccessV1.Check:input_type -> access_v1.CheckRequest
-	1, // 1: access_v1.AccessV1.Check:output_type -> google.protobuf.Empty
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1, // 0: access_v1.Principal.permissions:type_name -> access_v1.Permission
+	8, // 1: access_v1.Principal.expires_at:type_name -> google.protobuf.Timestamp
+	8, // 2: access_v1.Principal.auth_time:type_name -> google.protobuf.Timestamp
+	2, // 3: access_v1.CheckResponse.principal:type_name -> access_v1.Principal
+	4, // 4: access_v1.BatchCheckRequest.items:type_name -> access_v1.AccessItem
+	6, // 5: access_v1.BatchCheckResponse.decisions:type_name -> access_v1.AccessDecision
+	0, // 6: access_v1.AccessV1.Check:input_type -> access_v1.CheckRequest
+	0, // 7: access_v1.AccessV1.CheckV2:input_type -> access_v1.CheckRequest
+	5, // 8: access_v1.AccessV1.BatchCheck:input_type -> access_v1.BatchCheckRequest
+	9, // 9: access_v1.AccessV1.Check:output_type -> google.protobuf.Empty
+	3, // 10: access_v1.AccessV1.CheckV2:output_type -> access_v1.CheckResponse
+	7, // 11: access_v1.AccessV1.BatchCheck:output_type -> access_v1.BatchCheckResponse
+	9, // [9:12] is the sub-list for method output_type
+	6, // [6:9] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_access_proto_init() }
@@ -108,13 +623,17 @@ func file_access_proto_init() {
 	if File_access_proto != nil {
 		return
 	}
+	file_access_proto_msgTypes[4].OneofWrappers = []any{
+		(*AccessItem_RequiredLvl)(nil),
+		(*AccessItem_Permission)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_access_proto_rawDesc), len(file_access_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -20,7 +20,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AccessV1_Check_FullMethodName = "/access_v1.AccessV1/Check"
+	AccessV1_Check_FullMethodName      = "/access_v1.AccessV1/Check"
+	AccessV1_CheckV2_FullMethodName    = "/access_v1.AccessV1/CheckV2"
+	AccessV1_BatchCheck_FullMethodName = "/access_v1.AccessV1/BatchCheck"
 )
 
 // AccessV1Client is the client API for AccessV1 service.
@@ -28,6 +30,11 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type AccessV1Client interface {
 	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// CheckV2 is Check that describes the principal. Unlike Check, it takes a
+	// zero required_lvl, which only authenticates the caller: the user role is
+	// of level zero, so demanding a level would turn plain users away.
+	CheckV2(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	BatchCheck(ctx context.Context, in *BatchCheckRequest, opts ...grpc.CallOption) (*BatchCheckResponse, error)
 }
 
 type accessV1Client struct {
@@ -48,11 +55,36 @@ func (c *accessV1Client) Check(ctx context.Context, in *CheckRequest, opts ...gr
 	return out, nil
 }
 
+func (c *accessV1Client) CheckV2(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckResponse)
+	err := c.cc.Invoke(ctx, AccessV1_CheckV2_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accessV1Client) BatchCheck(ctx context.Context, in *BatchCheckRequest, opts ...grpc.CallOption) (*BatchCheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchCheckResponse)
+	err := c.cc.Invoke(ctx, AccessV1_BatchCheck_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AccessV1Server is the server API for AccessV1 service.
 // All implementations must embed UnimplementedAccessV1Server
 // for forward compatibility.
 type AccessV1Server interface {
 	Check(context.Context, *CheckRequest) (*emptypb.Empty, error)
+	// CheckV2 is Check that describes the principal. Unlike Check, it takes a
+	// zero required_lvl, which only authenticates the caller: the user role is
+	// of level zero, so demanding a level would turn plain users away.
+	CheckV2(context.Context, *CheckRequest) (*CheckResponse, error)
+	BatchCheck(context.Context, *BatchCheckRequest) (*BatchCheckResponse, error)
 	mustEmbedUnimplementedAccessV1Server()
 }
 
@@ -66,6 +98,12 @@ type UnimplementedAccessV1Server struct{}
 func (UnimplementedAccessV1Server) Check(context.Context, *CheckRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
 }
+func (UnimplementedAccessV1Server) CheckV2(context.Context, *CheckRequest) (*CheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckV2 not implemented")
+}
+func (UnimplementedAccessV1Server) BatchCheck(context.Context, *BatchCheckRequest) (*BatchCheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchCheck not implemented")
+}
 func (UnimplementedAccessV1Server) mustEmbedUnimplementedAccessV1Server() {}
 func (UnimplementedAccessV1Server) testEmbeddedByValue()                  {}
 
@@ -105,6 +143,42 @@ func _AccessV1_Check_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AccessV1_CheckV2_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccessV1Server).CheckV2(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AccessV1_CheckV2_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccessV1Server).CheckV2(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AccessV1_BatchCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccessV1Server).BatchCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AccessV1_BatchCheck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccessV1Server).BatchCheck(ctx, req.(*BatchCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AccessV1_ServiceDesc is the grpc.ServiceDesc for AccessV1 service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -116,6 +190,14 @@ var AccessV1_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Check",
 			Handler:    _AccessV1_Check_Handler,
 		},
+		{
+			MethodName: "CheckV2",
+			Handler:    _AccessV1_CheckV2_Handler,
+		},
+		{
+			MethodName: "BatchCheck",
+			Handler:    _AccessV1_BatchCheck_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "access.proto",
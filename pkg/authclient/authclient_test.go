@@ -0,0 +1,603 @@
+package authclient_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	accessAPI "github.com/nogavadu/auth-service/internal/api/grpc/access"
+	jwksAPI "github.com/nogavadu/auth-service/internal/api/http/jwks"
+	"github.com/nogavadu/auth-service/internal/domain/model"
+	"github.com/nogavadu/auth-service/internal/repository"
+	apiKeyRepoModel "github.com/nogavadu/auth-service/internal/repository/apikey/model"
+	roleRepoModel "github.com/nogavadu/auth-service/internal/repository/role/model"
+	suspensionRepoModel "github.com/nogavadu/auth-service/internal/repository/suspension/model"
+	userRepoModel "github.com/nogavadu/auth-service/internal/repository/user/model"
+	accessService "github.com/nogavadu/auth-service/internal/service/access"
+	"github.com/nogavadu/auth-service/internal/utils"
+	accessDesc "github.com/nogavadu/auth-service/pkg/access_v1"
+	"github.com/nogavadu/auth-service/pkg/authclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "test-access-secret"
+	testUserId = 42
+)
+
+var testUser = &model.User{
+	Id: testUserId,
+	UserInfo: model.UserInfo{
+		Email: "jane@example.com",
+		Role:  "moderator",
+	},
+}
+
+// authServer is the auth service run in-process: the JWKS endpoint over
+// HTTP and AccessV1 over gRPC, backed by the real access service and
+// in-memory repositories. Unless hs256 is set, the service signs access
+// tokens with key, which the JWKS publishes.
+type authServer struct {
+	jwksURL string
+	conn    *grpc.ClientConn
+
+	key    *rsa.PrivateKey
+	apiKey string
+
+	mu         sync.Mutex
+	publicKeys []*rsa.PublicKey
+
+	jwksFetches atomic.Int32
+	remoteCalls atomic.Int32
+}
+
+func newAuthServer(t *testing.T, hs256 bool) *authServer {
+	t.Helper()
+
+	s := &authServer{
+		key: generateKey(t),
+	}
+	s.publicKeys = []*rsa.PublicKey{&s.key.PublicKey}
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.jwksFetches.Add(1)
+
+		s.mu.Lock()
+		keys := s.publicKeys
+		s.mu.Unlock()
+
+		jwksAPI.New(keys...).ServeHTTP(w, r)
+	}))
+	t.Cleanup(jwks.Close)
+	s.jwksURL = jwks.URL + jwksAPI.Path
+
+	apiKey, apiKeyHash, _, err := utils.NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.apiKey = apiKey
+
+	signingKey := s.key
+	if hs256 {
+		signingKey = nil
+	}
+
+	serv := accessService.New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		"test-refresh-secret",
+		time.Hour,
+		testSecret,
+		time.Hour,
+		signingKey,
+		&userRepo{},
+		&roleRepo{},
+		nil,
+		&apiKeyRepo{hash: apiKeyHash},
+		&suspensionRepo{},
+	)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		s.remoteCalls.Add(1)
+		return handler(ctx, req)
+	}))
+	accessDesc.RegisterAccessV1Server(server, accessAPI.New(serv))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	s.conn, err = grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.conn.Close()
+	})
+
+	return s
+}
+
+// rotate publishes only the given key, as after a key rotation.
+func (s *authServer) rotate(key *rsa.PrivateKey) {
+	s.mu.Lock()
+	s.publicKeys = []*rsa.PublicKey{&key.PublicKey}
+	s.mu.Unlock()
+}
+
+func (s *authServer) keySet(t *testing.T, opts ...authclient.KeySetOption) *authclient.KeySet {
+	t.Helper()
+
+	keys := authclient.NewKeySet(context.Background(), s.jwksURL, opts...)
+	t.Cleanup(keys.Close)
+
+	return keys
+}
+
+func (s *authServer) verifier(t *testing.T) authclient.Verifier {
+	t.Helper()
+
+	return authclient.WithFallback(
+		authclient.NewJWKSVerifier(s.keySet(t)),
+		authclient.NewRemoteVerifier(s.conn),
+	)
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func rsaToken(t *testing.T, key *rsa.PrivateKey, exp time.Duration) string {
+	t.Helper()
+
+	token, err := utils.GenerateRSAToken(testUser, "session-1", time.Now(), key, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func hsToken(t *testing.T, secret string) string {
+	t.Helper()
+
+	token, err := utils.GenerateToken(testUser, "session-1", time.Now(), secret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// otherToken signs claims of the user under the given "typ" and audience,
+// as the provider does for ID tokens and the access tokens of its clients.
+func otherToken(t *testing.T, key *rsa.PrivateKey, typ string, audience string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, &model.UserClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  audience,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Id:    testUserId,
+		Email: testUser.Email,
+		Role:  testUser.Role,
+	})
+	token.Header["typ"] = typ
+	token.Header["kid"] = utils.KeyID(&key.PublicKey)
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestJWKSVerifier(t *testing.T) {
+	srv := newAuthServer(t, false)
+	verifier := authclient.NewJWKSVerifier(srv.keySet(t))
+
+	p, err := verifier.Verify(context.Background(), rsaToken(t, srv.key, time.Hour))
+	if err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+	if p.UserID != testUserId || p.Email != testUser.Email || p.Role != testUser.Role || p.SessionID != "session-1" {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	// Signed by another key under the published kid.
+	forged, err := utils.GenerateRSAToken(testUser, "", time.Time{}, generateKey(t), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(forged, ".")
+	valid := strings.Split(rsaToken(t, srv.key, time.Hour), ".")
+	forged = valid[0] + "." + parts[1] + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{name: "expired", token: rsaToken(t, srv.key, -time.Minute), want: authclient.ErrInvalidToken},
+		{name: "bad signature", token: forged, want: authclient.ErrInvalidToken},
+		{name: "hs256", token: hsToken(t, testSecret), want: authclient.ErrKeyUnavailable},
+		{name: "id token", token: otherToken(t, srv.key, utils.IdTokenType, "app"), want: authclient.ErrInvalidToken},
+		{name: "client access token", token: otherToken(t, srv.key, utils.AccessTokenType, "app"), want: authclient.ErrInvalidToken},
+		{name: "no audience", token: otherToken(t, srv.key, utils.AccessTokenType, ""), want: authclient.ErrInvalidToken},
+		{name: "api key", token: srv.apiKey, want: authclient.ErrKeyUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(context.Background(), tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySetRefetchesUnknownKid(t *testing.T) {
+	srv := newAuthServer(t, false)
+	verifier := authclient.NewJWKSVerifier(srv.keySet(t, authclient.WithMinRefreshInterval(0)))
+
+	if _, err := verifier.Verify(context.Background(), rsaToken(t, srv.key, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	fetches := srv.jwksFetches.Load()
+
+	rotated := generateKey(t)
+	srv.rotate(rotated)
+
+	if _, err := verifier.Verify(context.Background(), rsaToken(t, rotated, time.Hour)); err != nil {
+		t.Fatalf("token of the rotated key: %v", err)
+	}
+	if got := srv.jwksFetches.Load(); got != fetches+1 {
+		t.Errorf("got %d fetches after rotation, want %d", got, fetches+1)
+	}
+
+	// The old key is no longer published.
+	if _, err := verifier.Verify(context.Background(), rsaToken(t, srv.key, time.Hour)); !errors.Is(err, authclient.ErrKeyUnavailable) {
+		t.Errorf("token of the retired key: got %v, want %v", err, authclient.ErrKeyUnavailable)
+	}
+}
+
+func TestKeySetLimitsRefetches(t *testing.T) {
+	srv := newAuthServer(t, false)
+	verifier := authclient.NewJWKSVerifier(srv.keySet(t, authclient.WithMinRefreshInterval(time.Hour)))
+	fetches := srv.jwksFetches.Load()
+
+	rotated := generateKey(t)
+	srv.rotate(rotated)
+
+	for range 3 {
+		if _, err := verifier.Verify(context.Background(), rsaToken(t, rotated, time.Hour)); !errors.Is(err, authclient.ErrKeyUnavailable) {
+			t.Fatalf("got %v, want %v", err, authclient.ErrKeyUnavailable)
+		}
+	}
+	if got := srv.jwksFetches.Load(); got != fetches {
+		t.Errorf("unknown kids refetched the JWKS %d times", got-fetches)
+	}
+}
+
+func TestKeySetBackgroundRefresh(t *testing.T) {
+	srv := newAuthServer(t, false)
+	keys := srv.keySet(t,
+		authclient.WithRefreshInterval(20*time.Millisecond),
+		authclient.WithMinRefreshInterval(time.Hour),
+	)
+
+	rotated := generateKey(t)
+	srv.rotate(rotated)
+	kid := utils.KeyID(&rotated.PublicKey)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := keys.Key(context.Background(), kid); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the rotated key was not picked up by the background refresh")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFallbackToRemoteVerifier(t *testing.T) {
+	srv := newAuthServer(t, false)
+	verifier := srv.verifier(t)
+
+	t.Run("hs256", func(t *testing.T) {
+		hsSrv := newAuthServer(t, true)
+		calls := hsSrv.remoteCalls.Load()
+
+		p, err := hsSrv.verifier(t).Verify(context.Background(), hsToken(t, testSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.UserID != testUserId || p.Role != testUser.Role || p.RoleLevel != moderatorLevel {
+			t.Errorf("unexpected principal %+v", p)
+		}
+		if len(p.Permissions) != 1 || p.Permissions[0].Permission != "users:read" {
+			t.Errorf("unexpected permissions %+v", p.Permissions)
+		}
+		if got := hsSrv.remoteCalls.Load(); got != calls+1 {
+			t.Errorf("got %d remote calls, want 1", got-calls)
+		}
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		p, err := verifier.Verify(context.Background(), srv.apiKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.UserID != testUserId || len(p.Scopes) != 1 || p.Scopes[0] != "users:read" {
+			t.Errorf("unexpected principal %+v", p)
+		}
+	})
+
+	t.Run("rejected remotely", func(t *testing.T) {
+		if _, err := verifier.Verify(context.Background(), utils.APIKeyPrefix+"unknown"); !errors.Is(err, authclient.ErrInvalidToken) {
+			t.Errorf("got %v, want %v", err, authclient.ErrInvalidToken)
+		}
+	})
+
+	t.Run("tokens of clients rejected remotely", func(t *testing.T) {
+		remote := authclient.NewRemoteVerifier(srv.conn)
+		for _, token := range []string{
+			otherToken(t, srv.key, utils.IdTokenType, "app"),
+			otherToken(t, srv.key, utils.AccessTokenType, "app"),
+		} {
+			if _, err := remote.Verify(context.Background(), token); !errors.Is(err, authclient.ErrInvalidToken) {
+				t.Errorf("got %v, want %v", err, authclient.ErrInvalidToken)
+			}
+		}
+	})
+
+	t.Run("offline tokens stay offline", func(t *testing.T) {
+		calls := srv.remoteCalls.Load()
+
+		if _, err := verifier.Verify(context.Background(), rsaToken(t, srv.key, time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Verify(context.Background(), rsaToken(t, srv.key, -time.Minute)); !errors.Is(err, authclient.ErrInvalidToken) {
+			t.Errorf("expired token: got %v, want %v", err, authclient.ErrInvalidToken)
+		}
+		if got := srv.remoteCalls.Load(); got != calls {
+			t.Errorf("got %d remote calls, want none", got-calls)
+		}
+	})
+
+	t.Run("required level", func(t *testing.T) {
+		remote := authclient.NewRemoteVerifier(srv.conn).WithRequiredLevel(moderatorLevel + 1)
+		if _, err := remote.Verify(context.Background(), srv.apiKey); !errors.Is(err, authclient.ErrPermissionDenied) {
+			t.Errorf("got %v, want %v", err, authclient.ErrPermissionDenied)
+		}
+	})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	srv := newAuthServer(t, false)
+	interceptor := authclient.UnaryServerInterceptor(srv.verifier(t), authclient.SkipMethods("/test.Service/Public"))
+
+	call := func(method string, authorization string) (*authclient.Principal, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+
+		var principal *authclient.Principal
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ any) (any, error) {
+			principal, _ = authclient.FromContext(ctx)
+			return nil, nil
+		})
+
+		return principal, err
+	}
+
+	p, err := call("/test.Service/Private", "Bearer "+rsaToken(t, srv.key, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || p.UserID != testUserId {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	if _, err = call("/test.Service/Private", ""); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no token: got %v, want Unauthenticated", err)
+	}
+	if _, err = call("/test.Service/Private", "Bearer "+rsaToken(t, srv.key, -time.Minute)); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expired token: got %v, want Unauthenticated", err)
+	}
+
+	p, err = call("/test.Service/Public", "")
+	if err != nil || p != nil {
+		t.Errorf("skipped method: got %+v, %v", p, err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	srv := newAuthServer(t, false)
+	interceptor := authclient.StreamServerInterceptor(srv.verifier(t))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+srv.apiKey))
+	err := interceptor(nil, &serverStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}, func(_ any, stream grpc.ServerStream) error {
+		if id, ok := authclient.UserIDFromContext(stream.Context()); !ok || id != testUserId {
+			t.Errorf("got user %d, %v in the stream context", id, ok)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	srv := newAuthServer(t, false)
+	handler := authclient.HTTPMiddleware(srv.verifier(t), authclient.SkipMethods("/healthz"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if role, ok := authclient.RoleFromContext(r.Context()); ok {
+				_, _ = io.WriteString(w, role)
+			}
+		}),
+	)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantBody      string
+		wantChallenge string
+	}{
+		{name: "offline", path: "/", authorization: "Bearer " + rsaToken(t, srv.key, time.Hour), wantStatus: http.StatusOK, wantBody: testUser.Role},
+		{name: "remote", path: "/", authorization: "Bearer " + srv.apiKey, wantStatus: http.StatusOK, wantBody: testUser.Role},
+		{name: "no token", path: "/", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "invalid token", path: "/", authorization: "Bearer " + rsaToken(t, srv.key, -time.Minute), wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token"`},
+		{name: "skipped", path: "/healthz", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("got challenge %q, want %q", got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+const (
+	moderatorRoleId = 2
+	moderatorLevel  = 5
+)
+
+var moderatorRole = &roleRepoModel.Role{ID: moderatorRoleId, Name: "moderator", Level: moderatorLevel}
+
+type userRepo struct {
+	repository.UserRepository
+}
+
+func (r *userRepo) GetById(_ context.Context, id int) (*userRepoModel.User, error) {
+	if id != testUserId {
+		return nil, repository.ErrNotFound
+	}
+
+	return &userRepoModel.User{
+		Id: testUserId,
+		UserInfo: userRepoModel.UserInfo{
+			Email:  testUser.Email,
+			RoleId: moderatorRoleId,
+		},
+	}, nil
+}
+
+type roleRepo struct {
+	repository.RoleRepository
+}
+
+func (r *roleRepo) GetByName(_ context.Context, name string) (*roleRepoModel.Role, error) {
+	if name != moderatorRole.Name {
+		return nil, repository.ErrNotFound
+	}
+
+	return moderatorRole, nil
+}
+
+func (r *roleRepo) GetById(_ context.Context, id int) (*roleRepoModel.Role, error) {
+	if id != moderatorRoleId {
+		return nil, repository.ErrNotFound
+	}
+
+	return moderatorRole, nil
+}
+
+func (r *roleRepo) GetPermissions(_ context.Context, roleId int) ([]*roleRepoModel.Permission, error) {
+	if roleId != moderatorRoleId {
+		return nil, nil
+	}
+
+	return []*roleRepoModel.Permission{
+		{RoleId: moderatorRoleId, Permission: "users:read", Resource: "*"},
+	}, nil
+}
+
+type apiKeyRepo struct {
+	repository.APIKeyRepository
+	hash string
+}
+
+func (r *apiKeyRepo) GetByHash(_ context.Context, keyHash string) (*apiKeyRepoModel.APIKey, error) {
+	if keyHash != r.hash {
+		return nil, repository.ErrNotFound
+	}
+
+	return &apiKeyRepoModel.APIKey{
+		Id:      1,
+		UserId:  testUserId,
+		KeyHash: r.hash,
+		Scopes:  []string{"users:read"},
+	}, nil
+}
+
+func (r *apiKeyRepo) Touch(context.Context, int) error {
+	return nil
+}
+
+type suspensionRepo struct {
+	repository.SuspensionRepository
+}
+
+func (r *suspensionRepo) GetActive(context.Context, int) (*suspensionRepoModel.Suspension, error) {
+	return nil, repository.ErrNotFound
+}
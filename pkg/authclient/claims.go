@@ -0,0 +1,40 @@
+package authclient
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"strings"
+	"time"
+)
+
+type userClaims struct {
+	jwt.StandardClaims
+	Id    int    `json:"id"`
+	Email string `json:"Email"`
+	Role  string `json:"role"`
+
+	SessionId string `json:"sid,omitempty"`
+
+	ClientId string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	AuthTime int64 `json:"auth_time,omitempty"`
+}
+
+func (c *userClaims) principal() *Principal {
+	p := &Principal{
+		UserID:    c.Id,
+		Email:     c.Email,
+		Role:      c.Role,
+		SessionID: c.SessionId,
+		ClientID:  c.ClientId,
+		Scopes:    strings.Fields(c.Scope),
+	}
+	if c.ExpiresAt != 0 {
+		p.ExpiresAt = time.Unix(c.ExpiresAt, 0)
+	}
+	if c.AuthTime != 0 {
+		p.AuthTime = time.Unix(c.AuthTime, 0)
+	}
+
+	return p
+}
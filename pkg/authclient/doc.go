@@ -0,0 +1,24 @@
+// Package authclient lets other services authenticate requests against the
+// auth service without a round trip per call.
+//
+// Access tokens signed with the service's RSA key are verified offline with a
+// cached JWKS (see NewKeySet). Tokens the JWKS cannot vouch for, such as
+// HS256 tokens or ones signed by a key not yet published, are checked remotely
+// via AccessV1.CheckV2:
+//
+//	keys := authclient.NewKeySet(ctx, "http://auth:8080/.well-known/jwks.json")
+//	defer keys.Close()
+//
+//	verifier := authclient.WithFallback(
+//		authclient.NewJWKSVerifier(keys),
+//		authclient.NewRemoteVerifier(authConn),
+//	)
+//
+//	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(authclient.UnaryServerInterceptor(verifier)))
+//
+// Handlers read the caller with FromContext.
+//
+// On the calling side, TokenSource turns a refresh token into access tokens on
+// demand and can be plugged into grpc.WithPerRPCCredentials or an
+// http.Client's Transport via RoundTripper.
+package authclient
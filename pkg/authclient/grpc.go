@@ -0,0 +1,131 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+type options struct {
+	skip map[string]struct{}
+}
+
+type Option func(*options)
+
+// SkipMethods leaves the given full gRPC method names or HTTP paths unauthenticated.
+func SkipMethods(methods ...string) Option {
+	return func(o *options) {
+		for _, m := range methods {
+			o.skip[m] = struct{}{}
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		skip: map[string]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+func (o *options) skipped(method string) bool {
+	_, ok := o.skip[method]
+	return ok
+}
+
+// UnaryServerInterceptor authenticates every unary call and stores the principal in the context.
+func UnaryServerInterceptor(v Verifier, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if o.skipped(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticateGRPC(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates every stream and stores the principal in its context.
+func StreamServerInterceptor(v Verifier, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.skipped(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authenticateGRPC(ss.Context(), v)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticateGRPC(ctx context.Context, v Verifier) (context.Context, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	accessToken, err := bearerToken(header)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	p, err := v.Verify(ctx, accessToken)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return NewContext(ctx, p), nil
+}
+
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrKeyUnavailable):
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func bearerToken(header string) (string, error) {
+	if header == "" {
+		return "", ErrNoToken
+	}
+
+	if len(header) < len(authPrefix) || !strings.EqualFold(header[:len(authPrefix)], authPrefix) {
+		return "", ErrInvalidToken
+	}
+
+	return strings.TrimSpace(header[len(authPrefix):]), nil
+}
@@ -0,0 +1,44 @@
+package authclient
+
+import (
+	"errors"
+	"net/http"
+)
+
+// HTTPMiddleware authenticates requests by their Authorization header and
+// stores the principal in the request context.
+func HTTPMiddleware(v Verifier, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.skipped(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			accessToken, err := bearerToken(r.Header.Get("Authorization"))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			p, err := v.Verify(r.Context(), accessToken)
+			if err != nil {
+				switch {
+				case errors.Is(err, ErrPermissionDenied):
+					http.Error(w, err.Error(), http.StatusForbidden)
+				case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrKeyUnavailable):
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+				default:
+					http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package authclient
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshInterval    = 5 * time.Minute
+	defaultMinRefreshInterval = 10 * time.Second
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// KeySet caches the auth service's JWKS and refreshes it in the background.
+type KeySet struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	lastRefresh time.Time
+
+	refreshMu sync.Mutex
+	inFlight  chan struct{}
+	lastErr   error
+
+	stop chan struct{}
+	once sync.Once
+}
+
+type KeySetOption func(*KeySet)
+
+// WithHTTPClient sets the client used to fetch the JWKS.
+func WithHTTPClient(client *http.Client) KeySetOption {
+	return func(k *KeySet) {
+		k.client = client
+	}
+}
+
+// WithRefreshInterval sets how often the JWKS is refetched in the background.
+func WithRefreshInterval(d time.Duration) KeySetOption {
+	return func(k *KeySet) {
+		k.refreshInterval = d
+	}
+}
+
+// WithMinRefreshInterval limits how often an unknown kid may trigger a refetch.
+func WithMinRefreshInterval(d time.Duration) KeySetOption {
+	return func(k *KeySet) {
+		k.minRefreshInterval = d
+	}
+}
+
+// NewKeySet starts fetching the JWKS published at url. A failed initial fetch
+// is not fatal: lookups retry and the verifier may fall back to introspection.
+func NewKeySet(ctx context.Context, url string, opts ...KeySetOption) *KeySet {
+	k := &KeySet{
+		url:                url,
+		client:             &http.Client{Timeout: 10 * time.Second},
+		refreshInterval:    defaultRefreshInterval,
+		minRefreshInterval: defaultMinRefreshInterval,
+		keys:               map[string]*rsa.PublicKey{},
+		stop:               make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	_ = k.Refresh(ctx)
+	go k.loop()
+
+	return k
+}
+
+// Key returns the public key with the given kid, refetching the JWKS once if
+// the kid is unknown and the last fetch is old enough.
+func (k *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.lastRefresh) >= k.minRefreshInterval
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := k.Refresh(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKeyUnavailable, err)
+		}
+
+		k.mu.RLock()
+		key, ok = k.keys[kid]
+		k.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: unknown kid %q", ErrKeyUnavailable, kid)
+}
+
+// Refresh refetches the JWKS. Concurrent callers share a single request.
+func (k *KeySet) Refresh(ctx context.Context) error {
+	k.refreshMu.Lock()
+	if wait := k.inFlight; wait != nil {
+		k.refreshMu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		k.refreshMu.Lock()
+		err := k.lastErr
+		k.refreshMu.Unlock()
+		return err
+	}
+	done := make(chan struct{})
+	k.inFlight = done
+	k.refreshMu.Unlock()
+
+	keys, err := k.fetch(ctx)
+	if err == nil {
+		k.mu.Lock()
+		k.keys = keys
+		k.lastRefresh = time.Now()
+		k.mu.Unlock()
+	}
+
+	k.refreshMu.Lock()
+	k.lastErr = err
+	k.inFlight = nil
+	k.refreshMu.Unlock()
+	close(done)
+
+	return err
+}
+
+// Close stops the background refresh.
+func (k *KeySet) Close() {
+	k.once.Do(func() {
+		close(k.stop)
+	})
+}
+
+func (k *KeySet) loop() {
+	ticker := time.NewTicker(k.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), k.refreshInterval)
+			_ = k.Refresh(ctx)
+			cancel()
+		}
+	}
+}
+
+func (k *KeySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwks request: %w", err)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		pub, err := parseRSAKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %q: %w", key.Kid, err)
+		}
+		keys[key.Kid] = pub
+	}
+
+	return keys, nil
+}
+
+func parseRSAKey(key jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
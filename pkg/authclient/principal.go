@@ -0,0 +1,66 @@
+package authclient
+
+import (
+	"context"
+	"time"
+)
+
+// Principal is the authenticated caller as seen by the auth service.
+// RoleLevel and Permissions are only known when the token was checked
+// remotely; offline verification leaves them empty.
+//
+// Service accounts authenticate with the client credentials grant; their
+// principals have a ClientID and Scopes instead of a UserID and Email.
+type Principal struct {
+	UserID      int
+	Email       string
+	Role        string
+	RoleLevel   int
+	Permissions []Permission
+	SessionID   string
+	ClientID    string
+	Scopes      []string
+	// APIKeyID is set when the caller authenticated with an API key, which
+	// only RemoteVerifier can tell.
+	APIKeyID  int
+	ExpiresAt time.Time
+	AuthTime  time.Time
+}
+
+type Permission struct {
+	Permission string
+	Resource   string
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored by the middleware, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserIDFromContext returns the id of the authenticated user, if any.
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+
+	return p.UserID, true
+}
+
+// RoleFromContext returns the role of the authenticated user, if any.
+func RoleFromContext(ctx context.Context) (string, bool) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	return p.Role, true
+}
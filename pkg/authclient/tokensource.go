@@ -0,0 +1,252 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	authDesc "github.com/nogavadu/auth-service/pkg/auth_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshBefore = 30 * time.Second
+	defaultRefreshJitter = 10 * time.Second
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// TokenSource exchanges a refresh token for access tokens via
+// AuthV1.GetAccessToken and caches them until shortly before they expire.
+// It is safe for concurrent use; concurrent refreshes are collapsed into one call.
+type TokenSource struct {
+	client       authDesc.AuthV1Client
+	refreshToken string
+
+	refreshBefore time.Duration
+	refreshJitter time.Duration
+	insecure      bool
+	now           func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+	inFlight  chan struct{}
+	lastErr   error
+}
+
+type TokenSourceOption func(*TokenSource)
+
+// WithRefreshBefore sets how long before expiry the access token is renewed.
+func WithRefreshBefore(d time.Duration) TokenSourceOption {
+	return func(s *TokenSource) {
+		s.refreshBefore = d
+	}
+}
+
+// WithRefreshJitter adds up to d of random lead time to every renewal so
+// that many clients started together don't refresh in lockstep.
+func WithRefreshJitter(d time.Duration) TokenSourceOption {
+	return func(s *TokenSource) {
+		s.refreshJitter = d
+	}
+}
+
+// WithInsecureTransport allows the per-RPC credentials to be sent over
+// connections without transport security, e.g. inside a trusted network.
+func WithInsecureTransport() TokenSourceOption {
+	return func(s *TokenSource) {
+		s.insecure = true
+	}
+}
+
+func NewTokenSource(cc grpc.ClientConnInterface, refreshToken string, opts ...TokenSourceOption) *TokenSource {
+	s := &TokenSource{
+		client:        authDesc.NewAuthV1Client(cc),
+		refreshToken:  refreshToken,
+		refreshBefore: defaultRefreshBefore,
+		refreshJitter: defaultRefreshJitter,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Token returns a valid access token, obtaining a new one if the cached
+// token is missing or about to expire.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	for {
+		s.mu.Lock()
+		if s.token != "" && s.now().Before(s.refreshAt) {
+			token := s.token
+			s.mu.Unlock()
+			return token, nil
+		}
+
+		if wait := s.inFlight; wait != nil {
+			s.mu.Unlock()
+
+			select {
+			case <-wait:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+
+			s.mu.Lock()
+			token, expiresAt, err := s.token, s.expiresAt, s.lastErr
+			s.mu.Unlock()
+			if err != nil {
+				return "", err
+			}
+			if token != "" && s.now().Before(expiresAt) {
+				return token, nil
+			}
+			continue
+		}
+
+		done := make(chan struct{})
+		s.inFlight = done
+		s.mu.Unlock()
+
+		token, err := s.refresh(ctx)
+
+		s.mu.Lock()
+		s.inFlight = nil
+		s.lastErr = err
+		s.mu.Unlock()
+		close(done)
+
+		return token, err
+	}
+}
+
+// Invalidate drops the cached access token, e.g. after the server rejected it.
+func (s *TokenSource) Invalidate() {
+	s.mu.Lock()
+	s.token = ""
+	s.mu.Unlock()
+}
+
+func (s *TokenSource) refresh(ctx context.Context) (string, error) {
+	resp, err := s.client.GetAccessToken(ctx, &authDesc.GetAccessTokenRequest{
+		RefreshToken: s.refreshToken,
+	})
+	if err != nil {
+		if status.Code(err) == codes.Aborted || status.Code(err) == codes.InvalidArgument {
+			return "", fmt.Errorf("%w: %s", ErrInvalidRefreshToken, status.Convert(err).Message())
+		}
+
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var claims userClaims
+	if _, _, err = new(jwt.Parser).ParseUnverified(resp.GetAccessToken(), &claims); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.refreshBefore)
+	if claims.ExpiresAt != 0 {
+		expiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+
+	lead := s.refreshBefore
+	if s.refreshJitter > 0 {
+		lead += rand.N(s.refreshJitter)
+	}
+	refreshAt := expiresAt.Add(-lead)
+	if lifetime := expiresAt.Sub(now); lead >= lifetime {
+		refreshAt = now.Add(lifetime / 2)
+	}
+
+	s.mu.Lock()
+	s.token = resp.GetAccessToken()
+	s.expiresAt = expiresAt
+	s.refreshAt = refreshAt
+	s.mu.Unlock()
+
+	return resp.GetAccessToken(), nil
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials so the source
+// can be passed to grpc.WithPerRPCCredentials.
+func (s *TokenSource) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := s.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"authorization": authPrefix + token}, nil
+}
+
+func (s *TokenSource) RequireTransportSecurity() bool {
+	return !s.insecure
+}
+
+var _ credentials.PerRPCCredentials = (*TokenSource)(nil)
+
+// RoundTripper wraps base so every request carries a bearer access token.
+// A 401 response invalidates the token and, if the request can be replayed,
+// the request is retried once with a fresh one.
+func (s *TokenSource) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{
+		source: s,
+		base:   base,
+	}
+}
+
+type transport struct {
+	source *TokenSource
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.roundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	t.source.Invalidate()
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	_ = resp.Body.Close()
+
+	return t.roundTrip(retry)
+}
+
+func (t *transport) roundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", authPrefix+token)
+
+	return t.base.RoundTrip(r)
+}
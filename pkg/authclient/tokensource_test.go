@@ -0,0 +1,323 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	authDesc "github.com/nogavadu/auth-service/pkg/auth_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for TokenSource.now.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// tokenEndpoint stands in for AuthV1.GetAccessToken. It issues tokens
+// numbered from 1 that live for lifetime, and holds each call until gate is
+// closed when it is set.
+type tokenEndpoint struct {
+	authDesc.AuthV1Client
+	clock    *clock
+	lifetime time.Duration
+	gate     chan struct{}
+	err      error
+	calls    atomic.Int32
+}
+
+func (e *tokenEndpoint) GetAccessToken(ctx context.Context, _ *authDesc.GetAccessTokenRequest, _ ...grpc.CallOption) (*authDesc.GetAccessTokenResponse, error) {
+	n := e.calls.Add(1)
+	if e.gate != nil {
+		select {
+		case <-e.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Id:        strconv.Itoa(int(n)),
+		ExpiresAt: e.clock.Now().Add(e.lifetime).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &authDesc.GetAccessTokenResponse{AccessToken: token}, nil
+}
+
+func newTestTokenSource(endpoint *tokenEndpoint, opts ...TokenSourceOption) *TokenSource {
+	s := NewTokenSource(nil, "refresh-token", opts...)
+	s.client = endpoint
+	s.now = endpoint.clock.Now
+
+	return s
+}
+
+// tokenNumber tells which call of the endpoint issued the token.
+func tokenNumber(t *testing.T, token string) int {
+	t.Helper()
+
+	var claims jwt.StandardClaims
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, &claims); err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(claims.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+var epoch = time.Unix(1_700_000_000, 0)
+
+func TestTokenSourceCollapsesRefreshes(t *testing.T) {
+	endpoint := &tokenEndpoint{clock: &clock{now: epoch}, lifetime: time.Hour, gate: make(chan struct{})}
+	s := newTestTokenSource(endpoint)
+
+	const callers = 10
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = s.Token(context.Background())
+		}()
+	}
+
+	// Let every caller queue up behind the first refresh before it ends.
+	for endpoint.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(endpoint.gate)
+	wg.Wait()
+
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if tokens[i] != tokens[0] {
+			t.Errorf("caller %d got another token", i)
+		}
+	}
+	if calls := endpoint.calls.Load(); calls != 1 {
+		t.Errorf("got %d refreshes, want 1", calls)
+	}
+
+	if _, err := s.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls := endpoint.calls.Load(); calls != 1 {
+		t.Errorf("cached token was refreshed: got %d refreshes, want 1", calls)
+	}
+}
+
+func TestTokenSourceRefreshesEarly(t *testing.T) {
+	const (
+		before = 30 * time.Second
+		jitter = 10 * time.Second
+	)
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		// cachedUntil is the last time the first token is still served,
+		// refreshedBy the first one it must have been replaced at.
+		cachedUntil time.Duration
+		refreshedBy time.Duration
+	}{
+		{name: "long-lived token", lifetime: 5 * time.Minute, cachedUntil: 5*time.Minute - before - jitter - time.Second, refreshedBy: 5*time.Minute - before},
+		{name: "token shorter than the lead", lifetime: 20 * time.Second, cachedUntil: 9 * time.Second, refreshedBy: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clock{now: epoch}
+			endpoint := &tokenEndpoint{clock: c, lifetime: tt.lifetime}
+			s := newTestTokenSource(endpoint, WithRefreshBefore(before), WithRefreshJitter(jitter))
+
+			first, err := s.Token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.Set(epoch.Add(tt.cachedUntil))
+			token, err := s.Token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != first {
+				t.Fatalf("token was refreshed %v after it was issued", tt.cachedUntil)
+			}
+
+			c.Set(epoch.Add(tt.refreshedBy))
+			token, err = s.Token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tokenNumber(t, token) != 2 {
+				t.Fatalf("token was not refreshed %v after it was issued", tt.refreshedBy)
+			}
+		})
+	}
+}
+
+func TestTokenSourceJittersRefreshes(t *testing.T) {
+	const (
+		before = 30 * time.Second
+		jitter = 10 * time.Second
+	)
+	expiresAt := epoch.Add(time.Hour)
+
+	refreshAts := make(map[time.Time]bool)
+	for range 20 {
+		s := newTestTokenSource(&tokenEndpoint{clock: &clock{now: epoch}, lifetime: time.Hour}, WithRefreshBefore(before), WithRefreshJitter(jitter))
+		if _, err := s.Token(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if s.refreshAt.Before(expiresAt.Add(-before-jitter)) || s.refreshAt.After(expiresAt.Add(-before)) {
+			t.Fatalf("refresh at %v is outside [%v, %v]", s.refreshAt, expiresAt.Add(-before-jitter), expiresAt.Add(-before))
+		}
+		refreshAts[s.refreshAt] = true
+	}
+
+	if len(refreshAts) == 1 {
+		t.Error("all sources refresh at the same time")
+	}
+}
+
+func TestTokenSourceInvalidRefreshToken(t *testing.T) {
+	endpoint := &tokenEndpoint{clock: &clock{now: epoch}, err: status.Error(codes.InvalidArgument, "invalid refresh token")}
+	s := newTestTokenSource(endpoint)
+
+	if _, err := s.Token(context.Background()); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRoundTripperRetriesUnauthorized(t *testing.T) {
+	tests := []struct {
+		name string
+		// rejected tokens get a 401 from the server.
+		rejected   map[int]bool
+		body       func() io.Reader
+		wantStatus int
+		wantTokens []int
+	}{
+		{
+			name:       "valid token",
+			wantStatus: http.StatusOK,
+			wantTokens: []int{1},
+		},
+		{
+			name:       "revoked token",
+			rejected:   map[int]bool{1: true},
+			body:       func() io.Reader { return strings.NewReader("payload") },
+			wantStatus: http.StatusOK,
+			wantTokens: []int{1, 2},
+		},
+		{
+			name:       "rejected again",
+			rejected:   map[int]bool{1: true, 2: true},
+			wantStatus: http.StatusUnauthorized,
+			wantTokens: []int{1, 2},
+		},
+		{
+			name:     "body that cannot be replayed",
+			rejected: map[int]bool{1: true},
+			// Wrapping the reader hides it from http.NewRequest, so the
+			// request gets no GetBody.
+			body:       func() io.Reader { return struct{ io.Reader }{strings.NewReader("payload")} },
+			wantStatus: http.StatusUnauthorized,
+			wantTokens: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				tokens []int
+				bodies []string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := tokenNumber(t, strings.TrimPrefix(r.Header.Get("Authorization"), authPrefix))
+				body, _ := io.ReadAll(r.Body)
+
+				mu.Lock()
+				tokens = append(tokens, n)
+				bodies = append(bodies, string(body))
+				mu.Unlock()
+
+				if tt.rejected[n] {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			defer server.Close()
+
+			s := newTestTokenSource(&tokenEndpoint{clock: &clock{now: epoch}, lifetime: time.Hour})
+			client := &http.Client{Transport: s.RoundTripper(nil)}
+
+			var body io.Reader
+			if tt.body != nil {
+				body = tt.body()
+			}
+			req, err := http.NewRequest(http.MethodPost, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if !slices.Equal(tokens, tt.wantTokens) {
+				t.Errorf("server saw tokens %v, want %v", tokens, tt.wantTokens)
+			}
+			if tt.body != nil {
+				for i, got := range bodies {
+					if got != "payload" {
+						t.Errorf("request %d had body %q, want %q", i, got, "payload")
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	accessDesc "github.com/nogavadu/auth-service/pkg/access_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const (
+	authPrefix = "Bearer "
+
+	// APIKeyPrefix marks opaque API keys. They cannot be verified offline.
+	APIKeyPrefix = "ngv_"
+
+	// Audience is the "aud" of the access tokens the auth service issues to
+	// its users and service accounts. ID tokens and the access tokens of
+	// OpenID clients, signed with the same keys, name a client instead.
+	Audience = "auth-service"
+
+	accessTokenType = "at+jwt"
+)
+
+var (
+	ErrNoToken          = errors.New("authorization token is not provided")
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrKeyUnavailable   = errors.New("signing key is unavailable")
+	ErrPermissionDenied = errors.New("access denied")
+)
+
+// Verifier authenticates an access token and returns its principal.
+type Verifier interface {
+	Verify(ctx context.Context, accessToken string) (*Principal, error)
+}
+
+// JWKSVerifier verifies RS256 access tokens offline against a KeySet.
+type JWKSVerifier struct {
+	keys *KeySet
+}
+
+func NewJWKSVerifier(keys *KeySet) *JWKSVerifier {
+	return &JWKSVerifier{
+		keys: keys,
+	}
+}
+
+func (v *JWKSVerifier) Verify(ctx context.Context, accessToken string) (*Principal, error) {
+	if strings.HasPrefix(accessToken, APIKeyPrefix) {
+		return nil, fmt.Errorf("%w: api keys are verified remotely", ErrKeyUnavailable)
+	}
+
+	var keyErr error
+	token, err := jwt.ParseWithClaims(
+		accessToken,
+		&userClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				keyErr = fmt.Errorf("%w: unsupported signing method %s", ErrKeyUnavailable, token.Method.Alg())
+				return nil, keyErr
+			}
+
+			kid, _ := token.Header["kid"].(string)
+			key, err := v.keys.Key(ctx, kid)
+			if err != nil {
+				keyErr = err
+				return nil, err
+			}
+
+			return key, nil
+		},
+	)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(*userClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if token.Header["typ"] != accessTokenType || !claims.VerifyAudience(Audience, true) {
+		return nil, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+
+	return claims.principal(), nil
+}
+
+// RemoteVerifier asks AccessV1.CheckV2 to validate the token and describe its principal.
+type RemoteVerifier struct {
+	client      accessDesc.AccessV1Client
+	requiredLvl uint32
+}
+
+func NewRemoteVerifier(cc grpc.ClientConnInterface) *RemoteVerifier {
+	return &RemoteVerifier{
+		client: accessDesc.NewAccessV1Client(cc),
+	}
+}
+
+// WithRequiredLevel returns a copy of the verifier that also demands the given role level.
+func (v *RemoteVerifier) WithRequiredLevel(lvl uint32) *RemoteVerifier {
+	return &RemoteVerifier{
+		client:      v.client,
+		requiredLvl: lvl,
+	}
+}
+
+func (v *RemoteVerifier) Verify(ctx context.Context, accessToken string) (*Principal, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authPrefix+accessToken)
+
+	resp, err := v.client.CheckV2(ctx, &accessDesc.CheckRequest{RequiredLvl: v.requiredLvl})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unauthenticated:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidToken, status.Convert(err).Message())
+		case codes.PermissionDenied:
+			return nil, fmt.Errorf("%w: %s", ErrPermissionDenied, status.Convert(err).Message())
+		default:
+			return nil, fmt.Errorf("failed to check access token: %w", err)
+		}
+	}
+
+	principal := resp.GetPrincipal()
+	p := &Principal{
+		UserID:      int(principal.GetUserId()),
+		Email:       principal.GetEmail(),
+		RoleLevel:   int(principal.GetRoleLevel()),
+		Permissions: make([]Permission, 0, len(principal.GetPermissions())),
+		SessionID:   principal.GetSessionId(),
+		ClientID:    principal.GetClientId(),
+		Scopes:      principal.GetScopes(),
+		APIKeyID:    int(principal.GetApiKeyId()),
+	}
+	if roles := principal.GetRoles(); len(roles) > 0 {
+		p.Role = roles[0]
+	}
+	for _, permission := range principal.GetPermissions() {
+		p.Permissions = append(p.Permissions, Permission{
+			Permission: permission.GetPermission(),
+			Resource:   permission.GetResource(),
+		})
+	}
+	if principal.GetExpiresAt() != nil {
+		p.ExpiresAt = principal.GetExpiresAt().AsTime()
+	}
+	if principal.GetAuthTime() != nil {
+		p.AuthTime = principal.GetAuthTime().AsTime()
+	}
+
+	return p, nil
+}
+
+type fallbackVerifier struct {
+	primary  Verifier
+	fallback Verifier
+}
+
+// WithFallback verifies with primary and consults fallback only when primary
+// cannot obtain a signing key, which includes API keys. Tokens that are
+// expired or badly signed are rejected without a remote call.
+func WithFallback(primary, fallback Verifier) Verifier {
+	return &fallbackVerifier{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (v *fallbackVerifier) Verify(ctx context.Context, accessToken string) (*Principal, error) {
+	p, err := v.primary.Verify(ctx, accessToken)
+	if err != nil && errors.Is(err, ErrKeyUnavailable) {
+		return v.fallback.Verify(ctx, accessToken)
+	}
+
+	return p, err
+}